@@ -11,6 +11,7 @@ require (
 	github.com/go-openapi/swag v0.19.8 // indirect
 	github.com/google/uuid v1.1.1
 	github.com/jinzhu/gorm v1.9.12
+	github.com/lib/pq v1.1.1
 	github.com/mailru/easyjson v0.7.1 // indirect
 	github.com/spf13/viper v1.6.2
 	github.com/stretchr/testify v1.4.0
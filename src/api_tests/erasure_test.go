@@ -0,0 +1,88 @@
+package api_tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"little-diary-measurement-service/src/common"
+	"little-diary-measurement-service/src/config"
+	"little-diary-measurement-service/src/daos"
+	"little-diary-measurement-service/src/dto"
+	"little-diary-measurement-service/src/models"
+	"little-diary-measurement-service/src/router"
+	"little-diary-measurement-service/src/test_data"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestEraseTargetMeasurements(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tx := test_data.OnBeforeDBTest()
+	defer test_data.OnAfterDBTest(tx)
+
+	r := router.GetMainEngine(&common.ServiceLocator{
+		PublicKeyGetter:     &config.Config,
+		ErasureApiKeyGetter: &config.Config,
+	})
+	t1 := models.TargetUUID(fmt.Sprintf("%s", uuid.New()))
+	test_data.MeasurementStoredFactory.MustCreateWithOption(map[string]interface{}{"TargetUuid": t1})
+	test_data.MeasurementStoredFactory.MustCreateWithOption(map[string]interface{}{"TargetUuid": t1})
+	do := func(apiKey string, target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("DELETE", fmt.Sprintf("/api/v1/targets/%s/measurements", target), nil)
+		req.Header.Set("X-Api-Key", apiKey)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, do("wrong", string(t1)).Code)
+	assert.Equal(t, http.StatusBadRequest, do(config.Config.GetErasureApiKey(), "not-a-uuid").Code)
+
+	w := do(config.Config.GetErasureApiKey(), string(t1))
+	var receipt dto.ErasureReceiptResponse
+	if assert.Equal(t, http.StatusOK, w.Code) && assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &receipt)) {
+		assert.Equal(t, string(t1), receipt.TargetUuid)
+		assert.Equal(t, "api", receipt.RequestedBy)
+		assert.Equal(t, int64(2), receipt.MeasurementsDeleted)
+	}
+	measurements, err := daos.NewMeasurementDAO().GetMeasurementsByTargetUuid(t1)
+	if assert.Nil(t, err) {
+		assert.Empty(t, measurements)
+	}
+}
+
+func TestConsumeBabyDeletedEvent(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tx := test_data.OnBeforeDBTest()
+	defer test_data.OnAfterDBTest(tx)
+
+	r := router.GetMainEngine(&common.ServiceLocator{
+		PublicKeyGetter:          &config.Config,
+		FamilyEventsApiKeyGetter: &config.Config,
+	})
+	babyUuid := models.TargetUUID(fmt.Sprintf("%s", uuid.New()))
+	eventUuid := fmt.Sprintf("%s", uuid.New())
+	test_data.MeasurementStoredFactory.MustCreateWithOption(map[string]interface{}{"TargetUuid": babyUuid})
+	body := fmt.Sprintf(`{"type":"BabyDeleted","event_uuid":"%s","baby_uuid":"%s"}`, eventUuid, babyUuid)
+
+	// a redelivered event is acknowledged without erasing again
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest("POST", "/family/v1/events", strings.NewReader(body))
+		req.Header.Set("X-Api-Key", config.Config.GetFamilyEventsApiKey())
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusNoContent, w.Code)
+	}
+
+	receipt, err := daos.NewErasureDAO().GetReceiptByEventUuid(eventUuid)
+	if assert.Nil(t, err) {
+		assert.Equal(t, babyUuid, receipt.TargetUuid)
+		assert.Equal(t, int64(1), receipt.MeasurementsDeleted)
+	}
+}
@@ -0,0 +1,73 @@
+package api_tests
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"little-diary-measurement-service/src/common"
+	"little-diary-measurement-service/src/config"
+	"little-diary-measurement-service/src/daos"
+	"little-diary-measurement-service/src/models"
+	"little-diary-measurement-service/src/router"
+	"little-diary-measurement-service/src/test_data"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestConsumeFamilyEvent(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tx := test_data.OnBeforeDBTest()
+	defer test_data.OnAfterDBTest(tx)
+
+	r := router.GetMainEngine(&common.ServiceLocator{
+		PublicKeyGetter:          &config.Config,
+		FamilyEventsApiKeyGetter: &config.Config,
+	})
+	userUuid := fmt.Sprintf("%s", uuid.New())
+	babyUuid := fmt.Sprintf("%s", uuid.New())
+
+	tests := []struct {
+		name       string
+		apiKey     string
+		body       string
+		wantStatus int
+	}{
+		{
+			name:       "granted",
+			apiKey:     config.Config.GetFamilyEventsApiKey(),
+			body:       fmt.Sprintf(`{"type":"MembershipGranted","user_uuid":"%s","baby_uuid":"%s","permissions":["read"],"version":1}`, userUuid, babyUuid),
+			wantStatus: http.StatusNoContent,
+		},
+		{
+			name:       "unknown type",
+			apiKey:     config.Config.GetFamilyEventsApiKey(),
+			body:       fmt.Sprintf(`{"type":"BabyRenamed","user_uuid":"%s","baby_uuid":"%s","version":2}`, userUuid, babyUuid),
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "wrong api key",
+			apiKey:     "wrong",
+			body:       fmt.Sprintf(`{"type":"MembershipRevoked","user_uuid":"%s","baby_uuid":"%s","version":3}`, userUuid, babyUuid),
+			wantStatus: http.StatusUnauthorized,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/family/v1/events", strings.NewReader(tt.body))
+			req.Header.Set("X-Api-Key", tt.apiKey)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			assert.Equal(t, tt.wantStatus, w.Code)
+		})
+	}
+
+	grant, err := daos.NewAccessGrantDAO().GetGrant(userUuid, models.TargetUUID(babyUuid))
+	if assert.Nil(t, err) {
+		assert.Equal(t, models.Permissions{models.PermissionRead}, grant.GetPermissions())
+		assert.Equal(t, int64(1), grant.Version)
+	}
+}
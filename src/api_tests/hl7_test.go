@@ -0,0 +1,122 @@
+package api_tests
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"little-diary-measurement-service/src/common"
+	"little-diary-measurement-service/src/config"
+	"little-diary-measurement-service/src/hl7"
+	"little-diary-measurement-service/src/models"
+	"little-diary-measurement-service/src/router"
+	"little-diary-measurement-service/src/test_data"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func makeOruMessage(controlId string, patientId string) string {
+	return "MSH|^~\\&|LAB|CITY CLINIC|DIARY|LITTLE DIARY|20200401101500||ORU^R01|" + controlId + "|P|2.5.1\r" +
+		"PID|1||" + patientId + "^^^CITYCLINIC^MR\r" +
+		"OBR|1|||29463-7^Body weight^LN|||20200401093000\r" +
+		"OBX|1|NM|29463-7^Body weight^LN||4.52|kg|||||F\r" +
+		"OBX|2|NM|8302-2^Body height^LN||54.5|cm|||||F\r" +
+		"OBX|3|NM|9843-4^Head circumference^LN||361|mm|||||F\r"
+}
+
+func TestIngestHl7Message(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tx := test_data.OnBeforeDBTest()
+	defer test_data.OnAfterDBTest(tx)
+
+	mapping := &models.PatientMapping{
+		AssigningAuthority: "CITYCLINIC",
+		PatientId:          fmt.Sprintf("%s", uuid.New()),
+		TargetUuid:         models.TargetUUID(fmt.Sprintf("%s", uuid.New())),
+	}
+	assert.Nil(t, config.Config.DB.Create(mapping).Error)
+
+	r := router.GetMainEngine(&common.ServiceLocator{
+		PublicKeyGetter: &config.Config,
+		Hl7ApiKeyGetter: &config.Config,
+	})
+
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest("POST", "/hl7/v2/messages", strings.NewReader(makeOruMessage("MSG1", mapping.PatientId)))
+		req.Header.Set("X-Api-Key", config.Config.GetHl7ApiKey())
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if assert.Equal(t, http.StatusOK, w.Code) {
+			ack, err := hl7.Parse(w.Body.String())
+			if assert.Nil(t, err) {
+				msa, _ := ack.Segment("MSA")
+				assert.Equal(t, "AA", msa.Field(1))
+				assert.Equal(t, "MSG1", msa.Field(2))
+			}
+		}
+	}
+
+	var stored []*models.Measurement
+	err := config.Config.DB.
+		Where("target_uuid = ?", mapping.TargetUuid).
+		Order("measurement_type ASC").
+		Find(&stored).
+		Error
+	assert.Nil(t, err)
+	if assert.Len(t, stored, 3, "retransmitted message must not duplicate measurements") {
+		assert.Equal(t, models.MeasurementTypeHeadCircumference, stored[0].Type)
+		assert.InDelta(t, 36.1, stored[0].Value, 0.001)
+		assert.Equal(t, models.MeasurementTypeHeight, stored[1].Type)
+		assert.InDelta(t, 54.5, stored[1].Value, 0.001)
+		assert.Equal(t, models.MeasurementTypeWeight, stored[2].Type)
+		assert.InDelta(t, 4520, stored[2].Value, 0.01)
+		for _, m := range stored {
+			assert.Equal(t, models.MeasurementSourceClinic, m.Source)
+		}
+	}
+}
+
+func TestIngestHl7MessageUnknownPatient(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tx := test_data.OnBeforeDBTest()
+	defer test_data.OnAfterDBTest(tx)
+
+	r := router.GetMainEngine(&common.ServiceLocator{
+		PublicKeyGetter: &config.Config,
+		Hl7ApiKeyGetter: &config.Config,
+	})
+
+	req := httptest.NewRequest("POST", "/hl7/v2/messages", strings.NewReader(makeOruMessage("MSG2", "unknown")))
+	req.Header.Set("X-Api-Key", config.Config.GetHl7ApiKey())
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if assert.Equal(t, http.StatusUnprocessableEntity, w.Code) {
+		ack, err := hl7.Parse(w.Body.String())
+		if assert.Nil(t, err) {
+			msa, _ := ack.Segment("MSA")
+			assert.Equal(t, "AE", msa.Field(1))
+		}
+	}
+}
+
+func TestIngestHl7MessageUnauthorized(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	r := router.GetMainEngine(&common.ServiceLocator{
+		PublicKeyGetter: &config.Config,
+		Hl7ApiKeyGetter: &config.Config,
+	})
+
+	req := httptest.NewRequest("POST", "/hl7/v2/messages", strings.NewReader(makeOruMessage("MSG3", "any")))
+	req.Header.Set("X-Api-Key", "wrong key")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
@@ -22,9 +22,9 @@ func TestCheckHealth(t *testing.T) {
 
 	r := router.GetMainEngine(&common.ServiceLocator{
 		PublicKeyGetter: &config.Config,
-		UserHasAccessToBabyChecker: func() integrations.UserHasAccessToBabyChecker {
-			mockObj := new(test_data.MockUserHasAccessToBabyChecker)
-			mockObj.On("CheckUserHasAccessToBaby", mock.Anything, mock.Anything).Return(true, nil)
+		Authorizer: func() integrations.Authorizer {
+			mockObj := new(test_data.MockAuthorizer)
+			mockObj.On("GetPermissions", mock.Anything, mock.Anything).Return(test_data.ParentPermissions, nil)
 			return mockObj
 		}(),
 	})
@@ -0,0 +1,67 @@
+package apis
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"little-diary-measurement-service/src/common"
+	"little-diary-measurement-service/src/daos"
+	"little-diary-measurement-service/src/dto"
+	"little-diary-measurement-service/src/errors"
+	"little-diary-measurement-service/src/services"
+	"log"
+	"net/http"
+)
+
+// GetAlertsByTarget godoc
+// @Summary Retrieves alerts of the target, newest first
+// @Security ApiKeyAuth
+// @Produce json
+// @Param uuid path string true "Target UUID" format(uuid)
+// @Param acknowledged query bool false "Include acknowledged alerts" default(false)
+// @Success 200 {array} dto.AlertResponse
+// @Router /targets/{uuid}/alerts [get]
+func GetAlertsByTarget(c *gin.Context, locator *common.ServiceLocator) {
+	s := services.NewAlertService(daos.NewAlertDAO(), locator)
+	uuid := c.Param("uuid")
+	userUuid := c.GetString("UserUuid")
+	includeAcknowledged := c.Query("acknowledged") == "true"
+	if alerts, err := s.GetByTargetUuid(uuid, userUuid, includeAcknowledged); err != nil {
+		if _, ok := err.(*errors.ForbiddenError); ok {
+			c.AbortWithStatus(http.StatusForbidden)
+		} else {
+			c.AbortWithStatus(http.StatusInternalServerError)
+			log.Println(err)
+		}
+	} else {
+		dtos := []*dto.AlertResponse{}
+		for _, a := range alerts {
+			dtos = append(dtos, dto.AlertResponseFromModel(a))
+		}
+		c.JSON(http.StatusOK, dtos)
+	}
+}
+
+// AcknowledgeAlert godoc
+// @Summary Marks alert as acknowledged by the current user
+// @Security ApiKeyAuth
+// @Produce json
+// @Param uuid path string true "Alert UUID" format(uuid)
+// @Success 200 {object} dto.AlertResponse
+// @Router /alert/{uuid}/acknowledge [post]
+func AcknowledgeAlert(c *gin.Context, locator *common.ServiceLocator) {
+	s := services.NewAlertService(daos.NewAlertDAO(), locator)
+	uuid := c.Param("uuid")
+	userUuid := c.GetString("UserUuid")
+	if alert, err := s.Acknowledge(uuid, userUuid); err != nil {
+		if _, ok := err.(*errors.ForbiddenError); ok {
+			c.AbortWithStatus(http.StatusForbidden)
+		} else if gorm.IsRecordNotFoundError(err) {
+			c.AbortWithStatus(http.StatusNotFound)
+		} else {
+			c.AbortWithStatus(http.StatusInternalServerError)
+			log.Println(err)
+		}
+	} else {
+		c.JSON(http.StatusOK, dto.AlertResponseFromModel(alert))
+	}
+}
@@ -0,0 +1,106 @@
+package apis
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"little-diary-measurement-service/src/charts"
+	"little-diary-measurement-service/src/common"
+	"little-diary-measurement-service/src/daos"
+	"little-diary-measurement-service/src/errors"
+	"little-diary-measurement-service/src/growth"
+	"little-diary-measurement-service/src/models"
+	"little-diary-measurement-service/src/services"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultChartWidth  = 640
+	defaultChartHeight = 400
+	minChartSize       = 200
+	maxChartSize       = 2400
+)
+
+var chartThemes = map[string]charts.Theme{
+	"light": charts.LightTheme,
+	"dark":  charts.DarkTheme,
+}
+
+// GetGrowthChart godoc
+// @Summary Renders target measurements by age over WHO percentile curves (3rd-97th) as SVG
+// @Description Preterm babies are drawn by corrected age over Fenton preterm curves until 50 weeks postmenstrual age, the curves are left out when the service has no Fenton table.
+// @Security ApiKeyAuth
+// @Produce image/svg+xml
+// @Param uuid path string true "Target UUID" format(uuid)
+// @Param type query string true "Measurement type" Enums(HEIGHT, WEIGHT, HEAD_CIRCUMFERENCE)
+// @Param sex query string false "Sex of the child, baby profile by default" Enums(male, female)
+// @Param birth-date query string false "Birth date of the child, baby profile by default" format(date)
+// @Param theme query string false "Color theme" Enums(light, dark) default(light)
+// @Param width query int false "Image width" minimum(200) maximum(2400) default(640)
+// @Param height query int false "Image height" minimum(200) maximum(2400) default(400)
+// @Success 200 {file} file
+// @Router /targets/{uuid}/chart.svg [get]
+func GetGrowthChart(c *gin.Context, locator *common.ServiceLocator) {
+	s := services.NewChartService(daos.NewMeasurementDAO(), locator)
+	uuid := c.Param("uuid")
+	userUuid := c.GetString("UserUuid")
+	options, err := parseChartOptions(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var svg bytes.Buffer
+	if err := s.WriteGrowthChart(&svg, uuid, userUuid, options); err != nil {
+		if _, ok := err.(*errors.ForbiddenError); ok {
+			c.AbortWithStatus(http.StatusForbidden)
+		} else if _, ok := err.(*errors.ValidationError); ok {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		} else {
+			c.AbortWithStatus(http.StatusInternalServerError)
+			log.Println(err)
+		}
+		return
+	}
+	c.Data(http.StatusOK, "image/svg+xml", svg.Bytes())
+}
+
+func parseChartOptions(c *gin.Context) (services.ChartOptions, error) {
+	options := services.ChartOptions{Type: models.MeasurementType(c.Query("type"))}
+	if !isKnownMeasurementType(c.Query("type")) {
+		return options, fmt.Errorf("measurement type %s does not exist", c.Query("type"))
+	}
+	var err error
+	if c.Query("sex") != "" {
+		if options.Sex, err = growth.ParseSex(c.Query("sex")); err != nil {
+			return options, err
+		}
+	}
+	if c.Query("birth-date") != "" {
+		if options.BirthDate, err = time.Parse("2006-01-02", c.Query("birth-date")); err != nil {
+			return options, fmt.Errorf("birth-date has invalid date %s", c.Query("birth-date"))
+		}
+	}
+	theme, ok := chartThemes[c.DefaultQuery("theme", "light")]
+	if !ok {
+		return options, fmt.Errorf("theme %s does not exist", c.Query("theme"))
+	}
+	options.Theme = theme
+	if options.Width, err = parseChartSize(c, "width", defaultChartWidth); err != nil {
+		return options, err
+	}
+	if options.Height, err = parseChartSize(c, "height", defaultChartHeight); err != nil {
+		return options, err
+	}
+	return options, nil
+}
+
+func parseChartSize(c *gin.Context, name string, defaultValue int) (float64, error) {
+	value, err := strconv.Atoi(c.DefaultQuery(name, strconv.Itoa(defaultValue)))
+	if err != nil || value < minChartSize || value > maxChartSize {
+		return 0, fmt.Errorf("%s must be a number from %d to %d", name, minChartSize, maxChartSize)
+	}
+	return float64(value), nil
+}
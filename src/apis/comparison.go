@@ -0,0 +1,63 @@
+package apis
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"little-diary-measurement-service/src/common"
+	"little-diary-measurement-service/src/daos"
+	"little-diary-measurement-service/src/dto"
+	"little-diary-measurement-service/src/errors"
+	"little-diary-measurement-service/src/models"
+	"little-diary-measurement-service/src/services"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// CompareMeasurements godoc
+// @Summary Aligns growth of several children by age for overlaying them
+// @Description Each child's measurements are placed by age from its birth date in the baby profile and interpolated
+// @Description at common ages from birth to the oldest measured age. Values outside of a child's history are null.
+// @Security ApiKeyAuth
+// @Produce json
+// @Param target-uuid query []string true "Target UUIDs" collectionFormat(multi)
+// @Param type query string true "Measurement type" Enums(HEIGHT, WEIGHT, HEAD_CIRCUMFERENCE)
+// @Param step query integer false "Distance of common ages" default(1)
+// @Param age_unit query string false "Unit of ages" Enums(days, months) default(months)
+// @Success 200 {object} dto.ComparisonResponse
+// @Router /measurements/compare [get]
+func CompareMeasurements(c *gin.Context, locator *common.ServiceLocator) {
+	s := services.NewComparisonService(daos.NewMeasurementDAO(), locator)
+	uuids := c.QueryArray("target-uuid")
+	userUuid := c.GetString("UserUuid")
+	if len(uuids) == 0 || len(uuids) > maxTargetsPerRequest {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("from 1 to %d target-uuid are required", maxTargetsPerRequest)})
+		return
+	}
+	if !isKnownMeasurementType(c.Query("type")) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("measurement type %s does not exist", c.Query("type"))})
+		return
+	}
+	unit, ok := parseAgeUnit(c.DefaultQuery("age_unit", string(models.AgeUnitMonths)))
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("age_unit %s does not exist", c.Query("age_unit"))})
+		return
+	}
+	step, err := strconv.Atoi(c.DefaultQuery("step", "1"))
+	if err != nil || step < 1 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("step has invalid value %s", c.Query("step"))})
+		return
+	}
+	if comparison, err := s.Compare(uuids, models.MeasurementType(c.Query("type")), models.Age{Value: step, Unit: unit}, userUuid); err != nil {
+		if _, ok := err.(*errors.ForbiddenError); ok {
+			c.AbortWithStatus(http.StatusForbidden)
+		} else if _, ok := err.(*errors.ValidationError); ok {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		} else {
+			c.AbortWithStatus(http.StatusInternalServerError)
+			log.Println(err)
+		}
+	} else {
+		c.JSON(http.StatusOK, dto.ComparisonResponseFromModel(comparison, unit))
+	}
+}
@@ -0,0 +1,37 @@
+package apis
+
+import (
+	"github.com/gin-gonic/gin"
+	"little-diary-measurement-service/src/common"
+	"little-diary-measurement-service/src/daos"
+	"little-diary-measurement-service/src/dto"
+	"little-diary-measurement-service/src/errors"
+	"little-diary-measurement-service/src/services"
+	"log"
+	"net/http"
+)
+
+// EraseTargetMeasurements godoc
+// @Summary Hard-deletes all measurements of the target with their alerts, webhooks and mappings
+// @Description Meant for service callers erasing personal data, the receipt keeps only counts of deleted records.
+// @Produce json
+// @Param X-Api-Key header string true "Erasure api key"
+// @Param uuid path string true "Target UUID" format(uuid)
+// @Success 200 {object} dto.ErasureReceiptResponse
+// @Failure 400
+// @Failure 401
+// @Router /targets/{uuid}/measurements [delete]
+func EraseTargetMeasurements(c *gin.Context, locator *common.ServiceLocator) {
+	s := services.NewErasureService(daos.NewErasureDAO())
+	uuid := c.Param("uuid")
+	if receipt, err := s.EraseTarget(uuid); err != nil {
+		if _, ok := err.(*errors.ValidationError); ok {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		} else {
+			c.AbortWithStatus(http.StatusInternalServerError)
+			log.Println(err)
+		}
+	} else {
+		c.JSON(http.StatusOK, dto.ErasureReceiptResponseFromModel(receipt))
+	}
+}
@@ -0,0 +1,98 @@
+package apis
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"little-diary-measurement-service/src/common"
+	"little-diary-measurement-service/src/daos"
+	"little-diary-measurement-service/src/dto"
+	"little-diary-measurement-service/src/errors"
+	"little-diary-measurement-service/src/models"
+	"little-diary-measurement-service/src/services"
+	"log"
+	"net/http"
+	"time"
+)
+
+// RequestExport godoc
+// @Summary Starts export of all measurements of every baby the user can see
+// @Description The export is built in the background into a zip archive with measurements.json and measurements.csv.
+// @Description Asking again while an export is waiting or running returns that export.
+// @Security ApiKeyAuth
+// @Produce json
+// @Success 202 {object} dto.ExportResponse
+// @Router /me/exports [post]
+func RequestExport(c *gin.Context, locator *common.ServiceLocator) {
+	s := services.NewExportService(daos.NewExportDAO(), locator)
+	userUuid := c.GetString("UserUuid")
+	if job, err := s.Request(userUuid); err != nil {
+		abortExportError(c, err)
+	} else {
+		c.JSON(http.StatusAccepted, dto.ExportResponseFromModel(job))
+	}
+}
+
+// GetExport godoc
+// @Summary Retrieves status of the export, a completed export comes with a short-lived download link
+// @Security ApiKeyAuth
+// @Produce json
+// @Param uuid path string true "Export UUID" format(uuid)
+// @Success 200 {object} dto.ExportResponse
+// @Router /me/exports/{uuid} [get]
+func GetExport(c *gin.Context, locator *common.ServiceLocator) {
+	s := services.NewExportService(daos.NewExportDAO(), locator)
+	uuid := c.Param("uuid")
+	userUuid := c.GetString("UserUuid")
+	job, err := s.Get(uuid, userUuid)
+	if err != nil {
+		abortExportError(c, err)
+		return
+	}
+	response := dto.ExportResponseFromModel(job)
+	if job.Status == models.ExportCompleted {
+		link, expiresAt, err := s.DownloadLink(job, time.Now())
+		if err != nil {
+			abortExportError(c, err)
+			return
+		}
+		response.DownloadUrl = link
+		response.DownloadUrlExpiresAt = &expiresAt
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// DownloadExport godoc
+// @Summary Downloads the zip archive of the export with the signed link from the export status
+// @Produce application/zip
+// @Param uuid path string true "Export UUID" format(uuid)
+// @Param expires query integer true "Link expiry in unix seconds"
+// @Param signature query string true "Link signature"
+// @Success 200 {file} file
+// @Failure 403
+// @Failure 404
+// @Router /exports/{uuid}/download [get]
+func DownloadExport(c *gin.Context, locator *common.ServiceLocator) {
+	s := services.NewExportService(daos.NewExportDAO(), locator)
+	uuid := c.Param("uuid")
+	archive, err := s.Download(uuid, c.Query("expires"), c.Query("signature"), time.Now())
+	if err != nil {
+		abortExportError(c, err)
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="measurements-export-%s.zip"`, uuid))
+	c.Data(http.StatusOK, "application/zip", archive)
+}
+
+func abortExportError(c *gin.Context, err error) {
+	if _, ok := err.(*errors.ForbiddenError); ok {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	} else if _, ok := err.(*errors.ValidationError); ok {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	} else if gorm.IsRecordNotFoundError(err) {
+		c.AbortWithStatus(http.StatusNotFound)
+	} else {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		log.Println(err)
+	}
+}
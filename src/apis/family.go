@@ -0,0 +1,47 @@
+package apis
+
+import (
+	"github.com/gin-gonic/gin"
+	"little-diary-measurement-service/src/common"
+	"little-diary-measurement-service/src/daos"
+	"little-diary-measurement-service/src/dto"
+	"little-diary-measurement-service/src/errors"
+	"little-diary-measurement-service/src/services"
+	"log"
+	"net/http"
+)
+
+// ConsumeFamilyEvent godoc
+// @Summary Apply membership event of the family service to the local access grants, erase data of a deleted baby
+// @Description BabyDeleted hard-deletes measurements, alerts, webhooks and mappings of the baby once per event_uuid
+// @Description and records an erasure receipt.
+// @Accept json
+// @Param X-Api-Key header string true "Family service api key"
+// @Param event body dto.FamilyEvent true "Membership or baby deleted event"
+// @Success 204
+// @Failure 400
+// @Failure 401
+// @Router /family/v1/events [post]
+func ConsumeFamilyEvent(c *gin.Context, locator *common.ServiceLocator) {
+	var event dto.FamilyEvent
+	if err := c.BindJSON(&event); err != nil {
+		log.Println(err)
+		return
+	}
+	var err error
+	if event.Type == dto.FamilyEventBabyDeleted {
+		_, err = services.NewErasureService(daos.NewErasureDAO()).ConsumeBabyDeleted(event)
+	} else {
+		err = services.NewAccessGrantService(daos.NewAccessGrantDAO(), nil).ApplyEvent(event)
+	}
+	if err != nil {
+		if _, ok := err.(*errors.ValidationError); ok {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.AbortWithStatus(http.StatusInternalServerError)
+		log.Println(err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,43 @@
+package apis
+
+import (
+	"github.com/gin-gonic/gin"
+	"io/ioutil"
+	"little-diary-measurement-service/src/common"
+	"little-diary-measurement-service/src/daos"
+	"little-diary-measurement-service/src/hl7"
+	"little-diary-measurement-service/src/services"
+	"log"
+	"net/http"
+)
+
+const hl7ContentType = "x-application/hl7-v2+er7; charset=utf-8"
+
+// IngestHl7Message godoc
+// @Summary Ingest HL7 v2 ORU^R01 message from clinic system and reply with ACK
+// @Accept plain
+// @Produce plain
+// @Param X-Api-Key header string true "Clinic api key"
+// @Param message body string true "ER7 encoded ORU^R01 message"
+// @Success 200 {string} string "ACK with AA code"
+// @Failure 400 {string} string "ACK with AR code"
+// @Failure 422 {string} string "ACK with AE code"
+// @Router /hl7/v2/messages [post]
+func IngestHl7Message(c *gin.Context, locator *common.ServiceLocator) {
+	s := services.NewHl7IngestionService(daos.NewMeasurementDAO(), daos.NewPatientMappingDAO())
+	body, err := ioutil.ReadAll(c.Request.Body)
+	if err != nil {
+		c.AbortWithStatus(http.StatusBadRequest)
+		log.Println(err)
+		return
+	}
+	ack, code := s.HandleMessage(string(body))
+	status := http.StatusOK
+	switch code {
+	case hl7.AckCodeReject:
+		status = http.StatusBadRequest
+	case hl7.AckCodeError:
+		status = http.StatusUnprocessableEntity
+	}
+	c.Data(status, hl7ContentType, []byte(ack))
+}
@@ -0,0 +1,65 @@
+package apis
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"little-diary-measurement-service/src/common"
+	"little-diary-measurement-service/src/daos"
+	"little-diary-measurement-service/src/dto"
+	"little-diary-measurement-service/src/errors"
+	"little-diary-measurement-service/src/models"
+	"little-diary-measurement-service/src/services"
+	"log"
+	"net/http"
+	"time"
+)
+
+// InterpolateMeasurement godoc
+// @Summary Estimates value of one type at a date or an age between two measurements
+// @Description The value follows the reference percentiles between the bracketing measurements when sex and birth date
+// @Description of the child are known from the baby profile, otherwise it lies on a straight line between them.
+// @Description Values outside of the measured history are not extrapolated.
+// @Security ApiKeyAuth
+// @Produce json
+// @Param target-uuid query string true "Target UUID" format(uuid)
+// @Param type query string true "Measurement type" Enums(HEIGHT, WEIGHT, HEAD_CIRCUMFERENCE)
+// @Param at query string false "Time of the estimate, RFC3339 or date" format(datetime)
+// @Param age query integer false "Age of the child at the estimate, instead of at"
+// @Param age_unit query string false "Unit of age" Enums(days, months) default(days)
+// @Success 200 {object} dto.InterpolationResponse
+// @Router /measurements/interpolate [get]
+func InterpolateMeasurement(c *gin.Context, locator *common.ServiceLocator) {
+	s := services.NewInterpolationService(daos.NewMeasurementDAO(), locator)
+	uuid := c.Query("target-uuid")
+	userUuid := c.GetString("UserUuid")
+	if !isKnownMeasurementType(c.Query("type")) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("measurement type %s does not exist", c.Query("type"))})
+		return
+	}
+	at, err := parseTimeQuery(c, "at", time.UTC)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	age, err := parseAgeQuery(c, "age")
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if (at == nil) == (age == nil) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "exactly one of at and age is required"})
+		return
+	}
+	if interpolation, err := s.Interpolate(uuid, models.MeasurementType(c.Query("type")), at, age, userUuid); err != nil {
+		if _, ok := err.(*errors.ForbiddenError); ok {
+			c.AbortWithStatus(http.StatusForbidden)
+		} else if _, ok := err.(*errors.ValidationError); ok {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		} else {
+			c.AbortWithStatus(http.StatusInternalServerError)
+			log.Println(err)
+		}
+	} else {
+		c.JSON(http.StatusOK, dto.InterpolationResponseFromModel(interpolation))
+	}
+}
@@ -1,14 +1,18 @@
 package apis
 
 import (
+	"fmt"
 	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
 	"little-diary-measurement-service/src/common"
 	"little-diary-measurement-service/src/daos"
 	"little-diary-measurement-service/src/dto"
 	"little-diary-measurement-service/src/errors"
+	"little-diary-measurement-service/src/models"
 	"little-diary-measurement-service/src/services"
 	"log"
 	"net/http"
+	"time"
 )
 
 // GetMeasurement godoc
@@ -19,7 +23,7 @@ import (
 // @Success 200 {object} dto.MeasurementResponse
 // @Router /measurement/{uuid} [get]
 func GetMeasurement(c *gin.Context, locator *common.ServiceLocator) {
-	s := services.NewMeasurementService(daos.NewMeasurementDAO(), locator)
+	s := services.NewMeasurementService(daos.NewMeasurementDAO(), daos.NewAlertDAO(), locator)
 	uuid := c.Param("uuid")
 	userUuid := c.GetString("UserUuid")
 	if measurement, err := s.GetByMeasurementUuid(uuid, userUuid); err != nil {
@@ -36,18 +40,34 @@ func GetMeasurement(c *gin.Context, locator *common.ServiceLocator) {
 
 // GetMeasurementsByTarget godoc
 // @Summary Retrieves measurements based on given target UUID
+// @Description Derived types BMI and WEIGHT_FOR_LENGTH pair each weight with the closest height measured within 3 days,
+// @Description they are returned only when requested by type. WEIGHT_FOR_LENGTH is the weight in grams with its percentile
+// @Description for the paired length
 // @Security ApiKeyAuth
 // @Produce json
 // @Param target-uuid query string true "Target UUID" format(uuid)
+// @Param type query []string false "Measurement types" collectionFormat(multi) Enums(HEIGHT, WEIGHT, HEAD_CIRCUMFERENCE, BMI, WEIGHT_FOR_LENGTH)
+// @Param from query string false "Period start, RFC3339 or date" format(datetime)
+// @Param to query string false "Period end, RFC3339 or date" format(datetime)
+// @Param age_from query integer false "Period start as age of the child, instead of from"
+// @Param age_to query integer false "Period end as age of the child including the whole day or month, instead of to"
+// @Param age_unit query string false "Unit of age bounds" Enums(days, months) default(days)
 // @Success 200 {array} dto.MeasurementResponse
 // @Router /measurements [get]
 func GetMeasurementsByTarget(c *gin.Context, locator *common.ServiceLocator) {
-	s := services.NewMeasurementService(daos.NewMeasurementDAO(), locator)
+	s := services.NewMeasurementService(daos.NewMeasurementDAO(), daos.NewAlertDAO(), locator)
 	uuid := c.Query("target-uuid")
 	userUuid := c.GetString("UserUuid")
-	if measurements, err := s.GetByTargetUuid(uuid, userUuid); err != nil {
+	filter, err := parseMeasurementFilter(c, uuid, time.UTC, true)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if measurements, err := s.GetByFilter(filter, userUuid); err != nil {
 		if _, ok := err.(*errors.ForbiddenError); ok {
 			c.AbortWithStatus(http.StatusForbidden)
+		} else if _, ok := err.(*errors.ValidationError); ok {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		} else {
 			c.AbortWithStatus(http.StatusInternalServerError)
 			log.Println(err)
@@ -63,6 +83,8 @@ func GetMeasurementsByTarget(c *gin.Context, locator *common.ServiceLocator) {
 
 // SaveMeasurement godoc
 // @Summary Create or update measurement
+// @Description Values implausible compared to the recent history are saved as suspect with a warning,
+// @Description they are confirmed with POST /measurement/{uuid}/confirm
 // @Security ApiKeyAuth
 // @Accept json
 // @Produce json
@@ -71,7 +93,7 @@ func GetMeasurementsByTarget(c *gin.Context, locator *common.ServiceLocator) {
 // @Success 200 {object} dto.MeasurementResponse
 // @Router /measurement/{uuid} [put]
 func SaveMeasurement(c *gin.Context, locator *common.ServiceLocator) {
-	s := services.NewMeasurementService(daos.NewMeasurementDAO(), locator)
+	s := services.NewMeasurementService(daos.NewMeasurementDAO(), daos.NewAlertDAO(), locator)
 	uuid := c.Param("uuid")
 	userUuid := c.GetString("UserUuid")
 	var requestDto dto.MeasurementRequest
@@ -92,3 +114,120 @@ func SaveMeasurement(c *gin.Context, locator *common.ServiceLocator) {
 		c.JSON(http.StatusOK, responseDto)
 	}
 }
+
+// ConfirmMeasurement godoc
+// @Summary Confirms suspect measurement value is right and clears the warning
+// @Security ApiKeyAuth
+// @Produce json
+// @Param uuid path string true "Measurement UUID" format(uuid)
+// @Success 200 {object} dto.MeasurementResponse
+// @Router /measurement/{uuid}/confirm [post]
+func ConfirmMeasurement(c *gin.Context, locator *common.ServiceLocator) {
+	s := services.NewMeasurementService(daos.NewMeasurementDAO(), daos.NewAlertDAO(), locator)
+	uuid := c.Param("uuid")
+	userUuid := c.GetString("UserUuid")
+	if measurement, err := s.Confirm(uuid, userUuid); err != nil {
+		if _, ok := err.(*errors.ForbiddenError); ok {
+			c.AbortWithStatus(http.StatusForbidden)
+		} else if gorm.IsRecordNotFoundError(err) {
+			c.AbortWithStatus(http.StatusNotFound)
+		} else {
+			c.AbortWithStatus(http.StatusInternalServerError)
+			log.Println(err)
+		}
+	} else {
+		c.JSON(http.StatusOK, dto.MeasurementResponseFromModel(measurement))
+	}
+}
+
+// GetLatestMeasurements godoc
+// @Summary Retrieves the newest measurement of each type for every target with change since the previous one
+// @Security ApiKeyAuth
+// @Produce json
+// @Param target-uuid query []string true "Target UUIDs" collectionFormat(multi)
+// @Success 200 {array} dto.TargetLatestResponse
+// @Router /measurements/latest [get]
+func GetLatestMeasurements(c *gin.Context, locator *common.ServiceLocator) {
+	s := services.NewMeasurementService(daos.NewMeasurementDAO(), daos.NewAlertDAO(), locator)
+	uuids := c.QueryArray("target-uuid")
+	userUuid := c.GetString("UserUuid")
+	if len(uuids) == 0 || len(uuids) > maxTargetsPerRequest {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("from 1 to %d target-uuid are required", maxTargetsPerRequest)})
+		return
+	}
+	if latest, err := s.GetLatest(uuids, userUuid); err != nil {
+		if _, ok := err.(*errors.ForbiddenError); ok {
+			c.AbortWithStatus(http.StatusForbidden)
+		} else {
+			c.AbortWithStatus(http.StatusInternalServerError)
+			log.Println(err)
+		}
+	} else {
+		c.JSON(http.StatusOK, dto.TargetLatestResponsesFromModel(uuids, latest, time.Now()))
+	}
+}
+
+// AggregateMeasurements godoc
+// @Summary Summarizes measurements of one type by day, week or month
+// @Security ApiKeyAuth
+// @Produce json
+// @Param target-uuid query string true "Target UUID" format(uuid)
+// @Param type query string true "Measurement type" Enums(HEIGHT, WEIGHT, HEAD_CIRCUMFERENCE, BMI, WEIGHT_FOR_LENGTH)
+// @Param bucket query string false "Bucket size" Enums(day, week, month) default(day)
+// @Param tz query string false "IANA time zone of bucket boundaries" default(UTC)
+// @Param from query string false "Period start, RFC3339 or date" format(datetime)
+// @Param to query string false "Period end, RFC3339 or date" format(datetime)
+// @Param age_from query integer false "Period start as age of the child, instead of from"
+// @Param age_to query integer false "Period end as age of the child including the whole day or month, instead of to"
+// @Param age_unit query string false "Unit of age bounds" Enums(days, months) default(days)
+// @Success 200 {array} dto.MeasurementAggregateResponse
+// @Router /measurements/aggregate [get]
+func AggregateMeasurements(c *gin.Context, locator *common.ServiceLocator) {
+	s := services.NewMeasurementService(daos.NewMeasurementDAO(), daos.NewAlertDAO(), locator)
+	uuid := c.Query("target-uuid")
+	userUuid := c.GetString("UserUuid")
+	location, err := parseLocationQuery(c, "tz")
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	filter, err := parseMeasurementFilter(c, uuid, location, true)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := requireSingleType(filter); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	bucket, ok := parseAggregationBucket(c.DefaultQuery("bucket", string(models.AggregationBucketDay)))
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("bucket %s does not exist", c.Query("bucket"))})
+		return
+	}
+	if aggregates, err := s.Aggregate(filter, bucket, location, userUuid); err != nil {
+		if _, ok := err.(*errors.ForbiddenError); ok {
+			c.AbortWithStatus(http.StatusForbidden)
+		} else if _, ok := err.(*errors.ValidationError); ok {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		} else {
+			c.AbortWithStatus(http.StatusInternalServerError)
+			log.Println(err)
+		}
+	} else {
+		dtos := []*dto.MeasurementAggregateResponse{}
+		for _, a := range aggregates {
+			dtos = append(dtos, dto.MeasurementAggregateResponseFromModel(a, location))
+		}
+		c.JSON(http.StatusOK, dtos)
+	}
+}
+
+func parseAggregationBucket(value string) (models.AggregationBucket, bool) {
+	for _, bucket := range models.AggregationBuckets {
+		if string(bucket) == value {
+			return bucket, true
+		}
+	}
+	return "", false
+}
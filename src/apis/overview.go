@@ -0,0 +1,36 @@
+package apis
+
+import (
+	"github.com/gin-gonic/gin"
+	"little-diary-measurement-service/src/common"
+	"little-diary-measurement-service/src/daos"
+	"little-diary-measurement-service/src/dto"
+	"little-diary-measurement-service/src/services"
+	"log"
+	"net/http"
+	"time"
+)
+
+// GetMeasurementsOverview godoc
+// @Summary Summarizes measurements of every baby the user can see in one response
+// @Description Babies are listed by the family service, each comes with the latest value of every type,
+// @Description number of measurements by type and alerts not acknowledged yet
+// @Security ApiKeyAuth
+// @Produce json
+// @Success 200 {array} dto.TargetOverviewResponse
+// @Router /me/measurements/overview [get]
+func GetMeasurementsOverview(c *gin.Context, locator *common.ServiceLocator) {
+	s := services.NewOverviewService(daos.NewMeasurementDAO(), daos.NewAlertDAO(), locator)
+	userUuid := c.GetString("UserUuid")
+	if overviews, err := s.GetOverview(userUuid); err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		log.Println(err)
+	} else {
+		now := time.Now()
+		dtos := []*dto.TargetOverviewResponse{}
+		for _, overview := range overviews {
+			dtos = append(dtos, dto.TargetOverviewResponseFromModel(overview, now))
+		}
+		c.JSON(http.StatusOK, dtos)
+	}
+}
@@ -0,0 +1,118 @@
+package apis
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"little-diary-measurement-service/src/models"
+	"strconv"
+	"time"
+)
+
+// maxTargetsPerRequest limits endpoints working with several targets at once
+const maxTargetsPerRequest = 20
+
+// parseMeasurementFilter reads repeated "type" and "from"/"to" (RFC3339 or date) query parameters,
+// dates without time are taken in the given location, derived types are accepted only when allowed.
+// The period can be given by "age_from"/"age_to" in "age_unit" instead of dates
+func parseMeasurementFilter(c *gin.Context, targetUuid string, location *time.Location, allowDerived bool) (models.MeasurementFilter, error) {
+	filter := models.MeasurementFilter{TargetUuid: models.TargetUUID(targetUuid)}
+	for _, t := range c.QueryArray("type") {
+		if !isKnownMeasurementType(t) && !(allowDerived && models.MeasurementType(t).IsDerived()) {
+			return filter, fmt.Errorf("measurement type %s does not exist", t)
+		}
+		filter.Types = append(filter.Types, models.MeasurementType(t))
+	}
+	var err error
+	if filter.From, err = parseTimeQuery(c, "from", location); err != nil {
+		return filter, err
+	}
+	if filter.To, err = parseTimeQuery(c, "to", location); err != nil {
+		return filter, err
+	}
+	if filter.To != nil && len(c.Query("to")) == len("2006-01-02") {
+		// date without time includes the whole day
+		to := filter.To.AddDate(0, 0, 1).Add(-time.Nanosecond)
+		filter.To = &to
+	}
+	if filter.AgeFrom, err = parseAgeQuery(c, "age_from"); err != nil {
+		return filter, err
+	}
+	if filter.AgeTo, err = parseAgeQuery(c, "age_to"); err != nil {
+		return filter, err
+	}
+	if (filter.AgeFrom != nil || filter.AgeTo != nil) && (filter.From != nil || filter.To != nil) {
+		return filter, fmt.Errorf("age bounds can not be combined with from and to")
+	}
+	return filter, nil
+}
+
+// parseAgeQuery reads non-negative age in the unit of "age_unit" query parameter, days by default
+func parseAgeQuery(c *gin.Context, name string) (*models.Age, error) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, nil
+	}
+	unit, ok := parseAgeUnit(c.DefaultQuery("age_unit", string(models.AgeUnitDays)))
+	if !ok {
+		return nil, fmt.Errorf("age_unit %s does not exist", c.Query("age_unit"))
+	}
+	age, err := strconv.Atoi(value)
+	if err != nil || age < 0 {
+		return nil, fmt.Errorf("%s has invalid age %s", name, value)
+	}
+	return &models.Age{Value: age, Unit: unit}, nil
+}
+
+func parseAgeUnit(value string) (models.AgeUnit, bool) {
+	for _, unit := range models.AgeUnits {
+		if string(unit) == value {
+			return unit, true
+		}
+	}
+	return "", false
+}
+
+func parseTimeQuery(c *gin.Context, name string, location *time.Location) (*time.Time, error) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, nil
+	}
+	if t, ok := parseTime(value, location); ok {
+		return &t, nil
+	}
+	return nil, fmt.Errorf("%s has invalid time %s", name, value)
+}
+
+// parseTime accepts RFC3339 time or date taken in the location
+func parseTime(value string, location *time.Location) (time.Time, bool) {
+	for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, value, location); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func isKnownMeasurementType(t string) bool {
+	for _, known := range models.MeasurementTypes {
+		if string(known) == t {
+			return true
+		}
+	}
+	return false
+}
+
+func parseLocationQuery(c *gin.Context, name string) (*time.Location, error) {
+	location, err := time.LoadLocation(c.DefaultQuery(name, "UTC"))
+	if err != nil {
+		return nil, fmt.Errorf("%s has unknown time zone %s", name, c.Query(name))
+	}
+	return location, nil
+}
+
+func requireSingleType(filter models.MeasurementFilter) error {
+	if len(filter.Types) != 1 {
+		return fmt.Errorf("exactly one measurement type is required")
+	}
+	return nil
+}
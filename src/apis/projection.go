@@ -0,0 +1,84 @@
+package apis
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"little-diary-measurement-service/src/common"
+	"little-diary-measurement-service/src/daos"
+	"little-diary-measurement-service/src/dto"
+	"little-diary-measurement-service/src/errors"
+	"little-diary-measurement-service/src/growth"
+	"little-diary-measurement-service/src/models"
+	"little-diary-measurement-service/src/services"
+	"log"
+	"net/http"
+	"time"
+)
+
+const maxProjectionDates = 12
+
+// GetProjection godoc
+// @Summary Projects measurements of one type to future dates along the child's current percentile
+// @Description The channel is the mean WHO percentile (Fenton for preterm babies) of measurements taken within 30 days
+// @Description before the latest one. The band widens with the distance from the latest measurement.
+// @Security ApiKeyAuth
+// @Produce json
+// @Param target-uuid query string true "Target UUID" format(uuid)
+// @Param type query string true "Measurement type" Enums(HEIGHT, WEIGHT, HEAD_CIRCUMFERENCE)
+// @Param date query []string true "Projected dates, RFC3339 or date" collectionFormat(multi)
+// @Param sex query string false "Sex of the child, from the baby profile by default" Enums(male, female)
+// @Param birth-date query string false "Birth date of the child, from the baby profile by default" format(date)
+// @Success 200 {object} dto.ProjectionResponse
+// @Router /measurements/projection [get]
+func GetProjection(c *gin.Context, locator *common.ServiceLocator) {
+	s := services.NewProjectionService(daos.NewMeasurementDAO(), locator)
+	uuid := c.Query("target-uuid")
+	userUuid := c.GetString("UserUuid")
+	options, err := parseProjectionOptions(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if projection, err := s.Project(uuid, userUuid, options); err != nil {
+		if _, ok := err.(*errors.ForbiddenError); ok {
+			c.AbortWithStatus(http.StatusForbidden)
+		} else if _, ok := err.(*errors.ValidationError); ok {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		} else {
+			c.AbortWithStatus(http.StatusInternalServerError)
+			log.Println(err)
+		}
+	} else {
+		c.JSON(http.StatusOK, dto.ProjectionResponseFromModel(projection))
+	}
+}
+
+func parseProjectionOptions(c *gin.Context) (services.ProjectionOptions, error) {
+	options := services.ProjectionOptions{Type: models.MeasurementType(c.Query("type"))}
+	if !isKnownMeasurementType(c.Query("type")) {
+		return options, fmt.Errorf("measurement type %s does not exist", c.Query("type"))
+	}
+	var err error
+	if c.Query("sex") != "" {
+		if options.Sex, err = growth.ParseSex(c.Query("sex")); err != nil {
+			return options, err
+		}
+	}
+	if c.Query("birth-date") != "" {
+		if options.BirthDate, err = time.Parse("2006-01-02", c.Query("birth-date")); err != nil {
+			return options, fmt.Errorf("birth-date has invalid date %s", c.Query("birth-date"))
+		}
+	}
+	dates := c.QueryArray("date")
+	if len(dates) == 0 || len(dates) > maxProjectionDates {
+		return options, fmt.Errorf("from 1 to %d date are required", maxProjectionDates)
+	}
+	for _, value := range dates {
+		date, ok := parseTime(value, time.UTC)
+		if !ok {
+			return options, fmt.Errorf("date has invalid time %s", value)
+		}
+		options.Dates = append(options.Dates, date)
+	}
+	return options, nil
+}
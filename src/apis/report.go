@@ -0,0 +1,52 @@
+package apis
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"little-diary-measurement-service/src/common"
+	"little-diary-measurement-service/src/daos"
+	"little-diary-measurement-service/src/errors"
+	"little-diary-measurement-service/src/services"
+	"log"
+	"net/http"
+	"time"
+)
+
+// GetGrowthReport godoc
+// @Summary Renders one-page PDF growth report for the target
+// @Security ApiKeyAuth
+// @Produce application/pdf
+// @Param uuid path string true "Target UUID" format(uuid)
+// @Param type query []string false "Measurement types, all by default" collectionFormat(multi)
+// @Param from query string false "Period start, RFC3339 or date" format(datetime)
+// @Param to query string false "Period end, RFC3339 or date" format(datetime)
+// @Param age_from query integer false "Period start as age of the child, instead of from"
+// @Param age_to query integer false "Period end as age of the child including the whole day or month, instead of to"
+// @Param age_unit query string false "Unit of age bounds" Enums(days, months) default(days)
+// @Success 200 {file} file
+// @Router /targets/{uuid}/report.pdf [get]
+func GetGrowthReport(c *gin.Context, locator *common.ServiceLocator) {
+	s := services.NewReportService(daos.NewMeasurementDAO(), locator)
+	uuid := c.Param("uuid")
+	userUuid := c.GetString("UserUuid")
+	filter, err := parseMeasurementFilter(c, uuid, time.UTC, false)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var pdf bytes.Buffer
+	if err := s.WriteGrowthReport(&pdf, filter, userUuid); err != nil {
+		if _, ok := err.(*errors.ForbiddenError); ok {
+			c.AbortWithStatus(http.StatusForbidden)
+		} else if _, ok := err.(*errors.ValidationError); ok {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		} else {
+			c.AbortWithStatus(http.StatusInternalServerError)
+			log.Println(err)
+		}
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`inline; filename="growth-report-%s.pdf"`, uuid))
+	c.Data(http.StatusOK, "application/pdf", pdf.Bytes())
+}
@@ -0,0 +1,138 @@
+package apis
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"little-diary-measurement-service/src/common"
+	"little-diary-measurement-service/src/daos"
+	"little-diary-measurement-service/src/errors"
+	"little-diary-measurement-service/src/events"
+	"little-diary-measurement-service/src/services"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultStreamHeartbeat   = 15 * time.Second
+	defaultStreamMaxDuration = time.Hour
+	// open streams are closed this long after the user loses access to the target at the latest
+	streamAccessCheck = time.Minute
+	// milliseconds EventSource waits before reconnecting
+	streamRetry = 3000
+)
+
+// StreamMeasurements godoc
+// @Summary Streams create, update and delete events of the target measurements as Server-Sent Events
+// @Description Event name is the event type and data is the event JSON, id is the position to resume after with Last-Event-ID.
+// @Description A reset event tells the client to reload measurements since too much was missed. Comment lines are heartbeats.
+// @Description The stream is closed after a while or when the user loses access, every user may have a few streams open at once.
+// @Description Events of concurrent changes may come out of id order and may repeat after a reconnect, event_uuid identifies them.
+// @Security ApiKeyAuth
+// @Produce text/event-stream
+// @Param target-uuid query string true "Target UUID" format(uuid)
+// @Param Last-Event-ID header integer false "Id of the last received event"
+// @Success 200 {string} string
+// @Failure 429 {object} object
+// @Router /measurements/stream [get]
+func StreamMeasurements(c *gin.Context, locator *common.ServiceLocator) {
+	s := services.NewStreamService(daos.NewOutboxDAO(), locator)
+	uuid := c.Query("target-uuid")
+	userUuid := c.GetString("UserUuid")
+	var lastEventId int64
+	if header := c.GetHeader("Last-Event-ID"); header != "" {
+		var err error
+		if lastEventId, err = strconv.ParseInt(header, 10, 64); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Last-Event-ID has invalid id %s", header)})
+			return
+		}
+	}
+	stream, err := s.Open(uuid, userUuid, lastEventId)
+	if err != nil {
+		if _, ok := err.(*errors.ForbiddenError); ok {
+			c.AbortWithStatus(http.StatusForbidden)
+		} else if _, ok := err.(*errors.LimitError); ok {
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		} else {
+			c.AbortWithStatus(http.StatusInternalServerError)
+			log.Println(err)
+		}
+		return
+	}
+	defer stream.Live.Close()
+
+	heartbeat, maxDuration := defaultStreamHeartbeat, defaultStreamMaxDuration
+	if locator.StreamConfig != nil {
+		// unset or invalid settings keep the defaults
+		if configured := locator.StreamConfig.GetStreamHeartbeat(); configured > 0 {
+			heartbeat = configured
+		}
+		if configured := locator.StreamConfig.GetStreamMaxDuration(); configured > 0 {
+			maxDuration = configured
+		}
+	}
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	w := c.Writer
+	fmt.Fprintf(w, "retry: %d\n\n", streamRetry)
+	if stream.Reset {
+		fmt.Fprintf(w, "id: %d\nevent: reset\ndata: {}\n\n", stream.ResetId)
+	}
+	// outbox ids are taken before commit, a live event with an id lower than sent ones is still new,
+	// so only the replayed events are skipped
+	replayed := map[int64]bool{}
+	for _, message := range stream.Backlog {
+		writeStreamEvent(w, message)
+		replayed[message.Sequence] = true
+	}
+	w.Flush()
+
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+	accessCheck := time.NewTicker(streamAccessCheck)
+	defer accessCheck.Stop()
+	deadline := time.NewTimer(maxDuration)
+	defer deadline.Stop()
+	for {
+		select {
+		case message, ok := <-stream.Live.C:
+			if !ok {
+				// fell behind, the client resumes from the last sent event
+				return
+			}
+			if replayed[message.Sequence] {
+				continue
+			}
+			writeStreamEvent(w, message)
+		case <-ticker.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+		case <-accessCheck.C:
+			if err := s.CheckAccess(uuid, userUuid); err != nil {
+				if _, ok := err.(*errors.ForbiddenError); ok {
+					return
+				}
+				// the access is checked again later when the family service is not available
+				log.Println(err)
+			}
+		case <-deadline.C:
+			return
+		case <-c.Request.Context().Done():
+			return
+		}
+		w.Flush()
+	}
+}
+
+func writeStreamEvent(w gin.ResponseWriter, message events.Message) {
+	fmt.Fprintf(w, "id: %d\nevent: %s\n", message.Sequence, message.Subject)
+	for _, line := range strings.Split(string(message.Data), "\n") {
+		fmt.Fprintf(w, "data: %s\n", line)
+	}
+	fmt.Fprint(w, "\n")
+}
@@ -0,0 +1,82 @@
+package apis
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"little-diary-measurement-service/src/common"
+	"little-diary-measurement-service/src/daos"
+	"little-diary-measurement-service/src/dto"
+	"little-diary-measurement-service/src/errors"
+	"little-diary-measurement-service/src/growth"
+	"little-diary-measurement-service/src/services"
+	"log"
+	"net/http"
+	"time"
+)
+
+// GetVelocity godoc
+// @Summary Computes growth velocity between consecutive measurements and over the requested window
+// @Description Rates are in g/day for weight and cm/month for lengths. When sex and birth date are given or known
+// @Description from the baby profile, weight intervals of 1 or 2 months and length intervals of 2 months (give or take a week)
+// @Description up to the second birthday are compared with the WHO growth velocity (increment) standards.
+// @Security ApiKeyAuth
+// @Produce json
+// @Param target-uuid query string true "Target UUID" format(uuid)
+// @Param type query string true "Measurement type" Enums(HEIGHT, WEIGHT, HEAD_CIRCUMFERENCE)
+// @Param from query string false "Window start, RFC3339 or date" format(datetime)
+// @Param to query string false "Window end, RFC3339 or date" format(datetime)
+// @Param age_from query integer false "Period start as age of the child, instead of from"
+// @Param age_to query integer false "Period end as age of the child including the whole day or month, instead of to"
+// @Param age_unit query string false "Unit of age bounds" Enums(days, months) default(days)
+// @Param sex query string false "Sex of the child" Enums(male, female)
+// @Param birth-date query string false "Birth date of the child" format(date)
+// @Success 200 {object} dto.VelocityResponse
+// @Router /measurements/velocity [get]
+func GetVelocity(c *gin.Context, locator *common.ServiceLocator) {
+	s := services.NewVelocityService(daos.NewMeasurementDAO(), locator)
+	uuid := c.Query("target-uuid")
+	userUuid := c.GetString("UserUuid")
+	filter, err := parseMeasurementFilter(c, uuid, time.UTC, false)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := requireSingleType(filter); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	options, err := parseVelocityOptions(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if velocity, err := s.GetVelocity(filter, options, userUuid); err != nil {
+		if _, ok := err.(*errors.ForbiddenError); ok {
+			c.AbortWithStatus(http.StatusForbidden)
+		} else if _, ok := err.(*errors.ValidationError); ok {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		} else {
+			c.AbortWithStatus(http.StatusInternalServerError)
+			log.Println(err)
+		}
+	} else {
+		c.JSON(http.StatusOK, dto.VelocityResponseFromModel(velocity))
+	}
+}
+
+func parseVelocityOptions(c *gin.Context) (services.VelocityOptions, error) {
+	var options services.VelocityOptions
+	if c.Query("sex") == "" && c.Query("birth-date") == "" {
+		return options, nil
+	}
+	var err error
+	if options.Sex, err = growth.ParseSex(c.Query("sex")); err != nil {
+		return options, err
+	}
+	birthDate, err := time.Parse("2006-01-02", c.Query("birth-date"))
+	if err != nil {
+		return options, fmt.Errorf("birth-date has invalid date %s", c.Query("birth-date"))
+	}
+	options.BirthDate = &birthDate
+	return options, nil
+}
@@ -0,0 +1,179 @@
+package apis
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"little-diary-measurement-service/src/common"
+	"little-diary-measurement-service/src/daos"
+	"little-diary-measurement-service/src/dto"
+	"little-diary-measurement-service/src/errors"
+	"little-diary-measurement-service/src/services"
+	"log"
+	"net/http"
+)
+
+// CreateWebhook godoc
+// @Summary Subscribes a url to measurement events of the target
+// @Description Events are POSTed as JSON with X-Webhook-Id, X-Webhook-Delivery, X-Webhook-Event and X-Webhook-Timestamp headers.
+// @Description X-Webhook-Signature is sha256= followed by hex encoded HMAC-SHA256 of the timestamp, a dot and the body keyed by the secret.
+// @Description Failed deliveries are retried 8 times with exponential backoff, the webhook is disabled after 5 failed deliveries in a row.
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param webhook body dto.WebhookRequest true "Webhook"
+// @Success 201 {object} dto.WebhookResponse
+// @Router /webhooks [post]
+func CreateWebhook(c *gin.Context, locator *common.ServiceLocator) {
+	s := services.NewWebhookService(daos.NewWebhookDAO(), locator)
+	userUuid := c.GetString("UserUuid")
+	var requestDto dto.WebhookRequest
+	if err := c.BindJSON(&requestDto); err != nil {
+		log.Println(err)
+		return
+	}
+	if webhook, err := s.Create(requestDto, userUuid); err != nil {
+		abortWebhookError(c, err)
+	} else {
+		response := dto.WebhookResponseFromModel(webhook)
+		response.Secret = webhook.Secret
+		c.JSON(http.StatusCreated, response)
+	}
+}
+
+// GetWebhooksByTarget godoc
+// @Summary Retrieves webhooks of the target
+// @Security ApiKeyAuth
+// @Produce json
+// @Param target-uuid query string true "Target UUID" format(uuid)
+// @Success 200 {array} dto.WebhookResponse
+// @Router /webhooks [get]
+func GetWebhooksByTarget(c *gin.Context, locator *common.ServiceLocator) {
+	s := services.NewWebhookService(daos.NewWebhookDAO(), locator)
+	uuid := c.Query("target-uuid")
+	userUuid := c.GetString("UserUuid")
+	if webhooks, err := s.GetByTargetUuid(uuid, userUuid); err != nil {
+		abortWebhookError(c, err)
+	} else {
+		dtos := []*dto.WebhookResponse{}
+		for _, w := range webhooks {
+			dtos = append(dtos, dto.WebhookResponseFromModel(w))
+		}
+		c.JSON(http.StatusOK, dtos)
+	}
+}
+
+// GetWebhook godoc
+// @Summary Retrieves webhook based on given UUID
+// @Security ApiKeyAuth
+// @Produce json
+// @Param uuid path string true "Webhook UUID" format(uuid)
+// @Success 200 {object} dto.WebhookResponse
+// @Router /webhooks/{uuid} [get]
+func GetWebhook(c *gin.Context, locator *common.ServiceLocator) {
+	s := services.NewWebhookService(daos.NewWebhookDAO(), locator)
+	uuid := c.Param("uuid")
+	userUuid := c.GetString("UserUuid")
+	if webhook, err := s.Get(uuid, userUuid); err != nil {
+		abortWebhookError(c, err)
+	} else {
+		c.JSON(http.StatusOK, dto.WebhookResponseFromModel(webhook))
+	}
+}
+
+// UpdateWebhook godoc
+// @Summary Changes url, event types or state of the webhook
+// @Description Enabling a disabled webhook clears its failures and resumes its pending deliveries. Target of the webhook does not change.
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param uuid path string true "Webhook UUID" format(uuid)
+// @Param webhook body dto.WebhookRequest true "Webhook"
+// @Success 200 {object} dto.WebhookResponse
+// @Router /webhooks/{uuid} [put]
+func UpdateWebhook(c *gin.Context, locator *common.ServiceLocator) {
+	s := services.NewWebhookService(daos.NewWebhookDAO(), locator)
+	uuid := c.Param("uuid")
+	userUuid := c.GetString("UserUuid")
+	var requestDto dto.WebhookRequest
+	if err := c.BindJSON(&requestDto); err != nil {
+		log.Println(err)
+		return
+	}
+	if webhook, err := s.Update(uuid, requestDto, userUuid); err != nil {
+		abortWebhookError(c, err)
+	} else {
+		c.JSON(http.StatusOK, dto.WebhookResponseFromModel(webhook))
+	}
+}
+
+// DeleteWebhook godoc
+// @Summary Removes the webhook with its delivery log
+// @Security ApiKeyAuth
+// @Param uuid path string true "Webhook UUID" format(uuid)
+// @Success 204
+// @Router /webhooks/{uuid} [delete]
+func DeleteWebhook(c *gin.Context, locator *common.ServiceLocator) {
+	s := services.NewWebhookService(daos.NewWebhookDAO(), locator)
+	uuid := c.Param("uuid")
+	userUuid := c.GetString("UserUuid")
+	if err := s.Delete(uuid, userUuid); err != nil {
+		abortWebhookError(c, err)
+	} else {
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// GetWebhookDeliveries godoc
+// @Summary Retrieves the last 100 deliveries of the webhook, newest first
+// @Security ApiKeyAuth
+// @Produce json
+// @Param uuid path string true "Webhook UUID" format(uuid)
+// @Success 200 {array} dto.WebhookDeliveryResponse
+// @Router /webhooks/{uuid}/deliveries [get]
+func GetWebhookDeliveries(c *gin.Context, locator *common.ServiceLocator) {
+	s := services.NewWebhookService(daos.NewWebhookDAO(), locator)
+	uuid := c.Param("uuid")
+	userUuid := c.GetString("UserUuid")
+	if deliveries, err := s.GetDeliveries(uuid, userUuid); err != nil {
+		abortWebhookError(c, err)
+	} else {
+		dtos := []*dto.WebhookDeliveryResponse{}
+		for _, d := range deliveries {
+			dtos = append(dtos, dto.WebhookDeliveryResponseFromModel(d))
+		}
+		c.JSON(http.StatusOK, dtos)
+	}
+}
+
+// RedeliverWebhook godoc
+// @Summary Queues the delivery again with a fresh set of attempts
+// @Security ApiKeyAuth
+// @Produce json
+// @Param uuid path string true "Webhook UUID" format(uuid)
+// @Param delivery path string true "Delivery UUID" format(uuid)
+// @Success 202 {object} dto.WebhookDeliveryResponse
+// @Router /webhooks/{uuid}/deliveries/{delivery}/redeliver [post]
+func RedeliverWebhook(c *gin.Context, locator *common.ServiceLocator) {
+	s := services.NewWebhookService(daos.NewWebhookDAO(), locator)
+	uuid := c.Param("uuid")
+	deliveryUuid := c.Param("delivery")
+	userUuid := c.GetString("UserUuid")
+	if delivery, err := s.Redeliver(uuid, deliveryUuid, userUuid); err != nil {
+		abortWebhookError(c, err)
+	} else {
+		c.JSON(http.StatusAccepted, dto.WebhookDeliveryResponseFromModel(delivery))
+	}
+}
+
+func abortWebhookError(c *gin.Context, err error) {
+	if _, ok := err.(*errors.ForbiddenError); ok {
+		c.AbortWithStatus(http.StatusForbidden)
+	} else if _, ok := err.(*errors.ValidationError); ok {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	} else if gorm.IsRecordNotFoundError(err) {
+		c.AbortWithStatus(http.StatusNotFound)
+	} else {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		log.Println(err)
+	}
+}
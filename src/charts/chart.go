@@ -0,0 +1,286 @@
+package charts
+
+import (
+	"math"
+)
+
+type Point struct {
+	X float64
+	Y float64
+}
+
+type Color struct {
+	R, G, B uint8
+}
+
+type Align int
+
+const (
+	AlignLeft Align = iota
+	AlignCenter
+	AlignRight
+)
+
+type TextStyle struct {
+	Size  float64
+	Bold  bool
+	Align Align
+	Color Color
+}
+
+// Canvas is implemented by output formats, coordinates start at the top left corner
+type Canvas interface {
+	Line(from Point, to Point, width float64, color Color)
+	Polyline(points []Point, width float64, color Color)
+	Rect(x, y, width, height float64, fill Color)
+	Polygon(points []Point, fill Color, opacity float64)
+	Text(at Point, style TextStyle, text string)
+}
+
+type Theme struct {
+	Background  Color
+	Axis        Color
+	Grid        Color
+	Text        Color
+	Palette     []Color
+	Band        Color
+	BandOpacity float64
+	Reference   Color
+}
+
+var LightTheme = Theme{
+	Background:  Color{255, 255, 255},
+	Axis:        Color{80, 80, 80},
+	Grid:        Color{225, 225, 225},
+	Text:        Color{40, 40, 40},
+	Palette:     []Color{{31, 119, 180}, {214, 39, 40}, {44, 160, 44}},
+	Band:        Color{76, 175, 80},
+	BandOpacity: 0.15,
+	Reference:   Color{56, 142, 60},
+}
+
+var DarkTheme = Theme{
+	Background:  Color{30, 32, 36},
+	Axis:        Color{170, 170, 170},
+	Grid:        Color{60, 63, 68},
+	Text:        Color{225, 225, 225},
+	Palette:     []Color{{100, 181, 246}, {239, 154, 154}, {165, 214, 167}},
+	Band:        Color{129, 199, 132},
+	BandOpacity: 0.18,
+	Reference:   Color{129, 199, 132},
+}
+
+type Axis struct {
+	Label string
+	// Ticks returns about count tick positions for the data range, NiceTicks is used when empty
+	Ticks  func(min, max float64, count int) []float64
+	Format func(value float64) string
+}
+
+type Series struct {
+	Name   string
+	Points []Point
+	Color  *Color
+}
+
+// Band is a filled area between two lines with the same X positions, e.g. between reference percentiles
+type Band struct {
+	Lower []Point
+	Upper []Point
+}
+
+type Chart struct {
+	Title  string
+	XAxis  Axis
+	YAxis  Axis
+	Series []Series
+	// Bands and Curves are reference data drawn below series, curves are labelled by name at the right end
+	Bands  []Band
+	Curves []Series
+	Theme  Theme
+}
+
+const (
+	titleSize      = 11
+	labelSize      = 8
+	plotPadding    = 6
+	leftMargin     = 46
+	bottomMargin   = 30
+	topMargin      = 20
+	rightMargin    = 10
+	curveMargin    = 22
+	curveWidth     = 0.75
+	seriesWidth    = 1.5
+	markerSize     = 3
+	xTickSpacing   = 55
+	yTickSpacing   = 25
+	emptyChartText = "No data"
+)
+
+// Draw renders the chart into the given box of the canvas
+func (c *Chart) Draw(canvas Canvas, x, y, width, height float64) {
+	theme := c.Theme
+	canvas.Rect(x, y, width, height, theme.Background)
+	canvas.Text(Point{x + width/2, y + titleSize + 2}, TextStyle{Size: titleSize, Bold: true, Align: AlignCenter, Color: theme.Text}, c.Title)
+
+	plotX := x + leftMargin
+	plotY := y + topMargin + plotPadding
+	plotWidth := width - leftMargin - rightMargin
+	if len(c.Curves) > 0 {
+		plotWidth -= curveMargin
+	}
+	plotHeight := height - topMargin - bottomMargin - plotPadding
+
+	minX, maxX, minY, maxY, ok := c.bounds()
+	if !ok {
+		canvas.Line(Point{plotX, plotY + plotHeight}, Point{plotX + plotWidth, plotY + plotHeight}, 1, theme.Axis)
+		canvas.Text(Point{plotX + plotWidth/2, plotY + plotHeight/2}, TextStyle{Size: labelSize, Align: AlignCenter, Color: theme.Text}, emptyChartText)
+		return
+	}
+
+	xTicks := ticks(c.XAxis, minX, maxX, int(plotWidth/xTickSpacing)+1)
+	yTicks := ticks(c.YAxis, minY, maxY, int(plotHeight/yTickSpacing)+1)
+	minX, maxX = extend(minX, maxX, xTicks)
+	minY, maxY = extend(minY, maxY, yTicks)
+
+	project := func(p Point) Point {
+		return Point{
+			X: plotX + (p.X-minX)/(maxX-minX)*plotWidth,
+			Y: plotY + plotHeight - (p.Y-minY)/(maxY-minY)*plotHeight,
+		}
+	}
+
+	tickStyle := TextStyle{Size: labelSize, Align: AlignCenter, Color: theme.Text}
+	for _, tick := range xTicks {
+		p := project(Point{tick, minY})
+		canvas.Line(Point{p.X, plotY}, Point{p.X, plotY + plotHeight}, 0.5, theme.Grid)
+		canvas.Text(Point{p.X, plotY + plotHeight + labelSize + 3}, tickStyle, format(c.XAxis, tick))
+	}
+	tickStyle.Align = AlignRight
+	for _, tick := range yTicks {
+		p := project(Point{minX, tick})
+		canvas.Line(Point{plotX, p.Y}, Point{plotX + plotWidth, p.Y}, 0.5, theme.Grid)
+		canvas.Text(Point{plotX - 4, p.Y + labelSize/3}, tickStyle, format(c.YAxis, tick))
+	}
+	projectAll := func(points []Point) []Point {
+		projected := make([]Point, len(points))
+		for j, p := range points {
+			projected[j] = project(p)
+		}
+		return projected
+	}
+	for _, band := range c.Bands {
+		outline := projectAll(band.Lower)
+		upper := projectAll(band.Upper)
+		for j := len(upper) - 1; j >= 0; j-- {
+			outline = append(outline, upper[j])
+		}
+		canvas.Polygon(outline, theme.Band, theme.BandOpacity)
+	}
+	for i, curve := range c.Curves {
+		color := theme.Reference
+		if curve.Color != nil {
+			color = *curve.Color
+		}
+		projected := projectAll(curve.Points)
+		canvas.Polyline(projected, curveWidth, color)
+		if len(projected) > 0 {
+			last := projected[len(projected)-1]
+			canvas.Text(Point{last.X + 3, last.Y + labelSize/3}, TextStyle{Size: labelSize - 1, Color: color}, c.Curves[i].Name)
+		}
+	}
+
+	canvas.Line(Point{plotX, plotY}, Point{plotX, plotY + plotHeight}, 1, theme.Axis)
+	canvas.Line(Point{plotX, plotY + plotHeight}, Point{plotX + plotWidth, plotY + plotHeight}, 1, theme.Axis)
+
+	labelStyle := TextStyle{Size: labelSize, Align: AlignCenter, Color: theme.Text}
+	canvas.Text(Point{plotX + plotWidth/2, y + height - 4}, labelStyle, c.XAxis.Label)
+	labelStyle.Align = AlignLeft
+	canvas.Text(Point{x + 2, y + topMargin}, labelStyle, c.YAxis.Label)
+
+	for i, series := range c.Series {
+		color := c.seriesColor(i)
+		projected := projectAll(series.Points)
+		canvas.Polyline(projected, seriesWidth, color)
+		for _, p := range projected {
+			canvas.Rect(p.X-markerSize/2, p.Y-markerSize/2, markerSize, markerSize, color)
+		}
+	}
+
+	if len(c.Series) > 1 {
+		legendX := plotX + 6
+		for i, series := range c.Series {
+			legendY := plotY + 4 + float64(i)*(labelSize+3)
+			canvas.Rect(legendX, legendY, 8, 3, c.seriesColor(i))
+			canvas.Text(Point{legendX + 11, legendY + 4}, TextStyle{Size: labelSize, Color: theme.Text}, series.Name)
+		}
+	}
+}
+
+func (c *Chart) seriesColor(i int) Color {
+	if c.Series[i].Color != nil {
+		return *c.Series[i].Color
+	}
+	if len(c.Theme.Palette) == 0 {
+		return c.Theme.Axis
+	}
+	return c.Theme.Palette[i%len(c.Theme.Palette)]
+}
+
+// bounds covers series and reference data, chart without series is treated as empty
+func (c *Chart) bounds() (minX, maxX, minY, maxY float64, ok bool) {
+	minX, minY = math.Inf(1), math.Inf(1)
+	maxX, maxY = math.Inf(-1), math.Inf(-1)
+	var lines [][]Point
+	for _, series := range c.Series {
+		if len(series.Points) > 0 {
+			ok = true
+		}
+		lines = append(lines, series.Points)
+	}
+	if !ok {
+		return
+	}
+	for _, band := range c.Bands {
+		lines = append(lines, band.Lower, band.Upper)
+	}
+	for _, curve := range c.Curves {
+		lines = append(lines, curve.Points)
+	}
+	for _, points := range lines {
+		for _, p := range points {
+			minX, maxX = math.Min(minX, p.X), math.Max(maxX, p.X)
+			minY, maxY = math.Min(minY, p.Y), math.Max(maxY, p.Y)
+		}
+	}
+	if maxX == minX {
+		minX, maxX = minX-1, maxX+1
+	}
+	if maxY == minY {
+		minY, maxY = minY-1, maxY+1
+	}
+	margin := (maxY - minY) * 0.05
+	return minX, maxX, minY - margin, maxY + margin, true
+}
+
+func ticks(axis Axis, min, max float64, count int) []float64 {
+	if axis.Ticks != nil {
+		return axis.Ticks(min, max, count)
+	}
+	return NiceTicks(min, max, count)
+}
+
+func format(axis Axis, value float64) string {
+	if axis.Format != nil {
+		return axis.Format(value)
+	}
+	return FormatNumber(value)
+}
+
+// extend widens the range to include all ticks so the plot starts and ends on grid lines
+func extend(min, max float64, ticks []float64) (float64, float64) {
+	for _, tick := range ticks {
+		min, max = math.Min(min, tick), math.Max(max, tick)
+	}
+	return min, max
+}
@@ -0,0 +1,135 @@
+package charts
+
+import (
+	"bytes"
+	"encoding/xml"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+type recordingCanvas struct {
+	lines     int
+	polylines [][]Point
+	polygons  [][]Point
+	rects     int
+	texts     []string
+}
+
+func (r *recordingCanvas) Line(from Point, to Point, width float64, color Color) {
+	r.lines++
+}
+
+func (r *recordingCanvas) Polyline(points []Point, width float64, color Color) {
+	r.polylines = append(r.polylines, points)
+}
+
+func (r *recordingCanvas) Rect(x, y, width, height float64, fill Color) {
+	r.rects++
+}
+
+func (r *recordingCanvas) Polygon(points []Point, fill Color, opacity float64) {
+	r.polygons = append(r.polygons, points)
+}
+
+func (r *recordingCanvas) Text(at Point, style TextStyle, text string) {
+	r.texts = append(r.texts, text)
+}
+
+func TestNiceTicks(t *testing.T) {
+	assert.Equal(t, []float64{0, 20, 40, 60, 80, 100}, NiceTicks(3, 97, 6))
+	assert.Equal(t, []float64{3, 3.5, 4, 4.5, 5}, NiceTicks(3.1, 4.9, 6))
+	assert.Equal(t, []float64{7}, NiceTicks(7, 7, 6))
+}
+
+func TestDayTicks(t *testing.T) {
+	assert.Equal(t, []float64{0, 7, 14, 21}, DayTicks(1, 20, 6))
+
+	from := DaysSinceEpoch(time.Date(2020, 1, 10, 0, 0, 0, 0, time.UTC))
+	to := DaysSinceEpoch(time.Date(2020, 10, 20, 0, 0, 0, 0, time.UTC))
+	var labels []string
+	for _, tick := range DayTicks(from, to, 5) {
+		labels = append(labels, FormatDay(tick))
+	}
+	assert.Equal(t, []string{"Jan 20", "Apr 20", "Jul 20", "Oct 20", "Jan 21"}, labels)
+}
+
+func TestFormat(t *testing.T) {
+	assert.Equal(t, "0.3", FormatNumber(0.1*3))
+	assert.Equal(t, "15", FormatNumber(15))
+	day := DaysSinceEpoch(time.Date(2020, 4, 1, 0, 0, 0, 0, time.UTC))
+	assert.Equal(t, "Apr 20", FormatDay(day))
+	assert.Equal(t, "02 Apr 20", FormatDay(day+1))
+}
+
+func TestChartDraw(t *testing.T) {
+	chart := Chart{
+		Title: "Weight",
+		XAxis: Axis{Label: "Date"},
+		YAxis: Axis{Label: "kg"},
+		Series: []Series{
+			{Name: "first", Points: []Point{{0, 3.5}, {30, 4.2}, {60, 5.1}}},
+			{Name: "second", Points: []Point{{0, 3.2}, {60, 4.8}}},
+		},
+		Theme: LightTheme,
+	}
+	canvas := &recordingCanvas{}
+	chart.Draw(canvas, 0, 0, 300, 200)
+
+	if assert.Len(t, canvas.polylines, 2) {
+		for _, p := range canvas.polylines[0] {
+			assert.True(t, p.X >= 0 && p.X <= 300 && p.Y >= 0 && p.Y <= 200, "point %v is outside of the chart", p)
+		}
+		assert.True(t, canvas.polylines[0][0].Y > canvas.polylines[0][2].Y, "larger values must be drawn higher")
+	}
+	assert.Contains(t, canvas.texts, "Weight")
+	assert.Contains(t, canvas.texts, "first")
+	assert.Contains(t, canvas.texts, "second")
+}
+
+func TestChartDrawEmpty(t *testing.T) {
+	chart := Chart{Title: "Height", Theme: LightTheme}
+	canvas := &recordingCanvas{}
+	chart.Draw(canvas, 0, 0, 300, 200)
+
+	assert.Len(t, canvas.polylines, 0)
+	assert.Contains(t, canvas.texts, emptyChartText)
+}
+
+func TestChartDrawReference(t *testing.T) {
+	chart := Chart{
+		Series: []Series{{Name: "child", Points: []Point{{1, 4}, {2, 5}}}},
+		Bands:  []Band{{Lower: []Point{{0, 2}, {3, 5}}, Upper: []Point{{0, 4}, {3, 8}}}},
+		Curves: []Series{{Name: "50", Points: []Point{{0, 3}, {3, 6.5}}}},
+		Theme:  DarkTheme,
+	}
+	canvas := &recordingCanvas{}
+	chart.Draw(canvas, 0, 0, 300, 200)
+
+	if assert.Len(t, canvas.polygons, 1) {
+		assert.Len(t, canvas.polygons[0], 4)
+	}
+	// curve is drawn before the series
+	if assert.Len(t, canvas.polylines, 2) {
+		assert.Len(t, canvas.polylines[0], 2)
+		assert.True(t, canvas.polylines[0][0].X < canvas.polylines[1][0].X, "reference starts before the series")
+	}
+	assert.Contains(t, canvas.texts, "50")
+}
+
+func TestSvgCanvas(t *testing.T) {
+	canvas := NewSvgCanvas(200, 100)
+	canvas.Text(Point{10, 20}, TextStyle{Size: 8, Align: AlignCenter, Color: Color{255, 0, 0}}, "a < b & c")
+	canvas.Polygon([]Point{{0, 0}, {10, 0}, {10, 10.123}}, Color{0, 128, 0}, 0.2)
+	canvas.Polyline([]Point{{0, 0}, {1, 1}}, 1.5, Color{})
+
+	var out bytes.Buffer
+	assert.Nil(t, canvas.Write(&out))
+	svg := out.String()
+	assert.True(t, strings.HasPrefix(svg, `<svg xmlns="http://www.w3.org/2000/svg" width="200" height="100"`))
+	assert.Contains(t, svg, `text-anchor="middle" fill="#ff0000">a &lt; b &amp; c</text>`)
+	assert.Contains(t, svg, `points="0,0 10,0 10,10.12" fill="#008000" fill-opacity="0.2"`)
+	assert.Contains(t, svg, `stroke="#000000" stroke-width="1.5"`)
+	assert.Nil(t, xml.Unmarshal(out.Bytes(), new(interface{})))
+}
@@ -0,0 +1,92 @@
+package charts
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// SvgCanvas collects chart elements into SVG document of fixed size
+type SvgCanvas struct {
+	Width  float64
+	Height float64
+	body   bytes.Buffer
+}
+
+func NewSvgCanvas(width, height float64) *SvgCanvas {
+	return &SvgCanvas{Width: width, Height: height}
+}
+
+func (s *SvgCanvas) Line(from Point, to Point, width float64, color Color) {
+	fmt.Fprintf(&s.body, `<line x1="%s" y1="%s" x2="%s" y2="%s" stroke="%s" stroke-width="%s"/>`+"\n",
+		svgNumber(from.X), svgNumber(from.Y), svgNumber(to.X), svgNumber(to.Y), hex(color), svgNumber(width))
+}
+
+func (s *SvgCanvas) Polyline(points []Point, width float64, color Color) {
+	if len(points) < 2 {
+		return
+	}
+	fmt.Fprintf(&s.body, `<polyline points="%s" fill="none" stroke="%s" stroke-width="%s" stroke-linejoin="round"/>`+"\n",
+		svgPoints(points), hex(color), svgNumber(width))
+}
+
+func (s *SvgCanvas) Rect(x, y, width, height float64, fill Color) {
+	fmt.Fprintf(&s.body, `<rect x="%s" y="%s" width="%s" height="%s" fill="%s"/>`+"\n",
+		svgNumber(x), svgNumber(y), svgNumber(width), svgNumber(height), hex(fill))
+}
+
+func (s *SvgCanvas) Polygon(points []Point, fill Color, opacity float64) {
+	if len(points) < 3 {
+		return
+	}
+	fmt.Fprintf(&s.body, `<polygon points="%s" fill="%s" fill-opacity="%s" stroke="none"/>`+"\n",
+		svgPoints(points), hex(fill), svgNumber(opacity))
+}
+
+func (s *SvgCanvas) Text(at Point, style TextStyle, text string) {
+	if text == "" {
+		return
+	}
+	anchor := "start"
+	switch style.Align {
+	case AlignCenter:
+		anchor = "middle"
+	case AlignRight:
+		anchor = "end"
+	}
+	weight := "normal"
+	if style.Bold {
+		weight = "bold"
+	}
+	var escaped bytes.Buffer
+	_ = xml.EscapeText(&escaped, []byte(text))
+	fmt.Fprintf(&s.body, `<text x="%s" y="%s" font-size="%s" font-weight="%s" text-anchor="%s" fill="%s">%s</text>`+"\n",
+		svgNumber(at.X), svgNumber(at.Y), svgNumber(style.Size), weight, anchor, hex(style.Color), escaped.String())
+}
+
+func (s *SvgCanvas) Write(w io.Writer) error {
+	_, err := fmt.Fprintf(w,
+		`<svg xmlns="http://www.w3.org/2000/svg" width="%s" height="%s" viewBox="0 0 %s %s" font-family="Helvetica, Arial, sans-serif">`+"\n%s</svg>\n",
+		svgNumber(s.Width), svgNumber(s.Height), svgNumber(s.Width), svgNumber(s.Height), s.body.String())
+	return err
+}
+
+func svgPoints(points []Point) string {
+	values := make([]string, len(points))
+	for i, p := range points {
+		values[i] = svgNumber(p.X) + "," + svgNumber(p.Y)
+	}
+	return strings.Join(values, " ")
+}
+
+func svgNumber(v float64) string {
+	return strconv.FormatFloat(math.Round(v*100)/100, 'f', -1, 64)
+}
+
+func hex(c Color) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
@@ -0,0 +1,94 @@
+package charts
+
+import (
+	"math"
+	"strconv"
+	"time"
+)
+
+const secondsInDay = 24 * 60 * 60
+
+var dayTickSteps = []float64{1, 2, 7, 14}
+var monthTickSteps = []int{1, 2, 3, 6, 12, 24, 60}
+
+const daysInMonth = 30.44
+
+// NiceTicks returns about count round ticks (1, 2, 5 multiplied by power of ten) covering the range
+func NiceTicks(min, max float64, count int) []float64 {
+	if max <= min || count < 2 {
+		return []float64{min}
+	}
+	rawStep := (max - min) / float64(count-1)
+	magnitude := math.Pow(10, math.Floor(math.Log10(rawStep)))
+	step := magnitude * 10
+	for _, m := range []float64{1, 2, 5, 10} {
+		if rawStep <= m*magnitude {
+			step = m * magnitude
+			break
+		}
+	}
+	return stepTicks(min, max, step)
+}
+
+// DayTicks works with axis in days and prefers calendar steps: days, weeks and whole months
+func DayTicks(min, max float64, count int) []float64 {
+	if count < 2 {
+		count = 2
+	}
+	for _, step := range dayTickSteps {
+		if (max-min)/step <= float64(count-1) {
+			return stepTicks(min, max, step)
+		}
+	}
+	months := monthTickSteps[len(monthTickSteps)-1]
+	for _, m := range monthTickSteps {
+		if (max-min)/(float64(m)*daysInMonth) <= float64(count-1) {
+			months = m
+			break
+		}
+	}
+	start := dayToTime(min)
+	monthIndex := start.Year()*12 + int(start.Month()) - 1
+	monthIndex -= monthIndex % months
+	tick := time.Date(monthIndex/12, time.Month(monthIndex%12+1), 1, 0, 0, 0, 0, time.UTC)
+	var result []float64
+	for {
+		result = append(result, DaysSinceEpoch(tick))
+		if DaysSinceEpoch(tick) >= max {
+			return result
+		}
+		tick = tick.AddDate(0, months, 0)
+	}
+}
+
+func stepTicks(min, max, step float64) []float64 {
+	var result []float64
+	first := math.Floor(min/step) * step
+	last := math.Ceil(max/step) * step
+	for v := first; v <= last+step/2; v += step {
+		result = append(result, math.Round(v/step)*step)
+	}
+	return result
+}
+
+// DaysSinceEpoch converts time to value of a day axis
+func DaysSinceEpoch(t time.Time) float64 {
+	return float64(t.Unix()) / secondsInDay
+}
+
+// FormatDay prints day axis value, first days of months are printed as month only
+func FormatDay(value float64) string {
+	t := dayToTime(value)
+	if t.Day() == 1 {
+		return t.Format("Jan 06")
+	}
+	return t.Format("02 Jan 06")
+}
+
+func dayToTime(value float64) time.Time {
+	return time.Unix(int64(math.Round(value*secondsInDay)), 0).UTC()
+}
+
+func FormatNumber(value float64) string {
+	return strconv.FormatFloat(math.Round(value*1e6)/1e6, 'f', -1, 64)
+}
@@ -1,8 +1,22 @@
 package common
 
-import "little-diary-measurement-service/src/integrations"
+import (
+	"little-diary-measurement-service/src/events"
+	"little-diary-measurement-service/src/integrations"
+)
 
 type ServiceLocator struct {
-	PublicKeyGetter            integrations.AuthServerJwtPublicKeyGetter
-	UserHasAccessToBabyChecker integrations.UserHasAccessToBabyChecker
+	PublicKeyGetter integrations.AuthServerJwtPublicKeyGetter
+	Authorizer      integrations.Authorizer
+	// optional, age aware outputs need birth date and sex from it
+	BabyProfileGetter        integrations.BabyProfileGetter
+	TargetLister             integrations.TargetLister
+	FamilyEventsApiKeyGetter integrations.FamilyEventsApiKeyGetter
+	ErasureApiKeyGetter      integrations.ErasureApiKeyGetter
+	Hl7ApiKeyGetter          integrations.Hl7ApiKeyGetter
+	PercentileCrossingConfig integrations.PercentileCrossingConfig
+	// optional, live measurement streams subscribe to it
+	StreamHub    *events.Hub
+	StreamConfig integrations.StreamConfig
+	ExportConfig integrations.ExportConfig
 }
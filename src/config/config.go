@@ -5,21 +5,58 @@ import (
 	"github.com/jinzhu/gorm"
 	"github.com/spf13/viper"
 	"strings"
+	"time"
 )
 
 var Config appConfig
 
 type appConfig struct {
-	DB                     *gorm.DB
-	DBErr                  error
-	ServerPort             int    `mapstructure:"server_port"`
-	DSN                    string `mapstructure:"dsn"`
-	AuthServerUrl          string `mapstructure:"auth_server_url"`
-	FamilyServerUrl        string `mapstructure:"family_server_url"`
-	AuthServerLoginPath    string `mapstructure:"auth_server_login_path"`
-	AuthServerUsername     string `mapstructure:"auth_server_username"`
-	AuthServerPassword     string `mapstructure:"auth_server_password"`
-	AuthServerJwtPublicKey string `mapstructure:"auth_server_jwt_public"`
+	DB                      *gorm.DB
+	DBErr                   error
+	ServerPort              int    `mapstructure:"server_port"`
+	DSN                     string `mapstructure:"dsn"`
+	AuthServerUrl           string `mapstructure:"auth_server_url"`
+	FamilyServerUrl         string `mapstructure:"family_server_url"`
+	AuthServerLoginPath     string `mapstructure:"auth_server_login_path"`
+	AuthServerUsername      string `mapstructure:"auth_server_username"`
+	AuthServerPassword      string `mapstructure:"auth_server_password"`
+	AuthServerJwtPublicKey  string `mapstructure:"auth_server_jwt_public"`
+	Hl7ApiKey               string `mapstructure:"hl7_api_key"`
+	Hl7MllpPort             int    `mapstructure:"hl7_mllp_port"`
+	FamilyEventsApiKey      string `mapstructure:"family_events_api_key"`
+	ErasureApiKey           string `mapstructure:"erasure_api_key"`
+	BabyProfileCacheMinutes int    `mapstructure:"baby_profile_cache_minutes"`
+	BabyProfileCacheSize    int    `mapstructure:"baby_profile_cache_size"`
+	// access grants are copied from the family server that often, zero turns the sync off
+	AccessGrantSyncIntervalMinutes int `mapstructure:"access_grant_sync_interval_minutes"`
+	// downward crossing of that many major percentile lines within any of the windows raises an alert
+	PercentileCrossingLines      int   `mapstructure:"percentile_crossing_lines"`
+	PercentileCrossingWindowDays []int `mapstructure:"percentile_crossing_window_days"`
+	// the outbox relay always runs and delivers measurement events to webhooks, with nats they are also
+	// published to the broker. Empty leaves webhooks only, the memory publisher is refused outside tests
+	EventPublisher string `mapstructure:"event_publisher"`
+	// nats publishes to JetStream, a stream has to capture the event_subject.> subjects
+	NatsUrl                        string `mapstructure:"nats_url"`
+	EventSubject                   string `mapstructure:"event_subject"`
+	OutboxRelayIntervalSeconds     int    `mapstructure:"outbox_relay_interval_seconds"`
+	WebhookDeliveryIntervalSeconds int    `mapstructure:"webhook_delivery_interval_seconds"`
+	WebhookTimeoutSeconds          int    `mapstructure:"webhook_timeout_seconds"`
+	// live streams send a heartbeat that often and are closed after the max duration, clients reconnect then
+	StreamHeartbeatSeconds   int `mapstructure:"stream_heartbeat_seconds"`
+	StreamMaxDurationMinutes int `mapstructure:"stream_max_duration_minutes"`
+	StreamConnectionsPerUser int `mapstructure:"stream_connections_per_user"`
+	// download links of exports are signed with the secret and work for the link TTL, archives are kept for the retention
+	ExportLinkSecret      string `mapstructure:"export_link_secret"`
+	ExportLinkMinutes     int    `mapstructure:"export_link_minutes"`
+	ExportRetentionHours  int    `mapstructure:"export_retention_hours"`
+	ExportIntervalSeconds int    `mapstructure:"export_interval_seconds"`
+	// CSV files with published reference LMS values, see growth.LoadFenton, growth.LoadWeightForLength,
+	// growth.LoadBMIForAge and growth.LoadVelocity. Preterm babies before 50 weeks and derived values have
+	// no percentiles and velocities are not compared without them
+	FentonLmsPath          string `mapstructure:"fenton_lms_path"`
+	WhoWeightForLengthPath string `mapstructure:"who_weight_for_length_path"`
+	WhoBMIForAgePath       string `mapstructure:"who_bmi_for_age_path"`
+	WhoVelocityPath        string `mapstructure:"who_velocity_path"`
 }
 
 func (a *appConfig) GetAuthServerUrl() string {
@@ -46,6 +83,78 @@ func (a *appConfig) GetFamilyServerUrl() string {
 	return a.FamilyServerUrl
 }
 
+func (a *appConfig) GetHl7ApiKey() string {
+	return a.Hl7ApiKey
+}
+
+func (a *appConfig) GetFamilyEventsApiKey() string {
+	return a.FamilyEventsApiKey
+}
+
+func (a *appConfig) GetErasureApiKey() string {
+	return a.ErasureApiKey
+}
+
+func (a *appConfig) GetBabyProfileCacheTTL() time.Duration {
+	return time.Duration(a.BabyProfileCacheMinutes) * time.Minute
+}
+
+func (a *appConfig) GetAccessGrantSyncInterval() time.Duration {
+	return time.Duration(a.AccessGrantSyncIntervalMinutes) * time.Minute
+}
+
+func (a *appConfig) GetPercentileCrossingLines() int {
+	return a.PercentileCrossingLines
+}
+
+func (a *appConfig) GetPercentileCrossingWindows() []time.Duration {
+	windows := make([]time.Duration, len(a.PercentileCrossingWindowDays))
+	for i, days := range a.PercentileCrossingWindowDays {
+		windows[i] = time.Duration(days) * 24 * time.Hour
+	}
+	return windows
+}
+
+func (a *appConfig) GetOutboxRelayInterval() time.Duration {
+	return time.Duration(a.OutboxRelayIntervalSeconds) * time.Second
+}
+
+func (a *appConfig) GetWebhookDeliveryInterval() time.Duration {
+	return time.Duration(a.WebhookDeliveryIntervalSeconds) * time.Second
+}
+
+func (a *appConfig) GetWebhookTimeout() time.Duration {
+	return time.Duration(a.WebhookTimeoutSeconds) * time.Second
+}
+
+func (a *appConfig) GetStreamHeartbeat() time.Duration {
+	return time.Duration(a.StreamHeartbeatSeconds) * time.Second
+}
+
+func (a *appConfig) GetStreamMaxDuration() time.Duration {
+	return time.Duration(a.StreamMaxDurationMinutes) * time.Minute
+}
+
+func (a *appConfig) GetStreamConnectionsPerUser() int {
+	return a.StreamConnectionsPerUser
+}
+
+func (a *appConfig) GetExportLinkSecret() string {
+	return a.ExportLinkSecret
+}
+
+func (a *appConfig) GetExportLinkTTL() time.Duration {
+	return time.Duration(a.ExportLinkMinutes) * time.Minute
+}
+
+func (a *appConfig) GetExportRetention() time.Duration {
+	return time.Duration(a.ExportRetentionHours) * time.Hour
+}
+
+func (a *appConfig) GetExportInterval() time.Duration {
+	return time.Duration(a.ExportIntervalSeconds) * time.Second
+}
+
 func LoadConfig(configPaths ...string) error {
 	v := viper.New()
 	v.SetConfigName("server")
@@ -54,6 +163,21 @@ func LoadConfig(configPaths ...string) error {
 	v.AutomaticEnv()
 
 	v.SetDefault("server_port", 8080)
+	v.SetDefault("access_grant_sync_interval_minutes", 60)
+	v.SetDefault("baby_profile_cache_minutes", 60)
+	v.SetDefault("baby_profile_cache_size", 10000)
+	v.SetDefault("percentile_crossing_lines", 2)
+	v.SetDefault("percentile_crossing_window_days", []int{30, 90, 180})
+	v.SetDefault("event_subject", "measurements")
+	v.SetDefault("outbox_relay_interval_seconds", 5)
+	v.SetDefault("webhook_delivery_interval_seconds", 10)
+	v.SetDefault("webhook_timeout_seconds", 10)
+	v.SetDefault("stream_heartbeat_seconds", 15)
+	v.SetDefault("stream_max_duration_minutes", 60)
+	v.SetDefault("stream_connections_per_user", 5)
+	v.SetDefault("export_link_minutes", 15)
+	v.SetDefault("export_retention_hours", 168)
+	v.SetDefault("export_interval_seconds", 10)
 
 	for _, path := range configPaths {
 		v.AddConfigPath(path)
@@ -0,0 +1,68 @@
+package daos
+
+import (
+	"little-diary-measurement-service/src/config"
+	"little-diary-measurement-service/src/models"
+	"time"
+)
+
+type AccessGrantDAO struct{}
+
+func NewAccessGrantDAO() *AccessGrantDAO {
+	return &AccessGrantDAO{}
+}
+
+func (dao *AccessGrantDAO) GetGrant(userUuid string, targetUuid models.TargetUUID) (*models.AccessGrant, error) {
+	var grant models.AccessGrant
+
+	err := config.Config.DB.
+		Where("user_uuid = ? AND target_uuid = ?", userUuid, targetUuid).
+		First(&grant).
+		Error
+
+	return &grant, err
+}
+
+func (dao *AccessGrantDAO) GetGrants(userUuid string, targetUuids []models.TargetUUID) ([]*models.AccessGrant, error) {
+	var grants []*models.AccessGrant
+	if len(targetUuids) == 0 {
+		return grants, nil
+	}
+	err := config.Config.DB.
+		Where("user_uuid = ? AND target_uuid IN (?)", userUuid, targetUuids).
+		Find(&grants).
+		Error
+	return grants, err
+}
+
+// ApplyGrant stores the grant unless a newer version is stored already, returns whether it was stored
+func (dao *AccessGrantDAO) ApplyGrant(grant *models.AccessGrant) (bool, error) {
+	now := time.Now()
+	result := config.Config.DB.Exec(`
+INSERT INTO access_grants (created_at, updated_at, user_uuid, target_uuid, permission, version)
+VALUES (?, ?, ?, ?, ?, ?)
+ON CONFLICT (user_uuid, target_uuid) DO UPDATE
+SET updated_at = EXCLUDED.updated_at, permission = EXCLUDED.permission, version = EXCLUDED.version
+WHERE access_grants.version < EXCLUDED.version`,
+		now, now, grant.UserUuid, grant.TargetUuid, grant.Permission, grant.Version)
+	return result.RowsAffected > 0, result.Error
+}
+
+// GetGrantedBefore returns grants with any permission that were last changed before the time
+func (dao *AccessGrantDAO) GetGrantedBefore(before time.Time) ([]*models.AccessGrant, error) {
+	var grants []*models.AccessGrant
+	err := config.Config.DB.
+		Where("permission <> '' AND updated_at < ?", before).
+		Find(&grants).
+		Error
+	return grants, err
+}
+
+// RevokeGrant removes all permissions of the grant unless it has changed since the time, returns whether it was revoked
+func (dao *AccessGrantDAO) RevokeGrant(grant *models.AccessGrant, unchangedSince time.Time) (bool, error) {
+	result := config.Config.DB.Exec(`
+UPDATE access_grants SET permission = '', updated_at = ?
+WHERE id = ? AND version = ? AND updated_at < ?`,
+		time.Now(), grant.ID, grant.Version, unchangedSince)
+	return result.RowsAffected > 0, result.Error
+}
@@ -0,0 +1,84 @@
+package daos
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"little-diary-measurement-service/src/models"
+	"little-diary-measurement-service/src/test_data"
+	"testing"
+	"time"
+)
+
+func TestAccessGrantDAO_ApplyGrant(t *testing.T) {
+	tx := test_data.OnBeforeDBTest()
+	defer test_data.OnAfterDBTest(tx)
+
+	userUuid := fmt.Sprintf("%s", uuid.New())
+	targetUuid := models.TargetUUID(fmt.Sprintf("%s", uuid.New()))
+	grant := func(version int64, permissions ...models.Permission) *models.AccessGrant {
+		g := &models.AccessGrant{UserUuid: userUuid, TargetUuid: targetUuid, Version: version}
+		g.SetPermissions(permissions)
+		return g
+	}
+
+	dao := &AccessGrantDAO{}
+	tests := []struct {
+		name        string
+		grant       *models.AccessGrant
+		wantApplied bool
+		want        models.Permissions
+	}{
+		{name: "new grant", grant: grant(2, models.PermissionRead), wantApplied: true, want: models.Permissions{models.PermissionRead}},
+		{name: "older version", grant: grant(1, models.PermissionAdmin), wantApplied: false, want: models.Permissions{models.PermissionRead}},
+		{name: "newer version", grant: grant(3, models.PermissionRead, models.PermissionWrite), wantApplied: true, want: models.Permissions{models.PermissionRead, models.PermissionWrite}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			applied, err := dao.ApplyGrant(tt.grant)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.wantApplied, applied)
+			stored, err := dao.GetGrant(userUuid, targetUuid)
+			if assert.Nil(t, err) {
+				assert.Equal(t, tt.want, stored.GetPermissions())
+			}
+		})
+	}
+
+	grants, err := dao.GetGrants(userUuid, []models.TargetUUID{targetUuid, models.TargetUUID(fmt.Sprintf("%s", uuid.New()))})
+	assert.Nil(t, err)
+	assert.Len(t, grants, 1)
+}
+
+func TestAccessGrantDAO_RevokeGrant(t *testing.T) {
+	tx := test_data.OnBeforeDBTest()
+	defer test_data.OnAfterDBTest(tx)
+
+	dao := &AccessGrantDAO{}
+	grant := &models.AccessGrant{
+		UserUuid:   fmt.Sprintf("%s", uuid.New()),
+		TargetUuid: models.TargetUUID(fmt.Sprintf("%s", uuid.New())),
+		Version:    1,
+	}
+	grant.SetPermissions(models.Permissions{models.PermissionRead})
+	_, err := dao.ApplyGrant(grant)
+	assert.Nil(t, err)
+	syncStartedAt := time.Now().Add(time.Second)
+
+	granted, err := dao.GetGrantedBefore(syncStartedAt)
+	if !assert.Nil(t, err) || !assert.NotEmpty(t, granted) {
+		return
+	}
+	stored, _ := dao.GetGrant(grant.UserUuid, grant.TargetUuid)
+	revoked, err := dao.RevokeGrant(stored, time.Now().Add(-time.Hour))
+	assert.Nil(t, err)
+	assert.False(t, revoked, "grant changed since is kept")
+	revoked, err = dao.RevokeGrant(stored, syncStartedAt)
+	assert.Nil(t, err)
+	assert.True(t, revoked)
+
+	stored, err = dao.GetGrant(grant.UserUuid, grant.TargetUuid)
+	if assert.Nil(t, err) {
+		assert.Equal(t, models.Permissions{}, stored.GetPermissions())
+	}
+}
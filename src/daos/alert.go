@@ -0,0 +1,83 @@
+package daos
+
+import (
+	"little-diary-measurement-service/src/config"
+	"little-diary-measurement-service/src/models"
+)
+
+type AlertDAO struct{}
+
+func NewAlertDAO() *AlertDAO {
+	return &AlertDAO{}
+}
+
+func (dao *AlertDAO) GetByAlertUuid(alertUuid models.AlertUUID) (*models.Alert, error) {
+	var alert models.Alert
+
+	err := config.Config.DB.
+		Where("alert_uuid = ?", alertUuid).
+		First(&alert).
+		Error
+
+	return &alert, err
+}
+
+func (dao *AlertDAO) GetByMeasurementUuid(measurementUuid models.MeasurementUUID, kind models.AlertKind) (*models.Alert, error) {
+	var alert models.Alert
+
+	err := config.Config.DB.
+		Where("measurement_uuid = ? AND alert_kind = ?", measurementUuid, kind).
+		First(&alert).
+		Error
+
+	return &alert, err
+}
+
+// GetAlertsByTargetUuid returns the newest alerts first, acknowledged ones only when asked
+func (dao *AlertDAO) GetAlertsByTargetUuid(targetUuid models.TargetUUID, includeAcknowledged bool) ([]*models.Alert, error) {
+	var alerts []*models.Alert
+	query := config.Config.DB.Where("target_uuid = ?", targetUuid)
+	if !includeAcknowledged {
+		query = query.Where("acknowledged_at IS NULL")
+	}
+	err := query.
+		Order("created_at DESC").
+		Find(&alerts).
+		Error
+	return alerts, err
+}
+
+// GetOpenAlertsByTargetUuids returns alerts not acknowledged yet of all targets, the newest first
+func (dao *AlertDAO) GetOpenAlertsByTargetUuids(targetUuids []models.TargetUUID) ([]*models.Alert, error) {
+	var alerts []*models.Alert
+	if len(targetUuids) == 0 {
+		return alerts, nil
+	}
+	err := config.Config.DB.
+		Where("target_uuid IN (?) AND acknowledged_at IS NULL", targetUuids).
+		Order("created_at DESC").
+		Find(&alerts).
+		Error
+	return alerts, err
+}
+
+func (dao *AlertDAO) SaveAlert(alert *models.Alert) error {
+	return config.Config.DB.Save(alert).Error
+}
+
+func (dao *AlertDAO) DeleteAlert(alert *models.Alert) error {
+	return config.Config.DB.Delete(alert).Error
+}
+
+func (dao *AlertDAO) GetAlertsByMeasurementUuids(measurementUuids []models.MeasurementUUID) ([]*models.Alert, error) {
+	var alerts []*models.Alert
+	if len(measurementUuids) == 0 {
+		return alerts, nil
+	}
+	err := config.Config.DB.
+		Where("measurement_uuid IN (?)", measurementUuids).
+		Order("created_at ASC").
+		Find(&alerts).
+		Error
+	return alerts, err
+}
@@ -0,0 +1,60 @@
+package daos
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"little-diary-measurement-service/src/models"
+	"little-diary-measurement-service/src/test_data"
+	"testing"
+	"time"
+)
+
+func TestAlertDAO_GetAlertsByTargetUuid(t *testing.T) {
+	tx := test_data.OnBeforeDBTest()
+	defer test_data.OnAfterDBTest(tx)
+
+	t1 := models.TargetUUID(fmt.Sprintf("%s", uuid.New()))
+	acknowledged := time.Now()
+	a1 := test_data.AlertStoredFactory.MustCreateWithOption(map[string]interface{}{"TargetUuid": t1, "AcknowledgedAt": &acknowledged}).(*models.Alert)
+	a2 := test_data.AlertStoredFactory.MustCreateWithOption(map[string]interface{}{"TargetUuid": t1}).(*models.Alert)
+	test_data.AlertStoredFactory.MustCreate()
+
+	tests := []struct {
+		name                string
+		includeAcknowledged bool
+		want                []*models.Alert
+	}{
+		{name: "open alerts", want: []*models.Alert{a2}},
+		{name: "all alerts newest first", includeAcknowledged: true, want: []*models.Alert{a2, a1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dao := &AlertDAO{}
+			got, err := dao.GetAlertsByTargetUuid(t1, tt.includeAcknowledged)
+			assert.Nil(t, err)
+			if assert.Len(t, got, len(tt.want)) {
+				for i, v := range tt.want {
+					assert.Equal(t, v.ID, got[i].ID)
+				}
+			}
+		})
+	}
+}
+
+func TestAlertDAO_GetByMeasurementUuid(t *testing.T) {
+	tx := test_data.OnBeforeDBTest()
+	defer test_data.OnAfterDBTest(tx)
+
+	stored := test_data.AlertStoredFactory.MustCreate().(*models.Alert)
+
+	dao := &AlertDAO{}
+	got, err := dao.GetByMeasurementUuid(stored.MeasurementUuid, models.AlertKindBirthWeightLoss)
+	if assert.Nil(t, err) {
+		assert.Equal(t, stored.Uuid, got.Uuid)
+	}
+
+	assert.Nil(t, dao.DeleteAlert(got))
+	_, err = dao.GetByAlertUuid(stored.Uuid)
+	assert.NotNil(t, err)
+}
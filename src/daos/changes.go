@@ -0,0 +1,99 @@
+package daos
+
+import (
+	"encoding/json"
+	"github.com/jinzhu/gorm"
+	"github.com/lib/pq"
+	"little-diary-measurement-service/src/events"
+	"little-diary-measurement-service/src/models"
+	"log"
+	"time"
+)
+
+const (
+	// changesChannel carries measurement changes committed by any instance
+	changesChannel = "measurement_changes"
+
+	listenerMinReconnect = time.Second
+	listenerMaxReconnect = time.Minute
+	listenerPingInterval = 90 * time.Second
+)
+
+// changeNotification is the NOTIFY payload, the event is the outbox payload which stays well under the 8000 bytes limit
+type changeNotification struct {
+	Sequence   int64                  `json:"sequence"`
+	EventUuid  string                 `json:"event_uuid"`
+	TargetUuid models.TargetUUID      `json:"target_uuid"`
+	Kind       models.OutboxEventType `json:"kind"`
+	Event      json.RawMessage        `json:"event"`
+}
+
+// notifyChange queues notification of the stored outbox event, Postgres delivers it when the transaction commits
+func notifyChange(tx *gorm.DB, event *models.OutboxEvent) error {
+	payload, err := json.Marshal(&changeNotification{
+		Sequence:   int64(event.ID),
+		EventUuid:  event.EventUuid,
+		TargetUuid: event.TargetUuid,
+		Kind:       event.Type,
+		Event:      json.RawMessage(event.Payload),
+	})
+	if err != nil {
+		return err
+	}
+	return tx.Exec("SELECT pg_notify(?, ?)", changesChannel, string(payload)).Error
+}
+
+// ChangeListener listens to measurement changes of all instances and publishes them to local subscribers keyed by
+// target uuid. A lost connection is re-established with growing delays, changes notified meanwhile are lost,
+// so OnReconnect is called for subscribers to catch up
+type ChangeListener struct {
+	Dsn         string
+	Publisher   events.Publisher
+	OnReconnect func()
+}
+
+// Listen blocks until the listener fails to start
+func (l *ChangeListener) Listen() error {
+	listener := pq.NewListener(l.Dsn, listenerMinReconnect, listenerMaxReconnect, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("measurement changes listener: %v", err)
+		}
+	})
+	defer listener.Close()
+	if err := listener.Listen(changesChannel); err != nil {
+		return err
+	}
+	for {
+		select {
+		case n := <-listener.Notify:
+			if n == nil {
+				if l.OnReconnect != nil {
+					l.OnReconnect()
+				}
+				continue
+			}
+			message, err := changeMessage(n.Extra)
+			if err != nil {
+				log.Printf("measurement changes listener: %v", err)
+				continue
+			}
+			l.Publisher.Publish(message)
+		case <-time.After(listenerPingInterval):
+			go listener.Ping()
+		}
+	}
+}
+
+func changeMessage(payload string) (events.Message, error) {
+	var change changeNotification
+	if err := json.Unmarshal([]byte(payload), &change); err != nil {
+		return events.Message{}, err
+	}
+	return events.Message{
+		ID:       change.EventUuid,
+		Sequence: change.Sequence,
+		Subject:  string(change.Kind),
+		Key:      string(change.TargetUuid),
+		Data:     change.Event,
+	}, nil
+}
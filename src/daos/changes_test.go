@@ -0,0 +1,21 @@
+package daos
+
+import (
+	"github.com/stretchr/testify/assert"
+	"little-diary-measurement-service/src/models"
+	"testing"
+)
+
+func TestChangeMessage(t *testing.T) {
+	message, err := changeMessage(`{"sequence":42,"event_uuid":"e1","target_uuid":"t1","kind":"MeasurementDeleted","event":{"event_uuid":"e1"}}`)
+	if assert.Nil(t, err) {
+		assert.Equal(t, int64(42), message.Sequence)
+		assert.Equal(t, "e1", message.ID)
+		assert.Equal(t, "t1", message.Key)
+		assert.Equal(t, string(models.OutboxEventMeasurementDeleted), message.Subject)
+		assert.Equal(t, `{"event_uuid":"e1"}`, string(message.Data))
+	}
+
+	_, err = changeMessage("not json")
+	assert.NotNil(t, err)
+}
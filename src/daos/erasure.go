@@ -0,0 +1,100 @@
+package daos
+
+import (
+	"database/sql"
+	"github.com/google/uuid"
+	"github.com/jinzhu/gorm"
+	"little-diary-measurement-service/src/config"
+	"little-diary-measurement-service/src/models"
+	"time"
+)
+
+type ErasureDAO struct{}
+
+func NewErasureDAO() *ErasureDAO {
+	return &ErasureDAO{}
+}
+
+// EraseTarget hard-deletes everything stored about the target, announces it with a TargetErased outbox event
+// and saves the receipt with counts of deleted records, all in one transaction. An erasure requested by a consumed
+// event records the event in the inbox first, an event seen before erases nothing and false is returned
+func (dao *ErasureDAO) EraseTarget(receipt *models.ErasureReceipt, inbox *models.InboxEvent) (bool, error) {
+	erased := false
+	err := inTransaction(func(tx *gorm.DB) error {
+		if inbox != nil {
+			err := tx.
+				Set("gorm:insert_option", "ON CONFLICT (event_uuid, source) DO NOTHING").
+				Create(inbox).
+				Error
+			// a skipped insert returns no id
+			if err == sql.ErrNoRows {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+		}
+		target := receipt.TargetUuid
+		// exports including the target are built again without it, a worker building one now finds its claim
+		// reset and discards the archive
+		exports := tx.Model(&models.ExportTarget{}).Select("export_uuid").Where("target_uuid = ?", target).SubQuery()
+		err := tx.Model(&models.ExportJob{}).
+			Where("export_uuid IN ?", exports).
+			Updates(map[string]interface{}{
+				"status":            models.ExportPending,
+				"lease_until":       nil,
+				"measurement_count": 0,
+				"error":             "",
+				"completed_at":      nil,
+				"expires_at":        nil,
+				"archive":           nil,
+			}).
+			Error
+		if err != nil {
+			return err
+		}
+		webhooks := tx.Model(&models.Webhook{}).Select("webhook_uuid").Where("target_uuid = ?", target).SubQuery()
+		deletions := []struct {
+			query *gorm.DB
+			value interface{}
+			count *int64
+		}{
+			{tx.Where("webhook_uuid IN ?", webhooks), &models.WebhookDelivery{}, &receipt.OtherRecordsDeleted},
+			{tx.Where("target_uuid = ?", target), &models.Webhook{}, &receipt.WebhooksDeleted},
+			{tx.Where("target_uuid = ?", target), &models.Alert{}, &receipt.AlertsDeleted},
+			{tx.Where("target_uuid = ?", target), &models.Measurement{}, &receipt.MeasurementsDeleted},
+			{tx.Where("target_uuid = ?", target), &models.OutboxEvent{}, &receipt.OtherRecordsDeleted},
+			{tx.Where("target_uuid = ?", target), &models.AccessGrant{}, &receipt.OtherRecordsDeleted},
+			{tx.Where("target_uuid = ?", target), &models.PatientMapping{}, &receipt.OtherRecordsDeleted},
+			{tx.Where("target_uuid = ?", target), &models.ExportTarget{}, &receipt.OtherRecordsDeleted},
+		}
+		for _, deletion := range deletions {
+			result := deletion.query.Delete(deletion.value)
+			if result.Error != nil {
+				return result.Error
+			}
+			*deletion.count += result.RowsAffected
+		}
+		event, err := models.NewTargetErasedEvent(uuid.New().String(), receipt, time.Now())
+		if err != nil {
+			return err
+		}
+		if err := insertOutboxEvent(tx, event); err != nil {
+			return err
+		}
+		erased = true
+		return tx.Create(receipt).Error
+	})
+	return erased, err
+}
+
+func (dao *ErasureDAO) GetReceiptByEventUuid(eventUuid string) (*models.ErasureReceipt, error) {
+	var receipt models.ErasureReceipt
+
+	err := config.Config.DB.
+		Where("event_uuid = ?", eventUuid).
+		First(&receipt).
+		Error
+
+	return &receipt, err
+}
@@ -0,0 +1,146 @@
+package daos
+
+import (
+	"github.com/jinzhu/gorm"
+	"little-diary-measurement-service/src/config"
+	"little-diary-measurement-service/src/models"
+	"time"
+)
+
+// exportJobColumns leaves out the archive, it is read only for download
+const exportJobColumns = "id, created_at, updated_at, export_uuid, user_uuid, status, attempt, lease_until, " +
+	"measurement_count, error, completed_at, expires_at"
+
+type ExportDAO struct{}
+
+func NewExportDAO() *ExportDAO {
+	return &ExportDAO{}
+}
+
+func (dao *ExportDAO) GetByExportUuid(exportUuid models.ExportUUID) (*models.ExportJob, error) {
+	var job models.ExportJob
+
+	err := config.Config.DB.
+		Select(exportJobColumns).
+		Where("export_uuid = ?", exportUuid).
+		First(&job).
+		Error
+	if err != nil {
+		return &job, err
+	}
+
+	return &job, loadExportTargets(&job)
+}
+
+// GetActiveExport returns the pending or running export of the user
+func (dao *ExportDAO) GetActiveExport(userUuid string) (*models.ExportJob, error) {
+	var job models.ExportJob
+
+	err := config.Config.DB.
+		Select(exportJobColumns).
+		Where("user_uuid = ? AND status IN (?)", userUuid, []models.ExportStatus{models.ExportPending, models.ExportRunning}).
+		Order("id ASC").
+		First(&job).
+		Error
+	if err != nil {
+		return &job, err
+	}
+
+	return &job, loadExportTargets(&job)
+}
+
+func (dao *ExportDAO) GetArchive(exportUuid models.ExportUUID) ([]byte, error) {
+	var archive []byte
+	err := config.Config.DB.
+		Model(&models.ExportJob{}).
+		Select("archive").
+		Where("export_uuid = ?", exportUuid).
+		Row().
+		Scan(&archive)
+	return archive, err
+}
+
+// CreateExport stores the requested export with its targets
+func (dao *ExportDAO) CreateExport(job *models.ExportJob) error {
+	return inTransaction(func(tx *gorm.DB) error {
+		if err := tx.Create(job).Error; err != nil {
+			return err
+		}
+		for i, target := range job.TargetUuids {
+			if err := tx.Create(&models.ExportTarget{ExportUuid: job.Uuid, TargetUuid: target, Position: i}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ClaimExport takes the oldest pending export, or a running one whose worker has not finished it within the lease.
+// Nil is returned when there is nothing to export
+func (dao *ExportDAO) ClaimExport(lease time.Duration, now time.Time) (*models.ExportJob, error) {
+	var jobs []*models.ExportJob
+	err := config.Config.DB.Raw(`
+		UPDATE export_jobs SET status = ?, attempt = attempt + 1, lease_until = ?, updated_at = ?
+		WHERE id IN (
+			SELECT id FROM export_jobs
+			WHERE status = ? OR (status = ? AND lease_until <= ?)
+			ORDER BY id
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+exportJobColumns,
+		models.ExportRunning, now.Add(lease), now, models.ExportPending, models.ExportRunning, now).
+		Scan(&jobs).
+		Error
+	if err != nil || len(jobs) == 0 {
+		return nil, err
+	}
+	return jobs[0], loadExportTargets(jobs[0])
+}
+
+// FinishExport stores the outcome of the claimed export. False is returned when the claim is not the latest anymore,
+// the export was taken over or reset by erasure of one of its targets, and the outcome is discarded
+func (dao *ExportDAO) FinishExport(job *models.ExportJob) (bool, error) {
+	result := config.Config.DB.
+		Model(&models.ExportJob{}).
+		Where("export_uuid = ? AND status = ? AND attempt = ?", job.Uuid, models.ExportRunning, job.Attempt).
+		Updates(map[string]interface{}{
+			"status":            job.Status,
+			"lease_until":       nil,
+			"measurement_count": job.MeasurementCount,
+			"error":             job.Error,
+			"completed_at":      job.CompletedAt,
+			"expires_at":        job.ExpiresAt,
+			"archive":           job.Archive,
+		})
+	return result.RowsAffected == 1, result.Error
+}
+
+// DeleteExpiredExports removes exports with their targets and archives once they expire
+func (dao *ExportDAO) DeleteExpiredExports(now time.Time) (int64, error) {
+	var deleted int64
+	err := inTransaction(func(tx *gorm.DB) error {
+		expired := tx.Model(&models.ExportJob{}).Select("export_uuid").Where("expires_at <= ?", now).SubQuery()
+		if err := tx.Where("export_uuid IN ?", expired).Delete(&models.ExportTarget{}).Error; err != nil {
+			return err
+		}
+		result := tx.Where("expires_at <= ?", now).Delete(&models.ExportJob{})
+		deleted = result.RowsAffected
+		return result.Error
+	})
+	return deleted, err
+}
+
+func loadExportTargets(job *models.ExportJob) error {
+	var targets []*models.ExportTarget
+	err := config.Config.DB.
+		Where("export_uuid = ?", job.Uuid).
+		Order("position ASC").
+		Find(&targets).
+		Error
+	job.TargetUuids = []models.TargetUUID{}
+	for _, target := range targets {
+		job.TargetUuids = append(job.TargetUuids, target.TargetUuid)
+	}
+	return err
+}
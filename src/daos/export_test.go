@@ -0,0 +1,110 @@
+package daos
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"little-diary-measurement-service/src/models"
+	"little-diary-measurement-service/src/test_data"
+	"testing"
+	"time"
+)
+
+func TestExportDAO_ClaimExport(t *testing.T) {
+	tx := test_data.OnBeforeDBTest()
+	defer test_data.OnAfterDBTest(tx)
+
+	dao := NewExportDAO()
+	now := time.Now()
+	target := models.TargetUUID(fmt.Sprintf("%s", uuid.New()))
+	job := &models.ExportJob{
+		Uuid:        models.ExportUUID(fmt.Sprintf("%s", uuid.New())),
+		UserUuid:    fmt.Sprintf("%s", uuid.New()),
+		Status:      models.ExportPending,
+		TargetUuids: []models.TargetUUID{target},
+	}
+	if !assert.Nil(t, dao.CreateExport(job)) {
+		return
+	}
+
+	first, err := dao.ClaimExport(time.Minute, now)
+	if !assert.Nil(t, err) || !assert.NotNil(t, first) {
+		return
+	}
+	assert.Equal(t, job.Uuid, first.Uuid)
+	assert.Equal(t, models.ExportRunning, first.Status)
+	assert.Equal(t, []models.TargetUUID{target}, first.TargetUuids)
+	claimed, err := dao.ClaimExport(time.Minute, now)
+	assert.Nil(t, err)
+	assert.Nil(t, claimed, "running export is leased")
+
+	second, err := dao.ClaimExport(time.Minute, now.Add(2*time.Minute))
+	if !assert.Nil(t, err) || !assert.NotNil(t, second, "expired lease is taken over") {
+		return
+	}
+	assert.Equal(t, job.Uuid, second.Uuid)
+
+	expiresAt := now.Add(time.Hour)
+	for _, claim := range []*models.ExportJob{first, second} {
+		claim.Status = models.ExportCompleted
+		claim.Archive = []byte(fmt.Sprintf("attempt %d", claim.Attempt))
+		claim.ExpiresAt = &expiresAt
+	}
+	finished, err := dao.FinishExport(first)
+	assert.Nil(t, err)
+	assert.False(t, finished, "taken over claim is discarded")
+	finished, err = dao.FinishExport(second)
+	assert.Nil(t, err)
+	assert.True(t, finished)
+	archive, err := dao.GetArchive(job.Uuid)
+	if assert.Nil(t, err) {
+		assert.Equal(t, []byte("attempt 2"), archive)
+	}
+
+	deleted, err := dao.DeleteExpiredExports(now)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), deleted)
+	deleted, err = dao.DeleteExpiredExports(expiresAt)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), deleted)
+}
+
+func TestErasureDAO_EraseTargetResetsExports(t *testing.T) {
+	tx := test_data.OnBeforeDBTest()
+	defer test_data.OnAfterDBTest(tx)
+
+	dao := NewExportDAO()
+	erased := models.TargetUUID(fmt.Sprintf("%s", uuid.New()))
+	kept := models.TargetUUID(fmt.Sprintf("%s", uuid.New()))
+	job := &models.ExportJob{
+		Uuid:        models.ExportUUID(fmt.Sprintf("%s", uuid.New())),
+		UserUuid:    fmt.Sprintf("%s", uuid.New()),
+		Status:      models.ExportPending,
+		TargetUuids: []models.TargetUUID{erased, kept},
+	}
+	assert.Nil(t, dao.CreateExport(job))
+	running, err := dao.ClaimExport(time.Minute, time.Now())
+	if !assert.Nil(t, err) || !assert.NotNil(t, running) {
+		return
+	}
+
+	receipt := &models.ErasureReceipt{
+		Uuid:        models.ErasureUUID(fmt.Sprintf("%s", uuid.New())),
+		TargetUuid:  erased,
+		RequestedBy: models.ErasureRequestedByApi,
+	}
+	_, err = NewErasureDAO().EraseTarget(receipt, nil)
+	assert.Nil(t, err)
+
+	running.Status = models.ExportCompleted
+	running.Archive = []byte("erased data")
+	finished, err := dao.FinishExport(running)
+	assert.Nil(t, err)
+	assert.False(t, finished, "archive built before the erasure is discarded")
+
+	reset, err := dao.GetByExportUuid(job.Uuid)
+	if assert.Nil(t, err) {
+		assert.Equal(t, models.ExportPending, reset.Status)
+		assert.Equal(t, []models.TargetUUID{kept}, reset.TargetUuids, "other targets of the export stay")
+	}
+}
@@ -1,8 +1,11 @@
 package daos
 
 import (
+	"github.com/google/uuid"
+	"github.com/jinzhu/gorm"
 	"little-diary-measurement-service/src/config"
 	"little-diary-measurement-service/src/models"
+	"time"
 )
 
 type MeasurementDAO struct{}
@@ -22,8 +25,79 @@ func (dao *MeasurementDAO) GetByMeasurementUuid(measurementUuid models.Measureme
 	return &measurement, err
 }
 
+// SaveMeasurement stores the measurement and its MeasurementCreated or MeasurementUpdated outbox event in one transaction
 func (dao *MeasurementDAO) SaveMeasurement(measurement *models.Measurement) error {
-	return config.Config.DB.Save(measurement).Error
+	return inTransaction(func(tx *gorm.DB) error {
+		return saveMeasurement(tx, measurement)
+	})
+}
+
+// SaveMeasurements stores the measurements with their outbox events in one transaction, all of them or none
+func (dao *MeasurementDAO) SaveMeasurements(measurements []*models.Measurement) error {
+	return inTransaction(func(tx *gorm.DB) error {
+		for _, measurement := range measurements {
+			if err := saveMeasurement(tx, measurement); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// SaveMeasurementWithAlerts stores the measurement with its outbox event and the alerts evaluated for it
+// in one transaction, other birth records of the type are unmarked first when clearBirth is set
+func (dao *MeasurementDAO) SaveMeasurementWithAlerts(measurement *models.Measurement, clearBirth bool, saved []*models.Alert, removed []*models.Alert) error {
+	return inTransaction(func(tx *gorm.DB) error {
+		if clearBirth {
+			if err := clearBirthMeasurements(tx, measurement.TargetUuid, measurement.Type, measurement.Uuid); err != nil {
+				return err
+			}
+		}
+		if err := saveMeasurement(tx, measurement); err != nil {
+			return err
+		}
+		for _, alert := range saved {
+			if err := tx.Save(alert).Error; err != nil {
+				return err
+			}
+		}
+		for _, alert := range removed {
+			if err := tx.Delete(alert).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func saveMeasurement(tx *gorm.DB, measurement *models.Measurement) error {
+	eventType := models.OutboxEventMeasurementUpdated
+	if measurement.ID == 0 {
+		eventType = models.OutboxEventMeasurementCreated
+	}
+	if err := tx.Save(measurement).Error; err != nil {
+		return err
+	}
+	return insertMeasurementEvent(tx, eventType, measurement)
+}
+
+// DeleteMeasurement removes the measurement with its MeasurementDeleted outbox event in one transaction
+func (dao *MeasurementDAO) DeleteMeasurement(measurement *models.Measurement) error {
+	return inTransaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(measurement).Error; err != nil {
+			return err
+		}
+		return insertMeasurementEvent(tx, models.OutboxEventMeasurementDeleted, measurement)
+	})
+}
+
+// insertMeasurementEvent stores the outbox event of the measurement change
+func insertMeasurementEvent(tx *gorm.DB, eventType models.OutboxEventType, measurement *models.Measurement) error {
+	event, err := models.NewMeasurementEvent(uuid.New().String(), eventType, measurement, time.Now())
+	if err != nil {
+		return err
+	}
+	return insertOutboxEvent(tx, event)
 }
 
 func (dao *MeasurementDAO) GetMeasurementsByTargetUuid(targetUuid models.TargetUUID) ([]*models.Measurement, error) {
@@ -35,3 +109,127 @@ func (dao *MeasurementDAO) GetMeasurementsByTargetUuid(targetUuid models.TargetU
 		Error
 	return measurements, err
 }
+
+func (dao *MeasurementDAO) GetMeasurementsByFilter(filter models.MeasurementFilter) ([]*models.Measurement, error) {
+	var measurements []*models.Measurement
+	query := config.Config.DB.Where("target_uuid = ?", filter.TargetUuid)
+	if len(filter.Types) > 0 {
+		query = query.Where("measurement_type IN (?)", filter.Types)
+	}
+	if filter.From != nil {
+		query = query.Where("measurement_date >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("measurement_date <= ?", *filter.To)
+	}
+	err := query.
+		Order("measurement_date ASC").
+		Find(&measurements).
+		Error
+	return measurements, err
+}
+
+// AggregateMeasurements groups filtered measurements into buckets truncated in the given location,
+// buckets are ordered from the oldest one
+func (dao *MeasurementDAO) AggregateMeasurements(filter models.MeasurementFilter, bucket models.AggregationBucket, location *time.Location) ([]*models.MeasurementAggregate, error) {
+	var aggregates []*models.MeasurementAggregate
+	query := config.Config.DB.Model(&models.Measurement{}).
+		Select(`date_trunc(?, measurement_date AT TIME ZONE ?) AT TIME ZONE ? AS bucket,
+			count(*) AS count,
+			min(measurement_value) AS min,
+			max(measurement_value) AS max,
+			avg(measurement_value) AS avg,
+			(array_agg(measurement_value ORDER BY measurement_date DESC))[1] AS last`,
+			string(bucket), location.String(), location.String()).
+		Where("target_uuid = ?", filter.TargetUuid)
+	if len(filter.Types) > 0 {
+		query = query.Where("measurement_type IN (?)", filter.Types)
+	}
+	if filter.From != nil {
+		query = query.Where("measurement_date >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("measurement_date <= ?", *filter.To)
+	}
+	err := query.
+		Group("1").
+		Order("1 ASC").
+		Scan(&aggregates).
+		Error
+	return aggregates, err
+}
+
+func (dao *MeasurementDAO) GetBirthMeasurement(targetUuid models.TargetUUID, measurementType models.MeasurementType) (*models.Measurement, error) {
+	var measurement models.Measurement
+
+	err := config.Config.DB.
+		Where("target_uuid = ? AND measurement_type = ? AND is_birth", targetUuid, measurementType).
+		First(&measurement).
+		Error
+
+	return &measurement, err
+}
+
+// ClearBirthMeasurements unmarks birth records of the type except the given one, every changed record gets its event
+func (dao *MeasurementDAO) ClearBirthMeasurements(targetUuid models.TargetUUID, measurementType models.MeasurementType, except models.MeasurementUUID) error {
+	return inTransaction(func(tx *gorm.DB) error {
+		return clearBirthMeasurements(tx, targetUuid, measurementType, except)
+	})
+}
+
+func clearBirthMeasurements(tx *gorm.DB, targetUuid models.TargetUUID, measurementType models.MeasurementType, except models.MeasurementUUID) error {
+	var births []*models.Measurement
+	err := tx.
+		Where("target_uuid = ? AND measurement_type = ? AND is_birth AND measurement_uuid <> ?", targetUuid, measurementType, except).
+		Find(&births).
+		Error
+	if err != nil {
+		return err
+	}
+	for _, birth := range births {
+		birth.IsBirth = false
+		if err := tx.Model(birth).Update("is_birth", false).Error; err != nil {
+			return err
+		}
+		if err := insertMeasurementEvent(tx, models.OutboxEventMeasurementUpdated, birth); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetLatestMeasurements returns the newest trusted measurement of every type for each target,
+// the previous value comes from the window evaluated before DISTINCT ON picks the newest row
+func (dao *MeasurementDAO) GetLatestMeasurements(targetUuids []models.TargetUUID) ([]*models.LatestMeasurement, error) {
+	var latest []*models.LatestMeasurement
+	if len(targetUuids) == 0 {
+		return latest, nil
+	}
+	err := config.Config.DB.Raw(`
+		SELECT DISTINCT ON (target_uuid, measurement_type)
+			target_uuid, measurement_type, measurement_uuid, measurement_date, measurement_value,
+			lag(measurement_value) OVER w AS previous_value,
+			lag(measurement_date) OVER w AS previous_date
+		FROM measurements
+		WHERE target_uuid IN (?) AND NOT suspect
+		WINDOW w AS (PARTITION BY target_uuid, measurement_type ORDER BY measurement_date)
+		ORDER BY target_uuid, measurement_type, measurement_date DESC`, targetUuids).
+		Scan(&latest).
+		Error
+	return latest, err
+}
+
+// CountMeasurements counts stored measurements of every type for each target
+func (dao *MeasurementDAO) CountMeasurements(targetUuids []models.TargetUUID) ([]*models.MeasurementCount, error) {
+	var counts []*models.MeasurementCount
+	if len(targetUuids) == 0 {
+		return counts, nil
+	}
+	err := config.Config.DB.Model(&models.Measurement{}).
+		Select("target_uuid, measurement_type, count(*) AS count").
+		Where("target_uuid IN (?)", targetUuids).
+		Group("target_uuid, measurement_type").
+		Scan(&counts).
+		Error
+	return counts, err
+}
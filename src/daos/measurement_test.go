@@ -149,3 +149,184 @@ func TestMeasurementDAO_GetMeasurementsByTargetUuid(t *testing.T) {
 		})
 	}
 }
+
+func TestMeasurementDAO_GetMeasurementsByFilter(t *testing.T) {
+	tx := test_data.OnBeforeDBTest()
+	defer test_data.OnAfterDBTest(tx)
+
+	t1 := models.TargetUUID(fmt.Sprintf("%s", uuid.New()))
+	now := time.Now()
+	weekAgo := now.AddDate(0, 0, -7)
+	monthAgo := now.AddDate(0, -1, 0)
+
+	m1 := test_data.MeasurementStoredFactory.MustCreateWithOption(map[string]interface{}{"TargetUuid": t1, "Timestamp": monthAgo}).(*models.Measurement)
+	m2 := test_data.MeasurementStoredFactory.MustCreateWithOption(map[string]interface{}{"TargetUuid": t1, "Timestamp": weekAgo, "Type": models.MeasurementTypeWeight}).(*models.Measurement)
+	m3 := test_data.MeasurementStoredFactory.MustCreateWithOption(map[string]interface{}{"TargetUuid": t1, "Timestamp": now}).(*models.Measurement)
+	test_data.MeasurementStoredFactory.MustCreate()
+
+	from := weekAgo.Add(-time.Hour)
+	to := weekAgo.Add(time.Hour)
+	tests := []struct {
+		name   string
+		filter models.MeasurementFilter
+		want   []*models.Measurement
+	}{
+		{name: "all of target", filter: models.MeasurementFilter{TargetUuid: t1}, want: []*models.Measurement{m1, m2, m3}},
+		{name: "by type", filter: models.MeasurementFilter{TargetUuid: t1, Types: []models.MeasurementType{models.MeasurementTypeHeight}},
+			want: []*models.Measurement{m1, m3}},
+		{name: "from", filter: models.MeasurementFilter{TargetUuid: t1, From: &from}, want: []*models.Measurement{m2, m3}},
+		{name: "period", filter: models.MeasurementFilter{TargetUuid: t1, From: &from, To: &to}, want: []*models.Measurement{m2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dao := &MeasurementDAO{}
+			got, err := dao.GetMeasurementsByFilter(tt.filter)
+			assert.Nil(t, err)
+			if assert.Len(t, got, len(tt.want)) {
+				for i, v := range tt.want {
+					assert.Equal(t, got[i].ID, v.ID)
+				}
+			}
+		})
+	}
+}
+
+func TestMeasurementDAO_AggregateMeasurements(t *testing.T) {
+	tx := test_data.OnBeforeDBTest()
+	defer test_data.OnAfterDBTest(tx)
+
+	t1 := models.TargetUUID(fmt.Sprintf("%s", uuid.New()))
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+	morning := time.Date(2020, 3, 2, 10, 0, 0, 0, time.UTC)
+	// the next day already started in Berlin
+	lateEvening := time.Date(2020, 3, 2, 23, 30, 0, 0, time.UTC)
+	nextWeek := time.Date(2020, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	test_data.MeasurementStoredFactory.MustCreateWithOption(map[string]interface{}{"TargetUuid": t1, "Timestamp": lateEvening, "Value": float32(52)})
+	test_data.MeasurementStoredFactory.MustCreateWithOption(map[string]interface{}{"TargetUuid": t1, "Timestamp": morning, "Value": float32(50)})
+	test_data.MeasurementStoredFactory.MustCreateWithOption(map[string]interface{}{"TargetUuid": t1, "Timestamp": nextWeek, "Value": float32(54)})
+	test_data.MeasurementStoredFactory.MustCreateWithOption(map[string]interface{}{"TargetUuid": t1, "Timestamp": morning, "Type": models.MeasurementTypeWeight})
+
+	heights := models.MeasurementFilter{TargetUuid: t1, Types: []models.MeasurementType{models.MeasurementTypeHeight}}
+	tests := []struct {
+		name     string
+		bucket   models.AggregationBucket
+		location *time.Location
+		want     []models.MeasurementAggregate
+	}{
+		{name: "day in UTC", bucket: models.AggregationBucketDay, location: time.UTC, want: []models.MeasurementAggregate{
+			{Bucket: time.Date(2020, 3, 2, 0, 0, 0, 0, time.UTC), Count: 2, Min: 50, Max: 52, Avg: 51, Last: 52},
+			{Bucket: time.Date(2020, 3, 10, 0, 0, 0, 0, time.UTC), Count: 1, Min: 54, Max: 54, Avg: 54, Last: 54},
+		}},
+		{name: "day in Berlin", bucket: models.AggregationBucketDay, location: berlin, want: []models.MeasurementAggregate{
+			{Bucket: time.Date(2020, 3, 2, 0, 0, 0, 0, berlin), Count: 1, Min: 50, Max: 50, Avg: 50, Last: 50},
+			{Bucket: time.Date(2020, 3, 3, 0, 0, 0, 0, berlin), Count: 1, Min: 52, Max: 52, Avg: 52, Last: 52},
+			{Bucket: time.Date(2020, 3, 10, 0, 0, 0, 0, berlin), Count: 1, Min: 54, Max: 54, Avg: 54, Last: 54},
+		}},
+		{name: "month", bucket: models.AggregationBucketMonth, location: berlin, want: []models.MeasurementAggregate{
+			{Bucket: time.Date(2020, 3, 1, 0, 0, 0, 0, berlin), Count: 3, Min: 50, Max: 54, Avg: 52, Last: 54},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dao := &MeasurementDAO{}
+			got, err := dao.AggregateMeasurements(heights, tt.bucket, tt.location)
+			assert.Nil(t, err)
+			if assert.Len(t, got, len(tt.want)) {
+				for i, v := range tt.want {
+					assert.True(t, v.Bucket.Equal(got[i].Bucket), "bucket %s, got %s", v.Bucket, got[i].Bucket)
+					assert.Equal(t, v.Count, got[i].Count)
+					assert.Equal(t, v.Min, got[i].Min)
+					assert.Equal(t, v.Max, got[i].Max)
+					assert.InDelta(t, v.Avg, got[i].Avg, 1e-4)
+					assert.Equal(t, v.Last, got[i].Last)
+				}
+			}
+		})
+	}
+}
+
+func TestMeasurementDAO_BirthMeasurement(t *testing.T) {
+	tx := test_data.OnBeforeDBTest()
+	defer test_data.OnAfterDBTest(tx)
+
+	t1 := models.TargetUUID(fmt.Sprintf("%s", uuid.New()))
+	m1 := test_data.MeasurementStoredFactory.MustCreateWithOption(map[string]interface{}{"TargetUuid": t1, "Type": models.MeasurementTypeWeight, "IsBirth": true}).(*models.Measurement)
+	m2 := test_data.MeasurementStoredFactory.MustCreateWithOption(map[string]interface{}{"TargetUuid": t1, "Type": models.MeasurementTypeWeight, "IsBirth": true}).(*models.Measurement)
+	test_data.MeasurementStoredFactory.MustCreateWithOption(map[string]interface{}{"TargetUuid": t1, "IsBirth": true})
+
+	dao := &MeasurementDAO{}
+	assert.Nil(t, dao.ClearBirthMeasurements(t1, models.MeasurementTypeWeight, m2.Uuid))
+
+	got, err := dao.GetBirthMeasurement(t1, models.MeasurementTypeWeight)
+	if assert.Nil(t, err) {
+		assert.Equal(t, m2.ID, got.ID)
+	}
+	got, err = dao.GetByMeasurementUuid(m1.Uuid)
+	if assert.Nil(t, err) {
+		assert.False(t, got.IsBirth)
+	}
+	_, err = dao.GetBirthMeasurement(models.TargetUUID(fmt.Sprintf("%s", uuid.New())), models.MeasurementTypeWeight)
+	assert.NotNil(t, err)
+}
+
+func TestMeasurementDAO_SaveMeasurementWithAlerts(t *testing.T) {
+	tx := test_data.OnBeforeDBTest()
+	defer test_data.OnAfterDBTest(tx)
+
+	t1 := models.TargetUUID(fmt.Sprintf("%s", uuid.New()))
+	former := test_data.MeasurementStoredFactory.MustCreateWithOption(map[string]interface{}{"TargetUuid": t1, "Type": models.MeasurementTypeWeight, "IsBirth": true}).(*models.Measurement)
+	stale := test_data.AlertStoredFactory.MustCreateWithOption(map[string]interface{}{"TargetUuid": t1}).(*models.Alert)
+	measurement := test_data.MeasurementFactory.MustCreateWithOption(map[string]interface{}{"TargetUuid": t1, "Type": models.MeasurementTypeWeight, "IsBirth": true}).(*models.Measurement)
+	alert := &models.Alert{Uuid: models.AlertUUID(fmt.Sprintf("%s", uuid.New())), TargetUuid: t1, MeasurementUuid: former.Uuid,
+		Kind: models.AlertKindBirthWeightLoss, Severity: models.AlertSeverityWarning, Reason: "weight is below birth weight"}
+
+	dao := &MeasurementDAO{}
+	assert.Nil(t, dao.SaveMeasurementWithAlerts(measurement, true, []*models.Alert{alert}, []*models.Alert{stale}))
+
+	got, err := dao.GetBirthMeasurement(t1, models.MeasurementTypeWeight)
+	if assert.Nil(t, err) {
+		assert.Equal(t, measurement.Uuid, got.Uuid)
+	}
+	alertDao := &AlertDAO{}
+	_, err = alertDao.GetByAlertUuid(alert.Uuid)
+	assert.Nil(t, err)
+	_, err = alertDao.GetByAlertUuid(stale.Uuid)
+	assert.NotNil(t, err)
+}
+
+func TestMeasurementDAO_GetLatestMeasurements(t *testing.T) {
+	tx := test_data.OnBeforeDBTest()
+	defer test_data.OnAfterDBTest(tx)
+
+	t1 := models.TargetUUID(fmt.Sprintf("%s", uuid.New()))
+	t2 := models.TargetUUID(fmt.Sprintf("%s", uuid.New()))
+	now := time.Now()
+	test_data.MeasurementStoredFactory.MustCreateWithOption(map[string]interface{}{"TargetUuid": t1, "Timestamp": now.AddDate(0, 0, -20), "Value": float32(50)})
+	h2 := test_data.MeasurementStoredFactory.MustCreateWithOption(map[string]interface{}{"TargetUuid": t1, "Timestamp": now.AddDate(0, 0, -10), "Value": float32(52)}).(*models.Measurement)
+	h3 := test_data.MeasurementStoredFactory.MustCreateWithOption(map[string]interface{}{"TargetUuid": t1, "Timestamp": now.AddDate(0, 0, -2), "Value": float32(54)}).(*models.Measurement)
+	test_data.MeasurementStoredFactory.MustCreateWithOption(map[string]interface{}{"TargetUuid": t1, "Timestamp": now, "Value": float32(5.4), "Suspect": true})
+	w1 := test_data.MeasurementStoredFactory.MustCreateWithOption(map[string]interface{}{"TargetUuid": t1, "Type": models.MeasurementTypeWeight, "Value": float32(4000)}).(*models.Measurement)
+	w2 := test_data.MeasurementStoredFactory.MustCreateWithOption(map[string]interface{}{"TargetUuid": t2, "Type": models.MeasurementTypeWeight, "Value": float32(3000)}).(*models.Measurement)
+	test_data.MeasurementStoredFactory.MustCreate()
+
+	dao := &MeasurementDAO{}
+	got, err := dao.GetLatestMeasurements([]models.TargetUUID{t1, t2})
+	assert.Nil(t, err)
+	byUuid := map[models.MeasurementUUID]*models.LatestMeasurement{}
+	for _, latest := range got {
+		byUuid[latest.Uuid] = latest
+	}
+	assert.Len(t, got, 3)
+	if latest, ok := byUuid[h3.Uuid]; assert.True(t, ok) {
+		assert.Equal(t, h3.Value, latest.Value)
+		if assert.NotNil(t, latest.PreviousValue) {
+			assert.Equal(t, h2.Value, *latest.PreviousValue)
+		}
+	}
+	if latest, ok := byUuid[w1.Uuid]; assert.True(t, ok) {
+		assert.Nil(t, latest.PreviousValue)
+	}
+	_, ok := byUuid[w2.Uuid]
+	assert.True(t, ok)
+}
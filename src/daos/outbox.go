@@ -0,0 +1,163 @@
+package daos
+
+import (
+	"github.com/jinzhu/gorm"
+	"little-diary-measurement-service/src/config"
+	"little-diary-measurement-service/src/models"
+	"sort"
+	"time"
+)
+
+// outboxRelayLock is the advisory lock key held while a relay claims events for a sink, so two relays never claim
+// events of the same target for the same sink
+const outboxRelayLock = 4507206
+
+// outboxClaimLease is how long claimed targets are left to the claiming relay, it outlasts publishing a whole batch
+// and a crashed relay's targets are claimed again after it
+const outboxClaimLease = 15 * time.Minute
+
+// insertOutboxEvent numbers the event within its target, stores it and notifies listening instances, they hear of it
+// once the transaction commits. The counter row of the target stays locked until then, so a later event of the target
+// gets its number only after the earlier one has committed and sequences of a target are visible in order without gaps
+func insertOutboxEvent(tx *gorm.DB, event *models.OutboxEvent) error {
+	err := tx.Raw(`
+INSERT INTO outbox_sequences (target_uuid, last_sequence) VALUES (?, 1)
+ON CONFLICT (target_uuid) DO UPDATE SET last_sequence = outbox_sequences.last_sequence + 1
+RETURNING last_sequence`, event.TargetUuid).
+		Row().
+		Scan(&event.TargetSequence)
+	if err != nil {
+		return err
+	}
+	if err := tx.Create(event).Error; err != nil {
+		return err
+	}
+	return notifyChange(tx, event)
+}
+
+type OutboxDAO struct{}
+
+func NewOutboxDAO() *OutboxDAO {
+	return &OutboxDAO{}
+}
+
+// ProcessPending claims the oldest events the sink has not published and passes them to publish in their sequence
+// order within a target. The cursor of the sink moves past each accepted event right away, targets are released
+// for the next run at the end. Targets claimed for the sink by another relay are skipped, a sink without a cursor
+// on a target starts with the events still kept
+func (dao *OutboxDAO) ProcessPending(sink string, limit int, publish func(event *models.OutboxEvent) error) (int, error) {
+	pending, err := dao.claimPending(sink, limit, time.Now())
+	if err != nil || len(pending) == 0 {
+		return 0, err
+	}
+	published := 0
+	var targets []models.TargetUUID
+	for _, event := range pending {
+		targets = append(targets, event.TargetUuid)
+		if publish(event) != nil {
+			continue
+		}
+		err := config.Config.DB.
+			Model(&models.OutboxCursor{}).
+			Where("sink = ? AND target_uuid = ?", sink, event.TargetUuid).
+			Update("published_sequence", event.TargetSequence).
+			Error
+		if err != nil {
+			return published, err
+		}
+		published++
+	}
+	err = config.Config.DB.
+		Model(&models.OutboxCursor{}).
+		Where("sink = ? AND target_uuid IN (?)", sink, targets).
+		Update("claimed_until", gorm.Expr("NULL")).
+		Error
+	return published, err
+}
+
+// claimPending takes the oldest events the sink has not published of targets no other relay has claimed for it,
+// and commits the claim before anything is published. Events of a target are inserted in sequence order,
+// so the batch holds the earliest unpublished events of each of its targets
+func (dao *OutboxDAO) claimPending(sink string, limit int, now time.Time) ([]*models.OutboxEvent, error) {
+	var pending []*models.OutboxEvent
+	err := inTransaction(func(tx *gorm.DB) error {
+		var locked bool
+		err := tx.Raw("SELECT pg_try_advisory_xact_lock(?, hashtext(?))", outboxRelayLock, sink).Row().Scan(&locked)
+		if err != nil || !locked {
+			return err
+		}
+		err = tx.Raw(`
+SELECT e.* FROM outbox_events e
+LEFT JOIN outbox_cursors c ON c.sink = ? AND c.target_uuid = e.target_uuid
+WHERE e.target_sequence > COALESCE(c.published_sequence, 0)
+AND (c.claimed_until IS NULL OR c.claimed_until <= ?)
+ORDER BY e.id
+LIMIT ?`, sink, now, limit).
+			Scan(&pending).
+			Error
+		if err != nil || len(pending) == 0 {
+			return err
+		}
+		var ids []uint
+		for _, event := range pending {
+			ids = append(ids, event.ID)
+		}
+		return tx.Exec(`
+INSERT INTO outbox_cursors (sink, target_uuid, published_sequence, claimed_until)
+SELECT ?, target_uuid, 0, ? FROM outbox_events WHERE id IN (?) GROUP BY target_uuid
+ON CONFLICT (sink, target_uuid) DO UPDATE SET claimed_until = EXCLUDED.claimed_until`, sink, now.Add(outboxClaimLease), ids).
+			Error
+	})
+	sort.Slice(pending, func(i, j int) bool {
+		if pending[i].TargetSequence != pending[j].TargetSequence {
+			return pending[i].TargetSequence < pending[j].TargetSequence
+		}
+		return pending[i].ID < pending[j].ID
+	})
+	return pending, err
+}
+
+// DeletePublished removes events stored before the given time that all the sinks have published
+func (dao *OutboxDAO) DeletePublished(before time.Time, sinks []string) error {
+	query := config.Config.DB.Where("created_at < ?", before)
+	for _, sink := range sinks {
+		query = query.Where(`target_sequence <= COALESCE(
+	(SELECT published_sequence FROM outbox_cursors c WHERE c.sink = ? AND c.target_uuid = outbox_events.target_uuid), 0)`, sink)
+	}
+	return query.Delete(&models.OutboxEvent{}).Error
+}
+
+// GetEventsAfter returns events of the target stored after the given outbox id in insertion order
+func (dao *OutboxDAO) GetEventsAfter(targetUuid models.TargetUUID, afterId int64, limit int) ([]*models.OutboxEvent, error) {
+	var stored []*models.OutboxEvent
+	err := config.Config.DB.
+		Where("target_uuid = ? AND id > ?", targetUuid, afterId).
+		Order("id ASC").
+		Limit(limit).
+		Find(&stored).
+		Error
+	return stored, err
+}
+
+// GetFirstEventId returns the oldest outbox id still kept of any target, zero when the outbox is empty
+func (dao *OutboxDAO) GetFirstEventId() (int64, error) {
+	var first struct{ ID int64 }
+	err := config.Config.DB.
+		Model(&models.OutboxEvent{}).
+		Select("COALESCE(MIN(id), 0) AS id").
+		Scan(&first).
+		Error
+	return first.ID, err
+}
+
+// GetLastEventId returns outbox id of the newest event of the target, zero when there are none
+func (dao *OutboxDAO) GetLastEventId(targetUuid models.TargetUUID) (int64, error) {
+	var last struct{ ID int64 }
+	err := config.Config.DB.
+		Model(&models.OutboxEvent{}).
+		Select("COALESCE(MAX(id), 0) AS id").
+		Where("target_uuid = ?", targetUuid).
+		Scan(&last).
+		Error
+	return last.ID, err
+}
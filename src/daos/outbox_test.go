@@ -0,0 +1,173 @@
+package daos
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"little-diary-measurement-service/src/config"
+	"little-diary-measurement-service/src/models"
+	"little-diary-measurement-service/src/test_data"
+	"testing"
+	"time"
+)
+
+func TestMeasurementDAO_SaveMeasurementWritesOutbox(t *testing.T) {
+	tx := test_data.OnBeforeDBTest()
+	defer test_data.OnAfterDBTest(tx)
+
+	measurement := test_data.MeasurementFactory.MustCreate().(*models.Measurement)
+	dao := &MeasurementDAO{}
+	assert.Nil(t, dao.SaveMeasurement(measurement))
+	measurement.Value = 42
+	assert.Nil(t, dao.SaveMeasurement(measurement))
+	assert.Nil(t, dao.DeleteMeasurement(measurement))
+
+	var stored []*models.OutboxEvent
+	config.Config.DB.Where("measurement_uuid = ?", measurement.Uuid).Order("id ASC").Find(&stored)
+	if assert.Len(t, stored, 3) {
+		assert.Equal(t, models.OutboxEventMeasurementCreated, stored[0].Type)
+		assert.Equal(t, models.OutboxEventMeasurementUpdated, stored[1].Type)
+		assert.Equal(t, models.OutboxEventMeasurementDeleted, stored[2].Type)
+		for i, event := range stored {
+			assert.Equal(t, int64(i+1), event.TargetSequence)
+		}
+		var event models.MeasurementEvent
+		assert.Nil(t, json.Unmarshal([]byte(stored[1].Payload), &event))
+		assert.Equal(t, stored[1].EventUuid, event.EventUuid)
+		assert.Equal(t, float32(42), event.Measurement.Value)
+		assert.Equal(t, measurement.TargetUuid, event.Measurement.TargetUuid)
+	}
+}
+
+// publishOutbox moves the cursors of the sink past all stored events
+func publishOutbox(sink string) {
+	config.Config.DB.Exec(`
+INSERT INTO outbox_cursors (sink, target_uuid, published_sequence)
+SELECT ?, target_uuid, MAX(target_sequence) FROM outbox_events GROUP BY target_uuid
+ON CONFLICT (sink, target_uuid) DO UPDATE SET published_sequence = EXCLUDED.published_sequence, claimed_until = NULL`, sink)
+}
+
+func TestOutboxDAO_ProcessPending(t *testing.T) {
+	tx := test_data.OnBeforeDBTest()
+	defer test_data.OnAfterDBTest(tx)
+	publishOutbox(models.OutboxSinkBroker)
+	publishOutbox(models.OutboxSinkWebhooks)
+
+	dao := &MeasurementDAO{}
+	var saved []*models.Measurement
+	for i := 0; i < 3; i++ {
+		measurement := test_data.MeasurementFactory.MustCreate().(*models.Measurement)
+		assert.Nil(t, dao.SaveMeasurement(measurement))
+		saved = append(saved, measurement)
+	}
+
+	outbox := &OutboxDAO{}
+	var seen []models.MeasurementUUID
+	published, err := outbox.ProcessPending(models.OutboxSinkBroker, 10, func(event *models.OutboxEvent) error {
+		seen = append(seen, *event.MeasurementUuid)
+		if *event.MeasurementUuid == saved[1].Uuid {
+			return fmt.Errorf("broker is unavailable")
+		}
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, published)
+	assert.Equal(t, []models.MeasurementUUID{saved[0].Uuid, saved[1].Uuid, saved[2].Uuid}, seen)
+
+	seen = nil
+	published, err = outbox.ProcessPending(models.OutboxSinkBroker, 10, func(event *models.OutboxEvent) error {
+		seen = append(seen, *event.MeasurementUuid)
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, published)
+	assert.Equal(t, []models.MeasurementUUID{saved[1].Uuid}, seen)
+
+	// webhooks have not published the events yet
+	sinks := []string{models.OutboxSinkBroker, models.OutboxSinkWebhooks}
+	assert.Nil(t, outbox.DeletePublished(time.Now().Add(time.Hour), sinks))
+	var left int
+	config.Config.DB.Model(&models.OutboxEvent{}).Count(&left)
+	assert.Equal(t, 3, left)
+
+	published, err = outbox.ProcessPending(models.OutboxSinkWebhooks, 10, func(event *models.OutboxEvent) error {
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, published)
+	assert.Nil(t, outbox.DeletePublished(time.Now().Add(time.Hour), sinks))
+	config.Config.DB.Model(&models.OutboxEvent{}).Count(&left)
+	assert.Equal(t, 0, left)
+}
+
+func TestOutboxDAO_ProcessPendingInTargetSequence(t *testing.T) {
+	tx := test_data.OnBeforeDBTest()
+	defer test_data.OnAfterDBTest(tx)
+	publishOutbox(models.OutboxSinkBroker)
+
+	// a transaction numbered later may insert its event first
+	measurement := test_data.MeasurementFactory.MustCreate().(*models.Measurement)
+	var stored []*models.OutboxEvent
+	for _, sequence := range []int64{2, 1} {
+		event, err := models.NewMeasurementEvent(uuid.New().String(), models.OutboxEventMeasurementUpdated, measurement, time.Now())
+		if !assert.Nil(t, err) {
+			return
+		}
+		event.TargetSequence = sequence
+		assert.Nil(t, config.Config.DB.Create(event).Error)
+		stored = append(stored, event)
+	}
+
+	var seen []string
+	published, err := (&OutboxDAO{}).ProcessPending(models.OutboxSinkBroker, 10, func(event *models.OutboxEvent) error {
+		seen = append(seen, event.EventUuid)
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, published)
+	assert.Equal(t, []string{stored[1].EventUuid, stored[0].EventUuid}, seen)
+}
+
+func TestOutboxDAO_ProcessPendingSkipsClaimedTargets(t *testing.T) {
+	tx := test_data.OnBeforeDBTest()
+	defer test_data.OnAfterDBTest(tx)
+	publishOutbox(models.OutboxSinkBroker)
+
+	dao := &MeasurementDAO{}
+	claimed := test_data.MeasurementFactory.MustCreate().(*models.Measurement)
+	free := test_data.MeasurementFactory.MustCreate().(*models.Measurement)
+	assert.Nil(t, dao.SaveMeasurement(claimed))
+	assert.Nil(t, dao.SaveMeasurement(free))
+	claimed.Value = 42
+	assert.Nil(t, dao.SaveMeasurement(claimed))
+	// another relay is publishing events of the target to the broker
+	config.Config.DB.Exec("INSERT INTO outbox_cursors (sink, target_uuid, published_sequence, claimed_until) VALUES (?, ?, 0, ?)",
+		models.OutboxSinkBroker, claimed.TargetUuid, time.Now().Add(time.Minute))
+
+	outbox := &OutboxDAO{}
+	var seen []models.MeasurementUUID
+	publish := func(event *models.OutboxEvent) error {
+		seen = append(seen, *event.MeasurementUuid)
+		return nil
+	}
+	published, err := outbox.ProcessPending(models.OutboxSinkBroker, 10, publish)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, published)
+	assert.Equal(t, []models.MeasurementUUID{free.Uuid}, seen)
+
+	var cursor models.OutboxCursor
+	config.Config.DB.Where("sink = ? AND target_uuid = ?", models.OutboxSinkBroker, free.TargetUuid).First(&cursor)
+	assert.Equal(t, int64(1), cursor.PublishedSequence)
+	assert.Nil(t, cursor.ClaimedUntil)
+
+	// the claim of a crashed relay expires
+	config.Config.DB.Model(&models.OutboxCursor{}).
+		Where("sink = ? AND target_uuid = ?", models.OutboxSinkBroker, claimed.TargetUuid).
+		Update("claimed_until", time.Now().Add(-time.Minute))
+	seen = nil
+	published, err = outbox.ProcessPending(models.OutboxSinkBroker, 10, publish)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, published)
+	assert.Equal(t, []models.MeasurementUUID{claimed.Uuid, claimed.Uuid}, seen)
+}
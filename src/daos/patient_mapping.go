@@ -0,0 +1,23 @@
+package daos
+
+import (
+	"little-diary-measurement-service/src/config"
+	"little-diary-measurement-service/src/models"
+)
+
+type PatientMappingDAO struct{}
+
+func NewPatientMappingDAO() *PatientMappingDAO {
+	return &PatientMappingDAO{}
+}
+
+func (dao *PatientMappingDAO) GetByPatientIdentifier(assigningAuthority string, patientId string) (*models.PatientMapping, error) {
+	var mapping models.PatientMapping
+
+	err := config.Config.DB.
+		Where("assigning_authority = ? AND patient_id = ?", assigningAuthority, patientId).
+		First(&mapping).
+		Error
+
+	return &mapping, err
+}
@@ -0,0 +1,15 @@
+package daos
+
+import (
+	"database/sql"
+	"github.com/jinzhu/gorm"
+	"little-diary-measurement-service/src/config"
+)
+
+// inTransaction runs fc in a new transaction or joins the one config.Config.DB already is
+func inTransaction(fc func(tx *gorm.DB) error) error {
+	if _, ok := config.Config.DB.CommonDB().(*sql.Tx); ok {
+		return fc(config.Config.DB)
+	}
+	return config.Config.DB.Transaction(fc)
+}
@@ -0,0 +1,113 @@
+package daos
+
+import (
+	"database/sql"
+	"github.com/jinzhu/gorm"
+	"little-diary-measurement-service/src/config"
+	"little-diary-measurement-service/src/models"
+	"time"
+)
+
+// webhookDeliveryLease is how long a claimed delivery is hidden from other workers while it is being sent
+const webhookDeliveryLease = 2 * time.Minute
+
+type WebhookDAO struct{}
+
+func NewWebhookDAO() *WebhookDAO {
+	return &WebhookDAO{}
+}
+
+func (dao *WebhookDAO) GetByWebhookUuid(webhookUuid models.WebhookUUID) (*models.Webhook, error) {
+	var webhook models.Webhook
+
+	err := config.Config.DB.
+		Where("webhook_uuid = ?", webhookUuid).
+		First(&webhook).
+		Error
+
+	return &webhook, err
+}
+
+func (dao *WebhookDAO) GetWebhooksByTargetUuid(targetUuid models.TargetUUID) ([]*models.Webhook, error) {
+	var webhooks []*models.Webhook
+	err := config.Config.DB.
+		Where("target_uuid = ?", targetUuid).
+		Order("created_at ASC").
+		Find(&webhooks).
+		Error
+	return webhooks, err
+}
+
+func (dao *WebhookDAO) SaveWebhook(webhook *models.Webhook) error {
+	return config.Config.DB.Save(webhook).Error
+}
+
+// DeleteWebhook removes the webhook together with its delivery log
+func (dao *WebhookDAO) DeleteWebhook(webhook *models.Webhook) error {
+	return inTransaction(func(tx *gorm.DB) error {
+		if err := tx.Where("webhook_uuid = ?", webhook.Uuid).Delete(&models.WebhookDelivery{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(webhook).Error
+	})
+}
+
+func (dao *WebhookDAO) GetDelivery(deliveryUuid models.WebhookDeliveryUUID) (*models.WebhookDelivery, error) {
+	var delivery models.WebhookDelivery
+
+	err := config.Config.DB.
+		Where("delivery_uuid = ?", deliveryUuid).
+		First(&delivery).
+		Error
+
+	return &delivery, err
+}
+
+// GetDeliveries returns the delivery log of the webhook, the newest first
+func (dao *WebhookDAO) GetDeliveries(webhookUuid models.WebhookUUID, limit int) ([]*models.WebhookDelivery, error) {
+	var deliveries []*models.WebhookDelivery
+	err := config.Config.DB.
+		Where("webhook_uuid = ?", webhookUuid).
+		Order("id DESC").
+		Limit(limit).
+		Find(&deliveries).
+		Error
+	return deliveries, err
+}
+
+// CreateDelivery stores the delivery unless the event has already been queued for the webhook
+func (dao *WebhookDAO) CreateDelivery(delivery *models.WebhookDelivery) error {
+	err := config.Config.DB.
+		Set("gorm:insert_option", "ON CONFLICT (webhook_uuid, event_uuid) DO NOTHING").
+		Create(delivery).
+		Error
+	// a skipped insert returns no id
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	return err
+}
+
+func (dao *WebhookDAO) SaveDelivery(delivery *models.WebhookDelivery) error {
+	return config.Config.DB.Save(delivery).Error
+}
+
+// ClaimDueDeliveries takes pending deliveries of enabled webhooks whose attempt is due, oldest first.
+// Claimed deliveries are postponed by the lease so concurrent workers skip them, a crashed worker's claims expire
+func (dao *WebhookDAO) ClaimDueDeliveries(limit int, now time.Time) ([]*models.WebhookDelivery, error) {
+	var deliveries []*models.WebhookDelivery
+	err := config.Config.DB.Raw(`
+		UPDATE webhook_deliveries SET next_attempt_at = ?
+		WHERE id IN (
+			SELECT d.id FROM webhook_deliveries d
+			JOIN webhooks w ON w.webhook_uuid = d.webhook_uuid AND w.enabled
+			WHERE d.status = ? AND d.next_attempt_at <= ?
+			ORDER BY d.id
+			LIMIT ?
+			FOR UPDATE OF d SKIP LOCKED
+		)
+		RETURNING *`, now.Add(webhookDeliveryLease), models.WebhookDeliveryPending, now, limit).
+		Scan(&deliveries).
+		Error
+	return deliveries, err
+}
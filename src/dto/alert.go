@@ -0,0 +1,32 @@
+package dto
+
+import (
+	"little-diary-measurement-service/src/models"
+	"time"
+)
+
+type AlertResponse struct {
+	Uuid            string     `json:"uuid" swaggertype:"string" format:"uuid"`
+	TargetUuid      string     `json:"target_uuid" swaggertype:"string" format:"uuid"`
+	MeasurementUuid string     `json:"measurement_uuid" swaggertype:"string" format:"uuid"`
+	Kind            string     `json:"kind" enums:"BIRTH_WEIGHT_LOSS,PERCENTILE_CROSSING"`
+	Severity        string     `json:"severity" enums:"warning,critical"`
+	Reason          string     `json:"reason"`
+	CreatedAt       time.Time  `json:"created_at" swaggertype:"string" format:"datetime"`
+	AcknowledgedAt  *time.Time `json:"acknowledged_at" swaggertype:"string" format:"datetime"`
+	AcknowledgedBy  string     `json:"acknowledged_by,omitempty" swaggertype:"string" format:"uuid"`
+}
+
+func AlertResponseFromModel(source *models.Alert) *AlertResponse {
+	return &AlertResponse{
+		Uuid:            string(source.Uuid),
+		TargetUuid:      string(source.TargetUuid),
+		MeasurementUuid: string(source.MeasurementUuid),
+		Kind:            string(source.Kind),
+		Severity:        string(source.Severity),
+		Reason:          source.Reason,
+		CreatedAt:       source.CreatedAt,
+		AcknowledgedAt:  source.AcknowledgedAt,
+		AcknowledgedBy:  source.AcknowledgedBy,
+	}
+}
@@ -0,0 +1,33 @@
+package dto
+
+import (
+	"little-diary-measurement-service/src/models"
+	"time"
+)
+
+// ErasureReceiptResponse records what was erased, it holds no personal data
+type ErasureReceiptResponse struct {
+	Uuid                string    `json:"uuid" swaggertype:"string" format:"uuid"`
+	TargetUuid          string    `json:"target_uuid" swaggertype:"string" format:"uuid"`
+	RequestedBy         string    `json:"requested_by" enums:"family_event,api"`
+	EventUuid           string    `json:"event_uuid,omitempty" swaggertype:"string" format:"uuid"`
+	MeasurementsDeleted int64     `json:"measurements_deleted"`
+	AlertsDeleted       int64     `json:"alerts_deleted"`
+	WebhooksDeleted     int64     `json:"webhooks_deleted"`
+	OtherRecordsDeleted int64     `json:"other_records_deleted"`
+	ErasedAt            time.Time `json:"erased_at" swaggertype:"string" format:"datetime"`
+}
+
+func ErasureReceiptResponseFromModel(source *models.ErasureReceipt) *ErasureReceiptResponse {
+	return &ErasureReceiptResponse{
+		Uuid:                string(source.Uuid),
+		TargetUuid:          string(source.TargetUuid),
+		RequestedBy:         string(source.RequestedBy),
+		EventUuid:           source.EventUuid,
+		MeasurementsDeleted: source.MeasurementsDeleted,
+		AlertsDeleted:       source.AlertsDeleted,
+		WebhooksDeleted:     source.WebhooksDeleted,
+		OtherRecordsDeleted: source.OtherRecordsDeleted,
+		ErasedAt:            source.CreatedAt,
+	}
+}
@@ -0,0 +1,34 @@
+package dto
+
+import (
+	"little-diary-measurement-service/src/models"
+	"time"
+)
+
+type ExportResponse struct {
+	Uuid             string     `json:"uuid" swaggertype:"string" format:"uuid"`
+	Status           string     `json:"status" enums:"pending,running,completed,failed"`
+	TargetCount      int        `json:"target_count"`
+	MeasurementCount int        `json:"measurement_count"`
+	Error            string     `json:"error,omitempty"`
+	CreatedAt        time.Time  `json:"created_at" swaggertype:"string" format:"datetime"`
+	CompletedAt      *time.Time `json:"completed_at" swaggertype:"string" format:"datetime"`
+	// the export and its archive are deleted then
+	ExpiresAt *time.Time `json:"expires_at" swaggertype:"string" format:"datetime"`
+	// signed link to the zip archive of a completed export, it works without authorization until the link expires
+	DownloadUrl          string     `json:"download_url,omitempty"`
+	DownloadUrlExpiresAt *time.Time `json:"download_url_expires_at,omitempty" swaggertype:"string" format:"datetime"`
+}
+
+func ExportResponseFromModel(source *models.ExportJob) *ExportResponse {
+	return &ExportResponse{
+		Uuid:             string(source.Uuid),
+		Status:           string(source.Status),
+		TargetCount:      len(source.TargetUuids),
+		MeasurementCount: source.MeasurementCount,
+		Error:            source.Error,
+		CreatedAt:        source.CreatedAt,
+		CompletedAt:      source.CompletedAt,
+		ExpiresAt:        source.ExpiresAt,
+	}
+}
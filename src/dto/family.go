@@ -0,0 +1,21 @@
+package dto
+
+// FamilyEvent is pushed by the family service when a user gains, changes or loses access to a baby
+// and when a baby is deleted
+type FamilyEvent struct {
+	Type string `json:"type" enums:"MembershipGranted,MembershipChanged,MembershipRevoked,BabyDeleted"`
+	// identifies the event for deduplication, required for BabyDeleted
+	EventUuid   string   `json:"event_uuid" swaggertype:"string" format:"uuid"`
+	UserUuid    string   `json:"user_uuid" swaggertype:"string" format:"uuid"`
+	BabyUuid    string   `json:"baby_uuid" swaggertype:"string" format:"uuid"`
+	Permissions []string `json:"permissions" enums:"read,write,delete,admin"`
+	// grows with every change of the membership, older events are ignored
+	Version int64 `json:"version"`
+}
+
+const (
+	FamilyEventMembershipGranted = "MembershipGranted"
+	FamilyEventMembershipChanged = "MembershipChanged"
+	FamilyEventMembershipRevoked = "MembershipRevoked"
+	FamilyEventBabyDeleted       = "BabyDeleted"
+)
@@ -6,20 +6,57 @@ import (
 )
 
 type MeasurementRequest struct {
-	Type       string    `json:"type" enums:"HEIGHT,WEIGHT"`
+	Type       string    `json:"type" enums:"HEIGHT,WEIGHT,HEAD_CIRCUMFERENCE"`
 	Timestamp  time.Time `json:"ts" swaggertype:"string" format:"datetime"`
 	Value      float32   `json:"value"`
 	TargetUuid string    `json:"target_uuid" swaggertype:"string" format:"uuid"`
+	// left out keeps the stored flag, new measurements are not birth records then
+	IsBirth *bool `json:"is_birth"`
 }
 
 type MeasurementResponse struct {
-	Type       string    `json:"type" enums:"HEIGHT,WEIGHT"`
-	Timestamp  time.Time `json:"ts" swaggertype:"string" format:"datetime"`
-	Value      float32   `json:"value"`
-	Uuid       string    `json:"uuid" swaggertype:"string" format:"uuid"`
-	TargetUuid string    `json:"target_uuid" swaggertype:"string" format:"uuid"`
+	Type       string           `json:"type" enums:"HEIGHT,WEIGHT,HEAD_CIRCUMFERENCE,BMI,WEIGHT_FOR_LENGTH"`
+	Timestamp  time.Time        `json:"ts" swaggertype:"string" format:"datetime"`
+	Value      float32          `json:"value"`
+	Uuid       string           `json:"uuid" swaggertype:"string" format:"uuid"`
+	TargetUuid string           `json:"target_uuid" swaggertype:"string" format:"uuid"`
+	Source     string           `json:"source" enums:"user,clinic"`
+	IsBirth    bool             `json:"is_birth"`
+	Suspect    bool             `json:"suspect"`
+	Warning    *WarningResponse `json:"warning,omitempty"`
+	Alerts     []*AlertResponse `json:"alerts"`
+	// absent when birth date of the target is unknown
+	Age *AgeResponse `json:"age,omitempty"`
+	// derived types only: uuids of the weight and the height, reference percentile when sex of the target is known
+	DerivedFrom []string `json:"derived_from,omitempty"`
+	Percentile  *float64 `json:"percentile,omitempty"`
 }
 
+// AgeResponse is completed age of the target at the measurement
+type AgeResponse struct {
+	Days   int `json:"days"`
+	Weeks  int `json:"weeks"`
+	Months int `json:"months"`
+	// only for preterm babies until the second birthday, negative before the due date
+	Corrected *AgeResponse `json:"corrected,omitempty"`
+}
+
+func ageResponseFromModel(source *models.MeasurementAge) *AgeResponse {
+	a := &AgeResponse{Days: source.Days, Weeks: source.Weeks, Months: source.Months}
+	if source.Corrected != nil {
+		a.Corrected = ageResponseFromModel(source.Corrected)
+	}
+	return a
+}
+
+// WarningResponse tells the client that the saved value needs user attention
+type WarningResponse struct {
+	Code    string `json:"code" enums:"SUSPECT_VALUE"`
+	Message string `json:"message"`
+}
+
+const WarningCodeSuspectValue = "SUSPECT_VALUE"
+
 func MeasurementResponseFromModel(source *models.Measurement) *MeasurementResponse {
 	m := &MeasurementResponse{
 		Type:       string(source.Type),
@@ -27,6 +64,294 @@ func MeasurementResponseFromModel(source *models.Measurement) *MeasurementRespon
 		Value:      source.Value,
 		Uuid:       string(source.Uuid),
 		TargetUuid: string(source.TargetUuid),
+		Source:     string(source.Source),
+		IsBirth:    source.IsBirth,
+		Suspect:    source.Suspect,
+		Alerts:     []*AlertResponse{},
 	}
+	if source.Suspect {
+		m.Warning = &WarningResponse{Code: WarningCodeSuspectValue, Message: source.SuspectReason}
+	}
+	for _, a := range source.Alerts {
+		m.Alerts = append(m.Alerts, AlertResponseFromModel(a))
+	}
+	if source.Age != nil {
+		m.Age = ageResponseFromModel(source.Age)
+	}
+	for _, derivedFrom := range source.DerivedFrom {
+		m.DerivedFrom = append(m.DerivedFrom, string(derivedFrom))
+	}
+	m.Percentile = source.Percentile
 	return m
 }
+
+type MeasurementAggregateResponse struct {
+	Bucket time.Time `json:"bucket" swaggertype:"string" format:"datetime"`
+	Count  int       `json:"count"`
+	Min    float32   `json:"min"`
+	Max    float32   `json:"max"`
+	Avg    float32   `json:"avg"`
+	Last   float32   `json:"last"`
+}
+
+// MeasurementAggregateResponseFromModel renders bucket start in the requested location
+func MeasurementAggregateResponseFromModel(source *models.MeasurementAggregate, location *time.Location) *MeasurementAggregateResponse {
+	return &MeasurementAggregateResponse{
+		Bucket: source.Bucket.In(location),
+		Count:  source.Count,
+		Min:    source.Min,
+		Max:    source.Max,
+		Avg:    source.Avg,
+		Last:   source.Last,
+	}
+}
+
+type VelocityResponse struct {
+	Type      string                      `json:"type" enums:"HEIGHT,WEIGHT,HEAD_CIRCUMFERENCE"`
+	Unit      string                      `json:"unit" enums:"g/day,cm/month"`
+	Intervals []*VelocityIntervalResponse `json:"intervals"`
+	Window    *VelocityIntervalResponse   `json:"window"`
+}
+
+type VelocityIntervalResponse struct {
+	From      time.Time                  `json:"from" swaggertype:"string" format:"datetime"`
+	To        time.Time                  `json:"to" swaggertype:"string" format:"datetime"`
+	FromUuid  string                     `json:"from_uuid" swaggertype:"string" format:"uuid"`
+	ToUuid    string                     `json:"to_uuid" swaggertype:"string" format:"uuid"`
+	Days      float64                    `json:"days"`
+	Gain      float64                    `json:"gain"`
+	Rate      float64                    `json:"rate"`
+	Reference *VelocityReferenceResponse `json:"reference,omitempty"`
+}
+
+type VelocityReferenceResponse struct {
+	IntervalMonths int     `json:"interval_months"`
+	MedianRate     float64 `json:"median_rate"`
+	Percentile     float64 `json:"percentile"`
+}
+
+func VelocityResponseFromModel(source *models.Velocity) *VelocityResponse {
+	v := &VelocityResponse{
+		Type:      string(source.Type),
+		Unit:      source.Unit,
+		Intervals: []*VelocityIntervalResponse{},
+	}
+	for _, interval := range source.Intervals {
+		v.Intervals = append(v.Intervals, velocityIntervalResponseFromModel(interval))
+	}
+	if source.Window != nil {
+		v.Window = velocityIntervalResponseFromModel(source.Window)
+	}
+	return v
+}
+
+func velocityIntervalResponseFromModel(source *models.VelocityInterval) *VelocityIntervalResponse {
+	i := &VelocityIntervalResponse{
+		From:     source.From.Timestamp,
+		To:       source.To.Timestamp,
+		FromUuid: string(source.From.Uuid),
+		ToUuid:   string(source.To.Uuid),
+		Days:     source.Days,
+		Gain:     source.Gain,
+		Rate:     source.Rate,
+	}
+	if source.Reference != nil {
+		i.Reference = &VelocityReferenceResponse{
+			IntervalMonths: source.Reference.IntervalMonths,
+			MedianRate:     source.Reference.MedianRate,
+			Percentile:     source.Reference.Percentile,
+		}
+	}
+	return i
+}
+
+type TargetLatestResponse struct {
+	TargetUuid string                       `json:"target_uuid" swaggertype:"string" format:"uuid"`
+	Latest     []*LatestMeasurementResponse `json:"latest"`
+}
+
+type LatestMeasurementResponse struct {
+	Type              string     `json:"type" enums:"HEIGHT,WEIGHT,HEAD_CIRCUMFERENCE"`
+	Uuid              string     `json:"uuid" swaggertype:"string" format:"uuid"`
+	Timestamp         time.Time  `json:"ts" swaggertype:"string" format:"datetime"`
+	Value             float32    `json:"value"`
+	PreviousTimestamp *time.Time `json:"previous_ts" swaggertype:"string" format:"datetime"`
+	Change            *float32   `json:"change"`
+	DaysSince         int        `json:"days_since"`
+}
+
+// TargetLatestResponsesFromModel groups latest measurements by requested targets keeping their order,
+// targets without measurements get an empty list
+func TargetLatestResponsesFromModel(targetUuids []string, source []*models.LatestMeasurement, now time.Time) []*TargetLatestResponse {
+	byTarget := map[string]*TargetLatestResponse{}
+	result := []*TargetLatestResponse{}
+	for _, targetUuid := range targetUuids {
+		if _, ok := byTarget[targetUuid]; ok {
+			continue
+		}
+		target := &TargetLatestResponse{TargetUuid: targetUuid, Latest: []*LatestMeasurementResponse{}}
+		byTarget[targetUuid] = target
+		result = append(result, target)
+	}
+	for _, latest := range source {
+		target, ok := byTarget[string(latest.TargetUuid)]
+		if !ok {
+			continue
+		}
+		target.Latest = append(target.Latest, latestMeasurementResponseFromModel(latest, now))
+	}
+	return result
+}
+
+func latestMeasurementResponseFromModel(latest *models.LatestMeasurement, now time.Time) *LatestMeasurementResponse {
+	l := &LatestMeasurementResponse{
+		Type:              string(latest.Type),
+		Uuid:              string(latest.Uuid),
+		Timestamp:         latest.Timestamp,
+		Value:             latest.Value,
+		PreviousTimestamp: latest.PreviousTimestamp,
+		DaysSince:         int(now.Sub(latest.Timestamp).Hours() / 24),
+	}
+	if latest.PreviousValue != nil {
+		change := latest.Value - *latest.PreviousValue
+		l.Change = &change
+	}
+	return l
+}
+
+type TargetOverviewResponse struct {
+	TargetUuid  string                       `json:"target_uuid" swaggertype:"string" format:"uuid"`
+	Permissions []string                     `json:"permissions"`
+	Latest      []*LatestMeasurementResponse `json:"latest"`
+	// number of stored measurements by type, types without measurements are left out
+	Counts     map[string]int   `json:"counts"`
+	OpenAlerts []*AlertResponse `json:"open_alerts"`
+}
+
+func TargetOverviewResponseFromModel(source *models.TargetOverview, now time.Time) *TargetOverviewResponse {
+	o := &TargetOverviewResponse{
+		TargetUuid:  string(source.TargetUuid),
+		Permissions: []string{},
+		Latest:      []*LatestMeasurementResponse{},
+		Counts:      map[string]int{},
+		OpenAlerts:  []*AlertResponse{},
+	}
+	for _, permission := range source.Permissions {
+		o.Permissions = append(o.Permissions, string(permission))
+	}
+	for _, latest := range source.Latest {
+		o.Latest = append(o.Latest, latestMeasurementResponseFromModel(latest, now))
+	}
+	for t, count := range source.Counts {
+		o.Counts[string(t)] = count
+	}
+	for _, alert := range source.OpenAlerts {
+		o.OpenAlerts = append(o.OpenAlerts, AlertResponseFromModel(alert))
+	}
+	return o
+}
+
+type InterpolationResponse struct {
+	Type      string               `json:"type" enums:"HEIGHT,WEIGHT,HEAD_CIRCUMFERENCE"`
+	Timestamp time.Time            `json:"ts" swaggertype:"string" format:"datetime"`
+	Value     float32              `json:"value"`
+	Method    string               `json:"method" enums:"exact,linear,percentile"`
+	Before    *MeasurementResponse `json:"before"`
+	After     *MeasurementResponse `json:"after"`
+	// absent when birth date of the target is unknown
+	Age *AgeResponse `json:"age,omitempty"`
+}
+
+func InterpolationResponseFromModel(source *models.Interpolation) *InterpolationResponse {
+	i := &InterpolationResponse{
+		Type:      string(source.Type),
+		Timestamp: source.Timestamp,
+		Value:     source.Value,
+		Method:    string(source.Method),
+		Before:    MeasurementResponseFromModel(source.Before),
+		After:     MeasurementResponseFromModel(source.After),
+	}
+	if source.Age != nil {
+		i.Age = ageResponseFromModel(source.Age)
+	}
+	return i
+}
+
+type ProjectionResponse struct {
+	Type string `json:"type" enums:"HEIGHT,WEIGHT,HEAD_CIRCUMFERENCE"`
+	// reference percentile of the channel the projection follows
+	Percentile float64                    `json:"percentile"`
+	Basis      []*MeasurementResponse     `json:"basis"`
+	Points     []*ProjectionPointResponse `json:"points"`
+}
+
+type ProjectionPointResponse struct {
+	Timestamp time.Time    `json:"ts" swaggertype:"string" format:"datetime"`
+	Value     float32      `json:"value"`
+	Low       float32      `json:"low"`
+	High      float32      `json:"high"`
+	Age       *AgeResponse `json:"age,omitempty"`
+}
+
+func ProjectionResponseFromModel(source *models.Projection) *ProjectionResponse {
+	p := &ProjectionResponse{
+		Type:       string(source.Type),
+		Percentile: source.Percentile,
+		Basis:      []*MeasurementResponse{},
+		Points:     []*ProjectionPointResponse{},
+	}
+	for _, m := range source.Basis {
+		p.Basis = append(p.Basis, MeasurementResponseFromModel(m))
+	}
+	for _, point := range source.Points {
+		r := &ProjectionPointResponse{Timestamp: point.Timestamp, Value: point.Value, Low: point.Low, High: point.High}
+		if point.Age != nil {
+			r.Age = ageResponseFromModel(point.Age)
+		}
+		p.Points = append(p.Points, r)
+	}
+	return p
+}
+
+type ComparisonResponse struct {
+	Type    string                      `json:"type" enums:"HEIGHT,WEIGHT,HEAD_CIRCUMFERENCE"`
+	AgeUnit string                      `json:"age_unit" enums:"days,months"`
+	Ages    []int                       `json:"ages"`
+	Series  []*ComparisonSeriesResponse `json:"series"`
+}
+
+// ComparisonSeriesResponse has a value for every age of the comparison, null outside of the measured history
+type ComparisonSeriesResponse struct {
+	TargetUuid   string                 `json:"target_uuid" swaggertype:"string" format:"uuid"`
+	BirthDate    string                 `json:"birth_date" format:"date"`
+	Values       []*float32             `json:"values"`
+	Measurements []*MeasurementResponse `json:"measurements"`
+}
+
+func ComparisonResponseFromModel(source *models.Comparison, unit models.AgeUnit) *ComparisonResponse {
+	c := &ComparisonResponse{
+		Type:    string(source.Type),
+		AgeUnit: string(unit),
+		Ages:    []int{},
+		Series:  []*ComparisonSeriesResponse{},
+	}
+	for _, age := range source.Ages {
+		c.Ages = append(c.Ages, age.Value)
+	}
+	for _, series := range source.Series {
+		s := &ComparisonSeriesResponse{
+			TargetUuid:   string(series.TargetUuid),
+			BirthDate:    series.BirthDate.Format("2006-01-02"),
+			Values:       series.Values,
+			Measurements: []*MeasurementResponse{},
+		}
+		if s.Values == nil {
+			s.Values = []*float32{}
+		}
+		for _, m := range series.Measurements {
+			s.Measurements = append(s.Measurements, MeasurementResponseFromModel(m))
+		}
+		c.Series = append(c.Series, s)
+	}
+	return c
+}
@@ -0,0 +1,77 @@
+package dto
+
+import (
+	"little-diary-measurement-service/src/models"
+	"time"
+)
+
+type WebhookRequest struct {
+	TargetUuid string   `json:"target_uuid" swaggertype:"string" format:"uuid"`
+	Url        string   `json:"url"`
+	EventTypes []string `json:"event_types" enums:"MeasurementCreated,MeasurementUpdated,MeasurementDeleted"`
+	// omitted keeps the webhook as it is, new webhooks are enabled
+	Enabled *bool `json:"enabled"`
+}
+
+type WebhookResponse struct {
+	Uuid                string     `json:"uuid" swaggertype:"string" format:"uuid"`
+	TargetUuid          string     `json:"target_uuid" swaggertype:"string" format:"uuid"`
+	Url                 string     `json:"url"`
+	EventTypes          []string   `json:"event_types"`
+	Enabled             bool       `json:"enabled"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	DisabledAt          *time.Time `json:"disabled_at" swaggertype:"string" format:"datetime"`
+	CreatedAt           time.Time  `json:"created_at" swaggertype:"string" format:"datetime"`
+	// signing secret, returned only when the webhook is created
+	Secret string `json:"secret,omitempty"`
+}
+
+type WebhookDeliveryResponse struct {
+	Uuid           string     `json:"uuid" swaggertype:"string" format:"uuid"`
+	WebhookUuid    string     `json:"webhook_uuid" swaggertype:"string" format:"uuid"`
+	EventUuid      string     `json:"event_uuid" swaggertype:"string" format:"uuid"`
+	EventType      string     `json:"event_type" enums:"MeasurementCreated,MeasurementUpdated,MeasurementDeleted"`
+	Status         string     `json:"status" enums:"pending,succeeded,failed"`
+	Attempts       int        `json:"attempts"`
+	NextAttemptAt  *time.Time `json:"next_attempt_at" swaggertype:"string" format:"datetime"`
+	LastAttemptAt  *time.Time `json:"last_attempt_at" swaggertype:"string" format:"datetime"`
+	LastStatusCode int        `json:"last_status_code,omitempty"`
+	LastError      string     `json:"last_error,omitempty"`
+	CreatedAt      time.Time  `json:"created_at" swaggertype:"string" format:"datetime"`
+}
+
+func WebhookResponseFromModel(source *models.Webhook) *WebhookResponse {
+	eventTypes := []string{}
+	for _, t := range source.GetEventTypes() {
+		eventTypes = append(eventTypes, string(t))
+	}
+	return &WebhookResponse{
+		Uuid:                string(source.Uuid),
+		TargetUuid:          string(source.TargetUuid),
+		Url:                 source.Url,
+		EventTypes:          eventTypes,
+		Enabled:             source.Enabled,
+		ConsecutiveFailures: source.ConsecutiveFailures,
+		DisabledAt:          source.DisabledAt,
+		CreatedAt:           source.CreatedAt,
+	}
+}
+
+func WebhookDeliveryResponseFromModel(source *models.WebhookDelivery) *WebhookDeliveryResponse {
+	response := &WebhookDeliveryResponse{
+		Uuid:           string(source.Uuid),
+		WebhookUuid:    string(source.WebhookUuid),
+		EventUuid:      source.EventUuid,
+		EventType:      string(source.EventType),
+		Status:         string(source.Status),
+		Attempts:       source.Attempts,
+		LastAttemptAt:  source.LastAttemptAt,
+		LastStatusCode: source.LastStatusCode,
+		LastError:      source.LastError,
+		CreatedAt:      source.CreatedAt,
+	}
+	if source.Status == models.WebhookDeliveryPending {
+		response.NextAttemptAt = source.NextAttemptAt
+	}
+	return response
+}
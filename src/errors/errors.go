@@ -7,3 +7,20 @@ type ForbiddenError struct {
 func (e *ForbiddenError) Error() string {
 	return e.S
 }
+
+type ValidationError struct {
+	S string
+}
+
+func (e *ValidationError) Error() string {
+	return e.S
+}
+
+// LimitError means the caller has used up a limit, retrying later may succeed
+type LimitError struct {
+	S string
+}
+
+func (e *LimitError) Error() string {
+	return e.S
+}
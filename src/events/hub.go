@@ -0,0 +1,86 @@
+package events
+
+import "sync"
+
+// Hub fans messages out to in-process subscribers of their key. Publishing never blocks,
+// a subscriber falling more than its buffer behind is unsubscribed and its channel closed
+type Hub struct {
+	mu          sync.Mutex
+	subscribers map[string]map[*Subscription]bool
+	owners      map[string]int
+}
+
+type Subscription struct {
+	C     <-chan Message
+	c     chan Message
+	hub   *Hub
+	key   string
+	owner string
+}
+
+func NewHub() *Hub {
+	return &Hub{subscribers: map[string]map[*Subscription]bool{}, owners: map[string]int{}}
+}
+
+// Subscribe receives messages of the key, false is returned when the owner already holds limit subscriptions
+func (h *Hub) Subscribe(key string, owner string, limit int, buffer int) (*Subscription, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if limit > 0 && h.owners[owner] >= limit {
+		return nil, false
+	}
+	c := make(chan Message, buffer)
+	s := &Subscription{C: c, c: c, hub: h, key: key, owner: owner}
+	if h.subscribers[key] == nil {
+		h.subscribers[key] = map[*Subscription]bool{}
+	}
+	h.subscribers[key][s] = true
+	h.owners[owner]++
+	return s, true
+}
+
+func (h *Hub) Publish(message Message) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for s := range h.subscribers[message.Key] {
+		select {
+		case s.c <- message:
+		default:
+			h.remove(s)
+		}
+	}
+	return nil
+}
+
+// Close unsubscribes everybody, the hub takes new subscriptions afterwards
+func (h *Hub) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, subscriptions := range h.subscribers {
+		for s := range subscriptions {
+			h.remove(s)
+		}
+	}
+	return nil
+}
+
+// Close unsubscribes, it may be called more than once
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.hub.remove(s)
+}
+
+func (h *Hub) remove(s *Subscription) {
+	if !h.subscribers[s.key][s] {
+		return
+	}
+	delete(h.subscribers[s.key], s)
+	if len(h.subscribers[s.key]) == 0 {
+		delete(h.subscribers, s.key)
+	}
+	if h.owners[s.owner]--; h.owners[s.owner] == 0 {
+		delete(h.owners, s.owner)
+	}
+	close(s.c)
+}
@@ -0,0 +1,35 @@
+package events
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestHub(t *testing.T) {
+	hub := NewHub()
+	first, ok := hub.Subscribe("t1", "user", 2, 1)
+	assert.True(t, ok)
+	second, ok := hub.Subscribe("t2", "user", 2, 1)
+	assert.True(t, ok)
+	_, ok = hub.Subscribe("t1", "user", 2, 1)
+	assert.False(t, ok, "the owner has reached the limit")
+	_, ok = hub.Subscribe("t1", "other", 2, 1)
+	assert.True(t, ok)
+
+	hub.Publish(Message{ID: "1", Key: "t1"})
+	assert.Equal(t, "1", (<-first.C).ID)
+	assert.Len(t, second.C, 0)
+
+	hub.Publish(Message{ID: "2", Key: "t1"})
+	hub.Publish(Message{ID: "3", Key: "t1"})
+	assert.Equal(t, "2", (<-first.C).ID)
+	_, open := <-first.C
+	assert.False(t, open, "slow subscriber is dropped")
+
+	second.Close()
+	second.Close()
+	_, ok = hub.Subscribe("t1", "user", 2, 1)
+	assert.True(t, ok, "closed subscriptions free the limit")
+
+	hub.Close()
+}
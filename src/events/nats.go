@@ -0,0 +1,258 @@
+package events
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const natsDefaultTimeout = 5 * time.Second
+
+// NatsPublisher publishes to a JetStream stream over the NATS client protocol. Every message carries the event uuid
+// as Nats-Msg-Id, which the stream deduplicates redeliveries by, and a reply inbox the stream acknowledges storing
+// the message to. A message is accepted only with that acknowledgement, so the subjects have to be captured
+// by a stream. A broken connection is dropped and dialled again on the next message
+type NatsPublisher struct {
+	// nats://[user:password@]host:port
+	Url     string
+	Timeout time.Duration
+
+	mu     sync.Mutex
+	conn   net.Conn
+	reader *bufio.Reader
+	inbox  string
+	sent   int
+}
+
+type natsInfo struct {
+	Headers bool `json:"headers"`
+}
+
+type natsConnect struct {
+	Verbose      bool   `json:"verbose"`
+	Pedantic     bool   `json:"pedantic"`
+	Name         string `json:"name"`
+	Lang         string `json:"lang"`
+	Version      string `json:"version"`
+	Headers      bool   `json:"headers"`
+	NoResponders bool   `json:"no_responders"`
+	User         string `json:"user,omitempty"`
+	Pass         string `json:"pass,omitempty"`
+}
+
+// natsPubAck is the reply of JetStream to a publication, Error is set when the stream has not stored the message
+type natsPubAck struct {
+	Stream    string `json:"stream"`
+	Seq       uint64 `json:"seq"`
+	Duplicate bool   `json:"duplicate"`
+	Error     *struct {
+		Code        int    `json:"code"`
+		Description string `json:"description"`
+	} `json:"error"`
+}
+
+func NewNatsPublisher(natsUrl string) *NatsPublisher {
+	return &NatsPublisher{Url: natsUrl, Timeout: natsDefaultTimeout}
+}
+
+func (p *NatsPublisher) Publish(message Message) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.conn == nil {
+		if err := p.connect(); err != nil {
+			return err
+		}
+	}
+	if err := p.publish(message); err != nil {
+		p.drop()
+		return err
+	}
+	return nil
+}
+
+func (p *NatsPublisher) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.drop()
+	return nil
+}
+
+func (p *NatsPublisher) connect() error {
+	server, err := url.Parse(p.Url)
+	if err != nil {
+		return err
+	}
+	conn, err := net.DialTimeout("tcp", server.Host, p.Timeout)
+	if err != nil {
+		return err
+	}
+	p.conn, p.reader = conn, bufio.NewReader(conn)
+	if err := p.handshake(server); err != nil {
+		p.drop()
+		return err
+	}
+	return nil
+}
+
+// handshake connects and subscribes to the reply inbox, the PONG to the closing PING confirms both
+func (p *NatsPublisher) handshake(server *url.URL) error {
+	p.conn.SetDeadline(time.Now().Add(p.Timeout))
+	line, err := p.readLine()
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(line, "INFO ") {
+		return fmt.Errorf("nats server sent %q instead of INFO", line)
+	}
+	var info natsInfo
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "INFO ")), &info); err != nil {
+		return err
+	}
+	if !info.Headers {
+		return fmt.Errorf("nats server does not support headers, JetStream publishing needs them")
+	}
+	connect := natsConnect{Name: "little-diary-measurement-service", Lang: "go", Version: "1.0.0", Headers: true, NoResponders: true}
+	if server.User != nil {
+		connect.User = server.User.Username()
+		connect.Pass, _ = server.User.Password()
+	}
+	options, err := json.Marshal(&connect)
+	if err != nil {
+		return err
+	}
+	p.inbox, p.sent = "_INBOX."+strings.ReplaceAll(uuid.New().String(), "-", ""), 0
+	if _, err := fmt.Fprintf(p.conn, "CONNECT %s\r\nSUB %s.* 1\r\nPING\r\n", options, p.inbox); err != nil {
+		return err
+	}
+	for {
+		line, err := p.readLine()
+		if err != nil {
+			return err
+		}
+		switch {
+		case line == "PONG":
+			return nil
+		case line == "PING":
+			if _, err := fmt.Fprint(p.conn, "PONG\r\n"); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			return natsError(line)
+		}
+	}
+}
+
+func (p *NatsPublisher) publish(message Message) error {
+	p.conn.SetDeadline(time.Now().Add(p.Timeout))
+	p.sent++
+	reply := fmt.Sprintf("%s.%d", p.inbox, p.sent)
+	header := fmt.Sprintf("NATS/1.0\r\nNats-Msg-Id: %s\r\n\r\n", message.ID)
+	_, err := fmt.Fprintf(p.conn, "HPUB %s %s %d %d\r\n%s%s\r\n", message.Subject, reply, len(header), len(header)+len(message.Data), header, message.Data)
+	if err != nil {
+		return err
+	}
+	return p.awaitAck(message.Subject, reply)
+}
+
+// awaitAck reads until the stream acknowledges the message sent with the reply subject, pings are answered
+// meanwhile and replies to earlier messages are skipped
+func (p *NatsPublisher) awaitAck(subject string, reply string) error {
+	for {
+		line, err := p.readLine()
+		if err != nil {
+			return err
+		}
+		fields := strings.Fields(line)
+		switch {
+		case line == "PING":
+			if _, err := fmt.Fprint(p.conn, "PONG\r\n"); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			return natsError(line)
+		case len(fields) >= 4 && fields[0] == "MSG":
+			payload, err := p.readPayload(fields[len(fields)-1])
+			if err != nil {
+				return err
+			}
+			if fields[1] == reply {
+				return pubAckError(payload)
+			}
+		case len(fields) >= 5 && fields[0] == "HMSG":
+			payload, err := p.readPayload(fields[len(fields)-1])
+			if err != nil {
+				return err
+			}
+			if fields[1] != reply {
+				continue
+			}
+			// a status line without a body is the server answering in place of the stream
+			headerSize, _ := strconv.Atoi(fields[len(fields)-2])
+			if headerSize > len(payload) {
+				return fmt.Errorf("nats server sent malformed headers")
+			}
+			status := strings.Fields(strings.SplitN(string(payload[:headerSize]), "\r\n", 2)[0])
+			if len(status) > 1 && status[1] == "503" {
+				return fmt.Errorf("no JetStream stream captures subject %s", subject)
+			}
+			if len(status) > 1 {
+				return fmt.Errorf("nats server answered %s with status %s", subject, strings.Join(status[1:], " "))
+			}
+			return pubAckError(payload[headerSize:])
+		}
+	}
+}
+
+func pubAckError(payload []byte) error {
+	var ack natsPubAck
+	if err := json.Unmarshal(payload, &ack); err != nil {
+		return fmt.Errorf("nats reply is not a JetStream acknowledgement: %v", err)
+	}
+	if ack.Error != nil {
+		return fmt.Errorf("JetStream rejected the message: %s (%d)", ack.Error.Description, ack.Error.Code)
+	}
+	if ack.Stream == "" {
+		return fmt.Errorf("nats reply is not a JetStream acknowledgement")
+	}
+	return nil
+}
+
+func natsError(line string) error {
+	return fmt.Errorf("nats server error: %s", strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+}
+
+func (p *NatsPublisher) readLine() (string, error) {
+	line, err := p.reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// readPayload reads a message body of the given size with its closing CRLF
+func (p *NatsPublisher) readPayload(size string) ([]byte, error) {
+	n, err := strconv.Atoi(size)
+	if err != nil || n < 0 {
+		return nil, fmt.Errorf("nats server sent invalid message size %s", size)
+	}
+	payload := make([]byte, n+2)
+	if _, err := io.ReadFull(p.reader, payload); err != nil {
+		return nil, err
+	}
+	return payload[:n], nil
+}
+
+func (p *NatsPublisher) drop() {
+	if p.conn != nil {
+		p.conn.Close()
+	}
+	p.conn, p.reader = nil, nil
+}
@@ -0,0 +1,175 @@
+package events
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeJetStream answers publications the way a server with a stream on the measurements subjects does
+// and records the published frames. Other subjects get replies of the failure the subject is named after
+type fakeJetStream struct {
+	listener net.Listener
+	headers  bool
+	frames   chan string
+}
+
+func newFakeJetStream(t *testing.T, headers bool) *fakeJetStream {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeJetStream{listener: listener, headers: headers, frames: make(chan string, 10)}
+	go s.serve()
+	return s
+}
+
+func (s *fakeJetStream) url() string {
+	return "nats://user:secret@" + s.listener.Addr().String()
+}
+
+func (s *fakeJetStream) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *fakeJetStream) handle(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	fmt.Fprintf(conn, "INFO {\"server_id\":\"fake\",\"headers\":%v,\"jetstream\":true}\r\n", s.headers)
+	sid, seq := "", 0
+	reply := func(subject string, body string) {
+		fmt.Fprintf(conn, "MSG %s %s %d\r\n%s\r\n", subject, sid, len(body), body)
+	}
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		fields := strings.Fields(line)
+		switch fields[0] {
+		case "CONNECT":
+			s.frames <- line
+		case "SUB":
+			sid = fields[2]
+		case "PING":
+			fmt.Fprint(conn, "PING\r\nPONG\r\n")
+		case "HPUB":
+			size, _ := strconv.Atoi(fields[len(fields)-1])
+			payload := make([]byte, size+2)
+			if _, err := io.ReadFull(reader, payload); err != nil {
+				return
+			}
+			s.frames <- line + "\n" + string(payload[:size])
+			subject, inbox := fields[1], fields[2]
+			switch subject {
+			case "forbidden":
+				fmt.Fprint(conn, "-ERR 'Permissions Violation for Publish to forbidden'\r\n")
+				return
+			case "unstored":
+				fmt.Fprintf(conn, "HMSG %s %s 16 16\r\nNATS/1.0 503\r\n\r\n\r\n", inbox, sid)
+			case "rejected":
+				reply(inbox, `{"error":{"code":503,"err_code":10077,"description":"maximum messages exceeded"}}`)
+			case "silent":
+			default:
+				// a late reply of an earlier message is skipped by the publisher
+				reply(inbox+"0", `{"stream":"OTHER","seq":1}`)
+				seq++
+				reply(inbox, fmt.Sprintf(`{"stream":"MEASUREMENTS","seq":%d}`, seq))
+			}
+		}
+	}
+}
+
+func TestNatsPublisher_Publish(t *testing.T) {
+	server := newFakeJetStream(t, true)
+	defer server.listener.Close()
+	publisher := NewNatsPublisher(server.url())
+	defer publisher.Close()
+
+	err := publisher.Publish(Message{ID: "42", Subject: "measurements.MeasurementCreated", Key: "a", Data: []byte(`{"value":1}`)})
+	assert.Nil(t, err)
+	connect := <-server.frames
+	assert.Contains(t, connect, `"headers":true,"no_responders":true`)
+	assert.Contains(t, connect, `"user":"user","pass":"secret"`)
+	frame := strings.SplitN(<-server.frames, "\n", 2)
+	fields := strings.Fields(frame[0])
+	header := "NATS/1.0\r\nNats-Msg-Id: 42\r\n\r\n"
+	if assert.Len(t, fields, 5) {
+		assert.Equal(t, "measurements.MeasurementCreated", fields[1])
+		assert.True(t, strings.HasPrefix(fields[2], "_INBOX."), "message needs a reply inbox for the acknowledgement")
+		assert.Equal(t, []string{strconv.Itoa(len(header)), strconv.Itoa(len(header) + 11)}, fields[3:])
+	}
+	assert.Equal(t, header+`{"value":1}`, frame[1])
+
+	err = publisher.Publish(Message{ID: "43", Subject: "measurements.MeasurementUpdated", Data: []byte("{}")})
+	assert.Nil(t, err)
+	<-server.frames
+}
+
+func TestNatsPublisher_NotAcknowledged(t *testing.T) {
+	server := newFakeJetStream(t, true)
+	defer server.listener.Close()
+	publisher := NewNatsPublisher(server.url())
+	publisher.Timeout = 200 * time.Millisecond
+	defer publisher.Close()
+
+	assert.Nil(t, publisher.Publish(Message{ID: "1", Subject: "measurements.MeasurementCreated", Data: []byte("{}")}))
+	<-server.frames
+	<-server.frames
+
+	tests := []struct {
+		subject string
+		wantErr string
+	}{
+		{"unstored", "no JetStream stream captures subject unstored"},
+		{"rejected", "JetStream rejected the message: maximum messages exceeded (503)"},
+		{"forbidden", "nats server error: 'Permissions Violation for Publish to forbidden'"},
+		{"silent", "i/o timeout"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.subject, func(t *testing.T) {
+			err := publisher.Publish(Message{ID: "2", Subject: tt.subject, Data: []byte("{}")})
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tt.wantErr)
+			}
+			<-server.frames
+
+			err = publisher.Publish(Message{ID: "3", Subject: "measurements.MeasurementDeleted", Data: []byte("{}")})
+			assert.Nil(t, err, "publisher should connect again after the error")
+			assert.Contains(t, <-server.frames, "CONNECT")
+			<-server.frames
+		})
+	}
+}
+
+func TestNatsPublisher_NoHeaders(t *testing.T) {
+	server := newFakeJetStream(t, false)
+	defer server.listener.Close()
+	publisher := NewNatsPublisher(server.url())
+	defer publisher.Close()
+
+	err := publisher.Publish(Message{ID: "1", Subject: "measurements.MeasurementCreated", Data: []byte("{}")})
+	assert.EqualError(t, err, "nats server does not support headers, JetStream publishing needs them")
+}
+
+func TestNatsPublisher_Unavailable(t *testing.T) {
+	listener, _ := net.Listen("tcp", "127.0.0.1:0")
+	address := listener.Addr().String()
+	listener.Close()
+	publisher := NewNatsPublisher("nats://" + address)
+
+	assert.Error(t, publisher.Publish(Message{ID: "1", Subject: "measurements.MeasurementCreated"}))
+}
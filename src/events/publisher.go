@@ -0,0 +1,18 @@
+package events
+
+// Message is a domain event ready to be published, ID is the event uuid consumers deduplicate redeliveries by
+// and Key is the target uuid brokers partitioning by key keep the order of.
+// Sequence is the position of the event in the outbox, live streams resume after it
+type Message struct {
+	ID       string
+	Sequence int64
+	Subject  string
+	Key      string
+	Data     []byte
+}
+
+// Publisher hands messages over to a broker, nil error means the broker has accepted the message
+type Publisher interface {
+	Publish(message Message) error
+	Close() error
+}
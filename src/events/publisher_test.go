@@ -0,0 +1,17 @@
+package events
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestMemoryPublisher(t *testing.T) {
+	publisher := NewMemoryPublisher()
+	publisher.Publish(Message{ID: "1"})
+	publisher.Publish(Message{ID: "2"})
+
+	messages := publisher.Messages()
+	assert.Len(t, messages, 2)
+	assert.Equal(t, "1", messages[0].ID)
+	assert.Equal(t, "2", messages[1].ID)
+}
@@ -0,0 +1,66 @@
+package growth
+
+import (
+	"fmt"
+	"io"
+)
+
+// WHO Child Growth Standards (2006) for derived metrics are published as LMS tables: weight-for-length every 0.5 cm
+// from 45 to 110 cm (kilograms) and BMI-for-age every month up to 5 years (kg/m²). The values are not bundled with
+// the service, until LoadWeightForLength and LoadBMIForAge read them derived values have no percentiles.
+
+const (
+	weightForLengthMinCm  = 45
+	weightForLengthMaxCm  = 110
+	weightForLengthStepCm = 0.5
+	bmiForAgeMinMonths    = 24
+	bmiForAgeMaxMonths    = 60
+	bmiForAgeStepMonths   = 1
+)
+
+// BMIFromAgeDays is the age from which BMI-for-age is screened on instead of weight-for-length
+const BMIFromAgeDays = bmiForAgeMinMonths * DaysInMonth
+
+var (
+	weightForLengthTable = table{factor: 1}
+	bmiForAgeTable       = table{factor: 1}
+)
+
+// LoadWeightForLength reads the WHO weight-for-length table from CSV with the header sex,length,l,m,s.
+// Length is in centimeters and M in kilograms, rows are needed every 0.5 cm from 45 to 110 cm
+func LoadWeightForLength(reader io.Reader) error {
+	tbl, err := readLMSTable(reader, "length", weightForLengthMinCm, weightForLengthMaxCm, weightForLengthStepCm)
+	if err != nil {
+		return fmt.Errorf("weight-for-length: %v", err)
+	}
+	weightForLengthTable = tbl
+	return nil
+}
+
+// LoadBMIForAge reads the WHO BMI-for-age table from CSV with the header sex,month,l,m,s.
+// Age is in completed months, rows are needed every month from 24 to 60
+func LoadBMIForAge(reader io.Reader) error {
+	tbl, err := readLMSTable(reader, "month", bmiForAgeMinMonths, bmiForAgeMaxMonths, bmiForAgeStepMonths)
+	if err != nil {
+		return fmt.Errorf("BMI-for-age: %v", err)
+	}
+	bmiForAgeTable = tbl
+	return nil
+}
+
+// LookupWeightForLength returns reference of body weight in kilograms at given length in centimeters,
+// false is returned outside of the 45 to 110 cm range
+func LookupWeightForLength(sex Sex, lengthCm float64) (LMS, bool) {
+	return interpolate(weightForLengthTable, sex, (lengthCm-weightForLengthMinCm)/weightForLengthStepCm)
+}
+
+// LookupBMIForAge returns reference of body mass index at age in days, false is returned outside of 2 to 5 years
+func LookupBMIForAge(sex Sex, ageDays float64) (LMS, bool) {
+	return interpolate(bmiForAgeTable, sex, (ageDays/DaysInMonth-bmiForAgeMinMonths)/bmiForAgeStepMonths)
+}
+
+// BMI returns body mass index of weight in grams and height in centimeters
+func BMI(weightGrams float64, heightCm float64) float64 {
+	meters := heightCm / 100
+	return weightGrams / 1000 / (meters * meters)
+}
@@ -0,0 +1,90 @@
+package growth
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"little-diary-measurement-service/src/models"
+	"strconv"
+	"strings"
+)
+
+// Fenton preterm growth chart (2013) is published by the University of Calgary as weekly L, M, S values
+// from 22 to 50 weeks of postmenstrual age. The values are not bundled with the service, until LoadFenton reads them
+// preterm babies have no reference before 50 weeks.
+
+const (
+	fentonFirstWeek = 22
+	fentonLastWeek  = 50
+	fentonStepWeeks = 1
+)
+
+var fentonHeader = []string{"sex", "type", "week", "l", "m", "s"}
+
+var fentonTypes = []models.MeasurementType{
+	models.MeasurementTypeWeight, models.MeasurementTypeHeight, models.MeasurementTypeHeadCircumference,
+}
+
+// LoadFenton reads the published LMS values from CSV with the header sex,type,week,l,m,s. Sex is male or female,
+// type is WEIGHT, HEIGHT or HEAD_CIRCUMFERENCE, weight is in grams, length and head circumference are in centimeters.
+// Every type needs both sexes at every week from 22 to 50, the tables are replaced only when the whole file is valid
+func LoadFenton(reader io.Reader) error {
+	records, err := csv.NewReader(reader).ReadAll()
+	if err != nil {
+		return err
+	}
+	if len(records) == 0 || strings.Join(records[0], ",") != strings.Join(fentonHeader, ",") {
+		return fmt.Errorf("fenton table must start with header %v", fentonHeader)
+	}
+	rows := map[models.MeasurementType]map[Sex][]*LMS{}
+	for _, t := range fentonTypes {
+		rows[t] = map[Sex][]*LMS{
+			SexMale:   make([]*LMS, fentonLastWeek-fentonFirstWeek+1),
+			SexFemale: make([]*LMS, fentonLastWeek-fentonFirstWeek+1),
+		}
+	}
+	for i, record := range records[1:] {
+		line := i + 2
+		sex, err := ParseSex(record[0])
+		if err != nil {
+			return fmt.Errorf("fenton line %d: %v", line, err)
+		}
+		bySex, ok := rows[models.MeasurementType(record[1])]
+		if !ok {
+			return fmt.Errorf("fenton line %d: type %s is not on the chart", line, record[1])
+		}
+		week, err := strconv.Atoi(record[2])
+		if err != nil || week < fentonFirstWeek || week > fentonLastWeek {
+			return fmt.Errorf("fenton line %d: week must be from %d to %d", line, fentonFirstWeek, fentonLastWeek)
+		}
+		var values [3]float64
+		for k := range values {
+			if values[k], err = strconv.ParseFloat(record[3+k], 64); err != nil {
+				return fmt.Errorf("fenton line %d: %v", line, err)
+			}
+		}
+		if values[1] <= 0 || values[2] <= 0 {
+			return fmt.Errorf("fenton line %d: M and S must be positive", line)
+		}
+		if bySex[sex][week-fentonFirstWeek] != nil {
+			return fmt.Errorf("fenton line %d: week %d is given twice", line, week)
+		}
+		bySex[sex][week-fentonFirstWeek] = &LMS{L: values[0], M: values[1], S: values[2]}
+	}
+
+	tables := map[models.MeasurementType]table{}
+	for _, t := range fentonTypes {
+		byWeek := map[Sex][]LMS{}
+		for _, sex := range []Sex{SexMale, SexFemale} {
+			for i, lms := range rows[t][sex] {
+				if lms == nil {
+					return fmt.Errorf("fenton table misses %s %s at week %d", sex, t, fentonFirstWeek+i)
+				}
+				byWeek[sex] = append(byWeek[sex], *lms)
+			}
+		}
+		tables[t] = table{byWeek[SexMale], byWeek[SexFemale], 1}
+	}
+	fentonTables = tables
+	return nil
+}
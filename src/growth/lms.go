@@ -0,0 +1,186 @@
+package growth
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"little-diary-measurement-service/src/models"
+	"math"
+	"strconv"
+	"strings"
+)
+
+type Sex string
+
+const (
+	SexMale   Sex = "male"
+	SexFemale Sex = "female"
+)
+
+const DaysInMonth = 30.4375
+
+// MajorPercentiles are the lines printed on WHO charts
+var MajorPercentiles = []float64{3, 15, 50, 85, 97}
+
+func ParseSex(value string) (Sex, error) {
+	switch Sex(value) {
+	case SexMale, SexFemale:
+		return Sex(value), nil
+	}
+	return "", fmt.Errorf("sex %s does not exist", value)
+}
+
+// LMS holds Box-Cox power (L), median (M) and coefficient of variation (S) of a reference distribution
+type LMS struct {
+	L float64
+	M float64
+	S float64
+}
+
+// ValueAt returns the measurement value with given z-score
+func (l LMS) ValueAt(z float64) float64 {
+	if math.Abs(l.L) < 1e-9 {
+		return l.M * math.Exp(l.S*z)
+	}
+	return l.M * math.Pow(1+l.L*l.S*z, 1/l.L)
+}
+
+func (l LMS) ZScore(value float64) float64 {
+	if math.Abs(l.L) < 1e-9 {
+		return math.Log(value/l.M) / l.S
+	}
+	return (math.Pow(value/l.M, l.L) - 1) / (l.L * l.S)
+}
+
+func (l LMS) Percentile(value float64) float64 {
+	return ZToPercentile(l.ZScore(value))
+}
+
+func (l LMS) ValueAtPercentile(percentile float64) float64 {
+	return l.ValueAt(PercentileToZ(percentile))
+}
+
+func PercentileToZ(percentile float64) float64 {
+	return math.Sqrt2 * math.Erfinv(2*percentile/100-1)
+}
+
+func ZToPercentile(z float64) float64 {
+	return 50 * (1 + math.Erf(z/math.Sqrt2))
+}
+
+type table struct {
+	boys  []LMS
+	girls []LMS
+	// factor converting table units into stored measurement units
+	factor float64
+}
+
+var whoTables = map[models.MeasurementType]table{
+	models.MeasurementTypeWeight:            {whoWeightForAgeBoys, whoWeightForAgeGirls, 1000},
+	models.MeasurementTypeHeight:            {whoLengthForAgeBoys, whoLengthForAgeGirls, 1},
+	models.MeasurementTypeHeadCircumference: {whoHeadCircumferenceForAgeBoys, whoHeadCircumferenceForAgeGirls, 1},
+}
+
+// Lookup returns reference for the measurement type at age in days, values are in stored units
+// (grams and centimeters), false is returned when the age is not covered by the reference
+func Lookup(t models.MeasurementType, sex Sex, ageDays float64) (LMS, bool) {
+	tbl, ok := whoTables[t]
+	if !ok {
+		return LMS{}, false
+	}
+	return interpolate(tbl, sex, ageDays/DaysInMonth)
+}
+
+// interpolate returns reference at fractional row position of the table
+func interpolate(tbl table, sex Sex, position float64) (LMS, bool) {
+	rows := tbl.boys
+	if sex == SexFemale {
+		rows = tbl.girls
+	}
+	if position < 0 || position > float64(len(rows)-1) {
+		return LMS{}, false
+	}
+	i := int(position)
+	if i == len(rows)-1 {
+		i--
+	}
+	k := position - float64(i)
+	a, b := rows[i], rows[i+1]
+	return LMS{
+		L: a.L + (b.L-a.L)*k,
+		M: (a.M + (b.M-a.M)*k) * tbl.factor,
+		S: a.S + (b.S-a.S)*k,
+	}, true
+}
+
+// readLMSTable reads CSV with the header sex,<key>,l,m,s into a table with rows at every step of the key
+// from first to last, both sexes are needed at every step. Rows between the steps or outside of the range are ignored,
+// so that expanded tables with finer steps can be read as well
+func readLMSTable(reader io.Reader, key string, first float64, last float64, step float64) (table, error) {
+	header := []string{"sex", key, "l", "m", "s"}
+	records, err := csv.NewReader(reader).ReadAll()
+	if err != nil {
+		return table{}, err
+	}
+	if len(records) == 0 || strings.Join(records[0], ",") != strings.Join(header, ",") {
+		return table{}, fmt.Errorf("table must start with header %v", header)
+	}
+	count := int(math.Round((last-first)/step)) + 1
+	rows := map[Sex][]*LMS{SexMale: make([]*LMS, count), SexFemale: make([]*LMS, count)}
+	for i, record := range records[1:] {
+		line := i + 2
+		sex, err := ParseSex(record[0])
+		if err != nil {
+			return table{}, fmt.Errorf("line %d: %v", line, err)
+		}
+		var values [4]float64
+		for k := range values {
+			if values[k], err = strconv.ParseFloat(record[1+k], 64); err != nil {
+				return table{}, fmt.Errorf("line %d: %v", line, err)
+			}
+		}
+		position := (values[0] - first) / step
+		index := int(math.Round(position))
+		if math.Abs(position-float64(index)) > 1e-6 || index < 0 || index >= count {
+			continue
+		}
+		if values[2] <= 0 || values[3] <= 0 {
+			return table{}, fmt.Errorf("line %d: M and S must be positive", line)
+		}
+		if rows[sex][index] != nil {
+			return table{}, fmt.Errorf("line %d: %s %s is given twice", line, key, record[1])
+		}
+		rows[sex][index] = &LMS{L: values[1], M: values[2], S: values[3]}
+	}
+	result := map[Sex][]LMS{}
+	for _, sex := range []Sex{SexMale, SexFemale} {
+		for i, lms := range rows[sex] {
+			if lms == nil {
+				return table{}, fmt.Errorf("table misses %s at %s %v", sex, key, first+float64(i)*step)
+			}
+			result[sex] = append(result[sex], *lms)
+		}
+	}
+	return table{result[SexMale], result[SexFemale], 1}, nil
+}
+
+// MaxAgeDays returns the oldest age covered by the reference of the type
+func MaxAgeDays(t models.MeasurementType) float64 {
+	tbl, ok := whoTables[t]
+	if !ok {
+		return 0
+	}
+	return float64(len(tbl.boys)-1) * DaysInMonth
+}
+
+// MajorLinesCrossedDown counts major percentile lines passed when going from the higher percentile to the lower one,
+// ending exactly on a line does not count as crossing it
+func MajorLinesCrossedDown(fromPercentile float64, toPercentile float64) int {
+	crossed := 0
+	for _, line := range MajorPercentiles {
+		if toPercentile < line && line <= fromPercentile {
+			crossed++
+		}
+	}
+	return crossed
+}
@@ -0,0 +1,260 @@
+package growth
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io"
+	"io/ioutil"
+	"little-diary-measurement-service/src/models"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestLMS(t *testing.T) {
+	// WHO weight-for-age boys at birth: 3rd percentile is 2.5 kg, 97th is 4.3 kg
+	birth := whoWeightForAgeBoys[0]
+	assert.InDelta(t, 3.3464, birth.ValueAt(0), 1e-6)
+	assert.InDelta(t, 2.5, birth.ValueAtPercentile(3), 0.05)
+	assert.InDelta(t, 4.3, birth.ValueAtPercentile(97), 0.05)
+	assert.InDelta(t, 1.5, birth.ZScore(birth.ValueAt(1.5)), 1e-9)
+
+	logNormal := LMS{L: 0, M: 10, S: 0.1}
+	assert.InDelta(t, -2, logNormal.ZScore(logNormal.ValueAt(-2)), 1e-9)
+}
+
+func TestPercentileToZ(t *testing.T) {
+	assert.InDelta(t, 0, PercentileToZ(50), 1e-9)
+	assert.InDelta(t, -1.881, PercentileToZ(3), 1e-3)
+	assert.InDelta(t, 97, ZToPercentile(PercentileToZ(97)), 1e-9)
+}
+
+func TestLookup(t *testing.T) {
+	lms, ok := Lookup(models.MeasurementTypeWeight, SexMale, 0)
+	assert.True(t, ok)
+	assert.InDelta(t, 3346.4, lms.M, 1e-6)
+
+	lms, ok = Lookup(models.MeasurementTypeHeight, SexFemale, 1.5*DaysInMonth)
+	assert.True(t, ok)
+	assert.InDelta(t, (53.6872+57.0673)/2, lms.M, 1e-6)
+
+	lms, ok = Lookup(models.MeasurementTypeHeadCircumference, SexFemale, MaxAgeDays(models.MeasurementTypeHeadCircumference))
+	assert.True(t, ok)
+	assert.InDelta(t, 47.1822, lms.M, 1e-6)
+
+	_, ok = Lookup(models.MeasurementTypeWeight, SexMale, 25*DaysInMonth)
+	assert.False(t, ok)
+	_, ok = Lookup(models.MeasurementTypeWeight, SexMale, -1)
+	assert.False(t, ok)
+}
+
+func TestParseSex(t *testing.T) {
+	sex, err := ParseSex("female")
+	assert.Nil(t, err)
+	assert.Equal(t, SexFemale, sex)
+	_, err = ParseSex("F")
+	assert.NotNil(t, err)
+}
+
+func TestLoadVelocity(t *testing.T) {
+	loadTestReference(t, "who_velocity.csv", LoadVelocity)
+	valid, err := ioutil.ReadFile("../test_data/who_velocity.csv")
+	if !assert.Nil(t, err) {
+		return
+	}
+	lines := strings.Split(strings.TrimSpace(string(valid)), "\n")
+	tests := []struct {
+		name  string
+		table string
+	}{
+		{"no header", strings.Join(lines[1:], "\n")},
+		{"missing interval", strings.Join(lines[:len(lines)-1], "\n")},
+		{"interval twice", strings.Join(append(lines, lines[1]), "\n")},
+		{"start out of range", strings.Join(append(lines, "male,WEIGHT,1,12,1,500,0.3,0"), "\n")},
+		{"unknown interval", strings.Join(append(lines, "male,HEIGHT,1,0,1,4,0.2,0"), "\n")},
+		{"unknown type", strings.Join(append(lines, "male,BMI,2,0,1,1,0.2,0"), "\n")},
+		{"bad value", strings.Join(append(lines, "male,WEIGHT,2,0,1,heavy,0.2,0"), "\n")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.NotNil(t, LoadVelocity(strings.NewReader(tt.table)))
+		})
+	}
+	_, ok := CompareVelocity(models.MeasurementTypeWeight, SexMale, 0, 0, DaysInMonth, 1000)
+	assert.True(t, ok, "tables are kept after an invalid file")
+}
+
+func TestCompareVelocity(t *testing.T) {
+	loadTestReference(t, "who_velocity.csv", LoadVelocity)
+	// test_data/who_velocity.csv: boys gain 1000 g in the first month and 1540 g from 3 to 5 months with Delta 500
+	comparison, ok := CompareVelocity(models.MeasurementTypeWeight, SexMale, 0, 0, DaysInMonth, 1000)
+	if assert.True(t, ok) {
+		assert.Equal(t, 1, comparison.Months)
+		assert.InDelta(t, 1000, comparison.MedianGain, 1e-6)
+		assert.InDelta(t, 50, comparison.Percentile, 1e-6)
+	}
+	comparison, ok = CompareVelocity(models.MeasurementTypeWeight, SexMale, 0, 3*DaysInMonth, 5*DaysInMonth, 1540)
+	if assert.True(t, ok) {
+		assert.Equal(t, 2, comparison.Months)
+		assert.InDelta(t, 1540, comparison.MedianGain, 1e-6)
+		assert.InDelta(t, 50, comparison.Percentile, 1e-6)
+	}
+
+	// a shorter interval is scaled to the standard one
+	comparison, ok = CompareVelocity(models.MeasurementTypeWeight, SexMale, 0, 0, DaysInMonth-5, 800)
+	if assert.True(t, ok) {
+		assert.InDelta(t, 1000*(DaysInMonth-5)/DaysInMonth, comparison.MedianGain, 1e-6)
+		assert.Less(t, comparison.Percentile, 50.0)
+	}
+	comparison, ok = CompareVelocity(models.MeasurementTypeWeight, SexMale, 0, 3*DaysInMonth, 5*DaysInMonth, -600)
+	if assert.True(t, ok) {
+		assert.Equal(t, 0.0, comparison.Percentile, "losses beyond Delta are below the distribution")
+	}
+
+	// born at 32 weeks, the first month after the due date is compared
+	comparison, ok = CompareVelocity(models.MeasurementTypeWeight, SexMale, 32*7, 8*7, 8*7+DaysInMonth, 1000)
+	if assert.True(t, ok) {
+		assert.InDelta(t, 50, comparison.Percentile, 1e-6)
+	}
+	_, ok = CompareVelocity(models.MeasurementTypeWeight, SexMale, 32*7, 0, DaysInMonth, 1000)
+	assert.False(t, ok, "there are no standards before the due date")
+
+	_, ok = CompareVelocity(models.MeasurementTypeWeight, SexMale, 0, 0, 45, 1000)
+	assert.False(t, ok, "interval fits no standard")
+	_, ok = CompareVelocity(models.MeasurementTypeHeight, SexFemale, 0, 0, DaysInMonth, 4)
+	assert.False(t, ok, "length has no 1-month increments")
+	_, ok = CompareVelocity(models.MeasurementTypeHeight, SexFemale, 0, 23*DaysInMonth, 25*DaysInMonth, 2)
+	assert.False(t, ok, "standards end at 24 months")
+	_, ok = CompareVelocity(models.MeasurementTypeHeadCircumference, SexFemale, 0, 0, 2*DaysInMonth, 4)
+	assert.False(t, ok)
+}
+
+func TestMajorLinesCrossedDown(t *testing.T) {
+	assert.Equal(t, 2, MajorLinesCrossedDown(60, 10))
+	assert.Equal(t, 1, MajorLinesCrossedDown(50, 20))
+	assert.Equal(t, 0, MajorLinesCrossedDown(40, 15))
+	assert.Equal(t, 0, MajorLinesCrossedDown(10, 90))
+	assert.Equal(t, 5, MajorLinesCrossedDown(99, 1))
+}
+
+// loadTestReference reads a synthetic table of test_data, shaped like the published one
+func loadTestReference(t *testing.T, name string, load func(io.Reader) error) {
+	file, err := os.Open("../test_data/" + name)
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer file.Close()
+	assert.Nil(t, load(file))
+}
+
+func TestLoadFenton(t *testing.T) {
+	loadTestReference(t, "fenton_lms.csv", LoadFenton)
+	valid, err := ioutil.ReadFile("../test_data/fenton_lms.csv")
+	if !assert.Nil(t, err) {
+		return
+	}
+	lines := strings.Split(strings.TrimSpace(string(valid)), "\n")
+	tests := []struct {
+		name  string
+		table string
+	}{
+		{"no header", strings.Join(lines[1:], "\n")},
+		{"missing week", strings.Join(lines[:len(lines)-1], "\n")},
+		{"week twice", strings.Join(append(lines, lines[1]), "\n")},
+		{"week out of range", strings.Join(append(lines, "male,WEIGHT,51,0.3,6000,0.12"), "\n")},
+		{"unknown type", strings.Join(append(lines, "male,BMI,30,1,14,0.1"), "\n")},
+		{"bad value", strings.Join(append(lines, "male,WEIGHT,30,0.3,heavy,0.12"), "\n")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.NotNil(t, LoadFenton(strings.NewReader(tt.table)))
+		})
+	}
+	_, standard, ok := LookupForAge(models.MeasurementTypeWeight, SexMale, 70, 30*7)
+	assert.True(t, ok, "tables are kept after an invalid file")
+	assert.Equal(t, StandardFenton, standard)
+}
+
+func TestLookupForAge(t *testing.T) {
+	loadTestReference(t, "fenton_lms.csv", LoadFenton)
+	// born at 30 weeks, 10 weeks later the baby is at term
+	gestationalAge := 30 * 7
+	lms, standard, ok := LookupForAge(models.MeasurementTypeWeight, SexMale, 70, gestationalAge)
+	if assert.True(t, ok) {
+		assert.Equal(t, StandardFenton, standard)
+		assert.InDelta(t, 3550, lms.M, 1e-6)
+	}
+	assert.InDelta(t, 0, CorrectedAgeDays(70, gestationalAge), 1e-9)
+
+	// at 50 weeks postmenstrual age the WHO chart by corrected age takes over
+	lms, standard, ok = LookupForAge(models.MeasurementTypeWeight, SexMale, 20*7+DaysInMonth*3, gestationalAge)
+	if assert.True(t, ok) {
+		who, _ := Lookup(models.MeasurementTypeWeight, SexMale, DaysInMonth*3+10*7)
+		assert.Equal(t, StandardWHO, standard)
+		assert.Equal(t, who, lms)
+	}
+
+	// term babies and unknown gestational age are not corrected
+	for _, gestationalAge := range []int{0, 39 * 7} {
+		lms, standard, ok = LookupForAge(models.MeasurementTypeHeight, SexFemale, 60, gestationalAge)
+		who, _ := Lookup(models.MeasurementTypeHeight, SexFemale, 60)
+		assert.True(t, ok)
+		assert.Equal(t, StandardWHO, standard)
+		assert.Equal(t, who, lms)
+	}
+
+	// correction stops at the second birthday
+	assert.Equal(t, 2*12*DaysInMonth, CorrectedAgeDays(2*12*DaysInMonth, gestationalAge))
+	_, _, ok = LookupForAge(models.MeasurementTypeWeight, SexMale, 10, 20*7)
+	assert.False(t, ok, "before 22 weeks there is no reference")
+}
+
+func TestLookupDerived(t *testing.T) {
+	loadTestReference(t, "who_weight_for_length.csv", LoadWeightForLength)
+	loadTestReference(t, "who_bmi_for_age.csv", LoadBMIForAge)
+
+	lms, ok := LookupWeightForLength(SexFemale, 47.5)
+	assert.True(t, ok)
+	assert.InDelta(t, (2.461+3.397)/2, lms.M, 1e-6)
+	lms, ok = LookupWeightForLength(SexFemale, 45.25)
+	assert.True(t, ok)
+	assert.InDelta(t, (weightForLengthTable.girls[0].M+weightForLengthTable.girls[1].M)/2, lms.M, 1e-9, "rows are every 0.5 cm")
+	_, ok = LookupWeightForLength(SexMale, 111)
+	assert.False(t, ok)
+
+	lms, ok = LookupBMIForAge(SexMale, BMIFromAgeDays)
+	assert.True(t, ok)
+	assert.InDelta(t, 16.0189, lms.M, 1e-6)
+	lms, _ = LookupBMIForAge(SexMale, BMIFromAgeDays+DaysInMonth)
+	assert.Equal(t, bmiForAgeTable.boys[1], lms, "rows are every month")
+	_, ok = LookupBMIForAge(SexMale, BMIFromAgeDays-1)
+	assert.False(t, ok, "BMI-for-age starts on the second birthday")
+
+	assert.InDelta(t, 16, BMI(16000, 100), 1e-9)
+}
+
+func TestReadLMSTable(t *testing.T) {
+	tbl, err := readLMSTable(strings.NewReader("sex,month,l,m,s\n"+
+		"male,0,1,10,0.1\nmale,0.5,1,10.5,0.1\nmale,1,1,11,0.1\nmale,2,1,12,0.1\n"+
+		"female,1,1,10,0.1\nfemale,2,1,11,0.1\n"), "month", 1, 2, 1)
+	if assert.Nil(t, err) {
+		assert.Equal(t, []LMS{{1, 11, 0.1}, {1, 12, 0.1}}, tbl.boys, "rows between steps and outside the range are ignored")
+		assert.Len(t, tbl.girls, 2)
+	}
+
+	tests := []struct {
+		name  string
+		table string
+	}{
+		{"other header", "sex,length,l,m,s\nmale,1,1,11,0.1\nmale,2,1,12,0.1\nfemale,1,1,10,0.1\nfemale,2,1,11,0.1\n"},
+		{"missing step", "sex,month,l,m,s\nmale,1,1,11,0.1\nmale,2,1,12,0.1\nfemale,1,1,10,0.1\n"},
+		{"step twice", "sex,month,l,m,s\nmale,1,1,11,0.1\nmale,1,1,11,0.1\nmale,2,1,12,0.1\nfemale,1,1,10,0.1\nfemale,2,1,11,0.1\n"},
+		{"zero median", "sex,month,l,m,s\nmale,1,1,0,0.1\nmale,2,1,12,0.1\nfemale,1,1,10,0.1\nfemale,2,1,11,0.1\n"},
+		{"unknown sex", "sex,month,l,m,s\nboy,1,1,11,0.1\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := readLMSTable(strings.NewReader(tt.table), "month", 1, 2, 1)
+			assert.NotNil(t, err)
+		})
+	}
+}
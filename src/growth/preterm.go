@@ -0,0 +1,66 @@
+package growth
+
+import "little-diary-measurement-service/src/models"
+
+// Standard names the growth reference a percentile was computed on
+type Standard string
+
+const (
+	StandardWHO    Standard = "WHO"
+	StandardFenton Standard = "FENTON"
+)
+
+const (
+	// TermGestationDays is the due date, corrected age counts from it
+	TermGestationDays = 40 * 7
+	// babies born before 37 weeks are preterm
+	pretermGestationDays = 37 * 7
+	// preterm babies move from Fenton to WHO charts at this postmenstrual age
+	fentonUntilPostmenstrualDays = 50 * 7
+	// age is no longer corrected from the second birthday
+	correctedAgeUntilDays = 2 * 12 * DaysInMonth
+)
+
+// fentonTables are empty until LoadFenton reads the published values
+var fentonTables = map[models.MeasurementType]table{}
+
+// IsPreterm tells whether gestational age at birth needs corrected age, zero means unknown and is taken as term
+func IsPreterm(gestationalAgeDays int) bool {
+	return gestationalAgeDays > 0 && gestationalAgeDays < pretermGestationDays
+}
+
+// CorrectedAgeDays subtracts weeks born early from the age of a preterm baby until the second birthday,
+// corrected age is negative before the due date
+func CorrectedAgeDays(ageDays float64, gestationalAgeDays int) float64 {
+	if !IsPreterm(gestationalAgeDays) || ageDays >= correctedAgeUntilDays {
+		return ageDays
+	}
+	return ageDays - float64(TermGestationDays-gestationalAgeDays)
+}
+
+// LookupForAge returns reference at chronological age of a baby born at the gestational age,
+// preterm babies are on Fenton charts by postmenstrual age until 50 weeks and on WHO charts by corrected age later
+func LookupForAge(t models.MeasurementType, sex Sex, ageDays float64, gestationalAgeDays int) (LMS, Standard, bool) {
+	return LookupForCorrectedAge(t, sex, CorrectedAgeDays(ageDays, gestationalAgeDays), gestationalAgeDays)
+}
+
+// LookupForCorrectedAge is LookupForAge with the age already corrected
+func LookupForCorrectedAge(t models.MeasurementType, sex Sex, correctedAgeDays float64, gestationalAgeDays int) (LMS, Standard, bool) {
+	if IsPreterm(gestationalAgeDays) {
+		postmenstrualDays := correctedAgeDays + TermGestationDays
+		if postmenstrualDays < fentonUntilPostmenstrualDays {
+			lms, ok := lookupFenton(t, sex, postmenstrualDays)
+			return lms, StandardFenton, ok
+		}
+	}
+	lms, ok := Lookup(t, sex, correctedAgeDays)
+	return lms, StandardWHO, ok
+}
+
+func lookupFenton(t models.MeasurementType, sex Sex, postmenstrualDays float64) (LMS, bool) {
+	tbl, ok := fentonTables[t]
+	if !ok {
+		return LMS{}, false
+	}
+	return interpolate(tbl, sex, (postmenstrualDays/7-fentonFirstWeek)/fentonStepWeeks)
+}
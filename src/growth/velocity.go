@@ -0,0 +1,192 @@
+package growth
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"little-diary-measurement-service/src/models"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// WHO Child Growth Standards: Growth velocity (2009) publishes increments between exact ages as LMS tables with
+// a Delta that was added to the increments before fitting, so that losses can be described as well. Weight has
+// 1-month increments from birth to 12 months (the first interval is 0 to 4 weeks) and 2-month increments from birth
+// to 24 months, length has 2-month increments from birth to 24 months. The values are not bundled with the service,
+// until LoadVelocity reads them intervals are not compared with the velocity standards.
+
+const (
+	// the measured interval may differ from the length of the standard interval by that many days
+	incrementToleranceDays = 7
+)
+
+var velocityHeader = []string{"sex", "type", "months", "start_month", "l", "m", "s", "delta"}
+
+// incrementStarts is the last start month of each published increment table by its interval length in months
+var incrementStarts = map[models.MeasurementType]map[int]int{
+	models.MeasurementTypeWeight: {1: 11, 2: 22},
+	models.MeasurementTypeHeight: {2: 22},
+}
+
+// increment is the LMS of the increment shifted by Delta
+type increment struct {
+	LMS
+	Delta float64
+}
+
+type incrementTable struct {
+	boys  []increment
+	girls []increment
+}
+
+// incrementTables are empty until LoadVelocity reads the published values
+var incrementTables = map[models.MeasurementType]map[int]incrementTable{}
+
+// VelocityUnit returns unit of growth velocity and its period in days,
+// weight gain is judged in grams per day and lengths in centimeters per month
+func VelocityUnit(t models.MeasurementType) (string, float64) {
+	if t == models.MeasurementTypeWeight {
+		return "g/day", 1
+	}
+	return "cm/month", DaysInMonth
+}
+
+// LoadVelocity reads the published increment tables from CSV with the header sex,type,months,start_month,l,m,s,delta.
+// Type is WEIGHT with increments in grams or HEIGHT with increments in centimeters, months is the interval length
+// and start_month the age the interval starts at. Weight needs 1-month intervals starting at 0 to 11 and 2-month
+// intervals starting at 0 to 22, length needs 2-month intervals starting at 0 to 22, both sexes each.
+// The tables are replaced only when the whole file is valid
+func LoadVelocity(reader io.Reader) error {
+	records, err := csv.NewReader(reader).ReadAll()
+	if err != nil {
+		return err
+	}
+	if len(records) == 0 || strings.Join(records[0], ",") != strings.Join(velocityHeader, ",") {
+		return fmt.Errorf("velocity table must start with header %v", velocityHeader)
+	}
+	rows := map[models.MeasurementType]map[int]map[Sex][]*increment{}
+	for t, starts := range incrementStarts {
+		rows[t] = map[int]map[Sex][]*increment{}
+		for months, last := range starts {
+			rows[t][months] = map[Sex][]*increment{SexMale: make([]*increment, last+1), SexFemale: make([]*increment, last+1)}
+		}
+	}
+	for i, record := range records[1:] {
+		line := i + 2
+		sex, err := ParseSex(record[0])
+		if err != nil {
+			return fmt.Errorf("velocity line %d: %v", line, err)
+		}
+		byMonths, ok := rows[models.MeasurementType(record[1])]
+		if !ok {
+			return fmt.Errorf("velocity line %d: type %s has no velocity standard", line, record[1])
+		}
+		months, err := strconv.Atoi(record[2])
+		if err != nil || byMonths[months] == nil {
+			return fmt.Errorf("velocity line %d: %s has no %s-month increments", line, record[1], record[2])
+		}
+		start, err := strconv.Atoi(record[3])
+		if err != nil || start < 0 || start >= len(byMonths[months][sex]) {
+			return fmt.Errorf("velocity line %d: %d-month intervals start from 0 to %d", line, months, len(byMonths[months][sex])-1)
+		}
+		var values [4]float64
+		for k := range values {
+			if values[k], err = strconv.ParseFloat(record[4+k], 64); err != nil {
+				return fmt.Errorf("velocity line %d: %v", line, err)
+			}
+		}
+		if values[1] <= 0 || values[2] <= 0 {
+			return fmt.Errorf("velocity line %d: M and S must be positive", line)
+		}
+		if byMonths[months][sex][start] != nil {
+			return fmt.Errorf("velocity line %d: interval starting at %d is given twice", line, start)
+		}
+		byMonths[months][sex][start] = &increment{LMS: LMS{L: values[0], M: values[1], S: values[2]}, Delta: values[3]}
+	}
+
+	tables := map[models.MeasurementType]map[int]incrementTable{}
+	for t, byMonths := range rows {
+		tables[t] = map[int]incrementTable{}
+		for months, bySex := range byMonths {
+			byStart := map[Sex][]increment{}
+			for _, sex := range []Sex{SexMale, SexFemale} {
+				for start, row := range bySex[sex] {
+					if row == nil {
+						return fmt.Errorf("velocity table misses %s %s %d-month interval starting at %d", sex, t, months, start)
+					}
+					byStart[sex] = append(byStart[sex], *row)
+				}
+			}
+			tables[t][months] = incrementTable{byStart[SexMale], byStart[SexFemale]}
+		}
+	}
+	incrementTables = tables
+	return nil
+}
+
+// VelocityComparison relates a gain to the WHO increment standard of the interval
+type VelocityComparison struct {
+	// Months is the length of the standard interval the gain was compared on
+	Months int
+	// MedianGain is the median increment of the standard interval scaled to the measured interval
+	MedianGain float64
+	Percentile float64
+}
+
+// CompareVelocity evaluates the gain between two ages in days against the WHO increment standards. Ages of preterm
+// babies are corrected for the gestational age at birth. The interval has to be within a week of 1 or 2 months,
+// the gain is scaled to the exact standard interval, whose start is interpolated between the published ones.
+// False is returned when no standard interval fits or the tables are not loaded
+func CompareVelocity(t models.MeasurementType, sex Sex, gestationalAgeDays int, fromAgeDays float64, toAgeDays float64, gain float64) (VelocityComparison, bool) {
+	fromAgeDays = CorrectedAgeDays(fromAgeDays, gestationalAgeDays)
+	toAgeDays = CorrectedAgeDays(toAgeDays, gestationalAgeDays)
+	days := toAgeDays - fromAgeDays
+	for months, tbl := range incrementTables[t] {
+		standardDays := float64(months) * DaysInMonth
+		if math.Abs(days-standardDays) > incrementToleranceDays {
+			continue
+		}
+		reference, ok := interpolateIncrement(tbl, sex, fromAgeDays/DaysInMonth)
+		if !ok {
+			return VelocityComparison{}, false
+		}
+		scale := days / standardDays
+		percentile := 0.0
+		// losses beyond Delta are below the whole distribution
+		if shifted := gain/scale + reference.Delta; shifted > 0 {
+			percentile = reference.Percentile(shifted)
+		}
+		return VelocityComparison{
+			Months:     months,
+			MedianGain: (reference.M - reference.Delta) * scale,
+			Percentile: percentile,
+		}, true
+	}
+	return VelocityComparison{}, false
+}
+
+// interpolateIncrement returns the increment of an interval starting at fractional month
+func interpolateIncrement(tbl incrementTable, sex Sex, startMonth float64) (increment, bool) {
+	rows := tbl.boys
+	if sex == SexFemale {
+		rows = tbl.girls
+	}
+	if startMonth < 0 || startMonth > float64(len(rows)-1) {
+		return increment{}, false
+	}
+	i := int(startMonth)
+	if i == len(rows)-1 {
+		i--
+	}
+	k := startMonth - float64(i)
+	a, b := rows[i], rows[i+1]
+	return increment{
+		LMS: LMS{
+			L: a.L + (b.L-a.L)*k,
+			M: a.M + (b.M-a.M)*k,
+			S: a.S + (b.S-a.S)*k,
+		},
+		Delta: a.Delta + (b.Delta-a.Delta)*k,
+	}, true
+}
@@ -0,0 +1,78 @@
+package hl7
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+type AckCode string
+
+const (
+	AckCodeAccept AckCode = "AA"
+	AckCodeError  AckCode = "AE"
+	AckCodeReject AckCode = "AR"
+)
+
+// Error is returned for messages which should be answered with negative acknowledgement
+type Error struct {
+	Code AckCode
+	S    string
+}
+
+func (e *Error) Error() string {
+	return e.S
+}
+
+// BuildAck makes an acknowledgement for the message, original may be nil when it could not be parsed
+func BuildAck(original *Message, code AckCode, text string, now time.Time) string {
+	var msh Segment
+	if original != nil {
+		msh, _ = original.Segment("MSH")
+	} else {
+		msh = Segment{message: &Message{}}
+	}
+	version := msh.Field(12)
+	if version == "" {
+		version = "2.5.1"
+	}
+	controlId := msh.Field(10)
+
+	header := []string{
+		"MSH",
+		defaultEncodingCharacters,
+		escape(msh.Component(5, 1)),
+		escape(msh.Component(6, 1)),
+		escape(msh.Component(3, 1)),
+		escape(msh.Component(4, 1)),
+		now.UTC().Format("20060102150405"),
+		"",
+		"ACK^" + msh.Component(9, 2) + "^ACK",
+		fmt.Sprintf("ACK%d", now.UnixNano()),
+		"P",
+		version,
+	}
+	segments := []string{
+		strings.Join(header, "|"),
+		strings.Join([]string{"MSA", string(code), escape(controlId), escape(text)}, "|"),
+	}
+	if code != AckCodeAccept {
+		severity := "E"
+		errorCode := "207^Application internal error^HL70357"
+		if code == AckCodeReject {
+			errorCode = "200^Unsupported message type^HL70357"
+		}
+		segments = append(segments, strings.Join([]string{"ERR", "", "", errorCode, severity, "", "", "", escape(text)}, "|"))
+	}
+	return strings.Join(segments, segmentSeparator) + segmentSeparator
+}
+
+func escape(value string) string {
+	return strings.NewReplacer(
+		`\`, `\E\`,
+		"|", `\F\`,
+		"^", `\S\`,
+		"~", `\R\`,
+		"&", `\T\`,
+	).Replace(value)
+}
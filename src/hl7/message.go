@@ -0,0 +1,178 @@
+package hl7
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	defaultEncodingCharacters = `^~\&`
+	segmentSeparator          = "\r"
+)
+
+// Message is a parsed HL7 v2 message in ER7 (pipe delimited) encoding
+type Message struct {
+	Segments []Segment
+
+	componentSeparator    string
+	repetitionSeparator   string
+	escapeCharacter       string
+	subComponentSeparator string
+}
+
+// Segment keeps raw field values, Fields[0] is a segment name
+type Segment struct {
+	Fields  []string
+	message *Message
+}
+
+func Parse(raw string) (*Message, error) {
+	raw = strings.ReplaceAll(raw, "\r\n", segmentSeparator)
+	raw = strings.ReplaceAll(raw, "\n", segmentSeparator)
+	raw = strings.Trim(raw, segmentSeparator+" \x00")
+	if !strings.HasPrefix(raw, "MSH") || len(raw) < 8 {
+		return nil, fmt.Errorf("message must start with MSH segment")
+	}
+
+	fieldSeparator := raw[3:4]
+	encodingCharacters := strings.SplitN(raw[4:], fieldSeparator, 2)[0]
+	if len(encodingCharacters) < 4 {
+		return nil, fmt.Errorf("invalid encoding characters %q", encodingCharacters)
+	}
+	m := &Message{
+		componentSeparator:    encodingCharacters[0:1],
+		repetitionSeparator:   encodingCharacters[1:2],
+		escapeCharacter:       encodingCharacters[2:3],
+		subComponentSeparator: encodingCharacters[3:4],
+	}
+
+	for _, line := range strings.Split(raw, segmentSeparator) {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		fields := strings.Split(line, fieldSeparator)
+		if len(fields[0]) != 3 {
+			return nil, fmt.Errorf("invalid segment name %q", fields[0])
+		}
+		if fields[0] == "MSH" {
+			// MSH-1 is the field separator itself, so shift the fields to keep HL7 numbering
+			fields = append([]string{fields[0], fieldSeparator}, fields[1:]...)
+		}
+		m.Segments = append(m.Segments, Segment{Fields: fields, message: m})
+	}
+	return m, nil
+}
+
+// Segment returns the first segment with given name
+func (m *Message) Segment(name string) (Segment, bool) {
+	for _, s := range m.Segments {
+		if s.Name() == name {
+			return s, true
+		}
+	}
+	return Segment{message: m}, false
+}
+
+func (m *Message) SegmentsByName(name string) []Segment {
+	var result []Segment
+	for _, s := range m.Segments {
+		if s.Name() == name {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+func (m *Message) ControlId() string {
+	msh, _ := m.Segment("MSH")
+	return msh.Field(10)
+}
+
+// MessageType returns MSH-9 as "ORU^R01"
+func (m *Message) MessageType() string {
+	msh, _ := m.Segment("MSH")
+	return msh.Component(9, 1) + "^" + msh.Component(9, 2)
+}
+
+func (s Segment) Name() string {
+	if len(s.Fields) == 0 {
+		return ""
+	}
+	return s.Fields[0]
+}
+
+// Field returns the first repetition of the field with given HL7 position
+func (s Segment) Field(position int) string {
+	return s.unescape(s.firstRepetition(position))
+}
+
+// Repetitions returns all repetitions of the field with given HL7 position
+func (s Segment) Repetitions(position int) []string {
+	if position <= 0 || position >= len(s.Fields) || s.Fields[position] == "" {
+		return nil
+	}
+	if s.Name() == "MSH" && position <= 2 {
+		return []string{s.Fields[position]}
+	}
+	return strings.Split(s.Fields[position], s.message.repetitionSeparator)
+}
+
+// Component returns a component of the first field repetition, both positions are 1-based
+func (s Segment) Component(position int, component int) string {
+	return RepetitionComponent(s, s.firstRepetition(position), component)
+}
+
+// RepetitionComponent returns a component of a value taken from Segment.Repetitions
+func RepetitionComponent(s Segment, value string, component int) string {
+	components := strings.Split(value, s.message.componentSeparator)
+	if component <= 0 || component > len(components) {
+		return ""
+	}
+	subComponents := strings.Split(components[component-1], s.message.subComponentSeparator)
+	return s.unescape(subComponents[0])
+}
+
+func (s Segment) firstRepetition(position int) string {
+	repetitions := s.Repetitions(position)
+	if len(repetitions) == 0 {
+		return ""
+	}
+	return repetitions[0]
+}
+
+func (s Segment) unescape(value string) string {
+	e := s.message.escapeCharacter
+	if !strings.Contains(value, e) {
+		return value
+	}
+	return strings.NewReplacer(
+		e+"F"+e, "|",
+		e+"S"+e, s.message.componentSeparator,
+		e+"R"+e, s.message.repetitionSeparator,
+		e+"T"+e, s.message.subComponentSeparator,
+		e+"E"+e, e,
+	).Replace(value)
+}
+
+// ParseTimestamp parses HL7 DTM value YYYY[MM[DD[HH[MM[SS[.S...]]]]]][+/-ZZZZ],
+// values without offset are treated as UTC
+func ParseTimestamp(value string) (time.Time, error) {
+	location := time.UTC
+	if i := strings.IndexAny(value, "+-"); i >= 0 {
+		offset, err := time.Parse("-0700", value[i:])
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid timestamp offset %q", value)
+		}
+		location = offset.Location()
+		value = value[:i]
+	}
+	if i := strings.Index(value, "."); i >= 0 {
+		value = value[:i]
+	}
+	const layout = "20060102150405"
+	if len(value) < 4 || len(value) > len(layout) || len(value)%2 != 0 {
+		return time.Time{}, fmt.Errorf("invalid timestamp %q", value)
+	}
+	return time.ParseInLocation(layout[:len(value)], value, location)
+}
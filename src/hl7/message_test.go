@@ -0,0 +1,232 @@
+package hl7
+
+import (
+	"bufio"
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+const oruMessage = "MSH|^~\\&|LAB|CITY CLINIC|DIARY|LITTLE DIARY|20200401101500+0300||ORU^R01^ORU_R01|MSG00001|P|2.5.1\r" +
+	"PID|1||12345^^^CITYCLINIC^MR~777^^^^SS||Doe^Baby\r" +
+	"OBR|1|||29463-7^Body weight^LN|||20200401093000+0300\r" +
+	"OBX|1|NM|29463-7^Body weight^LN||4.52|kg|||||F\r" +
+	"OBX|2|NM|8302-2^Body height^LN||54.5|cm|||||F|||20200401094500+0300\r" +
+	"OBX|3|ST|8302-2^Body height^LN||not measured||||||F\r" +
+	"OBX|4|NM|9843-4^Head circumference^LN||36|cm|||||D\r"
+
+func TestParse(t *testing.T) {
+	m, err := Parse(oruMessage)
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Len(t, m.Segments, 7)
+	assert.Equal(t, "MSG00001", m.ControlId())
+	assert.Equal(t, "ORU^R01", m.MessageType())
+
+	msh, ok := m.Segment("MSH")
+	assert.True(t, ok)
+	assert.Equal(t, "|", msh.Field(1))
+	assert.Equal(t, `^~\&`, msh.Field(2))
+	assert.Equal(t, "LAB", msh.Field(3))
+
+	pid, _ := m.Segment("PID")
+	assert.Len(t, pid.Repetitions(3), 2)
+	assert.Equal(t, "12345", pid.Component(3, 1))
+	assert.Equal(t, "CITYCLINIC", pid.Component(3, 4))
+
+	assert.Len(t, m.SegmentsByName("OBX"), 4)
+}
+
+func TestParseInvalid(t *testing.T) {
+	_, err := Parse("PID|1||12345")
+	assert.NotNil(t, err)
+
+	_, err = Parse("MSH|^~")
+	assert.NotNil(t, err)
+}
+
+func TestParseEscapes(t *testing.T) {
+	m, err := Parse("MSH|^~\\&|LAB|A\\F\\B\\S\\C|DIARY\nPID|1")
+	if assert.Nil(t, err) {
+		msh, _ := m.Segment("MSH")
+		assert.Equal(t, "A|B^C", msh.Field(4))
+		assert.Len(t, m.Segments, 2)
+	}
+}
+
+func TestParseTimestamp(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Time
+		wantErr bool
+	}{
+		{value: "2020", want: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{value: "20200401", want: time.Date(2020, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{value: "202004011015", want: time.Date(2020, 4, 1, 10, 15, 0, 0, time.UTC)},
+		{value: "20200401101530.1234", want: time.Date(2020, 4, 1, 10, 15, 30, 0, time.UTC)},
+		{value: "20200401101530+0300", want: time.Date(2020, 4, 1, 7, 15, 30, 0, time.UTC)},
+		{value: "2020040", wantErr: true},
+		{value: "", wantErr: true},
+		{value: "20200401+03", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseTimestamp(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseTimestamp() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err == nil {
+				assert.True(t, got.Equal(tt.want), "got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadOruR01(t *testing.T) {
+	m, _ := Parse(oruMessage)
+	oru, err := ReadOruR01(m)
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, "MSG00001", oru.ControlId)
+	assert.Equal(t, []PatientIdentifier{
+		{Id: "12345", AssigningAuthority: "CITYCLINIC"},
+		{Id: "777", AssigningAuthority: "CITY CLINIC"},
+	}, oru.PatientIdentifiers)
+
+	if assert.Len(t, oru.Observations, 2) {
+		assert.Equal(t, "29463-7", oru.Observations[0].Code)
+		assert.Equal(t, float32(4.52), oru.Observations[0].Value)
+		assert.Equal(t, "kg", oru.Observations[0].Units)
+		assert.True(t, oru.Observations[0].Timestamp.Equal(time.Date(2020, 4, 1, 6, 30, 0, 0, time.UTC)))
+
+		assert.Equal(t, "8302-2", oru.Observations[1].Code)
+		assert.True(t, oru.Observations[1].Timestamp.Equal(time.Date(2020, 4, 1, 6, 45, 0, 0, time.UTC)))
+	}
+}
+
+func TestReadOruR01Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		code    AckCode
+	}{
+		{
+			name:    "unsupported message type",
+			message: "MSH|^~\\&|LAB|CLINIC|||20200401||ADT^A01|1|P|2.5.1\rPID|1||12345",
+			code:    AckCodeReject,
+		},
+		{
+			name:    "missing control id",
+			message: "MSH|^~\\&|LAB|CLINIC|||20200401||ORU^R01|||2.5.1\rPID|1||12345",
+			code:    AckCodeReject,
+		},
+		{
+			name:    "missing patient",
+			message: "MSH|^~\\&|LAB|CLINIC|||20200401||ORU^R01|1|P|2.5.1\rOBX|1|NM|29463-7^^LN||4|kg|||||F",
+			code:    AckCodeError,
+		},
+		{
+			name:    "invalid value",
+			message: "MSH|^~\\&|LAB|CLINIC|||20200401||ORU^R01|1|P|2.5.1\rPID|1||12345\rOBX|1|NM|29463-7^^LN||4,5|kg|||||F",
+			code:    AckCodeError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := Parse(tt.message)
+			if !assert.Nil(t, err) {
+				return
+			}
+			_, err = ReadOruR01(m)
+			if assert.IsType(t, &Error{}, err) {
+				assert.Equal(t, tt.code, err.(*Error).Code)
+			}
+		})
+	}
+}
+
+func TestBuildAck(t *testing.T) {
+	m, _ := Parse(oruMessage)
+	now := time.Date(2020, 4, 1, 10, 0, 0, 0, time.UTC)
+
+	ack := BuildAck(m, AckCodeAccept, "ok", now)
+	parsed, err := Parse(ack)
+	if assert.Nil(t, err) {
+		msh, _ := parsed.Segment("MSH")
+		assert.Equal(t, "DIARY", msh.Field(3))
+		assert.Equal(t, "LAB", msh.Field(5))
+		assert.Equal(t, "CITY CLINIC", msh.Field(6))
+		assert.Equal(t, "20200401100000", msh.Field(7))
+		assert.Equal(t, "ACK^R01", parsed.MessageType())
+		msa, _ := parsed.Segment("MSA")
+		assert.Equal(t, "AA", msa.Field(1))
+		assert.Equal(t, "MSG00001", msa.Field(2))
+		_, hasErr := parsed.Segment("ERR")
+		assert.False(t, hasErr)
+	}
+
+	nak := BuildAck(nil, AckCodeReject, "bad|message", now)
+	parsed, err = Parse(nak)
+	if assert.Nil(t, err) {
+		msa, _ := parsed.Segment("MSA")
+		assert.Equal(t, "AR", msa.Field(1))
+		assert.Equal(t, "bad|message", msa.Field(3))
+		_, hasErr := parsed.Segment("ERR")
+		assert.True(t, hasErr)
+	}
+}
+
+func TestMllpFrame(t *testing.T) {
+	var buffer bytes.Buffer
+	assert.Nil(t, WriteMllpFrame(&buffer, "MSH|first"))
+	assert.Nil(t, WriteMllpFrame(&buffer, "MSH|second"))
+
+	reader := bufio.NewReader(&buffer)
+	first, err := ReadMllpFrame(reader)
+	assert.Nil(t, err)
+	assert.Equal(t, "MSH|first", first)
+	second, err := ReadMllpFrame(reader)
+	assert.Nil(t, err)
+	assert.Equal(t, "MSH|second", second)
+
+	_, err = ReadMllpFrame(bufio.NewReader(strings.NewReader("\x0bMSH|broken")))
+	assert.NotNil(t, err)
+}
+
+func TestMllpServer(t *testing.T) {
+	s := &MllpServer{Addr: "127.0.0.1:0", Handler: func(raw string) string { return "ACK|" + raw }}
+	listener, err := s.Listen()
+	if !assert.Nil(t, err) {
+		return
+	}
+	served := make(chan error, 1)
+	go func() {
+		served <- s.Serve(listener)
+	}()
+
+	_, err = (&MllpServer{Addr: listener.Addr().String()}).Listen()
+	assert.NotNil(t, err, "taken address is reported to the caller")
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if assert.Nil(t, err) {
+		assert.Nil(t, WriteMllpFrame(conn, "MSH|first"))
+		ack, err := ReadMllpFrame(bufio.NewReader(conn))
+		assert.Nil(t, err)
+		assert.Equal(t, "ACK|MSH|first", ack)
+		conn.Close()
+	}
+
+	listener.Close()
+	select {
+	case err := <-served:
+		assert.NotNil(t, err)
+	case <-time.After(time.Second):
+		t.Error("serving did not stop with the listener")
+	}
+}
@@ -0,0 +1,130 @@
+package hl7
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"time"
+)
+
+const (
+	mllpStartBlock     = 0x0b
+	mllpEndBlock       = 0x1c
+	mllpCarriageReturn = 0x0d
+	mllpMaxFrameSize   = 1 << 20
+	mllpReadTimeout    = 5 * time.Minute
+	mllpMinAcceptDelay = 5 * time.Millisecond
+	mllpMaxAcceptDelay = time.Second
+)
+
+// MllpServer accepts HL7 messages over minimal lower layer protocol and answers with handler result
+type MllpServer struct {
+	Addr    string
+	Handler func(raw string) string
+}
+
+// ListenAndServe binds the address and serves connections, see Serve
+func (s *MllpServer) ListenAndServe() error {
+	listener, err := s.Listen()
+	if err != nil {
+		return err
+	}
+	return s.Serve(listener)
+}
+
+// Listen binds the address, so that the caller learns of a taken port before serving starts
+func (s *MllpServer) Listen() (net.Listener, error) {
+	return net.Listen("tcp", s.Addr)
+}
+
+// Serve accepts connections until the listener fails, temporary accept errors are retried after a growing pause
+func (s *MllpServer) Serve(listener net.Listener) error {
+	defer listener.Close()
+	var delay time.Duration
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if netErr, ok := err.(net.Error); ok && netErr.Temporary() {
+				delay *= 2
+				if delay == 0 {
+					delay = mllpMinAcceptDelay
+				}
+				if delay > mllpMaxAcceptDelay {
+					delay = mllpMaxAcceptDelay
+				}
+				log.Printf("mllp accept: %v, retrying in %v", err, delay)
+				time.Sleep(delay)
+				continue
+			}
+			return err
+		}
+		delay = 0
+		go s.serve(conn)
+	}
+}
+
+func (s *MllpServer) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	for {
+		_ = conn.SetReadDeadline(time.Now().Add(mllpReadTimeout))
+		message, err := ReadMllpFrame(reader)
+		if err != nil {
+			if err != io.EOF {
+				log.Printf("mllp connection %s: %v", conn.RemoteAddr(), err)
+			}
+			return
+		}
+		if err := WriteMllpFrame(conn, s.Handler(message)); err != nil {
+			log.Printf("mllp connection %s: %v", conn.RemoteAddr(), err)
+			return
+		}
+	}
+}
+
+func ReadMllpFrame(reader *bufio.Reader) (string, error) {
+	for {
+		b, err := reader.ReadByte()
+		if err != nil {
+			return "", err
+		}
+		if b == mllpStartBlock {
+			break
+		}
+	}
+	var frame []byte
+	for {
+		b, err := reader.ReadByte()
+		if err != nil {
+			if err == io.EOF {
+				return "", io.ErrUnexpectedEOF
+			}
+			return "", err
+		}
+		if b == mllpEndBlock {
+			next, err := reader.ReadByte()
+			if err != nil {
+				return "", err
+			}
+			if next != mllpCarriageReturn {
+				return "", fmt.Errorf("mllp frame end block is not followed by carriage return")
+			}
+			return string(frame), nil
+		}
+		if len(frame) >= mllpMaxFrameSize {
+			return "", fmt.Errorf("mllp frame exceeds %d bytes", mllpMaxFrameSize)
+		}
+		frame = append(frame, b)
+	}
+}
+
+func WriteMllpFrame(w io.Writer, message string) error {
+	frame := make([]byte, 0, len(message)+3)
+	frame = append(frame, mllpStartBlock)
+	frame = append(frame, message...)
+	frame = append(frame, mllpEndBlock, mllpCarriageReturn)
+	_, err := w.Write(frame)
+	return err
+}
@@ -0,0 +1,116 @@
+package hl7
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+const MessageTypeOruR01 = "ORU^R01"
+
+type PatientIdentifier struct {
+	Id                 string
+	AssigningAuthority string
+}
+
+type Observation struct {
+	SetId        string
+	Code         string
+	CodingSystem string
+	Value        float32
+	Units        string
+	Timestamp    time.Time
+}
+
+// OruR01 is an unsolicited observation result reduced to the data we ingest
+type OruR01 struct {
+	ControlId          string
+	SendingFacility    string
+	PatientIdentifiers []PatientIdentifier
+	Observations       []Observation
+}
+
+// ReadOruR01 extracts patient identifiers and numeric final observations from ORU^R01 message
+func ReadOruR01(m *Message) (*OruR01, error) {
+	if m.MessageType() != MessageTypeOruR01 {
+		return nil, &Error{Code: AckCodeReject, S: fmt.Sprintf("unsupported message type %s", m.MessageType())}
+	}
+	msh, _ := m.Segment("MSH")
+	result := &OruR01{
+		ControlId:       m.ControlId(),
+		SendingFacility: msh.Component(4, 1),
+	}
+	if result.ControlId == "" {
+		return nil, &Error{Code: AckCodeReject, S: "message control id is missing"}
+	}
+
+	pid, ok := m.Segment("PID")
+	if !ok {
+		return nil, &Error{Code: AckCodeError, S: "PID segment is missing"}
+	}
+	for _, repetition := range pid.Repetitions(3) {
+		id := RepetitionComponent(pid, repetition, 1)
+		if id == "" {
+			continue
+		}
+		authority := RepetitionComponent(pid, repetition, 4)
+		if authority == "" {
+			authority = result.SendingFacility
+		}
+		result.PatientIdentifiers = append(result.PatientIdentifiers, PatientIdentifier{Id: id, AssigningAuthority: authority})
+	}
+	if len(result.PatientIdentifiers) == 0 {
+		return nil, &Error{Code: AckCodeError, S: "patient identifier is missing"}
+	}
+
+	messageTime, _ := ParseTimestamp(msh.Field(7))
+	observationTime := messageTime
+	for _, s := range m.Segments {
+		switch s.Name() {
+		case "OBR":
+			observationTime = messageTime
+			if ts, err := ParseTimestamp(s.Field(7)); err == nil {
+				observationTime = ts
+			}
+		case "OBX":
+			observation, err := readObservation(s, observationTime)
+			if err != nil {
+				return nil, err
+			}
+			if observation != nil {
+				result.Observations = append(result.Observations, *observation)
+			}
+		}
+	}
+	return result, nil
+}
+
+func readObservation(s Segment, defaultTime time.Time) (*Observation, error) {
+	status := s.Field(11)
+	if s.Field(2) != "NM" || (status != "" && status != "F" && status != "C") {
+		return nil, nil
+	}
+	value, err := strconv.ParseFloat(s.Field(5), 32)
+	if err != nil {
+		return nil, &Error{Code: AckCodeError, S: fmt.Sprintf("OBX %s has invalid numeric value %q", s.Field(1), s.Field(5))}
+	}
+	observation := &Observation{
+		SetId:        s.Field(1),
+		Code:         s.Component(3, 1),
+		CodingSystem: s.Component(3, 3),
+		Value:        float32(value),
+		Units:        s.Component(6, 1),
+		Timestamp:    defaultTime,
+	}
+	if raw := s.Field(14); raw != "" {
+		ts, err := ParseTimestamp(raw)
+		if err != nil {
+			return nil, &Error{Code: AckCodeError, S: fmt.Sprintf("OBX %s: %s", observation.SetId, err)}
+		}
+		observation.Timestamp = ts
+	}
+	if observation.Timestamp.IsZero() {
+		return nil, &Error{Code: AckCodeError, S: fmt.Sprintf("OBX %s has no observation time", observation.SetId)}
+	}
+	return observation, nil
+}
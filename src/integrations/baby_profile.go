@@ -0,0 +1,144 @@
+package integrations
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"little-diary-measurement-service/src/models"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+type BabyProfileDto struct {
+	BirthDate          string `json:"birth_date"`
+	Sex                string `json:"sex"`
+	GestationalAgeDays int    `json:"gestational_age_days"`
+}
+
+// defaultBabyProfileCacheSize bounds the cache when CacheSize is not set
+const defaultBabyProfileCacheSize = 10000
+
+type cachedBabyProfile struct {
+	profile   *models.BabyProfile
+	expiresAt time.Time
+}
+
+// BabyProfileIntegration reads baby profiles from the family server and keeps them for CacheTTL,
+// profiles unknown to the family server are cached as nil as well. At most CacheSize profiles are kept,
+// expired ones are dropped first and then the ones closest to expiry
+type BabyProfileIntegration struct {
+	Client      HttpClient
+	Config      FamilyServerConfig
+	AuthService AuthService
+	CacheTTL    time.Duration
+	CacheSize   int
+
+	mu    sync.Mutex
+	cache map[string]cachedBabyProfile
+}
+
+func (b *BabyProfileIntegration) GetBabyProfile(targetUuid string) (*models.BabyProfile, error) {
+	now := time.Now()
+	b.mu.Lock()
+	cached, ok := b.cache[targetUuid]
+	if ok && !now.Before(cached.expiresAt) {
+		delete(b.cache, targetUuid)
+	}
+	b.mu.Unlock()
+	if ok && now.Before(cached.expiresAt) {
+		return cached.profile, nil
+	}
+
+	profile, err := b.fetchBabyProfile(targetUuid)
+	if err != nil {
+		return nil, err
+	}
+	if b.CacheTTL > 0 {
+		b.storeBabyProfile(targetUuid, profile, now)
+	}
+	return profile, nil
+}
+
+func (b *BabyProfileIntegration) storeBabyProfile(targetUuid string, profile *models.BabyProfile, now time.Time) {
+	size := b.CacheSize
+	if size <= 0 {
+		size = defaultBabyProfileCacheSize
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.cache == nil {
+		b.cache = map[string]cachedBabyProfile{}
+	}
+	if _, ok := b.cache[targetUuid]; !ok && len(b.cache) >= size {
+		b.evict(now, size)
+	}
+	b.cache[targetUuid] = cachedBabyProfile{profile: profile, expiresAt: now.Add(b.CacheTTL)}
+}
+
+// evict drops expired profiles and, when the cache is still full, the profiles expiring first
+func (b *BabyProfileIntegration) evict(now time.Time, size int) {
+	for targetUuid, cached := range b.cache {
+		if !now.Before(cached.expiresAt) {
+			delete(b.cache, targetUuid)
+		}
+	}
+	for len(b.cache) >= size {
+		oldest := ""
+		for targetUuid, cached := range b.cache {
+			if oldest == "" || cached.expiresAt.Before(b.cache[oldest].expiresAt) {
+				oldest = targetUuid
+			}
+		}
+		delete(b.cache, oldest)
+	}
+}
+
+func (b *BabyProfileIntegration) fetchBabyProfile(targetUuid string) (*models.BabyProfile, error) {
+	accessToken, err := b.AuthService.GetAccessToken()
+	if err != nil {
+		return nil, err
+	}
+
+	profileUrl, err := url.Parse(b.Config.GetFamilyServerUrl())
+	if err != nil {
+		return nil, err
+	}
+	profileUrl.Path = fmt.Sprintf("/v1/baby/%s/profile", targetUuid)
+	request, err := http.NewRequest(http.MethodGet, profileUrl.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Authorization", fmt.Sprintf("Bearer %s", accessToken))
+	response, err := b.Client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if response.StatusCode != http.StatusOK {
+		textData, _ := ioutil.ReadAll(response.Body)
+		return nil, fmt.Errorf("baby profile error from family server %d: %s", response.StatusCode, textData)
+	}
+
+	var responseDto BabyProfileDto
+	if err = json.NewDecoder(response.Body).Decode(&responseDto); err != nil {
+		return nil, err
+	}
+	profile := &models.BabyProfile{
+		TargetUuid:         models.TargetUUID(targetUuid),
+		Sex:                responseDto.Sex,
+		GestationalAgeDays: responseDto.GestationalAgeDays,
+	}
+	if responseDto.BirthDate != "" {
+		birthDate, err := time.Parse("2006-01-02", responseDto.BirthDate)
+		if err != nil {
+			return nil, fmt.Errorf("baby profile has invalid birth date %s", responseDto.BirthDate)
+		}
+		profile.BirthDate = &birthDate
+	}
+	return profile, nil
+}
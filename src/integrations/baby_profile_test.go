@@ -0,0 +1,77 @@
+package integrations
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"little-diary-measurement-service/src/test_data"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestBabyProfileIntegration_GetBabyProfile(t *testing.T) {
+	targetUuid := "11111111-3333-412d-ade7-47be43827d68"
+	newIntegration := func(client HttpClient) *BabyProfileIntegration {
+		config := test_data.MockFamilyServerConfig{}
+		config.On("GetFamilyServerUrl").Return("https://family.little-diary.net")
+		authService := test_data.MockAuthService{}
+		authService.On("GetAccessToken", mock.Anything).Return("fake_token", nil)
+		return &BabyProfileIntegration{Client: client, Config: &config, AuthService: &authService, CacheTTL: time.Minute}
+	}
+
+	t.Run("Test cached profile", func(t *testing.T) {
+		client := new(test_data.MockHttpClient)
+		client.On("Do", mock.Anything).
+			Return(test_data.MakeHttpResponse(200, &BabyProfileDto{BirthDate: "2020-01-31", Sex: "female", GestationalAgeDays: 252}), nil).Once()
+		b := newIntegration(client)
+
+		for i := 0; i < 2; i++ {
+			profile, err := b.GetBabyProfile(targetUuid)
+			if assert.NoError(t, err) && assert.NotNil(t, profile) {
+				assert.Equal(t, time.Date(2020, 1, 31, 0, 0, 0, 0, time.UTC), *profile.BirthDate)
+				assert.Equal(t, "female", profile.Sex)
+				assert.Equal(t, 252, profile.GestationalAgeDays)
+			}
+		}
+		client.AssertNumberOfCalls(t, "Do", 1)
+		actualReq := client.Calls[0].Arguments.Get(0).(*http.Request)
+		assert.Equal(t, "https://family.little-diary.net/v1/baby/"+targetUuid+"/profile", actualReq.URL.String())
+		assert.Equal(t, "Bearer fake_token", actualReq.Header.Get("Authorization"))
+	})
+
+	t.Run("Test cache size", func(t *testing.T) {
+		client := new(test_data.MockHttpClient)
+		client.On("Do", mock.Anything).Return(test_data.MakeHttpResponse(404, nil), nil)
+		b := newIntegration(client)
+		b.CacheSize = 2
+
+		for _, uuid := range []string{targetUuid, "22222222-3333-412d-ade7-47be43827d68", "33333333-3333-412d-ade7-47be43827d68"} {
+			_, err := b.GetBabyProfile(uuid)
+			assert.NoError(t, err)
+		}
+		assert.Len(t, b.cache, 2)
+		assert.NotContains(t, b.cache, targetUuid)
+
+		// an expired profile is dropped before the one still valid
+		b.cache["33333333-3333-412d-ade7-47be43827d68"] = cachedBabyProfile{expiresAt: time.Now().Add(-time.Second)}
+		_, err := b.GetBabyProfile(targetUuid)
+		assert.NoError(t, err)
+		assert.Len(t, b.cache, 2)
+		assert.Contains(t, b.cache, "22222222-3333-412d-ade7-47be43827d68")
+	})
+
+	t.Run("Test unknown baby", func(t *testing.T) {
+		client := new(test_data.MockHttpClient)
+		client.On("Do", mock.Anything).Return(test_data.MakeHttpResponse(404, nil), nil)
+		profile, err := newIntegration(client).GetBabyProfile(targetUuid)
+		assert.NoError(t, err)
+		assert.Nil(t, profile)
+	})
+
+	t.Run("Test family server error", func(t *testing.T) {
+		client := new(test_data.MockHttpClient)
+		client.On("Do", mock.Anything).Return(test_data.MakeHttpResponse(500, nil), nil)
+		_, err := newIntegration(client).GetBabyProfile(targetUuid)
+		assert.Error(t, err)
+	})
+}
@@ -1,11 +1,23 @@
 package integrations
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"little-diary-measurement-service/src/models"
 	"net/http"
 	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// familyAccessConcurrency bounds single access checks running in parallel when batch endpoint is missing
+	familyAccessConcurrency = 4
+	// familyBatchRetryAfter is how long the batch endpoint is not asked after the family server answered it has none
+	familyBatchRetryAfter = 10 * time.Minute
 )
 
 type FamilyServerConfig interface {
@@ -20,46 +32,281 @@ type FamilyIntegration struct {
 	Client      HttpClient
 	Config      FamilyServerConfig
 	AuthService AuthService
+	// unix nanoseconds until which the batch access endpoint is not asked, set when family server answered it has none
+	batchUnsupportedUntil int64
 }
 
+// CheckAccessResponseDto carries permissions of the user, family servers
+// answering only has_access grant the full parent access
 type CheckAccessResponseDto struct {
-	HasAccess bool `json:"has_access"`
+	HasAccess   bool                `json:"has_access"`
+	Permissions []models.Permission `json:"permissions"`
+}
+
+type CheckBatchAccessRequestDto struct {
+	BabyUuids []string `json:"baby_uuids"`
+}
+
+type CheckBatchAccessResponseDto struct {
+	Permissions map[string][]models.Permission `json:"permissions"`
 }
 
-func (f *FamilyIntegration) CheckUserHasAccessToBaby(userUuid string, targetUuid string) (bool, error) {
+// parentPermissions is what has_access used to mean before family server answered permissions
+var parentPermissions = models.Permissions{models.PermissionRead, models.PermissionWrite, models.PermissionDelete}
+
+func (r *CheckAccessResponseDto) permissions() models.Permissions {
+	if r.Permissions != nil {
+		return r.Permissions
+	}
+	if r.HasAccess {
+		return parentPermissions
+	}
+	return models.Permissions{}
+}
+
+// GetPermissions answers what the user may do with the target, unknown targets grant nothing
+func (f *FamilyIntegration) GetPermissions(userUuid string, targetUuid string) (models.Permissions, error) {
 	accessToken, err := f.AuthService.GetAccessToken()
 	if err != nil {
-		return false, nil
+		return models.Permissions{}, nil
 	}
 
 	accessUrl, err := url.Parse(f.Config.GetFamilyServerUrl())
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 	accessUrl.Path = fmt.Sprintf("/v1/access/%s/baby/%s", userUuid, targetUuid)
 	request, err := http.NewRequest(http.MethodGet, accessUrl.String(), nil)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 	request.Header.Set("Authorization", fmt.Sprintf("Bearer %s", accessToken))
 	response, err := f.Client.Do(request)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 	if response.StatusCode != http.StatusOK {
 		textData, _ := ioutil.ReadAll(response.Body)
 		if response.StatusCode == http.StatusNotFound {
-			return false, nil
+			return models.Permissions{}, nil
 		}
-		return false, fmt.Errorf("check access error from family server %d: %s", response.StatusCode, textData)
+		return nil, fmt.Errorf("check access error from family server %d: %s", response.StatusCode, textData)
 	}
 
 	var responseDto CheckAccessResponseDto
 	defer response.Body.Close()
 	err = json.NewDecoder(response.Body).Decode(&responseDto)
 	if err != nil {
-		return false, err
+		return nil, err
+	}
+
+	return responseDto.permissions(), nil
+}
+
+// GetPermissionsForBabies asks for all targets with one request to the family server. Older family servers
+// without batch endpoint are asked for every target separately, the batch endpoint is tried again after a while
+func (f *FamilyIntegration) GetPermissionsForBabies(userUuid string, targetUuids []string) (map[string]models.Permissions, error) {
+	if time.Now().UnixNano() >= atomic.LoadInt64(&f.batchUnsupportedUntil) {
+		permissions, answered, err := f.checkBatch(userUuid, targetUuids)
+		if answered {
+			return permissions, err
+		}
+	}
+	return f.checkEach(userUuid, targetUuids)
+}
+
+// checkBatch returns false when the batch endpoint did not answer and single checks are needed
+func (f *FamilyIntegration) checkBatch(userUuid string, targetUuids []string) (map[string]models.Permissions, bool, error) {
+	accessToken, err := f.AuthService.GetAccessToken()
+	if err != nil {
+		return nil, true, err
+	}
+
+	accessUrl, err := url.Parse(f.Config.GetFamilyServerUrl())
+	if err != nil {
+		return nil, true, err
+	}
+	accessUrl.Path = fmt.Sprintf("/v1/access/%s/babies", userUuid)
+	body, err := json.Marshal(&CheckBatchAccessRequestDto{BabyUuids: targetUuids})
+	if err != nil {
+		return nil, true, err
+	}
+	request, err := http.NewRequest(http.MethodPost, accessUrl.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, true, err
+	}
+	request.Header.Set("Authorization", fmt.Sprintf("Bearer %s", accessToken))
+	request.Header.Set("Content-Type", "application/json")
+	response, err := f.Client.Do(request)
+	if err != nil {
+		return nil, true, err
+	}
+	defer response.Body.Close()
+	switch response.StatusCode {
+	case http.StatusOK:
+	case http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		atomic.StoreInt64(&f.batchUnsupportedUntil, time.Now().Add(familyBatchRetryAfter).UnixNano())
+		return nil, false, nil
+	case http.StatusNotFound:
+		// unknown user or a server without the endpoint, single checks tell them apart
+		return nil, false, nil
+	default:
+		textData, _ := ioutil.ReadAll(response.Body)
+		return nil, true, fmt.Errorf("batch check access error from family server %d: %s", response.StatusCode, textData)
+	}
+
+	var responseDto CheckBatchAccessResponseDto
+	if err = json.NewDecoder(response.Body).Decode(&responseDto); err != nil {
+		return nil, true, err
+	}
+	permissions := make(map[string]models.Permissions, len(targetUuids))
+	for _, targetUuid := range targetUuids {
+		permissions[targetUuid] = responseDto.Permissions[targetUuid]
+		if permissions[targetUuid] == nil {
+			permissions[targetUuid] = models.Permissions{}
+		}
+	}
+	return permissions, true, nil
+}
+
+func (f *FamilyIntegration) checkEach(userUuid string, targetUuids []string) (map[string]models.Permissions, error) {
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		firstErr error
+	)
+	permissions := make(map[string]models.Permissions, len(targetUuids))
+	slots := make(chan struct{}, familyAccessConcurrency)
+	for _, targetUuid := range targetUuids {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(targetUuid string) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			granted, err := f.GetPermissions(userUuid, targetUuid)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil && firstErr == nil {
+				firstErr = err
+			}
+			permissions[targetUuid] = granted
+		}(targetUuid)
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return permissions, nil
+}
+
+// accessGrantsPageSize limits grants in one page of the backfill listing
+const accessGrantsPageSize = 500
+
+type AccessGrantDto struct {
+	UserUuid    string              `json:"user_uuid"`
+	BabyUuid    string              `json:"baby_uuid"`
+	Permissions []models.Permission `json:"permissions"`
+	Version     int64               `json:"version"`
+}
+
+type AccessGrantsPageDto struct {
+	Grants []AccessGrantDto `json:"grants"`
+	// cursor of the next page, empty on the last page
+	Next string `json:"next"`
+}
+
+// ListAccessGrants returns a page of all access grants known to the family server, empty cursor starts from the beginning
+func (f *FamilyIntegration) ListAccessGrants(cursor string) (*AccessGrantsPageDto, error) {
+	accessToken, err := f.AuthService.GetAccessToken()
+	if err != nil {
+		return nil, err
+	}
+
+	grantsUrl, err := url.Parse(f.Config.GetFamilyServerUrl())
+	if err != nil {
+		return nil, err
+	}
+	grantsUrl.Path = "/v1/access/grants"
+	query := url.Values{}
+	query.Set("limit", fmt.Sprintf("%d", accessGrantsPageSize))
+	if cursor != "" {
+		query.Set("after", cursor)
+	}
+	grantsUrl.RawQuery = query.Encode()
+	request, err := http.NewRequest(http.MethodGet, grantsUrl.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Authorization", fmt.Sprintf("Bearer %s", accessToken))
+	response, err := f.Client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		textData, _ := ioutil.ReadAll(response.Body)
+		return nil, fmt.Errorf("list access grants error from family server %d: %s", response.StatusCode, textData)
+	}
+
+	var page AccessGrantsPageDto
+	if err = json.NewDecoder(response.Body).Decode(&page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+type AccessibleBabyDto struct {
+	BabyUuid    string              `json:"baby_uuid"`
+	Permissions []models.Permission `json:"permissions"`
+}
+
+type AccessibleBabiesResponseDto struct {
+	Babies []AccessibleBabyDto `json:"babies"`
+}
+
+// ListTargets returns babies the user has any access to with the permissions granted on each
+func (f *FamilyIntegration) ListTargets(userUuid string) ([]*models.AccessibleTarget, error) {
+	accessToken, err := f.AuthService.GetAccessToken()
+	if err != nil {
+		return nil, err
+	}
+
+	babiesUrl, err := url.Parse(f.Config.GetFamilyServerUrl())
+	if err != nil {
+		return nil, err
+	}
+	babiesUrl.Path = fmt.Sprintf("/v1/access/%s/babies", userUuid)
+	request, err := http.NewRequest(http.MethodGet, babiesUrl.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Authorization", fmt.Sprintf("Bearer %s", accessToken))
+	response, err := f.Client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode == http.StatusNotFound {
+		return []*models.AccessibleTarget{}, nil
+	}
+	if response.StatusCode != http.StatusOK {
+		textData, _ := ioutil.ReadAll(response.Body)
+		return nil, fmt.Errorf("list babies error from family server %d: %s", response.StatusCode, textData)
 	}
 
-	return responseDto.HasAccess, nil
+	var responseDto AccessibleBabiesResponseDto
+	if err = json.NewDecoder(response.Body).Decode(&responseDto); err != nil {
+		return nil, err
+	}
+	targets := []*models.AccessibleTarget{}
+	for _, baby := range responseDto.Babies {
+		permissions := models.Permissions(baby.Permissions)
+		if permissions == nil {
+			permissions = parentPermissions
+		}
+		targets = append(targets, &models.AccessibleTarget{TargetUuid: models.TargetUUID(baby.BabyUuid), Permissions: permissions})
+	}
+	return targets, nil
 }
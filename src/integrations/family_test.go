@@ -1,15 +1,19 @@
 package integrations
 
 import (
+	"encoding/json"
 	"fmt"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"little-diary-measurement-service/src/models"
 	"little-diary-measurement-service/src/test_data"
 	"net/http"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
-func TestFamilyIntegration_CheckUserHasAccessToBaby(t *testing.T) {
+func TestFamilyIntegration_GetPermissions(t *testing.T) {
 	type fields struct {
 		Client      HttpClient
 		Config      FamilyServerConfig
@@ -23,7 +27,7 @@ func TestFamilyIntegration_CheckUserHasAccessToBaby(t *testing.T) {
 		name    string
 		fields  fields
 		args    args
-		want    bool
+		want    models.Permissions
 		wantErr bool
 	}{
 		{
@@ -50,7 +54,7 @@ func TestFamilyIntegration_CheckUserHasAccessToBaby(t *testing.T) {
 				userUuid:   "74822532-56e4-4ff0-8890-3247a17433cc",
 				targetUuid: "11111111-3333-412d-ade7-47be43827d68",
 			},
-			want:    true,
+			want:    models.Permissions{models.PermissionRead, models.PermissionWrite, models.PermissionDelete},
 			wantErr: false,
 		},
 		{
@@ -77,7 +81,37 @@ func TestFamilyIntegration_CheckUserHasAccessToBaby(t *testing.T) {
 				userUuid:   "74822532-56e4-4ff0-8890-3247a17433cc",
 				targetUuid: "11111111-3333-412d-ade7-47be43827d68",
 			},
-			want:    false,
+			want:    models.Permissions{},
+			wantErr: false,
+		},
+		{
+			name: "Test read only",
+			fields: fields{
+				Client: func() HttpClient {
+					mockObj := new(test_data.MockHttpClient)
+					mockObj.On("Do", mock.Anything).
+						Return(test_data.MakeHttpResponse(200, &CheckAccessResponseDto{
+							HasAccess:   true,
+							Permissions: []models.Permission{models.PermissionRead},
+						}), nil)
+					return mockObj
+				}(),
+				Config: func() FamilyServerConfig {
+					mockObj := test_data.MockFamilyServerConfig{}
+					mockObj.On("GetFamilyServerUrl").Return("https://family.little-diary.net")
+					return &mockObj
+				}(),
+				AuthService: func() AuthService {
+					mockObj := test_data.MockAuthService{}
+					mockObj.On("GetAccessToken", mock.Anything).Return("fake_token", nil)
+					return &mockObj
+				}(),
+			},
+			args: args{
+				userUuid:   "74822532-56e4-4ff0-8890-3247a17433cc",
+				targetUuid: "11111111-3333-412d-ade7-47be43827d68",
+			},
+			want:    models.Permissions{models.PermissionRead},
 			wantErr: false,
 		},
 		{
@@ -104,7 +138,7 @@ func TestFamilyIntegration_CheckUserHasAccessToBaby(t *testing.T) {
 				userUuid:   "74822532-56e4-4ff0-8890-3247a17433cc",
 				targetUuid: "11111111-3333-412d-ade7-47be43827d68",
 			},
-			want:    false,
+			want:    models.Permissions{},
 			wantErr: false,
 		},
 	}
@@ -115,14 +149,12 @@ func TestFamilyIntegration_CheckUserHasAccessToBaby(t *testing.T) {
 				Config:      tt.fields.Config,
 				AuthService: tt.fields.AuthService,
 			}
-			got, err := f.CheckUserHasAccessToBaby(tt.args.userUuid, tt.args.targetUuid)
+			got, err := f.GetPermissions(tt.args.userUuid, tt.args.targetUuid)
 			if (err != nil) != tt.wantErr {
-				t.Errorf("CheckUserHasAccessToBaby() error = %v, wantErr %v", err, tt.wantErr)
+				t.Errorf("GetPermissions() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
-			if got != tt.want {
-				t.Errorf("CheckUserHasAccessToBaby() got = %v, want %v", got, tt.want)
-			}
+			assert.Equal(t, tt.want, got)
 
 			actualReq := tt.fields.Client.(*test_data.MockHttpClient).Calls[0].Arguments.Get(0).(*http.Request)
 			assert.Equal(t, actualReq.URL.String(), fmt.Sprintf("%s/v1/access/%s/baby/%s",
@@ -133,3 +165,178 @@ func TestFamilyIntegration_CheckUserHasAccessToBaby(t *testing.T) {
 		})
 	}
 }
+
+func TestFamilyIntegration_GetPermissionsForBabies(t *testing.T) {
+	userUuid := "74822532-56e4-4ff0-8890-3247a17433cc"
+	targetUuids := []string{"11111111-3333-412d-ade7-47be43827d68", "22222222-3333-412d-ade7-47be43827d68"}
+	pathIs := func(method string, path string) interface{} {
+		return mock.MatchedBy(func(req *http.Request) bool {
+			return req.Method == method && req.URL.Path == path
+		})
+	}
+	newIntegration := func(client HttpClient) *FamilyIntegration {
+		config := test_data.MockFamilyServerConfig{}
+		config.On("GetFamilyServerUrl").Return("https://family.little-diary.net")
+		authService := test_data.MockAuthService{}
+		authService.On("GetAccessToken", mock.Anything).Return("fake_token", nil)
+		return &FamilyIntegration{Client: client, Config: &config, AuthService: &authService}
+	}
+
+	t.Run("Test batch endpoint", func(t *testing.T) {
+		client := new(test_data.MockHttpClient)
+		client.On("Do", pathIs(http.MethodPost, fmt.Sprintf("/v1/access/%s/babies", userUuid))).
+			Return(test_data.MakeHttpResponse(200, &CheckBatchAccessResponseDto{
+				Permissions: map[string][]models.Permission{targetUuids[0]: {models.PermissionRead}},
+			}), nil)
+		f := newIntegration(client)
+
+		got, err := f.GetPermissionsForBabies(userUuid, targetUuids)
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]models.Permissions{
+			targetUuids[0]: {models.PermissionRead},
+			targetUuids[1]: {},
+		}, got)
+		client.AssertNumberOfCalls(t, "Do", 1)
+
+		actualReq := client.Calls[0].Arguments.Get(0).(*http.Request)
+		assert.Equal(t, fmt.Sprintf("Bearer %s", "fake_token"), actualReq.Header.Get("Authorization"))
+		var body CheckBatchAccessRequestDto
+		assert.NoError(t, json.NewDecoder(actualReq.Body).Decode(&body))
+		assert.Equal(t, targetUuids, body.BabyUuids)
+	})
+
+	t.Run("Test fallback to single checks", func(t *testing.T) {
+		client := new(test_data.MockHttpClient)
+		client.On("Do", pathIs(http.MethodPost, fmt.Sprintf("/v1/access/%s/babies", userUuid))).
+			Return(test_data.MakeHttpResponse(405, nil), nil).Once()
+		client.On("Do", pathIs(http.MethodGet, fmt.Sprintf("/v1/access/%s/baby/%s", userUuid, targetUuids[0]))).
+			Return(test_data.MakeHttpResponse(200, &CheckAccessResponseDto{HasAccess: true}), nil).Once()
+		client.On("Do", pathIs(http.MethodGet, fmt.Sprintf("/v1/access/%s/baby/%s", userUuid, targetUuids[1]))).
+			Return(test_data.MakeHttpResponse(200, &CheckAccessResponseDto{HasAccess: false}), nil).Once()
+		f := newIntegration(client)
+
+		got, err := f.GetPermissionsForBabies(userUuid, targetUuids)
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]models.Permissions{
+			targetUuids[0]: {models.PermissionRead, models.PermissionWrite, models.PermissionDelete},
+			targetUuids[1]: {},
+		}, got)
+		client.AssertExpectations(t)
+
+		// batch endpoint is not asked again once it is known to be missing
+		client.On("Do", pathIs(http.MethodGet, fmt.Sprintf("/v1/access/%s/baby/%s", userUuid, targetUuids[0]))).
+			Return(test_data.MakeHttpResponse(200, &CheckAccessResponseDto{HasAccess: true}), nil).Once()
+		got, err = f.GetPermissionsForBabies(userUuid, targetUuids[:1])
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]models.Permissions{
+			targetUuids[0]: {models.PermissionRead, models.PermissionWrite, models.PermissionDelete},
+		}, got)
+		client.AssertNumberOfCalls(t, "Do", 4)
+
+		// batch endpoint is asked again after a while
+		atomic.StoreInt64(&f.batchUnsupportedUntil, time.Now().Add(-time.Second).UnixNano())
+		client.On("Do", pathIs(http.MethodPost, fmt.Sprintf("/v1/access/%s/babies", userUuid))).
+			Return(test_data.MakeHttpResponse(200, &CheckBatchAccessResponseDto{}), nil).Once()
+		_, err = f.GetPermissionsForBabies(userUuid, targetUuids[:1])
+		assert.NoError(t, err)
+		client.AssertNumberOfCalls(t, "Do", 5)
+	})
+
+	t.Run("Test unknown user keeps batch endpoint", func(t *testing.T) {
+		client := new(test_data.MockHttpClient)
+		client.On("Do", pathIs(http.MethodPost, fmt.Sprintf("/v1/access/%s/babies", userUuid))).
+			Return(test_data.MakeHttpResponse(404, nil), nil).Twice()
+		client.On("Do", pathIs(http.MethodGet, fmt.Sprintf("/v1/access/%s/baby/%s", userUuid, targetUuids[0]))).
+			Return(test_data.MakeHttpResponse(404, nil), nil).Twice()
+		f := newIntegration(client)
+
+		for i := 0; i < 2; i++ {
+			got, err := f.GetPermissionsForBabies(userUuid, targetUuids[:1])
+			assert.NoError(t, err)
+			assert.Equal(t, map[string]models.Permissions{targetUuids[0]: {}}, got)
+		}
+		client.AssertExpectations(t)
+	})
+
+	t.Run("Test batch error", func(t *testing.T) {
+		client := new(test_data.MockHttpClient)
+		client.On("Do", mock.Anything).Return(test_data.MakeHttpResponse(500, nil), nil)
+		f := newIntegration(client)
+
+		_, err := f.GetPermissionsForBabies(userUuid, targetUuids)
+		assert.Error(t, err)
+	})
+}
+
+func TestFamilyIntegration_ListAccessGrants(t *testing.T) {
+	client := new(test_data.MockHttpClient)
+	client.On("Do", mock.Anything).
+		Return(test_data.MakeHttpResponse(200, &AccessGrantsPageDto{
+			Grants: []AccessGrantDto{{
+				UserUuid:    "74822532-56e4-4ff0-8890-3247a17433cc",
+				BabyUuid:    "11111111-3333-412d-ade7-47be43827d68",
+				Permissions: []models.Permission{models.PermissionRead},
+				Version:     4,
+			}},
+			Next: "11111111-3333-412d-ade7-47be43827d68",
+		}), nil)
+	config := test_data.MockFamilyServerConfig{}
+	config.On("GetFamilyServerUrl").Return("https://family.little-diary.net")
+	authService := test_data.MockAuthService{}
+	authService.On("GetAccessToken", mock.Anything).Return("fake_token", nil)
+	f := &FamilyIntegration{Client: client, Config: &config, AuthService: &authService}
+
+	page, err := f.ListAccessGrants("page-cursor")
+	if assert.NoError(t, err) {
+		assert.Len(t, page.Grants, 1)
+		assert.Equal(t, int64(4), page.Grants[0].Version)
+		assert.Equal(t, "11111111-3333-412d-ade7-47be43827d68", page.Next)
+	}
+	actualReq := client.Calls[0].Arguments.Get(0).(*http.Request)
+	assert.Equal(t, "https://family.little-diary.net/v1/access/grants?after=page-cursor&limit=500", actualReq.URL.String())
+	assert.Equal(t, "Bearer fake_token", actualReq.Header.Get("Authorization"))
+}
+
+func TestFamilyIntegration_ListTargets(t *testing.T) {
+	tests := []struct {
+		name     string
+		response *http.Response
+		want     []*models.AccessibleTarget
+		wantErr  bool
+	}{
+		{
+			name: "listed",
+			response: test_data.MakeHttpResponse(200, &AccessibleBabiesResponseDto{Babies: []AccessibleBabyDto{
+				{BabyUuid: "11111111-3333-412d-ade7-47be43827d68", Permissions: []models.Permission{models.PermissionRead}},
+				{BabyUuid: "22222222-3333-412d-ade7-47be43827d68"},
+			}}),
+			want: []*models.AccessibleTarget{
+				{TargetUuid: "11111111-3333-412d-ade7-47be43827d68", Permissions: models.Permissions{models.PermissionRead}},
+				{TargetUuid: "22222222-3333-412d-ade7-47be43827d68", Permissions: parentPermissions},
+			},
+		},
+		{name: "unknown user", response: test_data.MakeHttpResponse(404, nil), want: []*models.AccessibleTarget{}},
+		{name: "server error", response: test_data.MakeHttpResponse(500, nil), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := new(test_data.MockHttpClient)
+			client.On("Do", mock.Anything).Return(tt.response, nil)
+			config := test_data.MockFamilyServerConfig{}
+			config.On("GetFamilyServerUrl").Return("https://family.little-diary.net")
+			authService := test_data.MockAuthService{}
+			authService.On("GetAccessToken", mock.Anything).Return("fake_token", nil)
+			f := &FamilyIntegration{Client: client, Config: &config, AuthService: &authService}
+
+			got, err := f.ListTargets("74822532-56e4-4ff0-8890-3247a17433cc")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ListTargets() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			assert.Equal(t, tt.want, got)
+			actualReq := client.Calls[0].Arguments.Get(0).(*http.Request)
+			assert.Equal(t, "https://family.little-diary.net/v1/access/74822532-56e4-4ff0-8890-3247a17433cc/babies", actualReq.URL.String())
+			assert.Equal(t, http.MethodGet, actualReq.Method)
+		})
+	}
+}
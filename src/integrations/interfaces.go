@@ -1,15 +1,72 @@
 package integrations
 
-import "net/http"
+import (
+	"little-diary-measurement-service/src/models"
+	"net/http"
+	"time"
+)
 
 type HttpClient interface {
 	Do(request *http.Request) (*http.Response, error)
 }
 
-type UserHasAccessToBabyChecker interface {
-	CheckUserHasAccessToBaby(userUuid string, targetUuid string) (bool, error)
+// Authorizer answers permissions of the user on targets,
+// every requested target is in the batch result
+type Authorizer interface {
+	GetPermissions(userUuid string, targetUuid string) (models.Permissions, error)
+	GetPermissionsForBabies(userUuid string, targetUuids []string) (map[string]models.Permissions, error)
+}
+
+// TargetLister lists targets the user has access to
+type TargetLister interface {
+	ListTargets(userUuid string) ([]*models.AccessibleTarget, error)
+}
+
+// AccessGrantLister pages through all access grants of the family service for the local replica
+type AccessGrantLister interface {
+	ListAccessGrants(cursor string) (*AccessGrantsPageDto, error)
+}
+
+// BabyProfileGetter returns profile of the target, nil when the family service does not know it
+type BabyProfileGetter interface {
+	GetBabyProfile(targetUuid string) (*models.BabyProfile, error)
 }
 
 type AuthServerJwtPublicKeyGetter interface {
 	GetAuthServerJwtPublicKey() string
 }
+
+type Hl7ApiKeyGetter interface {
+	GetHl7ApiKey() string
+}
+
+type FamilyEventsApiKeyGetter interface {
+	GetFamilyEventsApiKey() string
+}
+
+type ErasureApiKeyGetter interface {
+	GetErasureApiKey() string
+}
+
+type PercentileCrossingConfig interface {
+	GetPercentileCrossingLines() int
+	GetPercentileCrossingWindows() []time.Duration
+}
+
+// WebhookPoster posts payload to the url and returns status code of the response
+type WebhookPoster interface {
+	PostWebhook(url string, headers http.Header, body []byte) (int, error)
+}
+
+// StreamConfig limits live measurement streams
+type StreamConfig interface {
+	GetStreamHeartbeat() time.Duration
+	GetStreamMaxDuration() time.Duration
+	GetStreamConnectionsPerUser() int
+}
+
+// ExportConfig signs download links of personal data exports
+type ExportConfig interface {
+	GetExportLinkSecret() string
+	GetExportLinkTTL() time.Duration
+}
@@ -0,0 +1,53 @@
+package integrations
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWebhookIntegration_PostWebhook(t *testing.T) {
+	var received *http.Request
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+		body, _ = ioutil.ReadAll(r.Body)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	integration := WebhookIntegration{Client: server.Client()}
+	headers := http.Header{}
+	headers.Set("X-Webhook-Signature", "sha256=abc")
+	status, err := integration.PostWebhook(server.URL, headers, []byte(`{"event_uuid":"e1"}`))
+	if assert.Nil(t, err) {
+		assert.Equal(t, http.StatusAccepted, status)
+		assert.Equal(t, http.MethodPost, received.Method)
+		assert.Equal(t, "application/json", received.Header.Get("Content-Type"))
+		assert.Equal(t, "sha256=abc", received.Header.Get("X-Webhook-Signature"))
+		assert.Equal(t, `{"event_uuid":"e1"}`, string(body))
+	}
+
+	_, err = (&WebhookIntegration{Client: NewWebhookClient(time.Second)}).PostWebhook(server.URL, http.Header{}, []byte("{}"))
+	assert.Error(t, err, "loopback address is refused")
+}
+
+func TestIsPublicIP(t *testing.T) {
+	for address, public := range map[string]bool{
+		"93.184.216.34": true,
+		"127.0.0.1":     false,
+		"10.1.2.3":      false,
+		"172.20.0.5":    false,
+		"192.168.1.10":  false,
+		"169.254.1.1":   false,
+		"::1":           false,
+		"fd00::1":       false,
+		"0.0.0.0":       false,
+	} {
+		assert.Equal(t, public, isPublicIP(net.ParseIP(address)), address)
+	}
+}
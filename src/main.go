@@ -7,13 +7,31 @@ import (
 	"github.com/swaggo/gin-swagger"
 	"github.com/swaggo/gin-swagger/swaggerFiles"
 	"gopkg.in/gormigrate.v1"
+	"io"
 	"little-diary-measurement-service/src/common"
 	"little-diary-measurement-service/src/config"
+	"little-diary-measurement-service/src/daos"
 	_ "little-diary-measurement-service/src/docs"
+	"little-diary-measurement-service/src/events"
+	"little-diary-measurement-service/src/growth"
+	"little-diary-measurement-service/src/hl7"
 	"little-diary-measurement-service/src/integrations"
 	"little-diary-measurement-service/src/migrations"
+	"little-diary-measurement-service/src/models"
 	"little-diary-measurement-service/src/router"
+	"little-diary-measurement-service/src/services"
+	"log"
 	"net/http"
+	"os"
+	"time"
+)
+
+const (
+	outboxRelayBatchSize     = 100
+	webhookDeliveryBatchSize = 50
+	// the changes listener is started again after failures with the delay doubling up to the maximum
+	changeListenerMinRetry = time.Second
+	changeListenerMaxRetry = time.Minute
 )
 
 // @title Measurement service API
@@ -37,12 +55,28 @@ func main() {
 		Client: &http.Client{},
 		Config: &config.Config,
 	}
+	familyIntegration := integrations.FamilyIntegration{
+		Client:      &http.Client{},
+		Config:      &config.Config,
+		AuthService: &authIntegration,
+	}
 	serviceLocator := common.ServiceLocator{
-		PublicKeyGetter: &config.Config,
-		UserHasAccessToBabyChecker: &integrations.FamilyIntegration{
+		PublicKeyGetter:          &config.Config,
+		Hl7ApiKeyGetter:          &config.Config,
+		PercentileCrossingConfig: &config.Config,
+		FamilyEventsApiKeyGetter: &config.Config,
+		ErasureApiKeyGetter:      &config.Config,
+		Authorizer:               services.NewReplicaAuthorizer(daos.NewAccessGrantDAO(), &familyIntegration),
+		TargetLister:             &familyIntegration,
+		StreamHub:                events.NewHub(),
+		StreamConfig:             &config.Config,
+		ExportConfig:             &config.Config,
+		BabyProfileGetter: &integrations.BabyProfileIntegration{
 			Client:      &http.Client{},
 			Config:      &config.Config,
 			AuthService: &authIntegration,
+			CacheTTL:    config.Config.GetBabyProfileCacheTTL(),
+			CacheSize:   config.Config.BabyProfileCacheSize,
 		},
 	}
 
@@ -57,11 +91,161 @@ func main() {
 
 	config.Config.DB.LogMode(true)
 
+	// the published tables are not bundled, the service runs without them with fewer percentiles
+	references := []struct {
+		path    string
+		load    func(io.Reader) error
+		setting string
+		missing string
+	}{
+		{config.Config.FentonLmsPath, growth.LoadFenton, "fenton_lms_path",
+			"preterm babies have no percentiles before 50 weeks of postmenstrual age"},
+		{config.Config.WhoWeightForLengthPath, growth.LoadWeightForLength, "who_weight_for_length_path",
+			"weight-for-length values have no percentiles"},
+		{config.Config.WhoBMIForAgePath, growth.LoadBMIForAge, "who_bmi_for_age_path",
+			"BMI values have no percentiles"},
+		{config.Config.WhoVelocityPath, growth.LoadVelocity, "who_velocity_path",
+			"growth velocity is not compared with WHO standards"},
+	}
+	for _, reference := range references {
+		if reference.path == "" {
+			log.Printf("WARNING: %s is not set, %s", reference.setting, reference.missing)
+			continue
+		}
+		if err := loadReference(reference.path, reference.load); err != nil {
+			panic(fmt.Errorf("could not load growth reference %s: %v", reference.path, err))
+		}
+	}
+
 	m := gormigrate.New(config.Config.DB, gormigrate.DefaultOptions, migrations.GetMigrations())
 
 	if err := m.Migrate(); err != nil {
 		panic(fmt.Errorf("could not migrate: %v", err))
 	}
 
+	changeListener := daos.ChangeListener{
+		Dsn:       config.Config.DSN,
+		Publisher: serviceLocator.StreamHub,
+		// streams end so that their clients resume from the outbox after what was missed
+		OnReconnect: func() {
+			serviceLocator.StreamHub.Close()
+		},
+	}
+	go func() {
+		// live streams stay quiet while the listener is down, the rest of the service keeps working
+		delay := changeListenerMinRetry
+		for {
+			log.Println(fmt.Errorf("measurement changes listener failed, retrying in %v: %v", delay, changeListener.Listen()))
+			time.Sleep(delay)
+			if delay *= 2; delay > changeListenerMaxRetry {
+				delay = changeListenerMaxRetry
+			}
+		}
+	}()
+
+	if config.Config.Hl7MllpPort != 0 {
+		hl7Service := services.NewHl7IngestionService(daos.NewMeasurementDAO(), daos.NewPatientMappingDAO())
+		mllpServer := hl7.MllpServer{
+			Addr: fmt.Sprintf(":%v", config.Config.Hl7MllpPort),
+			Handler: func(raw string) string {
+				ack, _ := hl7Service.HandleMessage(raw)
+				return ack
+			},
+		}
+		listener, err := mllpServer.Listen()
+		if err != nil {
+			panic(fmt.Errorf("could not listen for HL7 messages: %v", err))
+		}
+		go func() {
+			// the service keeps running without clinic ingestion rather than going down with the listener
+			log.Println(fmt.Errorf("HL7 MLLP listener stopped: %v", mllpServer.Serve(listener)))
+		}()
+	}
+
+	if interval := config.Config.GetAccessGrantSyncInterval(); interval > 0 {
+		accessGrantService := services.NewAccessGrantService(daos.NewAccessGrantDAO(), &familyIntegration)
+		go func() {
+			for {
+				if applied, err := accessGrantService.Sync(); err != nil {
+					log.Println(fmt.Errorf("access grants sync failed: %v", err))
+				} else {
+					log.Printf("access grants sync changed %d grants", applied)
+				}
+				time.Sleep(interval)
+			}
+		}()
+	}
+
+	// every sink is relayed in its own loop, a broker outage does not hold webhooks back and the other way round
+	sinks := []services.OutboxSink{
+		{Name: models.OutboxSinkWebhooks, Publisher: services.NewWebhookPublisher(daos.NewWebhookDAO(), &serviceLocator)},
+	}
+	if broker := eventPublisher(); broker != nil {
+		sinks = append(sinks, services.OutboxSink{Name: models.OutboxSinkBroker, Publisher: broker})
+	}
+	relay := services.NewOutboxRelay(daos.NewOutboxDAO(), sinks, config.Config.EventSubject, outboxRelayBatchSize)
+	for _, sink := range sinks {
+		sink := sink
+		defer sink.Publisher.Close()
+		go func() {
+			for {
+				if _, err := relay.RelayOnce(sink); err != nil {
+					log.Println(fmt.Errorf("outbox relay to %s failed: %v", sink.Name, err))
+				}
+				time.Sleep(config.Config.GetOutboxRelayInterval())
+			}
+		}()
+	}
+
+	webhookDispatcher := services.NewWebhookDispatcher(daos.NewWebhookDAO(), &integrations.WebhookIntegration{
+		Client: integrations.NewWebhookClient(config.Config.GetWebhookTimeout()),
+	})
+	go func() {
+		for {
+			if _, err := webhookDispatcher.DeliverDue(webhookDeliveryBatchSize); err != nil {
+				log.Println(fmt.Errorf("webhook delivery failed: %v", err))
+			}
+			time.Sleep(config.Config.GetWebhookDeliveryInterval())
+		}
+	}()
+
+	exportWorker := services.NewExportWorker(daos.NewExportDAO(), daos.NewMeasurementDAO(), config.Config.GetExportRetention())
+	go func() {
+		for {
+			// waiting exports are built one after another, the worker sleeps once none is left
+			built, err := exportWorker.RunOnce()
+			if err != nil {
+				log.Println(fmt.Errorf("export failed: %v", err))
+			}
+			if !built || err != nil {
+				time.Sleep(config.Config.GetExportInterval())
+			}
+		}
+	}()
+
 	r.Run(fmt.Sprintf(":%v", config.Config.ServerPort))
 }
+
+// eventPublisher returns the configured broker, nil when events go to webhooks only
+func eventPublisher() events.Publisher {
+	switch config.Config.EventPublisher {
+	case "memory":
+		// messages would pile up in the process and the outbox would count them as published
+		panic(fmt.Errorf("event publisher memory is only for tests, use nats or leave it empty"))
+	case "nats":
+		return events.NewNatsPublisher(config.Config.NatsUrl)
+	case "":
+		return nil
+	}
+	panic(fmt.Errorf("event publisher %s does not exist", config.Config.EventPublisher))
+}
+
+// loadReference reads a published growth reference table, see growth.LoadFenton for an example of the layout
+func loadReference(path string, load func(io.Reader) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return load(file)
+}
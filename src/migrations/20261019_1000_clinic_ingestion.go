@@ -0,0 +1,31 @@
+package migrations
+
+import (
+	"github.com/jinzhu/gorm"
+	"gopkg.in/gormigrate.v1"
+	"time"
+)
+
+func Getmigration202610191000ClinicIngestion() *gormigrate.Migration {
+	m := gormigrate.Migration{ID: "20261019_1000_clinic_ingestion",
+		Migrate: func(tx *gorm.DB) error {
+			type MeasurementSource string
+			type TargetUUID string
+
+			type Measurement struct {
+				Source MeasurementSource `gorm:"column:measurement_source;not null;default:'user'"`
+			}
+
+			type PatientMapping struct {
+				ID                 uint       `gorm:"primary_key;column:id"`
+				CreatedAt          time.Time  `gorm:"column:created_at"`
+				UpdatedAt          time.Time  `gorm:"column:updated_at"`
+				AssigningAuthority string     `gorm:"column:assigning_authority;not null;unique_index:idx_patient_mapping"`
+				PatientId          string     `gorm:"column:patient_id;not null;unique_index:idx_patient_mapping"`
+				TargetUuid         TargetUUID `gorm:"column:target_uuid;not null;type:uuid"`
+			}
+
+			return tx.AutoMigrate(&Measurement{}, &PatientMapping{}).Error
+		}}
+	return &m
+}
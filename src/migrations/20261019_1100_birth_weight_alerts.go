@@ -0,0 +1,39 @@
+package migrations
+
+import (
+	"github.com/jinzhu/gorm"
+	"gopkg.in/gormigrate.v1"
+	"time"
+)
+
+func Getmigration202610191100BirthWeightAlerts() *gormigrate.Migration {
+	m := gormigrate.Migration{ID: "20261019_1100_birth_weight_alerts",
+		Migrate: func(tx *gorm.DB) error {
+			type AlertUUID string
+			type AlertKind string
+			type AlertSeverity string
+			type MeasurementUUID string
+			type TargetUUID string
+
+			type Measurement struct {
+				IsBirth bool `gorm:"column:is_birth;not null;default:false"`
+			}
+
+			type Alert struct {
+				ID              uint            `gorm:"primary_key;column:id"`
+				CreatedAt       time.Time       `gorm:"column:created_at"`
+				UpdatedAt       time.Time       `gorm:"column:updated_at"`
+				Uuid            AlertUUID       `gorm:"column:alert_uuid;unique;not null;type:uuid"`
+				TargetUuid      TargetUUID      `gorm:"column:target_uuid;not null;index;type:uuid"`
+				MeasurementUuid MeasurementUUID `gorm:"column:measurement_uuid;not null;type:uuid;unique_index:idx_alert_measurement_kind"`
+				Kind            AlertKind       `gorm:"column:alert_kind;not null;unique_index:idx_alert_measurement_kind"`
+				Severity        AlertSeverity   `gorm:"column:severity;not null"`
+				Reason          string          `gorm:"column:reason;not null"`
+				AcknowledgedAt  *time.Time      `gorm:"column:acknowledged_at"`
+				AcknowledgedBy  string          `gorm:"column:acknowledged_by"`
+			}
+
+			return tx.AutoMigrate(&Measurement{}, &Alert{}).Error
+		}}
+	return &m
+}
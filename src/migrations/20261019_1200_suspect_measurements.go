@@ -0,0 +1,20 @@
+package migrations
+
+import (
+	"github.com/jinzhu/gorm"
+	"gopkg.in/gormigrate.v1"
+)
+
+func Getmigration202610191200SuspectMeasurements() *gormigrate.Migration {
+	m := gormigrate.Migration{ID: "20261019_1200_suspect_measurements",
+		Migrate: func(tx *gorm.DB) error {
+			type Measurement struct {
+				Suspect       bool   `gorm:"column:suspect;not null;default:false"`
+				SuspectReason string `gorm:"column:suspect_reason"`
+				Confirmed     bool   `gorm:"column:confirmed;not null;default:false"`
+			}
+
+			return tx.AutoMigrate(&Measurement{}).Error
+		}}
+	return &m
+}
@@ -0,0 +1,27 @@
+package migrations
+
+import (
+	"github.com/jinzhu/gorm"
+	"gopkg.in/gormigrate.v1"
+	"time"
+)
+
+func Getmigration202610191300AccessGrants() *gormigrate.Migration {
+	m := gormigrate.Migration{ID: "20261019_1300_access_grants",
+		Migrate: func(tx *gorm.DB) error {
+			type TargetUUID string
+
+			type AccessGrant struct {
+				ID         uint       `gorm:"primary_key;column:id"`
+				CreatedAt  time.Time  `gorm:"column:created_at"`
+				UpdatedAt  time.Time  `gorm:"column:updated_at"`
+				UserUuid   string     `gorm:"column:user_uuid;not null;type:uuid;unique_index:idx_access_grant_user_target"`
+				TargetUuid TargetUUID `gorm:"column:target_uuid;not null;type:uuid;unique_index:idx_access_grant_user_target"`
+				Permission string     `gorm:"column:permission;not null"`
+				Version    int64      `gorm:"column:version;not null"`
+			}
+
+			return tx.AutoMigrate(&AccessGrant{}).Error
+		}}
+	return &m
+}
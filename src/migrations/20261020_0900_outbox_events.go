@@ -0,0 +1,29 @@
+package migrations
+
+import (
+	"github.com/jinzhu/gorm"
+	"gopkg.in/gormigrate.v1"
+	"time"
+)
+
+func Getmigration202610200900OutboxEvents() *gormigrate.Migration {
+	m := gormigrate.Migration{ID: "20261020_0900_outbox_events",
+		Migrate: func(tx *gorm.DB) error {
+			type TargetUUID string
+			type MeasurementUUID string
+
+			type OutboxEvent struct {
+				ID              uint            `gorm:"primary_key;column:id"`
+				CreatedAt       time.Time       `gorm:"column:created_at"`
+				EventUuid       string          `gorm:"column:event_uuid;unique;not null;type:uuid"`
+				Type            string          `gorm:"column:event_type;not null"`
+				TargetUuid      TargetUUID      `gorm:"column:target_uuid;not null;type:uuid"`
+				MeasurementUuid MeasurementUUID `gorm:"column:measurement_uuid;not null;type:uuid"`
+				Payload         string          `gorm:"column:payload;not null;type:jsonb"`
+				PublishedAt     *time.Time      `gorm:"column:published_at;index"`
+			}
+
+			return tx.AutoMigrate(&OutboxEvent{}).Error
+		}}
+	return &m
+}
@@ -0,0 +1,49 @@
+package migrations
+
+import (
+	"github.com/jinzhu/gorm"
+	"gopkg.in/gormigrate.v1"
+	"time"
+)
+
+func Getmigration202610201000Webhooks() *gormigrate.Migration {
+	m := gormigrate.Migration{ID: "20261020_1000_webhooks",
+		Migrate: func(tx *gorm.DB) error {
+			type TargetUUID string
+
+			type Webhook struct {
+				ID                  uint       `gorm:"primary_key;column:id"`
+				CreatedAt           time.Time  `gorm:"column:created_at"`
+				UpdatedAt           time.Time  `gorm:"column:updated_at"`
+				Uuid                string     `gorm:"column:webhook_uuid;unique;not null;type:uuid"`
+				TargetUuid          TargetUUID `gorm:"column:target_uuid;not null;index;type:uuid"`
+				CreatedBy           string     `gorm:"column:created_by;not null;type:uuid"`
+				Url                 string     `gorm:"column:url;not null"`
+				Secret              string     `gorm:"column:secret;not null"`
+				EventTypes          string     `gorm:"column:event_types;not null"`
+				Enabled             bool       `gorm:"column:enabled;not null"`
+				ConsecutiveFailures int        `gorm:"column:consecutive_failures;not null"`
+				DisabledAt          *time.Time `gorm:"column:disabled_at"`
+			}
+
+			type WebhookDelivery struct {
+				ID             uint       `gorm:"primary_key;column:id"`
+				CreatedAt      time.Time  `gorm:"column:created_at"`
+				UpdatedAt      time.Time  `gorm:"column:updated_at"`
+				Uuid           string     `gorm:"column:delivery_uuid;unique;not null;type:uuid"`
+				WebhookUuid    string     `gorm:"column:webhook_uuid;not null;type:uuid;unique_index:idx_webhook_delivery_event"`
+				EventUuid      string     `gorm:"column:event_uuid;not null;type:uuid;unique_index:idx_webhook_delivery_event"`
+				EventType      string     `gorm:"column:event_type;not null"`
+				Payload        string     `gorm:"column:payload;not null;type:jsonb"`
+				Status         string     `gorm:"column:status;not null;index"`
+				Attempts       int        `gorm:"column:attempts;not null"`
+				NextAttemptAt  *time.Time `gorm:"column:next_attempt_at;index"`
+				LastAttemptAt  *time.Time `gorm:"column:last_attempt_at"`
+				LastStatusCode int        `gorm:"column:last_status_code;not null"`
+				LastError      string     `gorm:"column:last_error;not null"`
+			}
+
+			return tx.AutoMigrate(&Webhook{}, &WebhookDelivery{}).Error
+		}}
+	return &m
+}
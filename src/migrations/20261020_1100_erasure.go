@@ -0,0 +1,42 @@
+package migrations
+
+import (
+	"github.com/jinzhu/gorm"
+	"gopkg.in/gormigrate.v1"
+	"time"
+)
+
+func Getmigration202610201100Erasure() *gormigrate.Migration {
+	m := gormigrate.Migration{ID: "20261020_1100_erasure",
+		Migrate: func(tx *gorm.DB) error {
+			type TargetUUID string
+
+			type InboxEvent struct {
+				ID        uint      `gorm:"primary_key;column:id"`
+				CreatedAt time.Time `gorm:"column:created_at"`
+				EventUuid string    `gorm:"column:event_uuid;not null;type:uuid;unique_index:idx_inbox_event"`
+				Source    string    `gorm:"column:source;not null;unique_index:idx_inbox_event"`
+				Type      string    `gorm:"column:event_type;not null"`
+			}
+
+			type ErasureReceipt struct {
+				ID                  uint       `gorm:"primary_key;column:id"`
+				CreatedAt           time.Time  `gorm:"column:created_at"`
+				Uuid                string     `gorm:"column:receipt_uuid;unique;not null;type:uuid"`
+				TargetUuid          TargetUUID `gorm:"column:target_uuid;not null;index;type:uuid"`
+				RequestedBy         string     `gorm:"column:requested_by;not null"`
+				EventUuid           string     `gorm:"column:event_uuid;index"`
+				MeasurementsDeleted int64      `gorm:"column:measurements_deleted;not null"`
+				AlertsDeleted       int64      `gorm:"column:alerts_deleted;not null"`
+				WebhooksDeleted     int64      `gorm:"column:webhooks_deleted;not null"`
+				OtherRecordsDeleted int64      `gorm:"column:other_records_deleted;not null"`
+			}
+
+			// TargetErased events are not about a single measurement
+			if err := tx.Exec("ALTER TABLE outbox_events ALTER COLUMN measurement_uuid DROP NOT NULL").Error; err != nil {
+				return err
+			}
+			return tx.AutoMigrate(&InboxEvent{}, &ErasureReceipt{}).Error
+		}}
+	return &m
+}
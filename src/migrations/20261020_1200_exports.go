@@ -0,0 +1,38 @@
+package migrations
+
+import (
+	"github.com/jinzhu/gorm"
+	"gopkg.in/gormigrate.v1"
+	"time"
+)
+
+func Getmigration202610201200Exports() *gormigrate.Migration {
+	m := gormigrate.Migration{ID: "20261020_1200_exports",
+		Migrate: func(tx *gorm.DB) error {
+			type ExportJob struct {
+				ID               uint       `gorm:"primary_key;column:id"`
+				CreatedAt        time.Time  `gorm:"column:created_at"`
+				UpdatedAt        time.Time  `gorm:"column:updated_at"`
+				Uuid             string     `gorm:"column:export_uuid;unique;not null;type:uuid"`
+				UserUuid         string     `gorm:"column:user_uuid;not null;index;type:uuid"`
+				Status           string     `gorm:"column:status;not null;index"`
+				Attempt          int        `gorm:"column:attempt;not null"`
+				LeaseUntil       *time.Time `gorm:"column:lease_until"`
+				MeasurementCount int        `gorm:"column:measurement_count;not null"`
+				Error            string     `gorm:"column:error;not null"`
+				CompletedAt      *time.Time `gorm:"column:completed_at"`
+				ExpiresAt        *time.Time `gorm:"column:expires_at;index"`
+				Archive          []byte     `gorm:"column:archive;type:bytea"`
+			}
+
+			type ExportTarget struct {
+				ID         uint   `gorm:"primary_key;column:id"`
+				ExportUuid string `gorm:"column:export_uuid;not null;type:uuid;unique_index:idx_export_target"`
+				TargetUuid string `gorm:"column:target_uuid;not null;type:uuid;unique_index:idx_export_target;index"`
+				Position   int    `gorm:"column:position;not null"`
+			}
+
+			return tx.AutoMigrate(&ExportJob{}, &ExportTarget{}).Error
+		}}
+	return &m
+}
@@ -0,0 +1,40 @@
+package migrations
+
+import (
+	"github.com/jinzhu/gorm"
+	"gopkg.in/gormigrate.v1"
+)
+
+func Getmigration202610210900OutboxSequences() *gormigrate.Migration {
+	m := gormigrate.Migration{ID: "20261021_0900_outbox_sequences",
+		Migrate: func(tx *gorm.DB) error {
+			type TargetUUID string
+
+			type OutboxSequence struct {
+				TargetUuid   TargetUUID `gorm:"primary_key;column:target_uuid;type:uuid"`
+				LastSequence int64      `gorm:"column:last_sequence;not null"`
+			}
+
+			if err := tx.AutoMigrate(&OutboxSequence{}).Error; err != nil {
+				return err
+			}
+			// stored events are numbered in insertion order, the relay has published them in that order so far
+			statements := []string{
+				"ALTER TABLE outbox_events ADD COLUMN target_sequence bigint",
+				`UPDATE outbox_events e SET target_sequence = numbered.sequence
+				FROM (SELECT id, row_number() OVER (PARTITION BY target_uuid ORDER BY id) AS sequence FROM outbox_events) numbered
+				WHERE e.id = numbered.id`,
+				"ALTER TABLE outbox_events ALTER COLUMN target_sequence SET NOT NULL",
+				"CREATE UNIQUE INDEX idx_outbox_target_sequence ON outbox_events (target_uuid, target_sequence)",
+				`INSERT INTO outbox_sequences (target_uuid, last_sequence)
+				SELECT target_uuid, MAX(target_sequence) FROM outbox_events GROUP BY target_uuid`,
+			}
+			for _, statement := range statements {
+				if err := tx.Exec(statement).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		}}
+	return &m
+}
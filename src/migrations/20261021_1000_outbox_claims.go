@@ -0,0 +1,14 @@
+package migrations
+
+import (
+	"github.com/jinzhu/gorm"
+	"gopkg.in/gormigrate.v1"
+)
+
+func Getmigration202610211000OutboxClaims() *gormigrate.Migration {
+	m := gormigrate.Migration{ID: "20261021_1000_outbox_claims",
+		Migrate: func(tx *gorm.DB) error {
+			return tx.Exec("ALTER TABLE outbox_events ADD COLUMN claimed_until timestamp with time zone").Error
+		}}
+	return &m
+}
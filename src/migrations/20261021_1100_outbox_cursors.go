@@ -0,0 +1,41 @@
+package migrations
+
+import (
+	"github.com/jinzhu/gorm"
+	"gopkg.in/gormigrate.v1"
+	"time"
+)
+
+func Getmigration202610211100OutboxCursors() *gormigrate.Migration {
+	m := gormigrate.Migration{ID: "20261021_1100_outbox_cursors",
+		Migrate: func(tx *gorm.DB) error {
+			type TargetUUID string
+
+			type OutboxCursor struct {
+				Sink              string     `gorm:"primary_key;column:sink"`
+				TargetUuid        TargetUUID `gorm:"primary_key;column:target_uuid;type:uuid"`
+				PublishedSequence int64      `gorm:"column:published_sequence;not null"`
+				ClaimedUntil      *time.Time `gorm:"column:claimed_until"`
+			}
+
+			if err := tx.AutoMigrate(&OutboxCursor{}).Error; err != nil {
+				return err
+			}
+			// published events went to webhooks and the broker together, both sinks continue after them
+			statements := []string{
+				`INSERT INTO outbox_cursors (sink, target_uuid, published_sequence)
+				SELECT sink, target_uuid, MAX(target_sequence) FROM outbox_events, (VALUES ('webhooks'), ('broker')) AS sinks (sink)
+				WHERE published_at IS NOT NULL GROUP BY sink, target_uuid`,
+				"ALTER TABLE outbox_events DROP COLUMN published_at",
+				"ALTER TABLE outbox_events DROP COLUMN claimed_until",
+				"CREATE INDEX idx_outbox_created_at ON outbox_events (created_at)",
+			}
+			for _, statement := range statements {
+				if err := tx.Exec(statement).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		}}
+	return &m
+}
@@ -7,6 +7,17 @@ import (
 func GetMigrations() []*gormigrate.Migration {
 	migrations := []*gormigrate.Migration{
 		Getmigration201903252053InitTables(),
+		Getmigration202610191000ClinicIngestion(),
+		Getmigration202610191100BirthWeightAlerts(),
+		Getmigration202610191200SuspectMeasurements(),
+		Getmigration202610191300AccessGrants(),
+		Getmigration202610200900OutboxEvents(),
+		Getmigration202610201000Webhooks(),
+		Getmigration202610201100Erasure(),
+		Getmigration202610201200Exports(),
+		Getmigration202610210900OutboxSequences(),
+		Getmigration202610211000OutboxClaims(),
+		Getmigration202610211100OutboxCursors(),
 	}
 	return migrations
 }
@@ -1,24 +1,651 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
 
 type MeasurementId uint
 type MeasurementType string
 type MeasurementUUID string
 type TargetUUID string
+type MeasurementSource string
+
+const (
+	MeasurementTypeHeight            MeasurementType = "HEIGHT"
+	MeasurementTypeWeight            MeasurementType = "WEIGHT"
+	MeasurementTypeHeadCircumference MeasurementType = "HEAD_CIRCUMFERENCE"
+	// derived types are computed from a weight and a height measured close in time, they are never stored
+	MeasurementTypeBMI             MeasurementType = "BMI"
+	MeasurementTypeWeightForLength MeasurementType = "WEIGHT_FOR_LENGTH"
+)
+
+var MeasurementTypes = []MeasurementType{
+	MeasurementTypeHeight,
+	MeasurementTypeWeight,
+	MeasurementTypeHeadCircumference,
+}
+
+var DerivedMeasurementTypes = []MeasurementType{
+	MeasurementTypeBMI,
+	MeasurementTypeWeightForLength,
+}
+
+func (t MeasurementType) IsDerived() bool {
+	for _, derived := range DerivedMeasurementTypes {
+		if t == derived {
+			return true
+		}
+	}
+	return false
+}
 
 const (
-	MeasurementTypeHeight MeasurementType = "HEIGHT"
-	MeasurementTypeWeight MeasurementType = "WEIGHT"
+	MeasurementSourceUser   MeasurementSource = "user"
+	MeasurementSourceClinic MeasurementSource = "clinic"
 )
 
+// Measurement is Suspect when its value is implausible compared to the target history,
+// the flag is kept off once the user has Confirmed the value
 type Measurement struct {
-	ID         MeasurementId   `gorm:"primary_key;column:id"`
+	ID            MeasurementId     `gorm:"primary_key;column:id"`
+	CreatedAt     time.Time         `gorm:"column:created_at"`
+	UpdatedAt     time.Time         `gorm:"column:updated_at"`
+	Type          MeasurementType   `gorm:"column:measurement_type;not null"`
+	Timestamp     time.Time         `gorm:"column:measurement_date;not null"`
+	Value         float32           `gorm:"column:measurement_value;not null"`
+	Uuid          MeasurementUUID   `gorm:"column:measurement_uuid;unique;not null;type:uuid"`
+	TargetUuid    TargetUUID        `gorm:"column:target_uuid;not null;index;type:uuid"`
+	Source        MeasurementSource `gorm:"column:measurement_source;not null;default:'user'"`
+	IsBirth       bool              `gorm:"column:is_birth;not null;default:false"`
+	Suspect       bool              `gorm:"column:suspect;not null;default:false"`
+	SuspectReason string            `gorm:"column:suspect_reason"`
+	Confirmed     bool              `gorm:"column:confirmed;not null;default:false"`
+	Alerts        []*Alert          `gorm:"-"`
+	Age           *MeasurementAge   `gorm:"-"`
+	// set for derived types only, the measurements the value is computed from
+	DerivedFrom []MeasurementUUID `gorm:"-"`
+	// reference percentile of derived types, nil when sex is unknown or the reference does not cover the value
+	Percentile *float64 `gorm:"-"`
+}
+
+// MeasurementFilter narrows measurements of a target, empty fields are not applied.
+// Age bounds are resolved into From and To by the birth date of the target
+type MeasurementFilter struct {
+	TargetUuid TargetUUID
+	Types      []MeasurementType
+	From       *time.Time
+	To         *time.Time
+	AgeFrom    *Age
+	AgeTo      *Age
+}
+
+// PatientMapping links a patient identifier issued by a clinic system to a target
+type PatientMapping struct {
+	ID                 uint       `gorm:"primary_key;column:id"`
+	CreatedAt          time.Time  `gorm:"column:created_at"`
+	UpdatedAt          time.Time  `gorm:"column:updated_at"`
+	AssigningAuthority string     `gorm:"column:assigning_authority;not null;unique_index:idx_patient_mapping"`
+	PatientId          string     `gorm:"column:patient_id;not null;unique_index:idx_patient_mapping"`
+	TargetUuid         TargetUUID `gorm:"column:target_uuid;not null;type:uuid"`
+}
+
+type AggregationBucket string
+
+const (
+	AggregationBucketDay   AggregationBucket = "day"
+	AggregationBucketWeek  AggregationBucket = "week"
+	AggregationBucketMonth AggregationBucket = "month"
+)
+
+var AggregationBuckets = []AggregationBucket{
+	AggregationBucketDay,
+	AggregationBucketWeek,
+	AggregationBucketMonth,
+}
+
+// MeasurementAggregate summarizes measurements of one type within a calendar bucket,
+// Last is the value of the latest measurement in the bucket
+type MeasurementAggregate struct {
+	Bucket time.Time `gorm:"column:bucket"`
+	Count  int       `gorm:"column:count"`
+	Min    float32   `gorm:"column:min"`
+	Max    float32   `gorm:"column:max"`
+	Avg    float32   `gorm:"column:avg"`
+	Last   float32   `gorm:"column:last"`
+}
+
+// LatestMeasurement is the newest measurement of a type for a target with the one taken before it
+type LatestMeasurement struct {
+	TargetUuid        TargetUUID      `gorm:"column:target_uuid"`
+	Type              MeasurementType `gorm:"column:measurement_type"`
+	Uuid              MeasurementUUID `gorm:"column:measurement_uuid"`
+	Timestamp         time.Time       `gorm:"column:measurement_date"`
+	Value             float32         `gorm:"column:measurement_value"`
+	PreviousValue     *float32        `gorm:"column:previous_value"`
+	PreviousTimestamp *time.Time      `gorm:"column:previous_date"`
+}
+
+// MeasurementCount is the number of stored measurements of a type for a target
+type MeasurementCount struct {
+	TargetUuid TargetUUID      `gorm:"column:target_uuid"`
+	Type       MeasurementType `gorm:"column:measurement_type"`
+	Count      int             `gorm:"column:count"`
+}
+
+// AccessibleTarget is a target the user can see with what the user may do with it
+type AccessibleTarget struct {
+	TargetUuid  TargetUUID
+	Permissions Permissions
+}
+
+// TargetOverview summarizes measurements of a target for the family dashboard
+type TargetOverview struct {
+	TargetUuid  TargetUUID
+	Permissions Permissions
+	Latest      []*LatestMeasurement
+	Counts      map[MeasurementType]int
+	OpenAlerts  []*Alert
+}
+
+// VelocityInterval is a change of measurement value between two measurements,
+// Rate is the change per velocity unit period
+type VelocityInterval struct {
+	From      *Measurement
+	To        *Measurement
+	Days      float64
+	Gain      float64
+	Rate      float64
+	Reference *VelocityReference
+}
+
+// VelocityReference compares the interval with the WHO increment standard of IntervalMonths,
+// MedianRate is in the same unit as the measured rate
+type VelocityReference struct {
+	IntervalMonths int
+	MedianRate     float64
+	Percentile     float64
+}
+
+// Velocity lists changes between consecutive measurements and the change over the whole window
+type Velocity struct {
+	Type      MeasurementType
+	Unit      string
+	Intervals []*VelocityInterval
+	Window    *VelocityInterval
+}
+
+// Projection extrapolates growth of the target along the reference percentile channel of its recent measurements
+type Projection struct {
+	Type       MeasurementType
+	Percentile float64
+	// measurements the channel is estimated from
+	Basis  []*Measurement
+	Points []*ProjectionPoint
+}
+
+// ProjectionPoint is a projected value with the band the value is likely to fall in
+type ProjectionPoint struct {
+	Timestamp time.Time
+	Value     float32
+	Low       float32
+	High      float32
+	Age       *MeasurementAge
+}
+
+// Comparison aligns measurements of several targets by age, values are resampled at the same ages for all of them
+type Comparison struct {
+	Type   MeasurementType
+	Ages   []Age
+	Series []*ComparisonSeries
+}
+
+// ComparisonSeries holds values of one target at ages of the comparison, nil where the value can not be interpolated
+type ComparisonSeries struct {
+	TargetUuid   TargetUUID
+	BirthDate    time.Time
+	Values       []*float32
+	Measurements []*Measurement
+}
+
+type InterpolationMethod string
+
+const (
+	// the value was measured at the requested time
+	InterpolationMethodExact InterpolationMethod = "exact"
+	// the value lies on a straight line between the bracketing measurements
+	InterpolationMethodLinear InterpolationMethod = "linear"
+	// the child keeps the same pace between reference percentiles of the bracketing measurements
+	InterpolationMethodPercentile InterpolationMethod = "percentile"
+)
+
+// Interpolation is an estimated value at a time between two measurements, Before and After are the same for exact match
+type Interpolation struct {
+	Type      MeasurementType
+	Timestamp time.Time
+	Value     float32
+	Method    InterpolationMethod
+	Before    *Measurement
+	After     *Measurement
+	Age       *MeasurementAge
+}
+
+type AlertUUID string
+type AlertKind string
+type AlertSeverity string
+
+const (
+	AlertKindBirthWeightLoss    AlertKind = "BIRTH_WEIGHT_LOSS"
+	AlertKindPercentileCrossing AlertKind = "PERCENTILE_CROSSING"
+)
+
+const (
+	AlertSeverityWarning  AlertSeverity = "warning"
+	AlertSeverityCritical AlertSeverity = "critical"
+)
+
+// Alert flags a measurement needing attention, there is at most one alert of a kind per measurement
+type Alert struct {
+	ID              uint            `gorm:"primary_key;column:id"`
+	CreatedAt       time.Time       `gorm:"column:created_at"`
+	UpdatedAt       time.Time       `gorm:"column:updated_at"`
+	Uuid            AlertUUID       `gorm:"column:alert_uuid;unique;not null;type:uuid"`
+	TargetUuid      TargetUUID      `gorm:"column:target_uuid;not null;index;type:uuid"`
+	MeasurementUuid MeasurementUUID `gorm:"column:measurement_uuid;not null;type:uuid;unique_index:idx_alert_measurement_kind"`
+	Kind            AlertKind       `gorm:"column:alert_kind;not null;unique_index:idx_alert_measurement_kind"`
+	Severity        AlertSeverity   `gorm:"column:severity;not null"`
+	Reason          string          `gorm:"column:reason;not null"`
+	AcknowledgedAt  *time.Time      `gorm:"column:acknowledged_at"`
+	AcknowledgedBy  string          `gorm:"column:acknowledged_by"`
+}
+
+type Permission string
+
+// Permissions granted to a user on a target, admin allows every action
+type Permissions []Permission
+
+const (
+	PermissionRead   Permission = "read"
+	PermissionWrite  Permission = "write"
+	PermissionDelete Permission = "delete"
+	PermissionAdmin  Permission = "admin"
+)
+
+func (p Permissions) Allows(action Permission) bool {
+	for _, granted := range p {
+		if granted == action || granted == PermissionAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// AccessGrant replicates permissions of a user on a target from the family service,
+// a revoked grant is kept without permissions so older events can not restore it
+type AccessGrant struct {
+	ID         uint       `gorm:"primary_key;column:id"`
+	CreatedAt  time.Time  `gorm:"column:created_at"`
+	UpdatedAt  time.Time  `gorm:"column:updated_at"`
+	UserUuid   string     `gorm:"column:user_uuid;not null;type:uuid;unique_index:idx_access_grant_user_target"`
+	TargetUuid TargetUUID `gorm:"column:target_uuid;not null;type:uuid;unique_index:idx_access_grant_user_target"`
+	// comma separated permissions
+	Permission string `gorm:"column:permission;not null"`
+	Version    int64  `gorm:"column:version;not null"`
+}
+
+func (g *AccessGrant) GetPermissions() Permissions {
+	permissions := Permissions{}
+	for _, p := range strings.Split(g.Permission, ",") {
+		if p != "" {
+			permissions = append(permissions, Permission(p))
+		}
+	}
+	return permissions
+}
+
+func (g *AccessGrant) SetPermissions(permissions Permissions) {
+	names := make([]string, len(permissions))
+	for i, p := range permissions {
+		names[i] = string(p)
+	}
+	g.Permission = strings.Join(names, ",")
+}
+
+// BabyProfile holds facts about the target kept by the family service, zero fields are unknown
+type BabyProfile struct {
+	TargetUuid TargetUUID
+	BirthDate  *time.Time
+	Sex        string
+	// gestational age at birth
+	GestationalAgeDays int
+}
+
+// MeasurementAge is the completed age of the target at the measurement
+type MeasurementAge struct {
+	Days   int
+	Weeks  int
+	Months int
+	// age from the due date for preterm babies, negative before it
+	Corrected *MeasurementAge
+}
+
+// AgeAt counts completed days, weeks and calendar months from birth date, nil before birth
+func AgeAt(birthDate time.Time, at time.Time) *MeasurementAge {
+	at = at.In(birthDate.Location())
+	if at.Before(birthDate) {
+		return nil
+	}
+	days := int(at.Sub(birthDate).Hours() / 24)
+	months := (at.Year()-birthDate.Year())*12 + int(at.Month()) - int(birthDate.Month())
+	if at.Day() < birthDate.Day() {
+		months--
+	}
+	return &MeasurementAge{Days: days, Weeks: days / 7, Months: months}
+}
+
+type AgeUnit string
+
+const (
+	AgeUnitDays   AgeUnit = "days"
+	AgeUnitMonths AgeUnit = "months"
+)
+
+var AgeUnits = []AgeUnit{
+	AgeUnitDays,
+	AgeUnitMonths,
+}
+
+// Age is an age of the child in days or calendar months
+type Age struct {
+	Value int
+	Unit  AgeUnit
+}
+
+// Reached returns the time the child born at birth date reaches the age
+func (a Age) Reached(birthDate time.Time) time.Time {
+	if a.Unit == AgeUnitMonths {
+		return birthDate.AddDate(0, a.Value, 0)
+	}
+	return birthDate.AddDate(0, 0, a.Value)
+}
+
+// Completed returns the last moment the child still has the age completed
+func (a Age) Completed(birthDate time.Time) time.Time {
+	return Age{Value: a.Value + 1, Unit: a.Unit}.Reached(birthDate).Add(-time.Nanosecond)
+}
+
+type OutboxEventType string
+
+const (
+	OutboxEventMeasurementCreated OutboxEventType = "MeasurementCreated"
+	OutboxEventMeasurementUpdated OutboxEventType = "MeasurementUpdated"
+	OutboxEventMeasurementDeleted OutboxEventType = "MeasurementDeleted"
+)
+
+// outbox sinks, each of them publishes the outbox on its own cursor
+const (
+	OutboxSinkWebhooks = "webhooks"
+	OutboxSinkBroker   = "broker"
+)
+
+// OutboxEvent is a domain event stored in the transaction of the change it describes,
+// the relay publishes it to every sink later
+type OutboxEvent struct {
+	ID         uint            `gorm:"primary_key;column:id"`
 	CreatedAt  time.Time       `gorm:"column:created_at"`
-	UpdatedAt  time.Time       `gorm:"column:updated_at"`
-	Type       MeasurementType `gorm:"column:measurement_type;not null"`
-	Timestamp  time.Time       `gorm:"column:measurement_date;not null"`
-	Value      float32         `gorm:"column:measurement_value;not null"`
-	Uuid       MeasurementUUID `gorm:"column:measurement_uuid;unique;not null;type:uuid"`
-	TargetUuid TargetUUID      `gorm:"column:target_uuid;not null;index;type:uuid"`
+	EventUuid  string          `gorm:"column:event_uuid;unique;not null;type:uuid"`
+	Type       OutboxEventType `gorm:"column:event_type;not null"`
+	TargetUuid TargetUUID      `gorm:"column:target_uuid;not null;type:uuid"`
+	// position of the event among events of the target, events of a target commit in this order
+	TargetSequence int64 `gorm:"column:target_sequence;not null"`
+	// empty for events about the whole target
+	MeasurementUuid *MeasurementUUID `gorm:"column:measurement_uuid;type:uuid"`
+	Payload         string           `gorm:"column:payload;not null;type:jsonb"`
+}
+
+// OutboxCursor is the last event of the target a sink has published
+type OutboxCursor struct {
+	Sink              string     `gorm:"primary_key;column:sink"`
+	TargetUuid        TargetUUID `gorm:"primary_key;column:target_uuid;type:uuid"`
+	PublishedSequence int64      `gorm:"column:published_sequence;not null"`
+	// set while a relay publishes events of the target to the sink, other relays leave them alone until then
+	ClaimedUntil *time.Time `gorm:"column:claimed_until"`
+}
+
+// MeasurementEvent is the published body of measurement events, Measurement is the state after the change
+// and the last known state for deletions
+type MeasurementEvent struct {
+	EventUuid   string              `json:"event_uuid"`
+	Type        OutboxEventType     `json:"type"`
+	OccurredAt  time.Time           `json:"occurred_at"`
+	Measurement MeasurementSnapshot `json:"measurement"`
+}
+
+type MeasurementSnapshot struct {
+	Uuid       MeasurementUUID   `json:"uuid"`
+	TargetUuid TargetUUID        `json:"target_uuid"`
+	Type       MeasurementType   `json:"type"`
+	Timestamp  time.Time         `json:"ts"`
+	Value      float32           `json:"value"`
+	Source     MeasurementSource `json:"source"`
+	IsBirth    bool              `json:"is_birth"`
+	Suspect    bool              `json:"suspect"`
+	Confirmed  bool              `json:"confirmed"`
+}
+
+// NewMeasurementEvent builds outbox event of the measurement with the given event uuid
+func NewMeasurementEvent(eventUuid string, eventType OutboxEventType, measurement *Measurement, occurredAt time.Time) (*OutboxEvent, error) {
+	measurementUuid := measurement.Uuid
+	payload, err := json.Marshal(&MeasurementEvent{
+		EventUuid:  eventUuid,
+		Type:       eventType,
+		OccurredAt: occurredAt,
+		Measurement: MeasurementSnapshot{
+			Uuid:       measurement.Uuid,
+			TargetUuid: measurement.TargetUuid,
+			Type:       measurement.Type,
+			Timestamp:  measurement.Timestamp,
+			Value:      measurement.Value,
+			Source:     measurement.Source,
+			IsBirth:    measurement.IsBirth,
+			Suspect:    measurement.Suspect,
+			Confirmed:  measurement.Confirmed,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &OutboxEvent{
+		CreatedAt:       occurredAt,
+		EventUuid:       eventUuid,
+		Type:            eventType,
+		TargetUuid:      measurement.TargetUuid,
+		MeasurementUuid: &measurementUuid,
+		Payload:         string(payload),
+	}, nil
+}
+
+var OutboxEventTypes = []OutboxEventType{OutboxEventMeasurementCreated, OutboxEventMeasurementUpdated, OutboxEventMeasurementDeleted}
+
+type WebhookUUID string
+type WebhookDeliveryUUID string
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryPending   WebhookDeliveryStatus = "pending"
+	WebhookDeliverySucceeded WebhookDeliveryStatus = "succeeded"
+	WebhookDeliveryFailed    WebhookDeliveryStatus = "failed"
+)
+
+// Webhook pushes measurement events of the target to the url, payloads are signed with the secret
+type Webhook struct {
+	ID         uint        `gorm:"primary_key;column:id"`
+	CreatedAt  time.Time   `gorm:"column:created_at"`
+	UpdatedAt  time.Time   `gorm:"column:updated_at"`
+	Uuid       WebhookUUID `gorm:"column:webhook_uuid;unique;not null;type:uuid"`
+	TargetUuid TargetUUID  `gorm:"column:target_uuid;not null;index;type:uuid"`
+	CreatedBy  string      `gorm:"column:created_by;not null;type:uuid"`
+	Url        string      `gorm:"column:url;not null"`
+	Secret     string      `gorm:"column:secret;not null"`
+	// comma separated event types, empty subscribes to every type
+	EventTypes string `gorm:"column:event_types;not null"`
+	Enabled    bool   `gorm:"column:enabled;not null"`
+	// deliveries failed in a row after all attempts, the webhook is disabled when it reaches the limit
+	ConsecutiveFailures int        `gorm:"column:consecutive_failures;not null"`
+	DisabledAt          *time.Time `gorm:"column:disabled_at"`
+}
+
+func (w *Webhook) GetEventTypes() []OutboxEventType {
+	var eventTypes []OutboxEventType
+	for _, t := range strings.Split(w.EventTypes, ",") {
+		if t != "" {
+			eventTypes = append(eventTypes, OutboxEventType(t))
+		}
+	}
+	return eventTypes
+}
+
+func (w *Webhook) SetEventTypes(eventTypes []OutboxEventType) {
+	names := make([]string, len(eventTypes))
+	for i, t := range eventTypes {
+		names[i] = string(t)
+	}
+	w.EventTypes = strings.Join(names, ",")
+}
+
+// Subscribes tells whether events of the type are pushed to the webhook
+func (w *Webhook) Subscribes(eventType OutboxEventType) bool {
+	eventTypes := w.GetEventTypes()
+	for _, t := range eventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return len(eventTypes) == 0
+}
+
+// WebhookDelivery is an event pushed to a webhook with the outcome of its last attempt, an event is delivered once per webhook
+type WebhookDelivery struct {
+	ID             uint                  `gorm:"primary_key;column:id"`
+	CreatedAt      time.Time             `gorm:"column:created_at"`
+	UpdatedAt      time.Time             `gorm:"column:updated_at"`
+	Uuid           WebhookDeliveryUUID   `gorm:"column:delivery_uuid;unique;not null;type:uuid"`
+	WebhookUuid    WebhookUUID           `gorm:"column:webhook_uuid;not null;type:uuid;unique_index:idx_webhook_delivery_event"`
+	EventUuid      string                `gorm:"column:event_uuid;not null;type:uuid;unique_index:idx_webhook_delivery_event"`
+	EventType      OutboxEventType       `gorm:"column:event_type;not null"`
+	Payload        string                `gorm:"column:payload;not null;type:jsonb"`
+	Status         WebhookDeliveryStatus `gorm:"column:status;not null;index"`
+	Attempts       int                   `gorm:"column:attempts;not null"`
+	NextAttemptAt  *time.Time            `gorm:"column:next_attempt_at;index"`
+	LastAttemptAt  *time.Time            `gorm:"column:last_attempt_at"`
+	LastStatusCode int                   `gorm:"column:last_status_code;not null"`
+	LastError      string                `gorm:"column:last_error;not null"`
+}
+
+const OutboxEventTargetErased OutboxEventType = "TargetErased"
+
+// TargetErasedEvent is the published body of TargetErased, consumers erase their copies of the target data
+type TargetErasedEvent struct {
+	EventUuid   string          `json:"event_uuid"`
+	Type        OutboxEventType `json:"type"`
+	OccurredAt  time.Time       `json:"occurred_at"`
+	TargetUuid  TargetUUID      `json:"target_uuid"`
+	ReceiptUuid ErasureUUID     `json:"receipt_uuid"`
+}
+
+// InboxEvent remembers an event consumed from another service, an event is processed once
+type InboxEvent struct {
+	ID        uint      `gorm:"primary_key;column:id"`
+	CreatedAt time.Time `gorm:"column:created_at"`
+	EventUuid string    `gorm:"column:event_uuid;not null;type:uuid;unique_index:idx_inbox_event"`
+	Source    string    `gorm:"column:source;not null;unique_index:idx_inbox_event"`
+	Type      string    `gorm:"column:event_type;not null"`
+}
+
+type ErasureUUID string
+type ErasureRequester string
+
+const (
+	ErasureRequestedByFamilyEvent ErasureRequester = "family_event"
+	ErasureRequestedByApi         ErasureRequester = "api"
+)
+
+// ErasureReceipt proves that data of the target was erased, it keeps only counts of deleted records
+type ErasureReceipt struct {
+	ID          uint             `gorm:"primary_key;column:id"`
+	CreatedAt   time.Time        `gorm:"column:created_at"`
+	Uuid        ErasureUUID      `gorm:"column:receipt_uuid;unique;not null;type:uuid"`
+	TargetUuid  TargetUUID       `gorm:"column:target_uuid;not null;index;type:uuid"`
+	RequestedBy ErasureRequester `gorm:"column:requested_by;not null"`
+	// consumed event the erasure was requested by, empty for api calls
+	EventUuid           string `gorm:"column:event_uuid;index"`
+	MeasurementsDeleted int64  `gorm:"column:measurements_deleted;not null"`
+	AlertsDeleted       int64  `gorm:"column:alerts_deleted;not null"`
+	WebhooksDeleted     int64  `gorm:"column:webhooks_deleted;not null"`
+	// webhook deliveries, outbox events, access grants, patient mappings and export targets
+	OtherRecordsDeleted int64 `gorm:"column:other_records_deleted;not null"`
+}
+
+// NewTargetErasedEvent builds outbox event announcing erasure of the target
+func NewTargetErasedEvent(eventUuid string, receipt *ErasureReceipt, occurredAt time.Time) (*OutboxEvent, error) {
+	payload, err := json.Marshal(&TargetErasedEvent{
+		EventUuid:   eventUuid,
+		Type:        OutboxEventTargetErased,
+		OccurredAt:  occurredAt,
+		TargetUuid:  receipt.TargetUuid,
+		ReceiptUuid: receipt.Uuid,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &OutboxEvent{
+		CreatedAt:  occurredAt,
+		EventUuid:  eventUuid,
+		Type:       OutboxEventTargetErased,
+		TargetUuid: receipt.TargetUuid,
+		Payload:    string(payload),
+	}, nil
+}
+
+type ExportUUID string
+type ExportStatus string
+
+const (
+	ExportPending   ExportStatus = "pending"
+	ExportRunning   ExportStatus = "running"
+	ExportCompleted ExportStatus = "completed"
+	ExportFailed    ExportStatus = "failed"
+)
+
+// ExportJob packages the measurements of every target the user could read when the export was requested
+// into a zip archive, the archive is kept until the export expires. An export whose target is erased
+// is built again without the target
+type ExportJob struct {
+	ID        uint         `gorm:"primary_key;column:id"`
+	CreatedAt time.Time    `gorm:"column:created_at"`
+	UpdatedAt time.Time    `gorm:"column:updated_at"`
+	Uuid      ExportUUID   `gorm:"column:export_uuid;unique;not null;type:uuid"`
+	UserUuid  string       `gorm:"column:user_uuid;not null;index;type:uuid"`
+	Status    ExportStatus `gorm:"column:status;not null;index"`
+	// counts claims by workers, a worker finishes the export only while its claim is the latest
+	Attempt int `gorm:"column:attempt;not null"`
+	// a running export whose lease has passed is taken over by another worker
+	LeaseUntil       *time.Time `gorm:"column:lease_until"`
+	MeasurementCount int        `gorm:"column:measurement_count;not null"`
+	Error            string     `gorm:"column:error;not null"`
+	CompletedAt      *time.Time `gorm:"column:completed_at"`
+	ExpiresAt        *time.Time `gorm:"column:expires_at;index"`
+	// zip archive of completed exports, loaded only for download
+	Archive []byte `gorm:"column:archive;type:bytea"`
+	// targets of the export in listing order, stored as export targets
+	TargetUuids []TargetUUID `gorm:"-"`
+}
+
+// ExportTarget is a target included in the export
+type ExportTarget struct {
+	ID         uint       `gorm:"primary_key;column:id"`
+	ExportUuid ExportUUID `gorm:"column:export_uuid;not null;type:uuid;unique_index:idx_export_target"`
+	TargetUuid TargetUUID `gorm:"column:target_uuid;not null;type:uuid;unique_index:idx_export_target;index"`
+	Position   int        `gorm:"column:position;not null"`
 }
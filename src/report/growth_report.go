@@ -0,0 +1,211 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"little-diary-measurement-service/src/charts"
+	"little-diary-measurement-service/src/models"
+	"math"
+	"time"
+)
+
+const (
+	margin        = 40.0
+	contentWidth  = A4Width - 2*margin
+	lineHeight    = 13.0
+	tableRowSize  = 11.0
+	tableFontSize = 8.0
+	dateLayout    = "02 Jan 2006"
+)
+
+var (
+	textColor   = charts.Color{R: 30, G: 30, B: 30}
+	mutedColor  = charts.Color{R: 110, G: 110, B: 110}
+	headerColor = charts.Color{R: 235, G: 240, B: 247}
+)
+
+// GrowthReport is a one-page overview of target measurements for pediatrician visits
+type GrowthReport struct {
+	TargetUuid   models.TargetUUID
+	From         *time.Time
+	To           *time.Time
+	Types        []models.MeasurementType
+	Measurements []*models.Measurement
+	GeneratedAt  time.Time
+}
+
+type typeSummary struct {
+	measurementType models.MeasurementType
+	measurements    []*models.Measurement
+	min, max        float32
+}
+
+func (r *GrowthReport) Render(w io.Writer) error {
+	document := NewDocument()
+	page := document.AddPage(A4Width, A4Height)
+
+	y := margin + 14
+	page.Text(charts.Point{X: margin, Y: y}, charts.TextStyle{Size: 18, Bold: true, Color: textColor}, "Growth report")
+	y += lineHeight + 4
+	info := charts.TextStyle{Size: 9, Color: mutedColor}
+	page.Text(charts.Point{X: margin, Y: y}, info, fmt.Sprintf("Target: %s", r.TargetUuid))
+	page.Text(charts.Point{X: margin + contentWidth, Y: y}, charts.TextStyle{Size: 9, Color: mutedColor, Align: charts.AlignRight},
+		fmt.Sprintf("Generated: %s", r.GeneratedAt.Format(dateLayout)))
+	y += lineHeight
+	page.Text(charts.Point{X: margin, Y: y}, info, fmt.Sprintf("Period: %s", r.period()))
+	y += lineHeight + 6
+
+	summaries := r.summaries()
+	y = r.drawSummary(page, summaries, y)
+	y = r.drawCharts(page, summaries, y+10)
+	r.drawTable(page, y+10)
+
+	return document.Write(w)
+}
+
+func (r *GrowthReport) period() string {
+	from, to := "first measurement", "today"
+	if r.From != nil {
+		from = r.From.Format(dateLayout)
+	}
+	if r.To != nil {
+		to = r.To.Format(dateLayout)
+	}
+	return fmt.Sprintf("%s - %s", from, to)
+}
+
+func (r *GrowthReport) summaries() []*typeSummary {
+	var result []*typeSummary
+	for _, t := range r.Types {
+		summary := &typeSummary{measurementType: t}
+		for _, m := range r.Measurements {
+			if m.Type != t {
+				continue
+			}
+			if len(summary.measurements) == 0 || m.Value < summary.min {
+				summary.min = m.Value
+			}
+			if len(summary.measurements) == 0 || m.Value > summary.max {
+				summary.max = m.Value
+			}
+			summary.measurements = append(summary.measurements, m)
+		}
+		result = append(result, summary)
+	}
+	return result
+}
+
+func (r *GrowthReport) drawSummary(page *Page, summaries []*typeSummary, y float64) float64 {
+	columns := []string{"Type", "Count", "First", "Latest", "Min", "Max", "Change"}
+	widths := []float64{95, 40, 105, 105, 55, 55, 60}
+	row := func(values []string, bold bool) {
+		x := margin
+		for i, value := range values {
+			page.Text(charts.Point{X: x + 3, Y: y + tableRowSize - 3}, charts.TextStyle{Size: tableFontSize, Bold: bold, Color: textColor}, value)
+			x += widths[i]
+		}
+		y += tableRowSize + 2
+	}
+
+	page.Text(charts.Point{X: margin, Y: y}, charts.TextStyle{Size: 11, Bold: true, Color: textColor}, "Summary")
+	y += 5
+	page.Rect(margin, y, contentWidth, tableRowSize+2, headerColor)
+	row(columns, true)
+	for _, s := range summaries {
+		if len(s.measurements) == 0 {
+			row([]string{TypeTitle(s.measurementType), "0", "-", "-", "-", "-", "-"}, false)
+			continue
+		}
+		first, latest := s.measurements[0], s.measurements[len(s.measurements)-1]
+		row([]string{
+			TypeTitle(s.measurementType),
+			fmt.Sprintf("%d", len(s.measurements)),
+			fmt.Sprintf("%s (%s)", FormatValue(first.Type, first.Value), first.Timestamp.Format("02.01.06")),
+			fmt.Sprintf("%s (%s)", FormatValue(latest.Type, latest.Value), latest.Timestamp.Format("02.01.06")),
+			FormatValue(s.measurementType, s.min),
+			FormatValue(s.measurementType, s.max),
+			formatChange(s.measurementType, latest.Value-first.Value),
+		}, false)
+	}
+	return y
+}
+
+func (r *GrowthReport) drawCharts(page *Page, summaries []*typeSummary, y float64) float64 {
+	var withData []*typeSummary
+	for _, s := range summaries {
+		if len(s.measurements) > 0 {
+			withData = append(withData, s)
+		}
+	}
+	if len(withData) == 0 {
+		return y
+	}
+	columns := int(math.Min(float64(len(withData)), 2))
+	rows := (len(withData) + columns - 1) / columns
+	width := contentWidth / float64(columns)
+	height := 170.0
+	if rows > 1 {
+		height = 150
+	}
+	for i, s := range withData {
+		chart := GrowthChart(s.measurementType, s.measurements)
+		chart.Draw(page, margin+float64(i%columns)*width, y+float64(i/columns)*height, width-4, height-6)
+	}
+	return y + float64(rows)*height
+}
+
+// drawTable writes measurements into three side by side columns, oldest rows are skipped when page is full
+func (r *GrowthReport) drawTable(page *Page, y float64) {
+	page.Text(charts.Point{X: margin, Y: y}, charts.TextStyle{Size: 11, Bold: true, Color: textColor}, "Measurements")
+	y += 5
+	const groups = 3
+	groupWidth := contentWidth / groups
+	rowsPerGroup := int((A4Height - margin - y - tableRowSize - 14) / tableRowSize)
+	if rowsPerGroup <= 0 {
+		return
+	}
+	capacity := rowsPerGroup * groups
+	measurements := r.Measurements
+	if len(measurements) > capacity {
+		// the notice is written next to the title, so all rows are left for measurements
+		omitted := len(measurements) - capacity
+		measurements = measurements[omitted:]
+		page.Text(charts.Point{X: margin + contentWidth, Y: y - 5}, charts.TextStyle{Size: tableFontSize, Align: charts.AlignRight, Color: mutedColor},
+			fmt.Sprintf("%d earlier measurements are not shown", omitted))
+	}
+
+	style := charts.TextStyle{Size: tableFontSize, Color: textColor}
+	header := style
+	header.Bold = true
+	for g := 0; g < groups; g++ {
+		x := margin + float64(g)*groupWidth
+		page.Rect(x, y, groupWidth-6, tableRowSize+2, headerColor)
+		page.Text(charts.Point{X: x + 3, Y: y + tableRowSize - 2}, header, "Date")
+		page.Text(charts.Point{X: x + 62, Y: y + tableRowSize - 2}, header, "Type")
+		page.Text(charts.Point{X: x + groupWidth - 10, Y: y + tableRowSize - 2}, charts.TextStyle{Size: tableFontSize, Bold: true, Align: charts.AlignRight, Color: textColor}, "Value")
+	}
+	y += tableRowSize + 2
+	for i, m := range measurements {
+		x := margin + float64(i/rowsPerGroup)*groupWidth
+		rowY := y + float64(i%rowsPerGroup)*tableRowSize + tableRowSize - 3
+		page.Text(charts.Point{X: x + 3, Y: rowY}, style, m.Timestamp.Format(dateLayout))
+		page.Text(charts.Point{X: x + 62, Y: rowY}, style, TypeTitle(m.Type))
+		page.Text(charts.Point{X: x + groupWidth - 10, Y: rowY}, charts.TextStyle{Size: tableFontSize, Align: charts.AlignRight, Color: textColor}, FormatValue(m.Type, m.Value))
+	}
+}
+
+// GrowthChart makes a chart of measurement values by date
+func GrowthChart(t models.MeasurementType, measurements []*models.Measurement) *charts.Chart {
+	unit, factor := DisplayUnit(t)
+	var points []charts.Point
+	for _, m := range measurements {
+		points = append(points, charts.Point{X: charts.DaysSinceEpoch(m.Timestamp), Y: float64(m.Value * factor)})
+	}
+	return &charts.Chart{
+		Title:  TypeTitle(t),
+		XAxis:  charts.Axis{Label: "Date", Ticks: charts.DayTicks, Format: charts.FormatDay},
+		YAxis:  charts.Axis{Label: unit},
+		Series: []charts.Series{{Name: TypeTitle(t), Points: points}},
+		Theme:  charts.LightTheme,
+	}
+}
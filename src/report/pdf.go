@@ -0,0 +1,209 @@
+package report
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"little-diary-measurement-service/src/charts"
+	"strings"
+)
+
+const (
+	A4Width  = 595.28
+	A4Height = 841.89
+)
+
+// Helvetica glyph widths for characters from 32 to 126 in 1/1000 of font size
+var helveticaWidths = [95]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+}
+
+// Document is a minimal PDF writer with standard Helvetica fonts, enough for text, lines and rectangles
+type Document struct {
+	pages []*Page
+}
+
+// Page implements charts.Canvas, coordinates start at the top left corner
+type Page struct {
+	Width   float64
+	Height  float64
+	content bytes.Buffer
+	// graphics states by fill opacity, used for translucent polygons
+	opacities map[string]string
+}
+
+func NewDocument() *Document {
+	return &Document{}
+}
+
+func (d *Document) AddPage(width, height float64) *Page {
+	p := &Page{Width: width, Height: height}
+	d.pages = append(d.pages, p)
+	return p
+}
+
+func (d *Document) Write(w io.Writer) error {
+	var out bytes.Buffer
+	var offsets []int
+	addObject := func(body string) int {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+		return len(offsets)
+	}
+
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	// object numbers of the catalog and page tree are fixed, pages refer to the tree as object 2
+	addObject("<< /Type /Catalog /Pages 2 0 R >>")
+	pagesIndex := len(offsets)
+	offsets = append(offsets, 0)
+	regular := addObject("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	bold := addObject("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+
+	var kids []string
+	for _, page := range d.pages {
+		var compressed bytes.Buffer
+		zw := zlib.NewWriter(&compressed)
+		if _, err := zw.Write(page.content.Bytes()); err != nil {
+			return err
+		}
+		if err := zw.Close(); err != nil {
+			return err
+		}
+		contents := addObject(fmt.Sprintf("<< /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream",
+			compressed.Len(), compressed.String()))
+		var states []string
+		for opacity, name := range page.opacities {
+			states = append(states, fmt.Sprintf("/%s << /ca %s >>", name, opacity))
+		}
+		pageObject := addObject(fmt.Sprintf(
+			"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %s %s] /Contents %d 0 R /Resources << /Font << /F1 %d 0 R /F2 %d 0 R >> /ExtGState << %s >> >> >>",
+			number(page.Width), number(page.Height), contents, regular, bold, strings.Join(states, " ")))
+		kids = append(kids, fmt.Sprintf("%d 0 R", pageObject))
+	}
+
+	offsets[pagesIndex] = out.Len()
+	fmt.Fprintf(&out, "2 0 obj\n<< /Type /Pages /Kids [%s] /Count %d >>\nendobj\n", strings.Join(kids, " "), len(kids))
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	_, err := w.Write(out.Bytes())
+	return err
+}
+
+func (p *Page) Line(from charts.Point, to charts.Point, width float64, color charts.Color) {
+	p.Polyline([]charts.Point{from, to}, width, color)
+}
+
+func (p *Page) Polyline(points []charts.Point, width float64, color charts.Color) {
+	if len(points) < 2 {
+		return
+	}
+	fmt.Fprintf(&p.content, "%s RG %s w 1 J 1 j\n", rgb(color), number(width))
+	for i, point := range points {
+		op := "l"
+		if i == 0 {
+			op = "m"
+		}
+		fmt.Fprintf(&p.content, "%s %s %s\n", number(point.X), number(p.Height-point.Y), op)
+	}
+	p.content.WriteString("S\n")
+}
+
+func (p *Page) Rect(x, y, width, height float64, fill charts.Color) {
+	fmt.Fprintf(&p.content, "%s rg %s %s %s %s re f\n",
+		rgb(fill), number(x), number(p.Height-y-height), number(width), number(height))
+}
+
+func (p *Page) Polygon(points []charts.Point, fill charts.Color, opacity float64) {
+	if len(points) < 3 {
+		return
+	}
+	key := number(opacity)
+	if p.opacities == nil {
+		p.opacities = map[string]string{}
+	}
+	state, ok := p.opacities[key]
+	if !ok {
+		state = fmt.Sprintf("GS%d", len(p.opacities)+1)
+		p.opacities[key] = state
+	}
+	fmt.Fprintf(&p.content, "q /%s gs %s rg\n", state, rgb(fill))
+	for i, point := range points {
+		op := "l"
+		if i == 0 {
+			op = "m"
+		}
+		fmt.Fprintf(&p.content, "%s %s %s\n", number(point.X), number(p.Height-point.Y), op)
+	}
+	p.content.WriteString("h f Q\n")
+}
+
+func (p *Page) Text(at charts.Point, style charts.TextStyle, text string) {
+	if text == "" {
+		return
+	}
+	font := "F1"
+	if style.Bold {
+		font = "F2"
+	}
+	x := at.X
+	switch style.Align {
+	case charts.AlignCenter:
+		x -= TextWidth(text, style.Size) / 2
+	case charts.AlignRight:
+		x -= TextWidth(text, style.Size)
+	}
+	fmt.Fprintf(&p.content, "BT %s rg /%s %s Tf %s %s Td (%s) Tj ET\n",
+		rgb(style.Color), font, number(style.Size), number(x), number(p.Height-at.Y), escapeText(text))
+}
+
+// TextWidth estimates width of text written with Helvetica
+func TextWidth(text string, size float64) float64 {
+	width := 0
+	for _, r := range text {
+		if r >= 32 && r <= 126 {
+			width += helveticaWidths[r-32]
+		} else {
+			width += 556
+		}
+	}
+	return float64(width) * size / 1000
+}
+
+func escapeText(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r >= 32 && r <= 126:
+			b.WriteRune(r)
+		case r >= 160 && r <= 255:
+			// latin-1 supplement matches WinAnsiEncoding
+			fmt.Fprintf(&b, "\\%03o", r)
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
+
+func rgb(c charts.Color) string {
+	return fmt.Sprintf("%s %s %s", number(float64(c.R)/255), number(float64(c.G)/255), number(float64(c.B)/255))
+}
+
+func number(v float64) string {
+	return strings.TrimRight(strings.TrimRight(fmt.Sprintf("%.3f", v), "0"), ".")
+}
@@ -0,0 +1,100 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"little-diary-measurement-service/src/charts"
+	"little-diary-measurement-service/src/models"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDocumentWrite(t *testing.T) {
+	document := NewDocument()
+	page := document.AddPage(A4Width, A4Height)
+	page.Text(charts.Point{X: 10, Y: 10}, charts.TextStyle{Size: 10}, "Hello (world)")
+	page.Rect(10, 10, 100, 50, charts.Color{R: 255})
+
+	var out bytes.Buffer
+	assert.Nil(t, document.Write(&out))
+	pdf := out.String()
+
+	assert.True(t, strings.HasPrefix(pdf, "%PDF-1.4"))
+	assert.True(t, strings.HasSuffix(pdf, "%%EOF\n"))
+	assert.Contains(t, pdf, "/Type /Pages /Kids [6 0 R] /Count 1")
+
+	// every xref entry must point to the beginning of its object
+	startXref := regexp.MustCompile(`startxref\n(\d+)`).FindStringSubmatch(pdf)
+	xrefOffset, _ := strconv.Atoi(startXref[1])
+	assert.True(t, strings.HasPrefix(pdf[xrefOffset:], "xref\n"))
+	entries := regexp.MustCompile(`(\d{10}) 00000 n`).FindAllStringSubmatch(pdf, -1)
+	assert.Len(t, entries, 6)
+	for i, entry := range entries {
+		offset, _ := strconv.Atoi(entry[1])
+		assert.True(t, strings.HasPrefix(pdf[offset:], fmt.Sprintf("%d 0 obj", i+1)), "object %d offset is wrong", i+1)
+	}
+}
+
+func TestEscapeText(t *testing.T) {
+	assert.Equal(t, `a\(b\)\\c`, escapeText(`a(b)\c`))
+	assert.Equal(t, `caf\351 ?`, escapeText("café ☃"))
+}
+
+func TestTextWidth(t *testing.T) {
+	assert.InDelta(t, 5.56, TextWidth("0", 10), 0.001)
+	assert.InDelta(t, 2.78+5.56, TextWidth(" a", 10), 0.001)
+}
+
+func TestFormatValue(t *testing.T) {
+	assert.Equal(t, "4.52 kg", FormatValue(models.MeasurementTypeWeight, 4520))
+	assert.Equal(t, "54.5 cm", FormatValue(models.MeasurementTypeHeight, 54.5))
+	assert.Equal(t, "+0.15 kg", formatChange(models.MeasurementTypeWeight, 150))
+}
+
+func TestGrowthReportRender(t *testing.T) {
+	start := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	var measurements []*models.Measurement
+	for i := 0; i < 300; i++ {
+		measurements = append(measurements,
+			&models.Measurement{Type: models.MeasurementTypeWeight, Timestamp: start.AddDate(0, 0, i), Value: 3500 + float32(i)*30})
+		if i%30 == 0 {
+			measurements = append(measurements,
+				&models.Measurement{Type: models.MeasurementTypeHeight, Timestamp: start.AddDate(0, 0, i), Value: 50 + float32(i)/10})
+		}
+	}
+	growthReport := GrowthReport{
+		TargetUuid:   "11111111-3333-412d-ade7-47be43827d68",
+		Types:        models.MeasurementTypes,
+		Measurements: measurements,
+		GeneratedAt:  start,
+	}
+	var out bytes.Buffer
+	assert.Nil(t, growthReport.Render(&out))
+	assert.True(t, strings.HasPrefix(out.String(), "%PDF-1.4"))
+	assert.Contains(t, out.String(), "/Count 1")
+}
+
+func TestGrowthReportDrawTable(t *testing.T) {
+	y := 600.0
+	capacity := int((A4Height-margin-y-5-tableRowSize-14)/tableRowSize) * 3
+	start := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	var measurements []*models.Measurement
+	for i := 0; i <= capacity; i++ {
+		measurements = append(measurements,
+			&models.Measurement{Type: models.MeasurementTypeWeight, Timestamp: start.AddDate(0, 0, i), Value: 3500 + float32(i)*30})
+	}
+	growthReport := GrowthReport{Measurements: measurements}
+	page := NewDocument().AddPage(A4Width, A4Height)
+	growthReport.drawTable(page, y)
+
+	content := page.content.String()
+	assert.Contains(t, content, "(1 earlier measurements are not shown)")
+	assert.NotContains(t, content, "("+start.Format(dateLayout)+")")
+	// every slot holds a measurement, the latest one fills the last row
+	assert.Len(t, regexp.MustCompile(`\(\d\d \w{3} \d{4}\) Tj`).FindAllString(content, -1), capacity)
+	assert.Contains(t, content, "("+measurements[capacity].Timestamp.Format(dateLayout)+")")
+}
@@ -0,0 +1,48 @@
+package report
+
+import (
+	"fmt"
+	"little-diary-measurement-service/src/models"
+	"strconv"
+)
+
+// DisplayUnit returns unit used in documents and factor converting stored value to it,
+// weight is stored in grams, lengths in centimeters
+func DisplayUnit(t models.MeasurementType) (string, float32) {
+	if t == models.MeasurementTypeWeight {
+		return "kg", 0.001
+	}
+	return "cm", 1
+}
+
+func FormatValue(t models.MeasurementType, value float32) string {
+	unit, factor := DisplayUnit(t)
+	return fmt.Sprintf("%s %s", strconv.FormatFloat(float64(value*factor), 'f', precision(t), 32), unit)
+}
+
+func formatChange(t models.MeasurementType, change float32) string {
+	sign := ""
+	if change > 0 {
+		sign = "+"
+	}
+	return sign + FormatValue(t, change)
+}
+
+func precision(t models.MeasurementType) int {
+	if t == models.MeasurementTypeWeight {
+		return 2
+	}
+	return 1
+}
+
+func TypeTitle(t models.MeasurementType) string {
+	switch t {
+	case models.MeasurementTypeHeight:
+		return "Height"
+	case models.MeasurementTypeWeight:
+		return "Weight"
+	case models.MeasurementTypeHeadCircumference:
+		return "Head circumference"
+	}
+	return string(t)
+}
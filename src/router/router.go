@@ -1,6 +1,7 @@
 package router
 
 import (
+	"crypto/subtle"
 	"github.com/gin-gonic/gin"
 	"little-diary-measurement-service/src/apis"
 	"little-diary-measurement-service/src/common"
@@ -26,8 +27,73 @@ func GetMainEngine(locator *common.ServiceLocator) *gin.Engine {
 		//v1.Use(auth())
 		v1.GET("/measurement/:uuid", wrapHandler(apis.GetMeasurement, locator))
 		v1.PUT("/measurement/:uuid", wrapHandler(apis.SaveMeasurement, locator))
+		v1.POST("/measurement/:uuid/confirm", wrapHandler(apis.ConfirmMeasurement, locator))
 
 		v1.GET("/measurements", wrapHandler(apis.GetMeasurementsByTarget, locator))
+		v1.GET("/measurements/latest", wrapHandler(apis.GetLatestMeasurements, locator))
+		v1.GET("/measurements/aggregate", wrapHandler(apis.AggregateMeasurements, locator))
+		v1.GET("/measurements/velocity", wrapHandler(apis.GetVelocity, locator))
+		v1.GET("/measurements/interpolate", wrapHandler(apis.InterpolateMeasurement, locator))
+		v1.GET("/measurements/projection", wrapHandler(apis.GetProjection, locator))
+		v1.GET("/measurements/compare", wrapHandler(apis.CompareMeasurements, locator))
+		v1.GET("/measurements/stream", wrapHandler(apis.StreamMeasurements, locator))
+
+		v1.GET("/me/measurements/overview", wrapHandler(apis.GetMeasurementsOverview, locator))
+		v1.POST("/me/exports", wrapHandler(apis.RequestExport, locator))
+		v1.GET("/me/exports/:uuid", wrapHandler(apis.GetExport, locator))
+
+		v1.GET("/targets/:uuid/report.pdf", wrapHandler(apis.GetGrowthReport, locator))
+		v1.GET("/targets/:uuid/chart.svg", wrapHandler(apis.GetGrowthChart, locator))
+		v1.GET("/targets/:uuid/alerts", wrapHandler(apis.GetAlertsByTarget, locator))
+
+		v1.POST("/alert/:uuid/acknowledge", wrapHandler(apis.AcknowledgeAlert, locator))
+
+		v1.POST("/webhooks", wrapHandler(apis.CreateWebhook, locator))
+		v1.GET("/webhooks", wrapHandler(apis.GetWebhooksByTarget, locator))
+		v1.GET("/webhooks/:uuid", wrapHandler(apis.GetWebhook, locator))
+		v1.PUT("/webhooks/:uuid", wrapHandler(apis.UpdateWebhook, locator))
+		v1.DELETE("/webhooks/:uuid", wrapHandler(apis.DeleteWebhook, locator))
+		v1.GET("/webhooks/:uuid/deliveries", wrapHandler(apis.GetWebhookDeliveries, locator))
+		v1.POST("/webhooks/:uuid/deliveries/:delivery/redeliver", wrapHandler(apis.RedeliverWebhook, locator))
+	}
+
+	hl7 := r.Group("/hl7/v2")
+	hl7.Use(apiKeyMiddleware(func() string {
+		if locator.Hl7ApiKeyGetter == nil {
+			return ""
+		}
+		return locator.Hl7ApiKeyGetter.GetHl7ApiKey()
+	}))
+	{
+		hl7.POST("/messages", wrapHandler(apis.IngestHl7Message, locator))
+	}
+
+	family := r.Group("/family/v1")
+	family.Use(apiKeyMiddleware(func() string {
+		if locator.FamilyEventsApiKeyGetter == nil {
+			return ""
+		}
+		return locator.FamilyEventsApiKeyGetter.GetFamilyEventsApiKey()
+	}))
+	{
+		family.POST("/events", wrapHandler(apis.ConsumeFamilyEvent, locator))
+	}
+
+	erasure := r.Group("/api/v1")
+	erasure.Use(apiKeyMiddleware(func() string {
+		if locator.ErasureApiKeyGetter == nil {
+			return ""
+		}
+		return locator.ErasureApiKeyGetter.GetErasureApiKey()
+	}))
+	{
+		erasure.DELETE("/targets/:uuid/measurements", wrapHandler(apis.EraseTargetMeasurements, locator))
+	}
+
+	// download links are signed, browsers follow them without a token
+	exports := r.Group("/api/v1/exports")
+	{
+		exports.GET("/:uuid/download", wrapHandler(apis.DownloadExport, locator))
 	}
 
 	status := r.Group("/status")
@@ -53,3 +119,16 @@ func jwtMiddleware(locator *common.ServiceLocator) gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// apiKeyMiddleware lets through service callers presenting the key, an empty key closes the group
+func apiKeyMiddleware(keyGetter func() string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		expected := keyGetter()
+		provided := c.Request.Header.Get("X-Api-Key")
+		if expected == "" || subtle.ConstantTimeCompare([]byte(provided), []byte(expected)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Api key is invalid"})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,33 @@
+package services
+
+import (
+	"little-diary-measurement-service/src/common"
+	"little-diary-measurement-service/src/errors"
+	"little-diary-measurement-service/src/models"
+)
+
+// authorize fails when the user is not permitted the action on the target
+func authorize(locator *common.ServiceLocator, userUuid string, targetUuid string, action models.Permission) error {
+	permissions, err := locator.Authorizer.GetPermissions(userUuid, targetUuid)
+	if err != nil {
+		return err
+	}
+	if !permissions.Allows(action) {
+		return &errors.ForbiddenError{S: "operation not allowed"}
+	}
+	return nil
+}
+
+// authorizeAll fails when the user is not permitted the action on at least one of the targets
+func authorizeAll(locator *common.ServiceLocator, userUuid string, targetUuids []string, action models.Permission) error {
+	permissions, err := locator.Authorizer.GetPermissionsForBabies(userUuid, targetUuids)
+	if err != nil {
+		return err
+	}
+	for _, targetUuid := range targetUuids {
+		if !permissions[targetUuid].Allows(action) {
+			return &errors.ForbiddenError{S: "operation not allowed"}
+		}
+	}
+	return nil
+}
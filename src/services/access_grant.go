@@ -0,0 +1,169 @@
+package services
+
+import (
+	"fmt"
+	"github.com/jinzhu/gorm"
+	"little-diary-measurement-service/src/dto"
+	"little-diary-measurement-service/src/errors"
+	"little-diary-measurement-service/src/integrations"
+	"little-diary-measurement-service/src/models"
+	"log"
+	"time"
+)
+
+type accessGrantDAO interface {
+	GetGrant(userUuid string, targetUuid models.TargetUUID) (*models.AccessGrant, error)
+	GetGrants(userUuid string, targetUuids []models.TargetUUID) ([]*models.AccessGrant, error)
+	ApplyGrant(grant *models.AccessGrant) (bool, error)
+	GetGrantedBefore(before time.Time) ([]*models.AccessGrant, error)
+	RevokeGrant(grant *models.AccessGrant, unchangedSince time.Time) (bool, error)
+}
+
+type accessGrantKey struct {
+	userUuid   string
+	targetUuid models.TargetUUID
+}
+
+// AccessGrantService keeps the local replica of family access grants
+type AccessGrantService struct {
+	dao    accessGrantDAO
+	lister integrations.AccessGrantLister
+}
+
+func NewAccessGrantService(dao accessGrantDAO, lister integrations.AccessGrantLister) *AccessGrantService {
+	return &AccessGrantService{dao, lister}
+}
+
+// ApplyEvent stores membership change of the event, events older than the stored grant are ignored
+func (s *AccessGrantService) ApplyEvent(event dto.FamilyEvent) error {
+	if event.UserUuid == "" || event.BabyUuid == "" {
+		return &errors.ValidationError{S: "membership event without user or baby"}
+	}
+	grant := &models.AccessGrant{
+		UserUuid:   event.UserUuid,
+		TargetUuid: models.TargetUUID(event.BabyUuid),
+		Version:    event.Version,
+	}
+	switch event.Type {
+	case dto.FamilyEventMembershipGranted, dto.FamilyEventMembershipChanged:
+		permissions := models.Permissions{}
+		for _, p := range event.Permissions {
+			permissions = append(permissions, models.Permission(p))
+		}
+		grant.SetPermissions(permissions)
+	case dto.FamilyEventMembershipRevoked:
+		grant.SetPermissions(models.Permissions{})
+	default:
+		return &errors.ValidationError{S: fmt.Sprintf("membership event type %s does not exist", event.Type)}
+	}
+	_, err := s.dao.ApplyGrant(grant)
+	return err
+}
+
+// Sync copies every grant of the family service into the replica and returns how many stored grants it changed.
+// Revocations are not listed, so once the whole listing is read, grants it missed lose their permissions
+// unless they were changed by an event during the sync
+func (s *AccessGrantService) Sync() (int, error) {
+	startedAt := time.Now()
+	listed := map[accessGrantKey]bool{}
+	applied := 0
+	cursor := ""
+	for {
+		page, err := s.lister.ListAccessGrants(cursor)
+		if err != nil {
+			return applied, err
+		}
+		for _, g := range page.Grants {
+			grant := &models.AccessGrant{
+				UserUuid:   g.UserUuid,
+				TargetUuid: models.TargetUUID(g.BabyUuid),
+				Version:    g.Version,
+			}
+			grant.SetPermissions(g.Permissions)
+			listed[accessGrantKey{grant.UserUuid, grant.TargetUuid}] = true
+			stored, err := s.dao.ApplyGrant(grant)
+			if err != nil {
+				return applied, err
+			}
+			if stored {
+				applied++
+			}
+		}
+		if page.Next == "" || page.Next == cursor {
+			break
+		}
+		cursor = page.Next
+	}
+	granted, err := s.dao.GetGrantedBefore(startedAt)
+	if err != nil {
+		return applied, err
+	}
+	for _, grant := range granted {
+		if listed[accessGrantKey{grant.UserUuid, grant.TargetUuid}] {
+			continue
+		}
+		revoked, err := s.dao.RevokeGrant(grant, startedAt)
+		if err != nil {
+			return applied, err
+		}
+		if revoked {
+			applied++
+		}
+	}
+	return applied, nil
+}
+
+// ReplicaAuthorizer answers from the local replica of access grants,
+// targets missing in the replica are asked at the fallback authorizer
+type ReplicaAuthorizer struct {
+	dao      accessGrantDAO
+	fallback integrations.Authorizer
+}
+
+func NewReplicaAuthorizer(dao accessGrantDAO, fallback integrations.Authorizer) *ReplicaAuthorizer {
+	return &ReplicaAuthorizer{dao, fallback}
+}
+
+func (a *ReplicaAuthorizer) GetPermissions(userUuid string, targetUuid string) (models.Permissions, error) {
+	grant, err := a.dao.GetGrant(userUuid, models.TargetUUID(targetUuid))
+	if err == nil {
+		return grant.GetPermissions(), nil
+	}
+	if !gorm.IsRecordNotFoundError(err) {
+		log.Println(err)
+	}
+	return a.fallback.GetPermissions(userUuid, targetUuid)
+}
+
+func (a *ReplicaAuthorizer) GetPermissionsForBabies(userUuid string, targetUuids []string) (map[string]models.Permissions, error) {
+	var targets []models.TargetUUID
+	for _, targetUuid := range targetUuids {
+		targets = append(targets, models.TargetUUID(targetUuid))
+	}
+	grants, err := a.dao.GetGrants(userUuid, targets)
+	if err != nil {
+		log.Println(err)
+		grants = nil
+	}
+	permissions := make(map[string]models.Permissions, len(targetUuids))
+	for _, grant := range grants {
+		permissions[string(grant.TargetUuid)] = grant.GetPermissions()
+	}
+	var missing []string
+	for _, targetUuid := range targetUuids {
+		if _, ok := permissions[targetUuid]; !ok {
+			missing = append(missing, targetUuid)
+		}
+	}
+	if len(missing) == 0 {
+		return permissions, nil
+	}
+	fetched, err := a.fallback.GetPermissionsForBabies(userUuid, missing)
+	if err != nil {
+		return nil, err
+	}
+	for targetUuid, granted := range fetched {
+		permissions[targetUuid] = granted
+	}
+	return permissions, nil
+}
@@ -0,0 +1,168 @@
+package services
+
+import (
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"little-diary-measurement-service/src/dto"
+	"little-diary-measurement-service/src/integrations"
+	"little-diary-measurement-service/src/models"
+	"little-diary-measurement-service/src/test_data"
+	"testing"
+	"time"
+)
+
+type mockAccessGrantDAO struct {
+	grants []*models.AccessGrant
+}
+
+func (m *mockAccessGrantDAO) GetGrant(userUuid string, targetUuid models.TargetUUID) (*models.AccessGrant, error) {
+	for _, grant := range m.grants {
+		if grant.UserUuid == userUuid && grant.TargetUuid == targetUuid {
+			return grant, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (m *mockAccessGrantDAO) GetGrants(userUuid string, targetUuids []models.TargetUUID) ([]*models.AccessGrant, error) {
+	var res []*models.AccessGrant
+	for _, targetUuid := range targetUuids {
+		if grant, err := m.GetGrant(userUuid, targetUuid); err == nil {
+			res = append(res, grant)
+		}
+	}
+	return res, nil
+}
+
+func (m *mockAccessGrantDAO) ApplyGrant(grant *models.AccessGrant) (bool, error) {
+	stored, err := m.GetGrant(grant.UserUuid, grant.TargetUuid)
+	if err != nil {
+		m.grants = append(m.grants, grant)
+		return true, nil
+	}
+	if stored.Version >= grant.Version {
+		return false, nil
+	}
+	stored.Permission = grant.Permission
+	stored.Version = grant.Version
+	stored.UpdatedAt = time.Now()
+	return true, nil
+}
+
+func (m *mockAccessGrantDAO) GetGrantedBefore(before time.Time) ([]*models.AccessGrant, error) {
+	var res []*models.AccessGrant
+	for _, grant := range m.grants {
+		if grant.Permission != "" && grant.UpdatedAt.Before(before) {
+			res = append(res, grant)
+		}
+	}
+	return res, nil
+}
+
+func (m *mockAccessGrantDAO) RevokeGrant(grant *models.AccessGrant, unchangedSince time.Time) (bool, error) {
+	if !grant.UpdatedAt.Before(unchangedSince) {
+		return false, nil
+	}
+	grant.Permission = ""
+	return true, nil
+}
+
+type mockAccessGrantLister struct {
+	pages map[string]*integrations.AccessGrantsPageDto
+}
+
+func (m *mockAccessGrantLister) ListAccessGrants(cursor string) (*integrations.AccessGrantsPageDto, error) {
+	return m.pages[cursor], nil
+}
+
+func TestAccessGrantService_ApplyEvent(t *testing.T) {
+	dao := &mockAccessGrantDAO{}
+	s := NewAccessGrantService(dao, nil)
+	event := func(eventType string, version int64, permissions ...string) dto.FamilyEvent {
+		return dto.FamilyEvent{Type: eventType, UserUuid: "user", BabyUuid: tUuid, Permissions: permissions, Version: version}
+	}
+	permissions := func() models.Permissions {
+		grant, err := dao.GetGrant("user", models.TargetUUID(tUuid))
+		assert.Nil(t, err)
+		return grant.GetPermissions()
+	}
+
+	assert.Nil(t, s.ApplyEvent(event(dto.FamilyEventMembershipGranted, 1, "read", "write")))
+	assert.Equal(t, models.Permissions{models.PermissionRead, models.PermissionWrite}, permissions())
+
+	assert.Nil(t, s.ApplyEvent(event(dto.FamilyEventMembershipRevoked, 3)))
+	assert.Equal(t, models.Permissions{}, permissions())
+
+	assert.Nil(t, s.ApplyEvent(event(dto.FamilyEventMembershipChanged, 2, "admin")))
+	assert.Equal(t, models.Permissions{}, permissions(), "late event must not restore revoked grant")
+
+	assert.NotNil(t, s.ApplyEvent(event("MembershipUnknown", 4)))
+	assert.NotNil(t, s.ApplyEvent(dto.FamilyEvent{Type: dto.FamilyEventMembershipGranted, Version: 5}))
+}
+
+func TestAccessGrantService_Sync(t *testing.T) {
+	dao := &mockAccessGrantDAO{grants: []*models.AccessGrant{
+		{UserUuid: "user", TargetUuid: "baby-2", Version: 7, Permission: "read"},
+		{UserUuid: "user", TargetUuid: "baby-3", Version: 2, Permission: "read,write"},
+	}}
+	lister := &mockAccessGrantLister{pages: map[string]*integrations.AccessGrantsPageDto{
+		"": {
+			Grants: []integrations.AccessGrantDto{{UserUuid: "user", BabyUuid: "baby-1", Permissions: []models.Permission{models.PermissionRead}, Version: 1}},
+			Next:   "page-2",
+		},
+		"page-2": {
+			Grants: []integrations.AccessGrantDto{{UserUuid: "user", BabyUuid: "baby-2", Permissions: []models.Permission{models.PermissionAdmin}, Version: 5}},
+		},
+	}}
+	s := NewAccessGrantService(dao, lister)
+
+	applied, err := s.Sync()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, applied)
+	grant, err := dao.GetGrant("user", "baby-1")
+	if assert.Nil(t, err) {
+		assert.Equal(t, models.Permissions{models.PermissionRead}, grant.GetPermissions())
+	}
+	grant, err = dao.GetGrant("user", "baby-2")
+	if assert.Nil(t, err) {
+		assert.Equal(t, int64(7), grant.Version)
+		assert.Equal(t, models.Permissions{models.PermissionRead}, grant.GetPermissions(), "listed grant is kept")
+	}
+	grant, err = dao.GetGrant("user", "baby-3")
+	if assert.Nil(t, err) {
+		assert.Equal(t, models.Permissions{}, grant.GetPermissions(), "grant missing in the listing is revoked")
+	}
+}
+
+func TestReplicaAuthorizer(t *testing.T) {
+	replicated := &models.AccessGrant{UserUuid: "user", TargetUuid: "baby-1", Version: 1}
+	replicated.SetPermissions(models.Permissions{models.PermissionRead})
+	revoked := &models.AccessGrant{UserUuid: "user", TargetUuid: "baby-2", Version: 2}
+	dao := &mockAccessGrantDAO{grants: []*models.AccessGrant{replicated, revoked}}
+	fallback := new(test_data.MockAuthorizer)
+	fallback.On("GetPermissions", "user", "baby-3").Return(test_data.ParentPermissions, nil)
+	a := NewReplicaAuthorizer(dao, fallback)
+
+	got, err := a.GetPermissions("user", "baby-1")
+	assert.Nil(t, err)
+	assert.Equal(t, models.Permissions{models.PermissionRead}, got)
+
+	got, err = a.GetPermissions("user", "baby-2")
+	assert.Nil(t, err)
+	assert.False(t, got.Allows(models.PermissionRead))
+
+	got, err = a.GetPermissions("user", "baby-3")
+	assert.Nil(t, err)
+	assert.Equal(t, test_data.ParentPermissions, got)
+
+	all, err := a.GetPermissionsForBabies("user", []string{"baby-1", "baby-2", "baby-3"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]models.Permissions{
+		"baby-1": {models.PermissionRead},
+		"baby-2": {},
+		"baby-3": test_data.ParentPermissions,
+	}, all)
+	fallback.AssertNumberOfCalls(t, "GetPermissions", 2)
+	fallback.AssertNotCalled(t, "GetPermissions", mock.Anything, "baby-1")
+}
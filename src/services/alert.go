@@ -0,0 +1,129 @@
+package services
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/jinzhu/gorm"
+	"little-diary-measurement-service/src/common"
+	"little-diary-measurement-service/src/models"
+	"time"
+)
+
+const (
+	birthWeightLossPeriod = 14 * 24 * time.Hour
+	// loss of birth weight in percents
+	birthWeightLossWarning  = 7
+	birthWeightLossCritical = 10
+)
+
+type alertDAO interface {
+	GetByAlertUuid(alertUuid models.AlertUUID) (*models.Alert, error)
+	GetByMeasurementUuid(measurementUuid models.MeasurementUUID, kind models.AlertKind) (*models.Alert, error)
+	GetAlertsByTargetUuid(targetUuid models.TargetUUID, includeAcknowledged bool) ([]*models.Alert, error)
+	GetAlertsByMeasurementUuids(measurementUuids []models.MeasurementUUID) ([]*models.Alert, error)
+	GetOpenAlertsByTargetUuids(targetUuids []models.TargetUUID) ([]*models.Alert, error)
+	SaveAlert(alert *models.Alert) error
+	DeleteAlert(alert *models.Alert) error
+}
+
+type AlertService struct {
+	dao            alertDAO
+	serviceLocator *common.ServiceLocator
+}
+
+func NewAlertService(dao alertDAO, locator *common.ServiceLocator) *AlertService {
+	return &AlertService{dao, locator}
+}
+
+func (s *AlertService) GetByTargetUuid(targetUuid string, userUuid string, includeAcknowledged bool) ([]*models.Alert, error) {
+	if err := authorize(s.serviceLocator, userUuid, targetUuid, models.PermissionRead); err != nil {
+		return nil, err
+	}
+	return s.dao.GetAlertsByTargetUuid(models.TargetUUID(targetUuid), includeAcknowledged)
+}
+
+// Acknowledge marks alert as seen by the user, acknowledging it again keeps the first acknowledgement
+func (s *AlertService) Acknowledge(alertUuid string, userUuid string) (*models.Alert, error) {
+	alert, err := s.dao.GetByAlertUuid(models.AlertUUID(alertUuid))
+	if err != nil {
+		return nil, err
+	}
+	if err := authorize(s.serviceLocator, userUuid, string(alert.TargetUuid), models.PermissionWrite); err != nil {
+		return nil, err
+	}
+	if alert.AcknowledgedAt != nil {
+		return alert, nil
+	}
+	now := time.Now()
+	alert.AcknowledgedAt = &now
+	alert.AcknowledgedBy = userUuid
+	return alert, s.dao.SaveAlert(alert)
+}
+
+// birthWeightLossAlert checks weight measured in the first two weeks after birth against birth weight
+func birthWeightLossAlert(birth *models.Measurement, measurement *models.Measurement) *models.Alert {
+	age := measurement.Timestamp.Sub(birth.Timestamp)
+	if birth.Value <= 0 || age < 0 || age > birthWeightLossPeriod {
+		return nil
+	}
+	loss := float64(birth.Value-measurement.Value) / float64(birth.Value) * 100
+	var severity models.AlertSeverity
+	switch {
+	case loss >= birthWeightLossCritical:
+		severity = models.AlertSeverityCritical
+	case loss >= birthWeightLossWarning:
+		severity = models.AlertSeverityWarning
+	default:
+		return nil
+	}
+	return &models.Alert{
+		Kind:     models.AlertKindBirthWeightLoss,
+		Severity: severity,
+		Reason: fmt.Sprintf("weight %.0f g is %.1f%% below birth weight %.0f g on day %d",
+			measurement.Value, loss, birth.Value, int(age.Hours()/24)),
+	}
+}
+
+// alertChanges are alerts to store and to remove, they are written in one transaction with the measurement
+type alertChanges struct {
+	saved   []*models.Alert
+	removed []*models.Alert
+}
+
+// add brings the stored alert of the kind for the measurement in line with the evaluated candidate,
+// nil candidate removes it, acknowledgement is dropped when severity changes
+func (c *alertChanges) add(stored *models.Alert, measurement *models.Measurement, kind models.AlertKind, candidate *models.Alert) {
+	if candidate == nil {
+		if stored != nil {
+			c.removed = append(c.removed, stored)
+		}
+		return
+	}
+	if stored == nil {
+		stored = &models.Alert{
+			Uuid:            models.AlertUUID(uuid.New().String()),
+			TargetUuid:      measurement.TargetUuid,
+			MeasurementUuid: measurement.Uuid,
+			Kind:            kind,
+		}
+	}
+	if stored.Severity != candidate.Severity {
+		stored.AcknowledgedAt = nil
+		stored.AcknowledgedBy = ""
+	}
+	stored.Severity = candidate.Severity
+	stored.Reason = candidate.Reason
+	c.saved = append(c.saved, stored)
+}
+
+// storedAlert returns the alert of the kind for the measurement, nil when there is none
+func storedAlert(dao alertDAO, measurementUuid models.MeasurementUUID, kind models.AlertKind) (*models.Alert, error) {
+	stored, err := dao.GetByMeasurementUuid(measurementUuid, kind)
+	if err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return stored, nil
+}
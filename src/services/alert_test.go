@@ -0,0 +1,270 @@
+package services
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"little-diary-measurement-service/src/common"
+	"little-diary-measurement-service/src/config"
+	"little-diary-measurement-service/src/dto"
+	"little-diary-measurement-service/src/integrations"
+	"little-diary-measurement-service/src/models"
+	"little-diary-measurement-service/src/test_data"
+	"testing"
+	"time"
+)
+
+type mockAlertDAO struct {
+	alerts []*models.Alert
+}
+
+func newMockAlertDAO() *mockAlertDAO {
+	return &mockAlertDAO{}
+}
+
+func (m *mockAlertDAO) GetByAlertUuid(alertUuid models.AlertUUID) (*models.Alert, error) {
+	for _, alert := range m.alerts {
+		if alert.Uuid == alertUuid {
+			return alert, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (m *mockAlertDAO) GetByMeasurementUuid(measurementUuid models.MeasurementUUID, kind models.AlertKind) (*models.Alert, error) {
+	for _, alert := range m.alerts {
+		if alert.MeasurementUuid == measurementUuid && alert.Kind == kind {
+			return alert, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (m *mockAlertDAO) GetAlertsByTargetUuid(targetUuid models.TargetUUID, includeAcknowledged bool) ([]*models.Alert, error) {
+	var res []*models.Alert
+	for _, alert := range m.alerts {
+		if alert.TargetUuid == targetUuid && (includeAcknowledged || alert.AcknowledgedAt == nil) {
+			res = append(res, alert)
+		}
+	}
+	return res, nil
+}
+
+func (m *mockAlertDAO) GetAlertsByMeasurementUuids(measurementUuids []models.MeasurementUUID) ([]*models.Alert, error) {
+	var res []*models.Alert
+	for _, alert := range m.alerts {
+		for _, measurementUuid := range measurementUuids {
+			if alert.MeasurementUuid == measurementUuid {
+				res = append(res, alert)
+			}
+		}
+	}
+	return res, nil
+}
+
+func (m *mockAlertDAO) GetOpenAlertsByTargetUuids(targetUuids []models.TargetUUID) ([]*models.Alert, error) {
+	var res []*models.Alert
+	for _, targetUuid := range targetUuids {
+		open, _ := m.GetAlertsByTargetUuid(targetUuid, false)
+		res = append(res, open...)
+	}
+	return res, nil
+}
+
+func (m *mockAlertDAO) SaveAlert(alert *models.Alert) error {
+	if alert.ID == 0 {
+		alert.ID = uint(len(m.alerts) + 1)
+		m.alerts = append(m.alerts, alert)
+	}
+	return nil
+}
+
+func (m *mockAlertDAO) DeleteAlert(alert *models.Alert) error {
+	for i, stored := range m.alerts {
+		if stored == alert {
+			m.alerts = append(m.alerts[:i], m.alerts[i+1:]...)
+		}
+	}
+	return nil
+}
+
+func TestBirthWeightLossAlert(t *testing.T) {
+	birthDate := time.Date(2020, 3, 1, 8, 0, 0, 0, time.UTC)
+	birth := &models.Measurement{Type: models.MeasurementTypeWeight, Timestamp: birthDate, Value: 3500, IsBirth: true}
+	tests := []struct {
+		name  string
+		days  int
+		value float32
+		want  models.AlertSeverity
+	}{
+		{name: "normal loss", days: 3, value: 3300},
+		{name: "warning", days: 3, value: 3255, want: models.AlertSeverityWarning},
+		{name: "critical", days: 4, value: 3150, want: models.AlertSeverityCritical},
+		{name: "after two weeks", days: 15, value: 3100},
+		{name: "before birth", days: -1, value: 3000},
+		{name: "birth itself", days: 0, value: 3500},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := birthWeightLossAlert(birth, &models.Measurement{Type: models.MeasurementTypeWeight, Timestamp: birthDate.AddDate(0, 0, tt.days), Value: tt.value})
+			if tt.want == "" {
+				assert.Nil(t, got)
+				return
+			}
+			if assert.NotNil(t, got) {
+				assert.Equal(t, tt.want, got.Severity)
+				assert.Equal(t, models.AlertKindBirthWeightLoss, got.Kind)
+			}
+		})
+	}
+}
+
+func TestAlertChanges(t *testing.T) {
+	m := &models.Measurement{Uuid: models.MeasurementUUID(fmt.Sprintf("%s", uuid.New())), TargetUuid: models.TargetUUID(tUuid)}
+
+	changes := &alertChanges{}
+	changes.add(nil, m, models.AlertKindBirthWeightLoss, &models.Alert{Severity: models.AlertSeverityWarning, Reason: "first"})
+	if !assert.Len(t, changes.saved, 1) {
+		return
+	}
+	stored := changes.saved[0]
+	assert.Equal(t, m.Uuid, stored.MeasurementUuid)
+	assert.NotEmpty(t, stored.Uuid)
+	acknowledged := time.Now()
+	stored.AcknowledgedAt = &acknowledged
+
+	changes = &alertChanges{}
+	changes.add(stored, m, models.AlertKindBirthWeightLoss, &models.Alert{Severity: models.AlertSeverityWarning, Reason: "same"})
+	assert.Equal(t, []*models.Alert{stored}, changes.saved)
+	assert.Equal(t, "same", stored.Reason)
+	assert.NotNil(t, stored.AcknowledgedAt, "acknowledgement is kept for the same severity")
+
+	changes.add(stored, m, models.AlertKindBirthWeightLoss, &models.Alert{Severity: models.AlertSeverityCritical, Reason: "worse"})
+	assert.Nil(t, stored.AcknowledgedAt)
+
+	changes = &alertChanges{}
+	changes.add(stored, m, models.AlertKindBirthWeightLoss, nil)
+	changes.add(nil, m, models.AlertKindPercentileCrossing, nil)
+	assert.Empty(t, changes.saved)
+	assert.Equal(t, []*models.Alert{stored}, changes.removed)
+}
+
+func TestMeasurementService_SaveEvaluatesAlerts(t *testing.T) {
+	targetUuid := fmt.Sprintf("%s", uuid.New())
+	birthDate := time.Now().AddDate(0, 0, -5)
+	locator := &common.ServiceLocator{
+		PublicKeyGetter: &config.Config,
+		Authorizer: func() integrations.Authorizer {
+			mockObj := new(test_data.MockAuthorizer)
+			mockObj.On("GetPermissions", mock.Anything, mock.Anything).Return(test_data.ParentPermissions, nil)
+			return mockObj
+		}(),
+	}
+	birth := &models.Measurement{Uuid: models.MeasurementUUID(fmt.Sprintf("%s", uuid.New())), TargetUuid: models.TargetUUID(targetUuid),
+		Type: models.MeasurementTypeWeight, Timestamp: birthDate, Value: 3500, IsBirth: true}
+	alertDao := newMockAlertDAO()
+	dao := &mockMeasurementDAO{records: []*models.Measurement{birth}, alerts: alertDao}
+	s := NewMeasurementService(dao, alertDao, locator)
+
+	measurementUuid := fmt.Sprintf("%s", uuid.New())
+	_, err := s.Save(measurementUuid, dto.MeasurementRequest{Type: "WEIGHT", Timestamp: birthDate.AddDate(0, 0, 3), Value: 3100, TargetUuid: targetUuid}, "any")
+	assert.Nil(t, err)
+	if assert.Len(t, alertDao.alerts, 1) {
+		assert.Equal(t, models.AlertSeverityCritical, alertDao.alerts[0].Severity)
+		assert.Equal(t, models.MeasurementUUID(measurementUuid), alertDao.alerts[0].MeasurementUuid)
+	}
+
+	_, err = s.Save(fmt.Sprintf("%s", uuid.New()), dto.MeasurementRequest{Type: "HEIGHT", Timestamp: birthDate.AddDate(0, 0, 3), Value: 30, TargetUuid: targetUuid}, "any")
+	assert.Nil(t, err)
+	assert.Len(t, alertDao.alerts, 1)
+}
+
+func TestMeasurementService_SaveBirthRechecksWeights(t *testing.T) {
+	targetUuid := fmt.Sprintf("%s", uuid.New())
+	birthDate := time.Now().AddDate(0, 0, -5)
+	locator := &common.ServiceLocator{
+		PublicKeyGetter: &config.Config,
+		Authorizer: func() integrations.Authorizer {
+			mockObj := new(test_data.MockAuthorizer)
+			mockObj.On("GetPermissions", mock.Anything, mock.Anything).Return(test_data.ParentPermissions, nil)
+			return mockObj
+		}(),
+	}
+	weight := &models.Measurement{Uuid: models.MeasurementUUID(fmt.Sprintf("%s", uuid.New())), TargetUuid: models.TargetUUID(targetUuid),
+		Type: models.MeasurementTypeWeight, Timestamp: birthDate.AddDate(0, 0, 3), Value: 3100}
+	alertDao := newMockAlertDAO()
+	dao := &mockMeasurementDAO{records: []*models.Measurement{weight}, alerts: alertDao}
+	s := NewMeasurementService(dao, alertDao, locator)
+
+	birthUuid := fmt.Sprintf("%s", uuid.New())
+	isBirth, notBirth := true, false
+	birth, err := s.Save(birthUuid, dto.MeasurementRequest{Type: "WEIGHT", Timestamp: birthDate, Value: 3500, TargetUuid: targetUuid, IsBirth: &isBirth}, "any")
+	if !assert.Nil(t, err) {
+		return
+	}
+	if assert.Len(t, alertDao.alerts, 1, "weight stored before the birth record is checked") {
+		assert.Equal(t, weight.Uuid, alertDao.alerts[0].MeasurementUuid)
+		assert.Equal(t, models.AlertSeverityCritical, alertDao.alerts[0].Severity)
+	}
+	dao.records = append(dao.records, birth)
+
+	_, err = s.Save(birthUuid, dto.MeasurementRequest{Type: "WEIGHT", Timestamp: birthDate, Value: 3350, TargetUuid: targetUuid, IsBirth: &isBirth}, "any")
+	assert.Nil(t, err)
+	if assert.Len(t, alertDao.alerts, 1, "corrected birth weight is applied") {
+		assert.Equal(t, models.AlertSeverityWarning, alertDao.alerts[0].Severity)
+	}
+
+	// a fix of the value without is_birth keeps the birth record
+	birth, err = s.Save(birthUuid, dto.MeasurementRequest{Type: "WEIGHT", Timestamp: birthDate, Value: 3450, TargetUuid: targetUuid}, "any")
+	if assert.Nil(t, err) {
+		assert.True(t, birth.IsBirth)
+	}
+	if assert.Len(t, alertDao.alerts, 1, "weights are checked against the fixed birth weight") {
+		assert.Equal(t, models.AlertSeverityCritical, alertDao.alerts[0].Severity)
+	}
+
+	_, err = s.Save(birthUuid, dto.MeasurementRequest{Type: "WEIGHT", Timestamp: birthDate, Value: 3450, TargetUuid: targetUuid, IsBirth: &notBirth}, "any")
+	assert.Nil(t, err)
+	assert.Empty(t, alertDao.alerts, "alerts go away with the birth record")
+}
+
+func TestAlertService_Acknowledge(t *testing.T) {
+	alertUuid := models.AlertUUID(fmt.Sprintf("%s", uuid.New()))
+	tests := []struct {
+		name        string
+		alertUuid   models.AlertUUID
+		permissions models.Permissions
+		wantErr     bool
+	}{
+		{name: "acknowledge", alertUuid: alertUuid, permissions: test_data.ParentPermissions},
+		{name: "test access denied", alertUuid: alertUuid, permissions: models.Permissions{}, wantErr: true},
+		{name: "test read only", alertUuid: alertUuid, permissions: models.Permissions{models.PermissionRead}, wantErr: true},
+		{name: "not existed alert", alertUuid: models.AlertUUID(fmt.Sprintf("%s", uuid.New())), permissions: test_data.ParentPermissions, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dao := &mockAlertDAO{alerts: []*models.Alert{{ID: 1, Uuid: alertUuid, TargetUuid: models.TargetUUID(tUuid)}}}
+			s := NewAlertService(dao, &common.ServiceLocator{
+				PublicKeyGetter: &config.Config,
+				Authorizer: func() integrations.Authorizer {
+					mockObj := new(test_data.MockAuthorizer)
+					mockObj.On("GetPermissions", mock.Anything, mock.Anything).Return(tt.permissions, nil)
+					return mockObj
+				}(),
+			})
+			got, err := s.Acknowledge(string(tt.alertUuid), "user")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Acknowledge() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err == nil {
+				assert.NotNil(t, got.AcknowledgedAt)
+				assert.Equal(t, "user", got.AcknowledgedBy)
+				open, _ := s.GetByTargetUuid(tUuid, "user", false)
+				assert.Empty(t, open)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package services
+
+import (
+	"fmt"
+	"io"
+	"little-diary-measurement-service/src/charts"
+	"little-diary-measurement-service/src/common"
+	"little-diary-measurement-service/src/errors"
+	"little-diary-measurement-service/src/growth"
+	"little-diary-measurement-service/src/models"
+	"little-diary-measurement-service/src/report"
+	"math"
+	"time"
+)
+
+const (
+	minChartAgeMonths   = 6
+	referenceStepMonths = 0.25
+)
+
+// ChartOptions without sex or birth date take them from the baby profile
+type ChartOptions struct {
+	Type      models.MeasurementType
+	Sex       growth.Sex
+	BirthDate time.Time
+	// preterm babies are drawn by corrected age over preterm and then WHO curves
+	GestationalAgeDays int
+	Theme              charts.Theme
+	Width              float64
+	Height             float64
+}
+
+type ChartService struct {
+	dao            measurementDAO
+	serviceLocator *common.ServiceLocator
+}
+
+func NewChartService(dao measurementDAO, locator *common.ServiceLocator) *ChartService {
+	return &ChartService{dao, locator}
+}
+
+// WriteGrowthChart renders target measurements by age over reference percentile curves as SVG
+func (s *ChartService) WriteGrowthChart(w io.Writer, targetUuid string, userUuid string, options ChartOptions) error {
+	if err := authorize(s.serviceLocator, userUuid, targetUuid, models.PermissionRead); err != nil {
+		return err
+	}
+	profile := babyProfile(s.serviceLocator, models.TargetUUID(targetUuid))
+	if sex, ok := profileSex(profile); ok && options.Sex == "" {
+		options.Sex = sex
+	}
+	if profile != nil && profile.BirthDate != nil && options.BirthDate.IsZero() {
+		options.BirthDate = *profile.BirthDate
+	}
+	if profile != nil {
+		options.GestationalAgeDays = profile.GestationalAgeDays
+	}
+	if options.Sex == "" || options.BirthDate.IsZero() {
+		return &errors.ValidationError{S: "sex and birth-date are required, the baby profile does not have them"}
+	}
+	measurements, err := s.dao.GetMeasurementsByFilter(models.MeasurementFilter{
+		TargetUuid: models.TargetUUID(targetUuid),
+		Types:      []models.MeasurementType{options.Type},
+	})
+	if err != nil {
+		return err
+	}
+	chart := percentileChart(measurements, options)
+	canvas := charts.NewSvgCanvas(options.Width, options.Height)
+	chart.Draw(canvas, 0, 0, options.Width, options.Height)
+	return canvas.Write(w)
+}
+
+func percentileChart(measurements []*models.Measurement, options ChartOptions) *charts.Chart {
+	unit, factor := report.DisplayUnit(options.Type)
+	title, xLabel := "%s for age", "Age, months"
+	minAge := 0.0
+	if growth.IsPreterm(options.GestationalAgeDays) {
+		title, xLabel = "%s for corrected age", "Corrected age, months"
+		minAge = growth.CorrectedAgeDays(0, options.GestationalAgeDays) / growth.DaysInMonth
+	}
+	var points []charts.Point
+	maxAge := float64(minChartAgeMonths)
+	for _, m := range measurements {
+		ageDays := m.Timestamp.Sub(options.BirthDate).Hours() / 24
+		if ageDays < 0 {
+			continue
+		}
+		age := growth.CorrectedAgeDays(ageDays, options.GestationalAgeDays) / growth.DaysInMonth
+		points = append(points, charts.Point{X: age, Y: float64(m.Value * factor)})
+		maxAge = math.Max(maxAge, math.Ceil(age+0.5))
+	}
+	maxAge = math.Min(maxAge, growth.MaxAgeDays(options.Type)/growth.DaysInMonth)
+
+	percentiles := growth.MajorPercentiles
+	curves := make([]charts.Series, len(percentiles))
+	for i, p := range percentiles {
+		curves[i].Name = charts.FormatNumber(p)
+	}
+	for age := minAge; age <= maxAge+1e-9; age += referenceStepMonths {
+		lms, _, ok := growth.LookupForCorrectedAge(options.Type, options.Sex, age*growth.DaysInMonth, options.GestationalAgeDays)
+		if !ok {
+			continue
+		}
+		for i, p := range percentiles {
+			curves[i].Points = append(curves[i].Points, charts.Point{X: age, Y: lms.ValueAtPercentile(p) * float64(factor)})
+		}
+	}
+	var bands []charts.Band
+	// outer band between the first and the last percentile and the inner one between the second pair
+	for i := 0; i < len(curves)/2; i++ {
+		bands = append(bands, charts.Band{Lower: curves[i].Points, Upper: curves[len(curves)-1-i].Points})
+	}
+
+	return &charts.Chart{
+		Title:  fmt.Sprintf(title, report.TypeTitle(options.Type)),
+		XAxis:  charts.Axis{Label: xLabel},
+		YAxis:  charts.Axis{Label: unit},
+		Series: []charts.Series{{Name: report.TypeTitle(options.Type), Points: points}},
+		Bands:  bands,
+		Curves: curves,
+		Theme:  options.Theme,
+	}
+}
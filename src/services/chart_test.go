@@ -0,0 +1,116 @@
+package services
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"little-diary-measurement-service/src/charts"
+	"little-diary-measurement-service/src/common"
+	"little-diary-measurement-service/src/config"
+	"little-diary-measurement-service/src/growth"
+	"little-diary-measurement-service/src/integrations"
+	"little-diary-measurement-service/src/models"
+	"little-diary-measurement-service/src/test_data"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPercentileChart(t *testing.T) {
+	birth := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	measurements := []*models.Measurement{
+		{Type: models.MeasurementTypeWeight, Timestamp: birth.AddDate(0, 0, -1), Value: 3000},
+		{Type: models.MeasurementTypeWeight, Timestamp: birth.AddDate(0, 0, 1), Value: 3300},
+		{Type: models.MeasurementTypeWeight, Timestamp: birth.AddDate(0, 9, 0), Value: 8100},
+	}
+	chart := percentileChart(measurements, ChartOptions{
+		Type:      models.MeasurementTypeWeight,
+		Sex:       growth.SexMale,
+		BirthDate: birth,
+		Theme:     charts.LightTheme,
+	})
+
+	if assert.Len(t, chart.Series, 1) && assert.Len(t, chart.Series[0].Points, 2, "measurements before birth are skipped") {
+		assert.InDelta(t, 3.3, chart.Series[0].Points[0].Y, 1e-6)
+		assert.InDelta(t, 9, chart.Series[0].Points[1].X, 0.1)
+	}
+	assert.Len(t, chart.Curves, len(growth.MajorPercentiles))
+	assert.Len(t, chart.Bands, 2)
+	median := chart.Curves[2]
+	assert.Equal(t, "50", median.Name)
+	assert.InDelta(t, 3.3464, median.Points[0].Y, 1e-6)
+	assert.InDelta(t, 10, median.Points[len(median.Points)-1].X, 1e-6, "reference covers the data with a margin")
+}
+
+func TestPercentileChartPreterm(t *testing.T) {
+	birth := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	measurements := []*models.Measurement{
+		{Type: models.MeasurementTypeWeight, Timestamp: birth.AddDate(0, 0, 1), Value: 1500},
+		{Type: models.MeasurementTypeWeight, Timestamp: birth.AddDate(0, 0, 70), Value: 3500},
+	}
+	chart := percentileChart(measurements, ChartOptions{
+		Type:               models.MeasurementTypeWeight,
+		Sex:                growth.SexMale,
+		BirthDate:          birth,
+		GestationalAgeDays: 30 * 7,
+		Theme:              charts.LightTheme,
+	})
+
+	assert.Equal(t, "Corrected age, months", chart.XAxis.Label)
+	if assert.Len(t, chart.Series[0].Points, 2) {
+		assert.InDelta(t, -69.0/growth.DaysInMonth, chart.Series[0].Points[0].X, 1e-6)
+		assert.InDelta(t, 0, chart.Series[0].Points[1].X, 1e-6, "the baby is at term 10 weeks after birth")
+	}
+	median := chart.Curves[2]
+	assert.InDelta(t, -70/growth.DaysInMonth, median.Points[0].X, 1e-6)
+	assert.InDelta(t, 1400, median.Points[0].Y*1000, 1e-3, "curves start on the preterm reference")
+}
+
+func TestChartService_WriteGrowthChart(t *testing.T) {
+	birthDate := time.Now().AddDate(0, -3, 0)
+	tests := []struct {
+		name        string
+		permissions models.Permissions
+		profile     *models.BabyProfile
+		sex         growth.Sex
+		wantErr     bool
+	}{
+		{name: "render", permissions: test_data.ParentPermissions, sex: growth.SexFemale},
+		{name: "access denied", permissions: models.Permissions{}, sex: growth.SexFemale, wantErr: true},
+		{name: "sex from profile", permissions: test_data.ParentPermissions, profile: &models.BabyProfile{Sex: "male"}},
+		{name: "unknown sex", permissions: test_data.ParentPermissions, profile: &models.BabyProfile{BirthDate: &birthDate}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewChartService(newMockMeasurementDAO(), &common.ServiceLocator{
+				PublicKeyGetter: &config.Config,
+				Authorizer: func() integrations.Authorizer {
+					mockObj := new(test_data.MockAuthorizer)
+					mockObj.On("GetPermissions", mock.Anything, mock.Anything).Return(tt.permissions, nil)
+					return mockObj
+				}(),
+				BabyProfileGetter: func() integrations.BabyProfileGetter {
+					mockObj := new(test_data.MockBabyProfileGetter)
+					mockObj.On("GetBabyProfile", tUuid).Return(tt.profile, nil)
+					return mockObj
+				}(),
+			})
+			var out bytes.Buffer
+			err := s.WriteGrowthChart(&out, tUuid, "any", ChartOptions{
+				Type:      models.MeasurementTypeHeight,
+				Sex:       tt.sex,
+				BirthDate: birthDate,
+				Theme:     charts.DarkTheme,
+				Width:     640,
+				Height:    400,
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("WriteGrowthChart() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err == nil {
+				assert.True(t, strings.HasPrefix(out.String(), "<svg"))
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package services
+
+import (
+	"fmt"
+	"little-diary-measurement-service/src/common"
+	"little-diary-measurement-service/src/errors"
+	"little-diary-measurement-service/src/models"
+)
+
+// maxComparisonAges keeps resampled series at a size a chart can show
+const maxComparisonAges = 400
+
+type ComparisonService struct {
+	dao            measurementDAO
+	serviceLocator *common.ServiceLocator
+}
+
+func NewComparisonService(dao measurementDAO, locator *common.ServiceLocator) *ComparisonService {
+	return &ComparisonService{dao, locator}
+}
+
+// Compare aligns measurements of the type of all targets by age from each birth date and resamples them
+// every step from birth to the oldest measured age, the user must have access to all targets
+func (s *ComparisonService) Compare(targetUuids []string, t models.MeasurementType, step models.Age, userUuid string) (*models.Comparison, error) {
+	if err := authorizeAll(s.serviceLocator, userUuid, targetUuids, models.PermissionRead); err != nil {
+		return nil, err
+	}
+	comparison := &models.Comparison{Type: t}
+	subjects := map[models.TargetUUID]growthSubject{}
+	for _, targetUuid := range targetUuids {
+		profile := babyProfile(s.serviceLocator, models.TargetUUID(targetUuid))
+		if profile == nil || profile.BirthDate == nil {
+			return nil, &errors.ValidationError{S: fmt.Sprintf("birth date of target %s is unknown", targetUuid)}
+		}
+		measurements, err := s.dao.GetMeasurementsByFilter(models.MeasurementFilter{
+			TargetUuid: models.TargetUUID(targetUuid),
+			Types:      []models.MeasurementType{t},
+		})
+		if err != nil {
+			return nil, err
+		}
+		attachAges(s.serviceLocator, measurements)
+		subjects[models.TargetUUID(targetUuid)] = profileSubject(profile)
+		comparison.Series = append(comparison.Series, &models.ComparisonSeries{
+			TargetUuid:   models.TargetUUID(targetUuid),
+			BirthDate:    *profile.BirthDate,
+			Measurements: measurements,
+		})
+	}
+	comparison.Ages = comparisonAges(comparison.Series, step)
+	if len(comparison.Ages) > maxComparisonAges {
+		return nil, &errors.ValidationError{S: fmt.Sprintf("comparison has more than %d ages, use a longer step", maxComparisonAges)}
+	}
+	for _, series := range comparison.Series {
+		resample(series, t, comparison.Ages, subjects[series.TargetUuid])
+	}
+	return comparison, nil
+}
+
+// comparisonAges returns ages from birth every step up to the oldest age any target was measured at
+func comparisonAges(series []*models.ComparisonSeries, step models.Age) []models.Age {
+	var ages []models.Age
+	for value := 0; len(ages) <= maxComparisonAges; value += step.Value {
+		age := models.Age{Value: value, Unit: step.Unit}
+		measuredLater := false
+		for _, s := range series {
+			for _, m := range s.Measurements {
+				if !m.Timestamp.Before(age.Reached(s.BirthDate)) {
+					measuredLater = true
+				}
+			}
+		}
+		if !measuredLater {
+			break
+		}
+		ages = append(ages, age)
+	}
+	return ages
+}
+
+// resample interpolates values of the series at the ages, ages out of the measured history stay empty
+func resample(series *models.ComparisonSeries, t models.MeasurementType, ages []models.Age, subject growthSubject) {
+	series.Values = make([]*float32, len(ages))
+	for i, age := range ages {
+		if interpolation, err := interpolate(series.Measurements, t, age.Reached(series.BirthDate), subject); err == nil {
+			series.Values[i] = &interpolation.Value
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package services
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"little-diary-measurement-service/src/common"
+	"little-diary-measurement-service/src/config"
+	"little-diary-measurement-service/src/models"
+	"little-diary-measurement-service/src/test_data"
+	"testing"
+	"time"
+)
+
+func TestComparisonService_Compare(t *testing.T) {
+	older := time.Date(2018, 5, 10, 0, 0, 0, 0, time.UTC)
+	younger := time.Date(2020, 2, 1, 0, 0, 0, 0, time.UTC)
+	dao := &mockMeasurementDAO{records: []*models.Measurement{
+		{TargetUuid: "older", Type: models.MeasurementTypeHeight, Timestamp: older, Value: 50},
+		{TargetUuid: "older", Type: models.MeasurementTypeHeight, Timestamp: older.AddDate(0, 4, 0), Value: 62},
+		{TargetUuid: "younger", Type: models.MeasurementTypeHeight, Timestamp: younger.AddDate(0, 1, 0), Value: 55},
+		{TargetUuid: "younger", Type: models.MeasurementTypeHeight, Timestamp: younger.AddDate(0, 2, 0), Value: 58},
+		{TargetUuid: "younger", Type: models.MeasurementTypeWeight, Timestamp: younger.AddDate(0, 3, 0), Value: 6000},
+	}}
+	profiles := new(test_data.MockBabyProfileGetter)
+	profiles.On("GetBabyProfile", "older").Return(&models.BabyProfile{BirthDate: &older}, nil)
+	profiles.On("GetBabyProfile", "younger").Return(&models.BabyProfile{BirthDate: &younger}, nil)
+	profiles.On("GetBabyProfile", "unknown").Return((*models.BabyProfile)(nil), nil)
+	authorizer := new(test_data.MockAuthorizer)
+	authorizer.On("GetPermissions", mock.Anything, "forbidden").Return(models.Permissions{}, nil)
+	authorizer.On("GetPermissions", mock.Anything, mock.Anything).Return(test_data.ParentPermissions, nil)
+	s := NewComparisonService(dao, &common.ServiceLocator{
+		PublicKeyGetter:   &config.Config,
+		Authorizer:        authorizer,
+		BabyProfileGetter: profiles,
+	})
+	monthly := models.Age{Value: 1, Unit: models.AgeUnitMonths}
+
+	comparison, err := s.Compare([]string{"older", "younger"}, models.MeasurementTypeHeight, monthly, "user")
+	if assert.Nil(t, err) {
+		assert.Len(t, comparison.Ages, 5, "ages go from birth to the oldest measured age")
+		if assert.Len(t, comparison.Series, 2) {
+			olderValues, youngerValues := comparison.Series[0].Values, comparison.Series[1].Values
+			assert.Equal(t, float32(50), *olderValues[0])
+			assert.InDelta(t, 56, *olderValues[2], 0.5)
+			assert.Nil(t, youngerValues[0], "values are not extrapolated")
+			assert.Equal(t, float32(55), *youngerValues[1])
+			assert.Equal(t, float32(58), *youngerValues[2])
+			assert.Nil(t, youngerValues[4])
+			assert.Equal(t, 1, comparison.Series[1].Measurements[0].Age.Months)
+		}
+	}
+
+	_, err = s.Compare([]string{"older", "forbidden"}, models.MeasurementTypeHeight, monthly, "user")
+	assert.NotNil(t, err, "access to every target is required")
+
+	_, err = s.Compare([]string{"older", "unknown"}, models.MeasurementTypeHeight, monthly, "user")
+	assert.NotNil(t, err, "birth date of every target is required")
+
+	comparison, err = s.Compare([]string{"older"}, models.MeasurementTypeHeight, models.Age{Value: 7, Unit: models.AgeUnitDays}, "user")
+	if assert.Nil(t, err) {
+		assert.Equal(t, models.Age{Value: 14, Unit: models.AgeUnitDays}, comparison.Ages[2])
+	}
+}
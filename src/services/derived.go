@@ -0,0 +1,201 @@
+package services
+
+import (
+	"github.com/google/uuid"
+	"little-diary-measurement-service/src/growth"
+	"little-diary-measurement-service/src/models"
+	"sort"
+	"time"
+)
+
+// derivedPairingWindow is the longest time between a weight and the height it is paired with
+const derivedPairingWindow = 72 * time.Hour
+
+// derivedSourceTypes are the stored types derived values are computed from
+var derivedSourceTypes = []models.MeasurementType{models.MeasurementTypeWeight, models.MeasurementTypeHeight}
+
+// splitDerivedTypes separates requested types into stored and derived ones
+func splitDerivedTypes(types []models.MeasurementType) ([]models.MeasurementType, []models.MeasurementType) {
+	var stored, derived []models.MeasurementType
+	for _, t := range types {
+		if t.IsDerived() {
+			derived = append(derived, t)
+		} else {
+			stored = append(stored, t)
+		}
+	}
+	return stored, derived
+}
+
+// derivedSourceFilter widens the filter period by the pairing window so that weights at its edges find their heights
+func derivedSourceFilter(filter models.MeasurementFilter) models.MeasurementFilter {
+	source := models.MeasurementFilter{TargetUuid: filter.TargetUuid, Types: derivedSourceTypes}
+	if filter.From != nil {
+		from := filter.From.Add(-derivedPairingWindow)
+		source.From = &from
+	}
+	if filter.To != nil {
+		to := filter.To.Add(derivedPairingWindow)
+		source.To = &to
+	}
+	return source
+}
+
+// deriveMeasurements pairs every weight within the filter period with the closest in time height inside
+// the pairing window and computes values of the derived types, suspect values are not paired.
+// Derived values take timestamp of the weight, results are ordered by time
+func deriveMeasurements(source []*models.Measurement, types []models.MeasurementType, filter models.MeasurementFilter, subject growthSubject) []*models.Measurement {
+	var weights, heights []*models.Measurement
+	for _, m := range source {
+		if m.Suspect {
+			continue
+		}
+		switch m.Type {
+		case models.MeasurementTypeWeight:
+			weights = append(weights, m)
+		case models.MeasurementTypeHeight:
+			heights = append(heights, m)
+		}
+	}
+	var derived []*models.Measurement
+	for _, weight := range weights {
+		if (filter.From != nil && weight.Timestamp.Before(*filter.From)) || (filter.To != nil && weight.Timestamp.After(*filter.To)) {
+			continue
+		}
+		height := closestInTime(heights, weight.Timestamp, derivedPairingWindow)
+		if height == nil || height.Value <= 0 {
+			continue
+		}
+		for _, t := range types {
+			m := derivedMeasurement(t, weight, height)
+			m.Percentile = derivedPercentile(t, weight, height, subject)
+			derived = append(derived, m)
+		}
+	}
+	sort.SliceStable(derived, func(i, j int) bool {
+		return derived[i].Timestamp.Before(derived[j].Timestamp)
+	})
+	return derived
+}
+
+func closestInTime(candidates []*models.Measurement, at time.Time, window time.Duration) *models.Measurement {
+	var closest *models.Measurement
+	var closestDistance time.Duration
+	for _, m := range candidates {
+		distance := m.Timestamp.Sub(at)
+		if distance < 0 {
+			distance = -distance
+		}
+		if distance <= window && (closest == nil || distance < closestDistance) {
+			closest, closestDistance = m, distance
+		}
+	}
+	return closest
+}
+
+// derivedMeasurement builds value of the type from the pair, its uuid is stable for the same pair
+func derivedMeasurement(t models.MeasurementType, weight *models.Measurement, height *models.Measurement) *models.Measurement {
+	var value float64
+	switch t {
+	case models.MeasurementTypeBMI:
+		value = growth.BMI(float64(weight.Value), float64(height.Value))
+	case models.MeasurementTypeWeightForLength:
+		// the weight itself, its percentile is taken for the paired length
+		value = float64(weight.Value)
+	}
+	return &models.Measurement{
+		Type:        t,
+		Timestamp:   weight.Timestamp,
+		Value:       float32(value),
+		Uuid:        models.MeasurementUUID(uuid.NewSHA1(uuid.NameSpaceOID, []byte(string(t)+string(weight.Uuid)+string(height.Uuid))).String()),
+		TargetUuid:  weight.TargetUuid,
+		Source:      weight.Source,
+		Confirmed:   weight.Confirmed && height.Confirmed,
+		DerivedFrom: []models.MeasurementUUID{weight.Uuid, height.Uuid},
+	}
+}
+
+// derivedPercentile compares the pair with the reference of the type, it needs known sex of the child.
+// Weight-for-length applies before the second birthday or when age is unknown, BMI-for-age from then on
+func derivedPercentile(t models.MeasurementType, weight *models.Measurement, height *models.Measurement, subject growthSubject) *float64 {
+	if len(subject.sexes) != 1 {
+		return nil
+	}
+	sex := subject.sexes[0]
+	ageKnown := !subject.birthDate.IsZero()
+	ageDays := growth.CorrectedAgeDays(weight.Timestamp.Sub(subject.birthDate).Hours()/24, subject.gestationalAgeDays)
+	var percentile float64
+	switch t {
+	case models.MeasurementTypeWeightForLength:
+		lms, ok := growth.LookupWeightForLength(sex, float64(height.Value))
+		if !ok || (ageKnown && ageDays >= growth.BMIFromAgeDays) {
+			return nil
+		}
+		percentile = lms.Percentile(float64(weight.Value) / 1000)
+	case models.MeasurementTypeBMI:
+		if !ageKnown {
+			return nil
+		}
+		lms, ok := growth.LookupBMIForAge(sex, ageDays)
+		if !ok {
+			return nil
+		}
+		percentile = lms.Percentile(growth.BMI(float64(weight.Value), float64(height.Value)))
+	default:
+		return nil
+	}
+	return &percentile
+}
+
+// aggregateMeasurements summarizes time ordered measurements by calendar buckets of the location
+// the same way the database does for stored types
+func aggregateMeasurements(measurements []*models.Measurement, bucket models.AggregationBucket, location *time.Location) []*models.MeasurementAggregate {
+	var aggregates []*models.MeasurementAggregate
+	var current *models.MeasurementAggregate
+	var sum float64
+	for _, m := range measurements {
+		start := bucketStart(m.Timestamp, bucket, location)
+		if current == nil || !current.Bucket.Equal(start) {
+			current = &models.MeasurementAggregate{Bucket: start, Min: m.Value, Max: m.Value}
+			aggregates = append(aggregates, current)
+			sum = 0
+		}
+		current.Count++
+		sum += float64(m.Value)
+		if m.Value < current.Min {
+			current.Min = m.Value
+		}
+		if m.Value > current.Max {
+			current.Max = m.Value
+		}
+		current.Avg = float32(sum / float64(current.Count))
+		current.Last = m.Value
+	}
+	return aggregates
+}
+
+// bucketStart truncates the time like date_trunc does, weeks start on Monday
+func bucketStart(t time.Time, bucket models.AggregationBucket, location *time.Location) time.Time {
+	local := t.In(location)
+	switch bucket {
+	case models.AggregationBucketWeek:
+		day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, location)
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	case models.AggregationBucketMonth:
+		return time.Date(local.Year(), local.Month(), 1, 0, 0, 0, 0, location)
+	}
+	return time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, location)
+}
+
+// profileSubject describes the child for derived percentiles, sex and birth date stay unknown without a profile
+func profileSubject(profile *models.BabyProfile) growthSubject {
+	var subject growthSubject
+	if sex, ok := profileSex(profile); ok {
+		subject.sexes = []growth.Sex{sex}
+	}
+	if profile != nil && profile.BirthDate != nil {
+		subject.birthDate = *profile.BirthDate
+		subject.gestationalAgeDays = profile.GestationalAgeDays
+	}
+	return subject
+}
@@ -0,0 +1,76 @@
+package services
+
+import (
+	"github.com/stretchr/testify/assert"
+	"little-diary-measurement-service/src/growth"
+	"little-diary-measurement-service/src/models"
+	"testing"
+	"time"
+)
+
+func TestDeriveMeasurements(t *testing.T) {
+	birth := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := birth.AddDate(0, 6, 0)
+	source := []*models.Measurement{
+		{Uuid: "h1", Type: models.MeasurementTypeHeight, Timestamp: at.Add(-48 * time.Hour), Value: 66},
+		{Uuid: "w1", Type: models.MeasurementTypeWeight, Timestamp: at, Value: 7900},
+		{Uuid: "h2", Type: models.MeasurementTypeHeight, Timestamp: at.Add(24 * time.Hour), Value: 67},
+		{Uuid: "w2", Type: models.MeasurementTypeWeight, Timestamp: at.AddDate(0, 0, 10), Value: 8000},
+		{Uuid: "w3", Type: models.MeasurementTypeWeight, Timestamp: at.AddDate(0, 0, 1), Value: 12000, Suspect: true},
+	}
+	subject := growthSubject{birthDate: birth, sexes: []growth.Sex{growth.SexMale}}
+	types := []models.MeasurementType{models.MeasurementTypeWeightForLength, models.MeasurementTypeBMI}
+
+	derived := deriveMeasurements(source, types, models.MeasurementFilter{}, subject)
+	if assert.Len(t, derived, 2, "weights without a height nearby and suspect values are skipped") {
+		wfl, bmi := derived[0], derived[1]
+		assert.Equal(t, []models.MeasurementUUID{"w1", "h2"}, wfl.DerivedFrom, "the closest height is paired")
+		assert.Equal(t, float32(7900), wfl.Value, "weight-for-length is the weight")
+		assert.Equal(t, at, wfl.Timestamp)
+		if assert.NotNil(t, wfl.Percentile) {
+			reference, _ := growth.LookupWeightForLength(growth.SexMale, 67)
+			assert.InDelta(t, reference.Percentile(7.9), *wfl.Percentile, 1e-9)
+		}
+		assert.InDelta(t, 7.9/(0.67*0.67), bmi.Value, 1e-4)
+		assert.Nil(t, bmi.Percentile, "BMI-for-age is screened from two years")
+		assert.NotEqual(t, wfl.Uuid, bmi.Uuid)
+		assert.Equal(t, wfl.Uuid, deriveMeasurements(source, types, models.MeasurementFilter{}, subject)[0].Uuid, "uuid is stable")
+	}
+
+	from := at.Add(time.Hour)
+	assert.Empty(t, deriveMeasurements(source, types, models.MeasurementFilter{From: &from}, subject), "weights outside of the period are skipped")
+
+	older := growthSubject{birthDate: birth.AddDate(-2, 0, 0), sexes: []growth.Sex{growth.SexMale}}
+	derived = deriveMeasurements(source, types, models.MeasurementFilter{}, older)
+	if assert.Len(t, derived, 2) {
+		assert.Nil(t, derived[0].Percentile, "weight-for-length is screened before two years")
+		assert.NotNil(t, derived[1].Percentile)
+	}
+
+	derived = deriveMeasurements(source, types, models.MeasurementFilter{}, growthSubject{sexes: crossingSexes})
+	if assert.Len(t, derived, 2) {
+		assert.Nil(t, derived[0].Percentile, "percentiles need known sex")
+	}
+}
+
+func TestAggregateMeasurements(t *testing.T) {
+	location, _ := time.LoadLocation("Europe/Prague")
+	measurements := []*models.Measurement{
+		{Timestamp: time.Date(2020, 3, 1, 23, 30, 0, 0, time.UTC), Value: 16},
+		{Timestamp: time.Date(2020, 3, 2, 8, 0, 0, 0, time.UTC), Value: 18},
+		{Timestamp: time.Date(2020, 3, 4, 8, 0, 0, 0, time.UTC), Value: 17},
+	}
+	aggregates := aggregateMeasurements(measurements, models.AggregationBucketDay, location)
+	if assert.Len(t, aggregates, 2) {
+		assert.Equal(t, time.Date(2020, 3, 2, 0, 0, 0, 0, location), aggregates[0].Bucket, "late evening UTC is the next day in Prague")
+		assert.Equal(t, 2, aggregates[0].Count)
+		assert.InDelta(t, 17, aggregates[0].Avg, 1e-6)
+		assert.Equal(t, float32(18), aggregates[0].Last)
+	}
+	aggregates = aggregateMeasurements(measurements, models.AggregationBucketWeek, location)
+	if assert.Len(t, aggregates, 1) {
+		assert.Equal(t, time.Date(2020, 3, 2, 0, 0, 0, 0, location), aggregates[0].Bucket, "weeks start on Monday")
+		assert.Equal(t, float32(16), aggregates[0].Min)
+		assert.Equal(t, float32(17), aggregates[0].Last)
+	}
+}
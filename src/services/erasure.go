@@ -0,0 +1,66 @@
+package services
+
+import (
+	"github.com/google/uuid"
+	"little-diary-measurement-service/src/dto"
+	"little-diary-measurement-service/src/errors"
+	"little-diary-measurement-service/src/models"
+)
+
+// familyInboxSource names the family service in the inbox of consumed events
+const familyInboxSource = "family"
+
+type erasureDAO interface {
+	EraseTarget(receipt *models.ErasureReceipt, inbox *models.InboxEvent) (bool, error)
+	GetReceiptByEventUuid(eventUuid string) (*models.ErasureReceipt, error)
+}
+
+// ErasureService hard-deletes all data of a target when the family service deletes the baby
+type ErasureService struct {
+	dao erasureDAO
+}
+
+func NewErasureService(dao erasureDAO) *ErasureService {
+	return &ErasureService{dao}
+}
+
+// ConsumeBabyDeleted erases the deleted baby once per event, a redelivered event returns the receipt of the first one
+func (s *ErasureService) ConsumeBabyDeleted(event dto.FamilyEvent) (*models.ErasureReceipt, error) {
+	if _, err := uuid.Parse(event.EventUuid); err != nil {
+		return nil, &errors.ValidationError{S: "baby deleted event without event uuid"}
+	}
+	receipt, err := newErasureReceipt(event.BabyUuid, models.ErasureRequestedByFamilyEvent)
+	if err != nil {
+		return nil, err
+	}
+	receipt.EventUuid = event.EventUuid
+	erased, err := s.dao.EraseTarget(receipt, &models.InboxEvent{EventUuid: event.EventUuid, Source: familyInboxSource, Type: event.Type})
+	if err != nil {
+		return nil, err
+	}
+	if !erased {
+		return s.dao.GetReceiptByEventUuid(event.EventUuid)
+	}
+	return receipt, nil
+}
+
+// EraseTarget erases the target on request of a service caller, erasing it again finds nothing to delete
+func (s *ErasureService) EraseTarget(targetUuid string) (*models.ErasureReceipt, error) {
+	receipt, err := newErasureReceipt(targetUuid, models.ErasureRequestedByApi)
+	if err != nil {
+		return nil, err
+	}
+	_, err = s.dao.EraseTarget(receipt, nil)
+	return receipt, err
+}
+
+func newErasureReceipt(targetUuid string, requestedBy models.ErasureRequester) (*models.ErasureReceipt, error) {
+	if _, err := uuid.Parse(targetUuid); err != nil {
+		return nil, &errors.ValidationError{S: "target uuid must be a uuid"}
+	}
+	return &models.ErasureReceipt{
+		Uuid:        models.ErasureUUID(uuid.New().String()),
+		TargetUuid:  models.TargetUUID(targetUuid),
+		RequestedBy: requestedBy,
+	}, nil
+}
@@ -0,0 +1,74 @@
+package services
+
+import (
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"little-diary-measurement-service/src/dto"
+	"little-diary-measurement-service/src/errors"
+	"little-diary-measurement-service/src/models"
+	"testing"
+)
+
+type mockErasureDAO struct {
+	inbox    map[string]bool
+	receipts []*models.ErasureReceipt
+}
+
+func (m *mockErasureDAO) EraseTarget(receipt *models.ErasureReceipt, inbox *models.InboxEvent) (bool, error) {
+	if inbox != nil {
+		if m.inbox[inbox.Source+inbox.EventUuid] {
+			return false, nil
+		}
+		m.inbox[inbox.Source+inbox.EventUuid] = true
+	}
+	receipt.MeasurementsDeleted = 3
+	m.receipts = append(m.receipts, receipt)
+	return true, nil
+}
+
+func (m *mockErasureDAO) GetReceiptByEventUuid(eventUuid string) (*models.ErasureReceipt, error) {
+	for _, receipt := range m.receipts {
+		if receipt.EventUuid == eventUuid {
+			return receipt, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func TestErasureService_ConsumeBabyDeleted(t *testing.T) {
+	dao := &mockErasureDAO{inbox: map[string]bool{}}
+	s := NewErasureService(dao)
+	event := dto.FamilyEvent{Type: dto.FamilyEventBabyDeleted, EventUuid: "0b6f4c1e-3f0a-4c55-9a9e-5d1f2e3a4b5c", BabyUuid: tUuid}
+
+	receipt, err := s.ConsumeBabyDeleted(event)
+	if assert.Nil(t, err) {
+		assert.Equal(t, models.TargetUUID(tUuid), receipt.TargetUuid)
+		assert.Equal(t, models.ErasureRequestedByFamilyEvent, receipt.RequestedBy)
+		assert.Equal(t, event.EventUuid, receipt.EventUuid)
+		assert.Equal(t, int64(3), receipt.MeasurementsDeleted)
+	}
+
+	again, err := s.ConsumeBabyDeleted(event)
+	if assert.Nil(t, err, "redelivered event is not an error") {
+		assert.Equal(t, receipt.Uuid, again.Uuid)
+		assert.Len(t, dao.receipts, 1, "redelivered event erases nothing")
+	}
+
+	_, err = s.ConsumeBabyDeleted(dto.FamilyEvent{Type: dto.FamilyEventBabyDeleted, BabyUuid: tUuid})
+	assert.IsType(t, &errors.ValidationError{}, err)
+	_, err = s.ConsumeBabyDeleted(dto.FamilyEvent{Type: dto.FamilyEventBabyDeleted, EventUuid: event.EventUuid, BabyUuid: "baby"})
+	assert.IsType(t, &errors.ValidationError{}, err)
+}
+
+func TestErasureService_EraseTarget(t *testing.T) {
+	dao := &mockErasureDAO{inbox: map[string]bool{}}
+	s := NewErasureService(dao)
+
+	receipt, err := s.EraseTarget(tUuid)
+	if assert.Nil(t, err) {
+		assert.Equal(t, models.ErasureRequestedByApi, receipt.RequestedBy)
+		assert.Empty(t, receipt.EventUuid)
+	}
+	_, err = s.EraseTarget("target")
+	assert.IsType(t, &errors.ValidationError{}, err)
+}
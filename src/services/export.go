@@ -0,0 +1,308 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/jinzhu/gorm"
+	"little-diary-measurement-service/src/common"
+	"little-diary-measurement-service/src/errors"
+	"little-diary-measurement-service/src/models"
+	"strconv"
+	"time"
+)
+
+const (
+	// a worker that has not finished the export by then is assumed dead
+	exportLease        = 10 * time.Minute
+	exportErrorLength  = 500
+	exportDownloadPath = "/api/v1/exports/%s/download?expires=%d&signature=%s"
+)
+
+var exportCsvHeader = []string{
+	"uuid", "target_uuid", "type", "value", "timestamp", "source", "is_birth", "suspect", "suspect_reason", "confirmed",
+	"created_at", "updated_at",
+}
+
+type exportDAO interface {
+	GetByExportUuid(exportUuid models.ExportUUID) (*models.ExportJob, error)
+	GetActiveExport(userUuid string) (*models.ExportJob, error)
+	GetArchive(exportUuid models.ExportUUID) ([]byte, error)
+	CreateExport(job *models.ExportJob) error
+	ClaimExport(lease time.Duration, now time.Time) (*models.ExportJob, error)
+	FinishExport(job *models.ExportJob) (bool, error)
+	DeleteExpiredExports(now time.Time) (int64, error)
+}
+
+type exportMeasurementDAO interface {
+	GetMeasurementsByTargetUuid(targetUuid models.TargetUUID) ([]*models.Measurement, error)
+}
+
+// ExportService takes requests for copies of the user's data, users see only their own exports
+type ExportService struct {
+	dao            exportDAO
+	serviceLocator *common.ServiceLocator
+}
+
+func NewExportService(dao exportDAO, locator *common.ServiceLocator) *ExportService {
+	return &ExportService{dao, locator}
+}
+
+// Request queues export of every target the family service lists for the user with read permission.
+// The targets are fixed now, a user asking again while an export is waiting or running gets that export
+func (s *ExportService) Request(userUuid string) (*models.ExportJob, error) {
+	active, err := s.dao.GetActiveExport(userUuid)
+	if err == nil {
+		return active, nil
+	}
+	if !gorm.IsRecordNotFoundError(err) {
+		return nil, err
+	}
+	if s.serviceLocator.TargetLister == nil {
+		return nil, fmt.Errorf("target listing is not configured")
+	}
+	accessible, err := s.serviceLocator.TargetLister.ListTargets(userUuid)
+	if err != nil {
+		return nil, err
+	}
+	seen := map[models.TargetUUID]bool{}
+	targets := []models.TargetUUID{}
+	for _, target := range accessible {
+		if seen[target.TargetUuid] || !target.Permissions.Allows(models.PermissionRead) {
+			continue
+		}
+		seen[target.TargetUuid] = true
+		targets = append(targets, target.TargetUuid)
+	}
+	job := &models.ExportJob{
+		Uuid:        models.ExportUUID(uuid.New().String()),
+		UserUuid:    userUuid,
+		Status:      models.ExportPending,
+		TargetUuids: targets,
+	}
+	return job, s.dao.CreateExport(job)
+}
+
+// Get returns the export of the user, exports of other users are not found
+func (s *ExportService) Get(exportUuid string, userUuid string) (*models.ExportJob, error) {
+	if _, err := uuid.Parse(exportUuid); err != nil {
+		return nil, &errors.ValidationError{S: "export uuid must be a uuid"}
+	}
+	job, err := s.dao.GetByExportUuid(models.ExportUUID(exportUuid))
+	if err != nil {
+		return nil, err
+	}
+	if job.UserUuid != userUuid {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return job, nil
+}
+
+// DownloadLink signs a link to the archive of the completed export, the link works for the configured time
+// and never longer than the export is kept
+func (s *ExportService) DownloadLink(job *models.ExportJob, now time.Time) (string, time.Time, error) {
+	secret, ttl, err := s.linkConfig()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	expires := now.Add(ttl).Truncate(time.Second)
+	if job.ExpiresAt != nil && job.ExpiresAt.Before(expires) {
+		expires = job.ExpiresAt.Truncate(time.Second)
+	}
+	signature := exportSignature(secret, string(job.Uuid), expires.Unix())
+	return fmt.Sprintf(exportDownloadPath, job.Uuid, expires.Unix(), signature), expires, nil
+}
+
+// Download returns the archive the signed link points to
+func (s *ExportService) Download(exportUuid string, expires string, signature string, now time.Time) ([]byte, error) {
+	secret, _, err := s.linkConfig()
+	if err != nil {
+		return nil, err
+	}
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return nil, &errors.ForbiddenError{S: "download link is not valid"}
+	}
+	given, err := hex.DecodeString(signature)
+	if err != nil {
+		return nil, &errors.ForbiddenError{S: "download link is not valid"}
+	}
+	expected, _ := hex.DecodeString(exportSignature(secret, exportUuid, expiresAt))
+	if !hmac.Equal(given, expected) {
+		return nil, &errors.ForbiddenError{S: "download link is not valid"}
+	}
+	if now.Unix() > expiresAt {
+		return nil, &errors.ForbiddenError{S: "download link has expired"}
+	}
+	job, err := s.dao.GetByExportUuid(models.ExportUUID(exportUuid))
+	if err != nil {
+		return nil, err
+	}
+	if job.Status != models.ExportCompleted {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return s.dao.GetArchive(job.Uuid)
+}
+
+func (s *ExportService) linkConfig() (string, time.Duration, error) {
+	if s.serviceLocator.ExportConfig == nil || s.serviceLocator.ExportConfig.GetExportLinkSecret() == "" {
+		return "", 0, fmt.Errorf("export download links are not configured")
+	}
+	return s.serviceLocator.ExportConfig.GetExportLinkSecret(), s.serviceLocator.ExportConfig.GetExportLinkTTL(), nil
+}
+
+// exportSignature is hex encoded HMAC-SHA256 of the export uuid, a dot and the link expiry in unix seconds
+func exportSignature(secret string, exportUuid string, expires int64) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(exportUuid))
+	mac.Write([]byte("."))
+	mac.Write([]byte(strconv.FormatInt(expires, 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// ExportWorker builds archives of requested exports one at a time, finished exports are kept for the retention
+type ExportWorker struct {
+	dao            exportDAO
+	measurementDao exportMeasurementDAO
+	retention      time.Duration
+}
+
+func NewExportWorker(dao exportDAO, measurementDao exportMeasurementDAO, retention time.Duration) *ExportWorker {
+	return &ExportWorker{dao, measurementDao, retention}
+}
+
+// RunOnce removes expired exports and builds the next one, false is returned when no export was waiting.
+// The archive is discarded when the export changed meanwhile, erasure of a target resets the export to be built again
+func (w *ExportWorker) RunOnce() (bool, error) {
+	now := time.Now()
+	if _, err := w.dao.DeleteExpiredExports(now); err != nil {
+		return false, err
+	}
+	job, err := w.dao.ClaimExport(exportLease, now)
+	if err != nil || job == nil {
+		return false, err
+	}
+	archive, count, err := w.buildArchive(job, now)
+	if err != nil {
+		job.Status = models.ExportFailed
+		job.Error = truncate(err.Error(), exportErrorLength)
+	} else {
+		job.Status = models.ExportCompleted
+		job.Archive = archive
+		job.MeasurementCount = count
+	}
+	completedAt := time.Now()
+	expiresAt := completedAt.Add(w.retention)
+	job.CompletedAt = &completedAt
+	job.ExpiresAt = &expiresAt
+	job.LeaseUntil = nil
+	_, err = w.dao.FinishExport(job)
+	return true, err
+}
+
+// exportDocument is measurements.json of the archive
+type exportDocument struct {
+	ExportUuid   models.ExportUUID    `json:"export_uuid"`
+	UserUuid     string               `json:"user_uuid"`
+	ExportedAt   time.Time            `json:"exported_at"`
+	TargetUuids  []models.TargetUUID  `json:"target_uuids"`
+	Measurements []*exportMeasurement `json:"measurements"`
+}
+
+type exportMeasurement struct {
+	Uuid          models.MeasurementUUID   `json:"uuid"`
+	TargetUuid    models.TargetUUID        `json:"target_uuid"`
+	Type          models.MeasurementType   `json:"type"`
+	Value         float32                  `json:"value"`
+	Timestamp     time.Time                `json:"timestamp"`
+	Source        models.MeasurementSource `json:"source"`
+	IsBirth       bool                     `json:"is_birth"`
+	Suspect       bool                     `json:"suspect"`
+	SuspectReason string                   `json:"suspect_reason"`
+	Confirmed     bool                     `json:"confirmed"`
+	CreatedAt     time.Time                `json:"created_at"`
+	UpdatedAt     time.Time                `json:"updated_at"`
+}
+
+// buildArchive zips measurements of the export targets as measurements.json and measurements.csv,
+// both hold the same measurements ordered by target and date. The service keeps no revisions of measurements,
+// the current state is all there is to export
+func (w *ExportWorker) buildArchive(job *models.ExportJob, now time.Time) ([]byte, int, error) {
+	document := &exportDocument{
+		ExportUuid:   job.Uuid,
+		UserUuid:     job.UserUuid,
+		ExportedAt:   now.UTC(),
+		TargetUuids:  job.TargetUuids,
+		Measurements: []*exportMeasurement{},
+	}
+	for _, target := range document.TargetUuids {
+		measurements, err := w.measurementDao.GetMeasurementsByTargetUuid(target)
+		if err != nil {
+			return nil, 0, err
+		}
+		for _, m := range measurements {
+			document.Measurements = append(document.Measurements, &exportMeasurement{
+				Uuid:          m.Uuid,
+				TargetUuid:    m.TargetUuid,
+				Type:          m.Type,
+				Value:         m.Value,
+				Timestamp:     m.Timestamp.UTC(),
+				Source:        m.Source,
+				IsBirth:       m.IsBirth,
+				Suspect:       m.Suspect,
+				SuspectReason: m.SuspectReason,
+				Confirmed:     m.Confirmed,
+				CreatedAt:     m.CreatedAt.UTC(),
+				UpdatedAt:     m.UpdatedAt.UTC(),
+			})
+		}
+	}
+
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	jsonFile, err := archive.Create("measurements.json")
+	if err != nil {
+		return nil, 0, err
+	}
+	encoder := json.NewEncoder(jsonFile)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(document); err != nil {
+		return nil, 0, err
+	}
+	csvFile, err := archive.Create("measurements.csv")
+	if err != nil {
+		return nil, 0, err
+	}
+	writer := csv.NewWriter(csvFile)
+	writer.Write(exportCsvHeader)
+	for _, m := range document.Measurements {
+		writer.Write([]string{
+			string(m.Uuid),
+			string(m.TargetUuid),
+			string(m.Type),
+			strconv.FormatFloat(float64(m.Value), 'f', -1, 32),
+			m.Timestamp.Format(time.RFC3339),
+			string(m.Source),
+			strconv.FormatBool(m.IsBirth),
+			strconv.FormatBool(m.Suspect),
+			m.SuspectReason,
+			strconv.FormatBool(m.Confirmed),
+			m.CreatedAt.Format(time.RFC3339),
+			m.UpdatedAt.Format(time.RFC3339),
+		})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return nil, 0, err
+	}
+	if err := archive.Close(); err != nil {
+		return nil, 0, err
+	}
+	return buf.Bytes(), len(document.Measurements), nil
+}
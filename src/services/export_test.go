@@ -0,0 +1,282 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"little-diary-measurement-service/src/common"
+	"little-diary-measurement-service/src/errors"
+	"little-diary-measurement-service/src/models"
+	"little-diary-measurement-service/src/test_data"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+type mockExportDAO struct {
+	jobs []*models.ExportJob
+}
+
+func (m *mockExportDAO) GetByExportUuid(exportUuid models.ExportUUID) (*models.ExportJob, error) {
+	for _, job := range m.jobs {
+		if job.Uuid == exportUuid {
+			return job, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (m *mockExportDAO) GetActiveExport(userUuid string) (*models.ExportJob, error) {
+	for _, job := range m.jobs {
+		if job.UserUuid == userUuid && (job.Status == models.ExportPending || job.Status == models.ExportRunning) {
+			return job, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (m *mockExportDAO) GetArchive(exportUuid models.ExportUUID) ([]byte, error) {
+	job, err := m.GetByExportUuid(exportUuid)
+	if err != nil {
+		return nil, err
+	}
+	return job.Archive, nil
+}
+
+func (m *mockExportDAO) CreateExport(job *models.ExportJob) error {
+	m.jobs = append(m.jobs, job)
+	return nil
+}
+
+// ClaimExport returns a copy like the database does, the worker changes the stored export only by finishing it
+func (m *mockExportDAO) ClaimExport(lease time.Duration, now time.Time) (*models.ExportJob, error) {
+	for _, job := range m.jobs {
+		if job.Status == models.ExportPending {
+			until := now.Add(lease)
+			job.Status = models.ExportRunning
+			job.Attempt++
+			job.LeaseUntil = &until
+			claimed := *job
+			return &claimed, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *mockExportDAO) FinishExport(job *models.ExportJob) (bool, error) {
+	stored, err := m.GetByExportUuid(job.Uuid)
+	if err != nil || stored.Status != models.ExportRunning || stored.Attempt != job.Attempt {
+		return false, nil
+	}
+	*stored = *job
+	return true, nil
+}
+
+func (m *mockExportDAO) DeleteExpiredExports(now time.Time) (int64, error) {
+	var kept []*models.ExportJob
+	for _, job := range m.jobs {
+		if job.ExpiresAt == nil || job.ExpiresAt.After(now) {
+			kept = append(kept, job)
+		}
+	}
+	deleted := len(m.jobs) - len(kept)
+	m.jobs = kept
+	return int64(deleted), nil
+}
+
+type mockExportConfig struct{}
+
+func (m *mockExportConfig) GetExportLinkSecret() string {
+	return "secret"
+}
+
+func (m *mockExportConfig) GetExportLinkTTL() time.Duration {
+	return 15 * time.Minute
+}
+
+func TestExportService_Request(t *testing.T) {
+	lister := new(test_data.MockTargetLister)
+	lister.On("ListTargets", "user").Return([]*models.AccessibleTarget{
+		{TargetUuid: "second", Permissions: models.Permissions{models.PermissionRead}},
+		{TargetUuid: "first", Permissions: test_data.ParentPermissions},
+		{TargetUuid: "second", Permissions: models.Permissions{models.PermissionRead}},
+		{TargetUuid: "hidden", Permissions: models.Permissions{}},
+	}, nil)
+	dao := &mockExportDAO{}
+	s := NewExportService(dao, &common.ServiceLocator{TargetLister: lister})
+
+	job, err := s.Request("user")
+	if assert.Nil(t, err) {
+		assert.Equal(t, models.ExportPending, job.Status)
+		assert.Equal(t, "user", job.UserUuid)
+		assert.Equal(t, []models.TargetUUID{"second", "first"}, job.TargetUuids, "readable targets once in listing order")
+	}
+
+	again, err := s.Request("user")
+	if assert.Nil(t, err) {
+		assert.Equal(t, job.Uuid, again.Uuid, "waiting export is returned")
+		assert.Len(t, dao.jobs, 1)
+	}
+
+	_, err = NewExportService(&mockExportDAO{}, &common.ServiceLocator{}).Request("user")
+	assert.NotNil(t, err)
+}
+
+func TestExportService_Get(t *testing.T) {
+	dao := &mockExportDAO{jobs: []*models.ExportJob{{Uuid: models.ExportUUID(tUuid), UserUuid: "user"}}}
+	s := NewExportService(dao, &common.ServiceLocator{})
+
+	job, err := s.Get(tUuid, "user")
+	if assert.Nil(t, err) {
+		assert.Equal(t, models.ExportUUID(tUuid), job.Uuid)
+	}
+
+	_, err = s.Get(tUuid, "other")
+	assert.True(t, gorm.IsRecordNotFoundError(err), "exports of other users are not found")
+
+	_, err = s.Get("not-a-uuid", "user")
+	assert.IsType(t, &errors.ValidationError{}, err)
+}
+
+func TestExportService_Download(t *testing.T) {
+	now := time.Date(2020, 3, 1, 8, 0, 0, 0, time.UTC)
+	expiresAt := now.Add(5 * time.Minute)
+	job := &models.ExportJob{
+		Uuid:      models.ExportUUID(tUuid),
+		UserUuid:  "user",
+		Status:    models.ExportCompleted,
+		ExpiresAt: &expiresAt,
+		Archive:   []byte("zip"),
+	}
+	s := NewExportService(&mockExportDAO{jobs: []*models.ExportJob{job}}, &common.ServiceLocator{ExportConfig: &mockExportConfig{}})
+
+	link, linkExpiresAt, err := s.DownloadLink(job, now)
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, expiresAt, linkExpiresAt, "link does not outlive the export")
+	assert.True(t, strings.HasPrefix(link, "/api/v1/exports/"+tUuid+"/download?"))
+	parsed, _ := url.Parse(link)
+	expires, signature := parsed.Query().Get("expires"), parsed.Query().Get("signature")
+
+	archive, err := s.Download(tUuid, expires, signature, now)
+	if assert.Nil(t, err) {
+		assert.Equal(t, []byte("zip"), archive)
+	}
+
+	tests := []struct {
+		name       string
+		exportUuid string
+		expires    string
+		signature  string
+		at         time.Time
+	}{
+		{"expired", tUuid, expires, signature, now.Add(10 * time.Minute)},
+		{"tampered expiry", tUuid, "9999999999", signature, now},
+		{"other export", "8d1e5f6a-2c3d-4e5f-9a01-b2c3d4e5f6a7", expires, signature, now},
+		{"bad signature", tUuid, expires, "zz", now},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.Download(tt.exportUuid, tt.expires, tt.signature, tt.at)
+			assert.IsType(t, &errors.ForbiddenError{}, err)
+		})
+	}
+
+	_, _, err = NewExportService(&mockExportDAO{}, &common.ServiceLocator{}).DownloadLink(job, now)
+	assert.NotNil(t, err, "links are not signed without a secret")
+}
+
+func TestExportWorker_RunOnce(t *testing.T) {
+	at := time.Date(2020, 3, 1, 8, 0, 0, 0, time.UTC)
+	measurementDao := &mockMeasurementDAO{records: []*models.Measurement{
+		{Uuid: "m1", TargetUuid: "first", Type: models.MeasurementTypeWeight, Timestamp: at, Value: 3500.5, IsBirth: true},
+		{Uuid: "m2", TargetUuid: "second", Type: models.MeasurementTypeHeight, Timestamp: at, Value: 52, SuspectReason: "jump, \"large\""},
+		{Uuid: "m3", TargetUuid: "other", Type: models.MeasurementTypeHeight, Timestamp: at, Value: 60},
+	}}
+	job := &models.ExportJob{
+		Uuid:        models.ExportUUID(tUuid),
+		UserUuid:    "user",
+		Status:      models.ExportPending,
+		TargetUuids: []models.TargetUUID{"first", "second"},
+	}
+	dao := &mockExportDAO{jobs: []*models.ExportJob{job}}
+	w := NewExportWorker(dao, measurementDao, 24*time.Hour)
+
+	built, err := w.RunOnce()
+	if !assert.Nil(t, err) || !assert.True(t, built) {
+		return
+	}
+	assert.Equal(t, models.ExportCompleted, job.Status)
+	assert.Equal(t, 2, job.MeasurementCount)
+	assert.Nil(t, job.LeaseUntil)
+	if assert.NotNil(t, job.ExpiresAt) {
+		assert.WithinDuration(t, time.Now().Add(24*time.Hour), *job.ExpiresAt, time.Minute)
+	}
+
+	reader, err := zip.NewReader(bytes.NewReader(job.Archive), int64(len(job.Archive)))
+	if !assert.Nil(t, err) || !assert.Len(t, reader.File, 2) {
+		return
+	}
+	files := map[string][]byte{}
+	for _, f := range reader.File {
+		r, _ := f.Open()
+		files[f.Name], _ = ioutil.ReadAll(r)
+		r.Close()
+	}
+
+	var document exportDocument
+	if assert.Nil(t, json.Unmarshal(files["measurements.json"], &document)) {
+		assert.Equal(t, []models.TargetUUID{"first", "second"}, document.TargetUuids)
+		if assert.Len(t, document.Measurements, 2) {
+			assert.Equal(t, models.MeasurementUUID("m1"), document.Measurements[0].Uuid)
+			assert.True(t, document.Measurements[0].IsBirth)
+		}
+	}
+	rows, err := csv.NewReader(bytes.NewReader(files["measurements.csv"])).ReadAll()
+	if assert.Nil(t, err) && assert.Len(t, rows, 3) {
+		assert.Equal(t, exportCsvHeader, rows[0])
+		assert.Equal(t, []string{"m1", "first", "WEIGHT", "3500.5", "2020-03-01T08:00:00Z"}, rows[1][:5])
+		assert.Equal(t, "jump, \"large\"", rows[2][8])
+	}
+
+	built, err = w.RunOnce()
+	assert.Nil(t, err)
+	assert.False(t, built, "nothing is waiting")
+}
+
+// erasingMeasurementDAO resets the export like erasure of one of its targets does while the archive is being built
+type erasingMeasurementDAO struct {
+	mockMeasurementDAO
+	job *models.ExportJob
+}
+
+func (m *erasingMeasurementDAO) GetMeasurementsByTargetUuid(targetUuid models.TargetUUID) ([]*models.Measurement, error) {
+	m.job.Status = models.ExportPending
+	m.job.TargetUuids = []models.TargetUUID{}
+	return m.mockMeasurementDAO.GetMeasurementsByTargetUuid(targetUuid)
+}
+
+func TestExportWorker_RunOnceDiscardsResetExport(t *testing.T) {
+	job := &models.ExportJob{
+		Uuid:        models.ExportUUID(tUuid),
+		UserUuid:    "user",
+		Status:      models.ExportPending,
+		TargetUuids: []models.TargetUUID{"erased"},
+	}
+	measurementDao := &erasingMeasurementDAO{job: job, mockMeasurementDAO: mockMeasurementDAO{records: []*models.Measurement{
+		{Uuid: "m1", TargetUuid: "erased", Type: models.MeasurementTypeWeight, Value: 3500},
+	}}}
+	w := NewExportWorker(&mockExportDAO{jobs: []*models.ExportJob{job}}, measurementDao, time.Hour)
+
+	built, err := w.RunOnce()
+	assert.Nil(t, err)
+	assert.True(t, built)
+	assert.Equal(t, models.ExportPending, job.Status, "reset export waits to be built again")
+	assert.Nil(t, job.Archive, "archive with erased measurements is discarded")
+}
@@ -0,0 +1,128 @@
+package services
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/jinzhu/gorm"
+	"little-diary-measurement-service/src/hl7"
+	"little-diary-measurement-service/src/models"
+	"log"
+	"strings"
+	"time"
+)
+
+type patientMappingDAO interface {
+	GetByPatientIdentifier(assigningAuthority string, patientId string) (*models.PatientMapping, error)
+}
+
+type observationMapping struct {
+	measurementType models.MeasurementType
+	// factors converting observation units to stored units (grams and centimeters)
+	units map[string]float32
+}
+
+var weightUnits = map[string]float32{"g": 1, "kg": 1000, "[lb_av]": 453.59237, "lb": 453.59237}
+var lengthUnits = map[string]float32{"cm": 1, "mm": 0.1, "m": 100, "[in_i]": 2.54, "in": 2.54}
+
+// LOINC codes of supported observations
+var observationMappings = map[string]observationMapping{
+	"29463-7": {models.MeasurementTypeWeight, weightUnits},
+	"3141-9":  {models.MeasurementTypeWeight, weightUnits},
+	"8302-2":  {models.MeasurementTypeHeight, lengthUnits},
+	"8306-3":  {models.MeasurementTypeHeight, lengthUnits},
+	"8305-5":  {models.MeasurementTypeHeight, lengthUnits},
+	"9843-4":  {models.MeasurementTypeHeadCircumference, lengthUnits},
+	"8287-5":  {models.MeasurementTypeHeadCircumference, lengthUnits},
+}
+
+// measurements created from the same message and OBX get the same UUID, so retransmissions are idempotent
+var hl7MeasurementNamespace = uuid.MustParse("5b0f2c59-3d0e-4b8a-9a55-4f4d3c8f1e27")
+
+type Hl7IngestionService struct {
+	dao        measurementDAO
+	mappingDao patientMappingDAO
+}
+
+func NewHl7IngestionService(dao measurementDAO, mappingDao patientMappingDAO) *Hl7IngestionService {
+	return &Hl7IngestionService{dao, mappingDao}
+}
+
+// HandleMessage ingests raw ER7 message and returns acknowledgement to send back to the clinic system
+func (s *Hl7IngestionService) HandleMessage(raw string) (string, hl7.AckCode) {
+	message, err := hl7.Parse(raw)
+	if err != nil {
+		log.Println(err)
+		return hl7.BuildAck(nil, hl7.AckCodeReject, err.Error(), time.Now()), hl7.AckCodeReject
+	}
+	measurements, err := s.Ingest(message)
+	if err != nil {
+		log.Println(err)
+		code := hl7.AckCodeError
+		if hl7Err, ok := err.(*hl7.Error); ok {
+			code = hl7Err.Code
+		}
+		return hl7.BuildAck(message, code, err.Error(), time.Now()), code
+	}
+	text := fmt.Sprintf("%d measurements stored", len(measurements))
+	return hl7.BuildAck(message, hl7.AckCodeAccept, text, time.Now()), hl7.AckCodeAccept
+}
+
+func (s *Hl7IngestionService) Ingest(message *hl7.Message) ([]*models.Measurement, error) {
+	oru, err := hl7.ReadOruR01(message)
+	if err != nil {
+		return nil, err
+	}
+	targetUuid, err := s.findTarget(oru.PatientIdentifiers)
+	if err != nil {
+		return nil, err
+	}
+
+	// every OBX is checked before anything is stored, a rejected message leaves no measurements behind
+	var result []*models.Measurement
+	for _, observation := range oru.Observations {
+		mapping, ok := observationMappings[observation.Code]
+		if !ok {
+			continue
+		}
+		factor, ok := mapping.units[strings.ToLower(observation.Units)]
+		if !ok {
+			return nil, &hl7.Error{Code: hl7.AckCodeError,
+				S: fmt.Sprintf("OBX %s has unsupported units %q", observation.SetId, observation.Units)}
+		}
+		measurementUuid := models.MeasurementUUID(uuid.NewSHA1(hl7MeasurementNamespace,
+			[]byte(strings.Join([]string{oru.SendingFacility, oru.ControlId, observation.SetId}, "|"))).String())
+
+		measurement, err := s.dao.GetByMeasurementUuid(measurementUuid)
+		if err != nil {
+			if !gorm.IsRecordNotFoundError(err) {
+				return nil, err
+			}
+			measurement = &models.Measurement{
+				Uuid:       measurementUuid,
+				TargetUuid: targetUuid,
+				Type:       mapping.measurementType,
+				Source:     models.MeasurementSourceClinic,
+			}
+		}
+		measurement.Value = observation.Value * factor
+		measurement.Timestamp = observation.Timestamp
+		result = append(result, measurement)
+	}
+	if err := s.dao.SaveMeasurements(result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (s *Hl7IngestionService) findTarget(identifiers []hl7.PatientIdentifier) (models.TargetUUID, error) {
+	for _, identifier := range identifiers {
+		mapping, err := s.mappingDao.GetByPatientIdentifier(identifier.AssigningAuthority, identifier.Id)
+		if err == nil {
+			return mapping.TargetUuid, nil
+		}
+		if !gorm.IsRecordNotFoundError(err) {
+			return "", err
+		}
+	}
+	return "", &hl7.Error{Code: hl7.AckCodeError, S: "patient is not mapped to any target"}
+}
@@ -0,0 +1,53 @@
+package services
+
+import (
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"little-diary-measurement-service/src/hl7"
+	"little-diary-measurement-service/src/models"
+	"testing"
+)
+
+type mockPatientMappingDAO struct {
+	mappings []*models.PatientMapping
+}
+
+func (m *mockPatientMappingDAO) GetByPatientIdentifier(assigningAuthority string, patientId string) (*models.PatientMapping, error) {
+	for _, mapping := range m.mappings {
+		if mapping.AssigningAuthority == assigningAuthority && mapping.PatientId == patientId {
+			return mapping, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+const hl7MessageHeader = "MSH|^~\\&|LAB|CITY CLINIC|DIARY|LITTLE DIARY|20200401101500+0300||ORU^R01^ORU_R01|MSG00001|P|2.5.1\r" +
+	"PID|1||12345^^^CITYCLINIC^MR||Doe^Baby\r" +
+	"OBR|1|||29463-7^Body weight^LN|||20200401093000+0300\r"
+
+func TestHl7IngestionService_HandleMessage(t *testing.T) {
+	dao := &mockMeasurementDAO{}
+	s := NewHl7IngestionService(dao, &mockPatientMappingDAO{mappings: []*models.PatientMapping{
+		{AssigningAuthority: "CITYCLINIC", PatientId: "12345", TargetUuid: models.TargetUUID(tUuid)},
+	}})
+
+	_, code := s.HandleMessage(hl7MessageHeader +
+		"OBX|1|NM|29463-7^Body weight^LN||4.52|kg|||||F\r" +
+		"OBX|2|NM|8302-2^Body height^LN||54.5|ft|||||F\r")
+	assert.Equal(t, hl7.AckCodeError, code)
+	assert.Empty(t, dao.records, "nothing is stored from a rejected message")
+
+	message := hl7MessageHeader +
+		"OBX|1|NM|29463-7^Body weight^LN||4.52|kg|||||F\r" +
+		"OBX|2|NM|8302-2^Body height^LN||54.5|cm|||||F\r"
+	_, code = s.HandleMessage(message)
+	assert.Equal(t, hl7.AckCodeAccept, code)
+	if assert.Len(t, dao.records, 2) {
+		assert.Equal(t, float32(4520), dao.records[0].Value)
+		assert.Equal(t, models.MeasurementSourceClinic, dao.records[1].Source)
+	}
+
+	_, code = s.HandleMessage(message)
+	assert.Equal(t, hl7.AckCodeAccept, code)
+	assert.Len(t, dao.records, 2, "retransmission updates the same measurements")
+}
@@ -0,0 +1,120 @@
+package services
+
+import (
+	"fmt"
+	"little-diary-measurement-service/src/common"
+	"little-diary-measurement-service/src/errors"
+	"little-diary-measurement-service/src/growth"
+	"little-diary-measurement-service/src/models"
+	"time"
+)
+
+type InterpolationService struct {
+	dao            measurementDAO
+	serviceLocator *common.ServiceLocator
+}
+
+func NewInterpolationService(dao measurementDAO, locator *common.ServiceLocator) *InterpolationService {
+	return &InterpolationService{dao, locator}
+}
+
+// Interpolate estimates value of the type at the time or at the age of the target, exactly one of them is given.
+// Age needs birth date from the baby profile, known sex and birth date enable interpolation along percentiles
+func (s *InterpolationService) Interpolate(targetUuid string, t models.MeasurementType, at *time.Time, age *models.Age, userUuid string) (*models.Interpolation, error) {
+	if err := authorize(s.serviceLocator, userUuid, targetUuid, models.PermissionRead); err != nil {
+		return nil, err
+	}
+	profile := babyProfile(s.serviceLocator, models.TargetUUID(targetUuid))
+	if age != nil {
+		if profile == nil || profile.BirthDate == nil {
+			return nil, &errors.ValidationError{S: "age needs birth date, the baby profile does not have it"}
+		}
+		reached := age.Reached(*profile.BirthDate)
+		at = &reached
+	}
+	measurements, err := s.dao.GetMeasurementsByFilter(models.MeasurementFilter{
+		TargetUuid: models.TargetUUID(targetUuid),
+		Types:      []models.MeasurementType{t},
+	})
+	if err != nil {
+		return nil, err
+	}
+	interpolation, err := interpolate(measurements, t, *at, profileSubject(profile))
+	if err != nil {
+		return nil, err
+	}
+	attachAges(s.serviceLocator, []*models.Measurement{interpolation.Before, interpolation.After})
+	if profile != nil && profile.BirthDate != nil {
+		interpolation.Age = models.AgeAt(*profile.BirthDate, interpolation.Timestamp)
+	}
+	return interpolation, nil
+}
+
+// interpolate estimates the value at the time from the closest measurements on both sides of it, suspect values are skipped.
+// Values are not extrapolated, a time outside of the history is a validation error
+func interpolate(measurements []*models.Measurement, t models.MeasurementType, at time.Time, subject growthSubject) (*models.Interpolation, error) {
+	var before, after *models.Measurement
+	for _, m := range measurements {
+		if m.Suspect {
+			continue
+		}
+		if !m.Timestamp.After(at) && (before == nil || !m.Timestamp.Before(before.Timestamp)) {
+			before = m
+		}
+		if !m.Timestamp.Before(at) && (after == nil || m.Timestamp.Before(after.Timestamp)) {
+			after = m
+		}
+	}
+	if before == nil || after == nil {
+		return nil, &errors.ValidationError{S: fmt.Sprintf("no %s measurements on both sides of %s", t, at.Format(time.RFC3339))}
+	}
+	interpolation := &models.Interpolation{Type: t, Timestamp: at, Before: before, After: after}
+	if before.Timestamp.Equal(at) {
+		interpolation.After = before
+		interpolation.Value = before.Value
+		interpolation.Method = models.InterpolationMethodExact
+		return interpolation, nil
+	}
+	if after.Timestamp.Equal(at) {
+		interpolation.Before = after
+		interpolation.Value = after.Value
+		interpolation.Method = models.InterpolationMethodExact
+		return interpolation, nil
+	}
+	k := at.Sub(before.Timestamp).Seconds() / after.Timestamp.Sub(before.Timestamp).Seconds()
+	if value, ok := percentileInterpolation(t, before, after, at, k, subject); ok {
+		interpolation.Value = float32(value)
+		interpolation.Method = models.InterpolationMethodPercentile
+		return interpolation, nil
+	}
+	interpolation.Value = before.Value + (after.Value-before.Value)*float32(k)
+	interpolation.Method = models.InterpolationMethodLinear
+	return interpolation, nil
+}
+
+// percentileInterpolation moves the z-score linearly between the measurements and reads the value off the reference,
+// it follows the curve of growth instead of a straight line when sex and birth date are known
+func percentileInterpolation(t models.MeasurementType, before *models.Measurement, after *models.Measurement, at time.Time, k float64, subject growthSubject) (float64, bool) {
+	if len(subject.sexes) != 1 || subject.birthDate.IsZero() {
+		return 0, false
+	}
+	lookup := func(ts time.Time) (growth.LMS, bool) {
+		lms, _, ok := growth.LookupForAge(t, subject.sexes[0], ts.Sub(subject.birthDate).Hours()/24, subject.gestationalAgeDays)
+		return lms, ok
+	}
+	beforeLms, ok := lookup(before.Timestamp)
+	if !ok {
+		return 0, false
+	}
+	afterLms, ok := lookup(after.Timestamp)
+	if !ok {
+		return 0, false
+	}
+	atLms, ok := lookup(at)
+	if !ok {
+		return 0, false
+	}
+	beforeZ := beforeLms.ZScore(float64(before.Value))
+	afterZ := afterLms.ZScore(float64(after.Value))
+	return atLms.ValueAt(beforeZ + (afterZ-beforeZ)*k), true
+}
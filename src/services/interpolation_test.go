@@ -0,0 +1,49 @@
+package services
+
+import (
+	"github.com/stretchr/testify/assert"
+	"little-diary-measurement-service/src/growth"
+	"little-diary-measurement-service/src/models"
+	"testing"
+	"time"
+)
+
+func TestInterpolate(t *testing.T) {
+	birth := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	measurements := []*models.Measurement{
+		{Uuid: "m1", Timestamp: birth.AddDate(0, 0, 167), Value: 7200},
+		{Uuid: "m2", Timestamp: birth.AddDate(0, 0, 180), Value: 9900, Suspect: true},
+		{Uuid: "m3", Timestamp: birth.AddDate(0, 0, 204), Value: 7900},
+	}
+	at := birth.AddDate(0, 0, 183)
+	unknown := growthSubject{sexes: crossingSexes}
+
+	got, err := interpolate(measurements, models.MeasurementTypeWeight, at, unknown)
+	if assert.Nil(t, err) {
+		assert.Equal(t, models.InterpolationMethodLinear, got.Method)
+		assert.Equal(t, models.MeasurementUUID("m1"), got.Before.Uuid, "suspect values are skipped")
+		assert.Equal(t, models.MeasurementUUID("m3"), got.After.Uuid)
+		assert.InDelta(t, 7200+700*16.0/37, got.Value, 1e-3)
+	}
+
+	boy := growthSubject{birthDate: birth, sexes: []growth.Sex{growth.SexMale}}
+	got, err = interpolate(measurements, models.MeasurementTypeWeight, at, boy)
+	if assert.Nil(t, err) {
+		assert.Equal(t, models.InterpolationMethodPercentile, got.Method)
+		before, _ := growth.Lookup(models.MeasurementTypeWeight, growth.SexMale, 167)
+		after, _ := growth.Lookup(models.MeasurementTypeWeight, growth.SexMale, 204)
+		at, _ := growth.Lookup(models.MeasurementTypeWeight, growth.SexMale, 183)
+		from, to := before.ZScore(7200), after.ZScore(7900)
+		assert.InDelta(t, from+(to-from)*16/37, at.ZScore(float64(got.Value)), 1e-4, "the estimate moves evenly between the z-scores")
+	}
+
+	got, err = interpolate(measurements, models.MeasurementTypeWeight, measurements[2].Timestamp, boy)
+	if assert.Nil(t, err) {
+		assert.Equal(t, models.InterpolationMethodExact, got.Method)
+		assert.Equal(t, got.Before, got.After)
+		assert.Equal(t, float32(7900), got.Value)
+	}
+
+	_, err = interpolate(measurements, models.MeasurementTypeWeight, birth.AddDate(0, 0, 250), boy)
+	assert.NotNil(t, err, "values are not extrapolated")
+}
@@ -5,23 +5,31 @@ import (
 	"github.com/jinzhu/gorm"
 	"little-diary-measurement-service/src/common"
 	"little-diary-measurement-service/src/dto"
-	"little-diary-measurement-service/src/errors"
 	"little-diary-measurement-service/src/models"
+	"sort"
+	"time"
 )
 
 type measurementDAO interface {
 	GetByMeasurementUuid(measurementUuid models.MeasurementUUID) (*models.Measurement, error)
-	SaveMeasurement(measurement *models.Measurement) error
+	SaveMeasurements(measurements []*models.Measurement) error
 	GetMeasurementsByTargetUuid(targetUuid models.TargetUUID) ([]*models.Measurement, error)
+	GetMeasurementsByFilter(filter models.MeasurementFilter) ([]*models.Measurement, error)
+	AggregateMeasurements(filter models.MeasurementFilter, bucket models.AggregationBucket, location *time.Location) ([]*models.MeasurementAggregate, error)
+	GetBirthMeasurement(targetUuid models.TargetUUID, measurementType models.MeasurementType) (*models.Measurement, error)
+	SaveMeasurementWithAlerts(measurement *models.Measurement, clearBirth bool, saved []*models.Alert, removed []*models.Alert) error
+	GetLatestMeasurements(targetUuids []models.TargetUUID) ([]*models.LatestMeasurement, error)
+	CountMeasurements(targetUuids []models.TargetUUID) ([]*models.MeasurementCount, error)
 }
 
 type MeasurementService struct {
 	dao            measurementDAO
+	alertDao       alertDAO
 	serviceLocator *common.ServiceLocator
 }
 
-func NewMeasurementService(dao measurementDAO, locator *common.ServiceLocator) *MeasurementService {
-	return &MeasurementService{dao, locator}
+func NewMeasurementService(dao measurementDAO, alertDao alertDAO, locator *common.ServiceLocator) *MeasurementService {
+	return &MeasurementService{dao, alertDao, locator}
 }
 
 func (s *MeasurementService) GetByMeasurementUuid(measurementUuid string, userUuid string) (*models.Measurement, error) {
@@ -29,14 +37,11 @@ func (s *MeasurementService) GetByMeasurementUuid(measurementUuid string, userUu
 	if err != nil {
 		return nil, err
 	}
-	allowed, err := s.serviceLocator.UserHasAccessToBabyChecker.CheckUserHasAccessToBaby(userUuid, string(measurement.TargetUuid))
-	if err != nil {
+	if err := authorize(s.serviceLocator, userUuid, string(measurement.TargetUuid), models.PermissionRead); err != nil {
 		return nil, err
 	}
-	if !allowed {
-		return nil, &errors.ForbiddenError{S: "operation not allowed"}
-	}
-	return measurement, err
+	attachAges(s.serviceLocator, []*models.Measurement{measurement})
+	return measurement, s.attachAlerts([]*models.Measurement{measurement})
 }
 
 func (s *MeasurementService) Save(uuid string, request dto.MeasurementRequest, userUuid string) (*models.Measurement, error) {
@@ -45,13 +50,9 @@ func (s *MeasurementService) Save(uuid string, request dto.MeasurementRequest, u
 		return nil, err
 	}
 
-	allowed, err := s.serviceLocator.UserHasAccessToBabyChecker.CheckUserHasAccessToBaby(userUuid, request.TargetUuid)
-	if err != nil {
+	if err := authorize(s.serviceLocator, userUuid, request.TargetUuid, models.PermissionWrite); err != nil {
 		return nil, err
 	}
-	if !allowed {
-		return nil, &errors.ForbiddenError{S: "operation not allowed"}
-	}
 	measurementUUID := models.MeasurementUUID(uuid)
 	measurement, err := s.dao.GetByMeasurementUuid(measurementUUID)
 	if err != nil {
@@ -60,32 +61,285 @@ func (s *MeasurementService) Save(uuid string, request dto.MeasurementRequest, u
 				Uuid:       measurementUUID,
 				TargetUuid: models.TargetUUID(request.TargetUuid),
 				Type:       models.MeasurementType(request.Type),
+				Source:     models.MeasurementSourceUser,
 			}
 		} else {
 			return nil, err
 		}
+	} else if string(measurement.TargetUuid) != request.TargetUuid {
+		// overwriting a value of another target needs permission on that target as well
+		if err := authorize(s.serviceLocator, userUuid, string(measurement.TargetUuid), models.PermissionWrite); err != nil {
+			return nil, err
+		}
+	}
+	previous := *measurement
+	if measurement.Value != request.Value || !measurement.Timestamp.Equal(request.Timestamp) {
+		// confirmation applies only to the value user has seen
+		measurement.Confirmed = false
 	}
 	measurement.Value = request.Value
 	measurement.Timestamp = request.Timestamp
-	err = s.dao.SaveMeasurement(measurement)
-	return measurement, err
+	if request.IsBirth != nil {
+		measurement.IsBirth = *request.IsBirth
+	}
+	if err = s.flagOutlier(measurement); err != nil {
+		return nil, err
+	}
+	return measurement, s.save(measurement, &previous)
+}
+
+// Confirm clears suspect flag of the measurement, user states the value is right
+func (s *MeasurementService) Confirm(measurementUuid string, userUuid string) (*models.Measurement, error) {
+	measurement, err := s.dao.GetByMeasurementUuid(models.MeasurementUUID(measurementUuid))
+	if err != nil {
+		return nil, err
+	}
+	if err := authorize(s.serviceLocator, userUuid, string(measurement.TargetUuid), models.PermissionWrite); err != nil {
+		return nil, err
+	}
+	measurement.Confirmed = true
+	measurement.Suspect = false
+	measurement.SuspectReason = ""
+	return measurement, s.save(measurement, nil)
+}
+
+// save stores the measurement with its refreshed alerts in one transaction. Previous state is given when the user
+// edited the measurement, the birth record moves to it when it is marked as one and a changed birth weight
+// is checked again against weights of the first two weeks
+func (s *MeasurementService) save(measurement *models.Measurement, previous *models.Measurement) error {
+	changes := &alertChanges{}
+	if err := s.evaluateAlerts(measurement, changes); err != nil {
+		return err
+	}
+	if previous != nil && birthWeightChanged(previous, measurement) {
+		if err := s.recheckBirthWeightLoss(measurement, changes); err != nil {
+			return err
+		}
+	}
+	clearBirth := previous != nil && measurement.IsBirth
+	if err := s.dao.SaveMeasurementWithAlerts(measurement, clearBirth, changes.saved, changes.removed); err != nil {
+		return err
+	}
+	attachAges(s.serviceLocator, []*models.Measurement{measurement})
+	return s.attachAlerts([]*models.Measurement{measurement})
+}
+
+// evaluateAlerts refreshes alerts of the measurement, suspect values do not raise alerts
+func (s *MeasurementService) evaluateAlerts(measurement *models.Measurement, changes *alertChanges) error {
+	if measurement.Type == models.MeasurementTypeWeight {
+		var candidate *models.Alert
+		if !measurement.Suspect {
+			birth, err := s.birthWeight(measurement)
+			if err != nil {
+				return err
+			}
+			if birth != nil {
+				candidate = birthWeightLossAlert(birth, measurement)
+			}
+		}
+		stored, err := storedAlert(s.alertDao, measurement.Uuid, models.AlertKindBirthWeightLoss)
+		if err != nil {
+			return err
+		}
+		changes.add(stored, measurement, models.AlertKindBirthWeightLoss, candidate)
+	}
+	if measurement.Type == models.MeasurementTypeWeight || measurement.Type == models.MeasurementTypeHeight {
+		var candidate *models.Alert
+		if !measurement.Suspect {
+			var err error
+			if candidate, err = s.percentileCrossingCandidate(measurement); err != nil {
+				return err
+			}
+		}
+		stored, err := storedAlert(s.alertDao, measurement.Uuid, models.AlertKindPercentileCrossing)
+		if err != nil {
+			return err
+		}
+		changes.add(stored, measurement, models.AlertKindPercentileCrossing, candidate)
+	}
+	return nil
+}
+
+// birthWeight returns the birth weight record of the target as it is once the measurement is saved,
+// nil when the target has none
+func (s *MeasurementService) birthWeight(measurement *models.Measurement) (*models.Measurement, error) {
+	if measurement.Type == models.MeasurementTypeWeight && measurement.IsBirth {
+		return measurement, nil
+	}
+	birth, err := s.dao.GetBirthMeasurement(measurement.TargetUuid, models.MeasurementTypeWeight)
+	if err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if birth.Uuid == measurement.Uuid {
+		// the measurement is not the birth record anymore
+		return nil, nil
+	}
+	return birth, nil
+}
+
+// recheckBirthWeightLoss evaluates the other weights of the target against the changed birth weight,
+// alerts raised against the former birth weight are refreshed as well
+func (s *MeasurementService) recheckBirthWeightLoss(measurement *models.Measurement, changes *alertChanges) error {
+	birth, err := s.birthWeight(measurement)
+	if err != nil {
+		return err
+	}
+	weights, err := s.dao.GetMeasurementsByFilter(models.MeasurementFilter{
+		TargetUuid: measurement.TargetUuid,
+		Types:      []models.MeasurementType{models.MeasurementTypeWeight},
+	})
+	if err != nil {
+		return err
+	}
+	alerts, err := s.alertDao.GetAlertsByTargetUuid(measurement.TargetUuid, true)
+	if err != nil {
+		return err
+	}
+	stored := map[models.MeasurementUUID]*models.Alert{}
+	for _, alert := range alerts {
+		if alert.Kind == models.AlertKindBirthWeightLoss {
+			stored[alert.MeasurementUuid] = alert
+		}
+	}
+	for _, weight := range weights {
+		if weight.Uuid == measurement.Uuid {
+			continue
+		}
+		var candidate *models.Alert
+		if birth != nil && !weight.Suspect {
+			candidate = birthWeightLossAlert(birth, weight)
+		}
+		if candidate != nil || stored[weight.Uuid] != nil {
+			changes.add(stored[weight.Uuid], weight, models.AlertKindBirthWeightLoss, candidate)
+		}
+	}
+	return nil
+}
+
+// birthWeightChanged tells whether saving the measurement marks, unmarks or corrects the birth weight
+func birthWeightChanged(previous *models.Measurement, measurement *models.Measurement) bool {
+	if measurement.Type != models.MeasurementTypeWeight || (!previous.IsBirth && !measurement.IsBirth) {
+		return false
+	}
+	return previous.IsBirth != measurement.IsBirth || previous.Value != measurement.Value ||
+		!previous.Timestamp.Equal(measurement.Timestamp)
+}
+
+// attachAlerts loads alerts of the measurements for responses
+func (s *MeasurementService) attachAlerts(measurements []*models.Measurement) error {
+	byUuid := map[models.MeasurementUUID]*models.Measurement{}
+	var uuids []models.MeasurementUUID
+	for _, m := range measurements {
+		m.Alerts = nil
+		byUuid[m.Uuid] = m
+		uuids = append(uuids, m.Uuid)
+	}
+	alerts, err := s.alertDao.GetAlertsByMeasurementUuids(uuids)
+	if err != nil {
+		return err
+	}
+	for _, alert := range alerts {
+		if m, ok := byUuid[alert.MeasurementUuid]; ok {
+			m.Alerts = append(m.Alerts, alert)
+		}
+	}
+	return nil
 }
 
 func (s *MeasurementService) GetByTargetUuid(targetUuid string, userUuid string) ([]*models.Measurement, error) {
-	allowed, err := s.serviceLocator.UserHasAccessToBabyChecker.CheckUserHasAccessToBaby(userUuid, targetUuid)
+	if err := authorize(s.serviceLocator, userUuid, targetUuid, models.PermissionRead); err != nil {
+		return nil, err
+	}
+	measurements, err := s.dao.GetMeasurementsByTargetUuid(models.TargetUUID(targetUuid))
+	if err != nil {
+		return nil, err
+	}
+	attachAges(s.serviceLocator, measurements)
+	return measurements, s.attachAlerts(measurements)
+}
+
+// GetByFilter returns measurements of the filter, derived types are computed from weights and heights
+// and are returned only when requested
+func (s *MeasurementService) GetByFilter(filter models.MeasurementFilter, userUuid string) ([]*models.Measurement, error) {
+	if err := authorize(s.serviceLocator, userUuid, string(filter.TargetUuid), models.PermissionRead); err != nil {
+		return nil, err
+	}
+	filter, err := resolveAgeFilter(s.serviceLocator, filter)
+	if err != nil {
+		return nil, err
+	}
+	stored, derived := splitDerivedTypes(filter.Types)
+	var measurements []*models.Measurement
+	if len(stored) > 0 || len(derived) == 0 {
+		storedFilter := filter
+		storedFilter.Types = stored
+		if measurements, err = s.dao.GetMeasurementsByFilter(storedFilter); err != nil {
+			return nil, err
+		}
+	}
+	if len(derived) > 0 {
+		derivedMeasurements, err := s.derive(filter, derived)
+		if err != nil {
+			return nil, err
+		}
+		measurements = append(measurements, derivedMeasurements...)
+		sort.SliceStable(measurements, func(i, j int) bool {
+			return measurements[i].Timestamp.Before(measurements[j].Timestamp)
+		})
+	}
+	attachAges(s.serviceLocator, measurements)
+	return measurements, s.attachAlerts(measurements)
+}
+
+// derive computes derived types within the filter period
+func (s *MeasurementService) derive(filter models.MeasurementFilter, types []models.MeasurementType) ([]*models.Measurement, error) {
+	source, err := s.dao.GetMeasurementsByFilter(derivedSourceFilter(filter))
+	if err != nil {
+		return nil, err
+	}
+	subject := profileSubject(babyProfile(s.serviceLocator, filter.TargetUuid))
+	return deriveMeasurements(source, types, filter, subject), nil
+}
+
+// GetLatest returns the newest measurement of each type for every target, the user must have access to all of them
+func (s *MeasurementService) GetLatest(targetUuids []string, userUuid string) ([]*models.LatestMeasurement, error) {
+	if err := authorizeAll(s.serviceLocator, userUuid, targetUuids, models.PermissionRead); err != nil {
+		return nil, err
+	}
+	var targets []models.TargetUUID
+	for _, targetUuid := range targetUuids {
+		targets = append(targets, models.TargetUUID(targetUuid))
+	}
+	return s.dao.GetLatestMeasurements(targets)
+}
+
+// Aggregate summarizes measurements of the filter target by calendar buckets of the given location
+func (s *MeasurementService) Aggregate(filter models.MeasurementFilter, bucket models.AggregationBucket, location *time.Location, userUuid string) ([]*models.MeasurementAggregate, error) {
+	if err := authorize(s.serviceLocator, userUuid, string(filter.TargetUuid), models.PermissionRead); err != nil {
+		return nil, err
+	}
+	filter, err := resolveAgeFilter(s.serviceLocator, filter)
 	if err != nil {
 		return nil, err
 	}
-	if !allowed {
-		return nil, &errors.ForbiddenError{S: "operation not allowed"}
+	if len(filter.Types) == 1 && filter.Types[0].IsDerived() {
+		derived, err := s.derive(filter, filter.Types)
+		if err != nil {
+			return nil, err
+		}
+		return aggregateMeasurements(derived, bucket, location), nil
 	}
-	return s.dao.GetMeasurementsByTargetUuid(models.TargetUUID(targetUuid))
+	return s.dao.AggregateMeasurements(filter, bucket, location)
 }
 
 func (s *MeasurementService) validateMeasurement(request dto.MeasurementRequest) error {
 	allowedTypes := map[string]bool{
-		string(models.MeasurementTypeHeight): true,
-		string(models.MeasurementTypeWeight): true,
+		string(models.MeasurementTypeHeight):            true,
+		string(models.MeasurementTypeWeight):            true,
+		string(models.MeasurementTypeHeadCircumference): true,
 	}
 	if _, exists := allowedTypes[request.Type]; exists == false {
 		return fmt.Errorf("measurement type %s does not exist", request.Type)
@@ -27,6 +27,8 @@ var records = []*models.Measurement{
 
 type mockMeasurementDAO struct {
 	records []*models.Measurement
+	// alerts written with measurements go there when set
+	alerts *mockAlertDAO
 }
 
 func (m *mockMeasurementDAO) GetMeasurementsByTargetUuid(targetUuid models.TargetUUID) ([]*models.Measurement, error) {
@@ -39,6 +41,48 @@ func (m *mockMeasurementDAO) GetMeasurementsByTargetUuid(targetUuid models.Targe
 	return res, nil
 }
 
+func (m *mockMeasurementDAO) GetMeasurementsByFilter(filter models.MeasurementFilter) ([]*models.Measurement, error) {
+	types := map[models.MeasurementType]bool{}
+	for _, t := range filter.Types {
+		types[t] = true
+	}
+	var res []*models.Measurement
+	for _, record := range m.records {
+		if record.TargetUuid != filter.TargetUuid ||
+			(len(types) > 0 && !types[record.Type]) ||
+			(filter.From != nil && record.Timestamp.Before(*filter.From)) ||
+			(filter.To != nil && record.Timestamp.After(*filter.To)) {
+			continue
+		}
+		res = append(res, record)
+	}
+	return res, nil
+}
+
+// AggregateMeasurements puts all filtered records into a single bucket truncated to the day
+func (m *mockMeasurementDAO) AggregateMeasurements(filter models.MeasurementFilter, bucket models.AggregationBucket, location *time.Location) ([]*models.MeasurementAggregate, error) {
+	filtered, _ := m.GetMeasurementsByFilter(filter)
+	if len(filtered) == 0 {
+		return nil, nil
+	}
+	ts := filtered[0].Timestamp.In(location)
+	aggregate := &models.MeasurementAggregate{Bucket: time.Date(ts.Year(), ts.Month(), ts.Day(), 0, 0, 0, 0, location)}
+	var sum float32
+	for _, record := range filtered {
+		if aggregate.Count == 0 || record.Value < aggregate.Min {
+			aggregate.Min = record.Value
+		}
+		if aggregate.Count == 0 || record.Value > aggregate.Max {
+			aggregate.Max = record.Value
+		}
+		aggregate.Count++
+		sum += record.Value
+		aggregate.Last = record.Value
+	}
+	aggregate.Avg = sum / float32(aggregate.Count)
+	return []*models.MeasurementAggregate{aggregate}, nil
+}
+
 func (m *mockMeasurementDAO) GetByMeasurementUuid(measurementUuid models.MeasurementUUID) (*models.Measurement, error) {
 	for _, record := range m.records {
 		if record.Uuid == measurementUuid {
@@ -48,6 +92,87 @@ func (m *mockMeasurementDAO) GetByMeasurementUuid(measurementUuid models.Measure
 	return nil, gorm.ErrRecordNotFound
 }
 
+func (m *mockMeasurementDAO) GetBirthMeasurement(targetUuid models.TargetUUID, measurementType models.MeasurementType) (*models.Measurement, error) {
+	for _, record := range m.records {
+		if record.TargetUuid == targetUuid && record.Type == measurementType && record.IsBirth {
+			return record, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (m *mockMeasurementDAO) SaveMeasurements(measurements []*models.Measurement) error {
+	for _, measurement := range measurements {
+		if _, err := m.GetByMeasurementUuid(measurement.Uuid); err != nil {
+			m.records = append(m.records, measurement)
+		}
+		if err := m.SaveMeasurement(measurement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *mockMeasurementDAO) SaveMeasurementWithAlerts(measurement *models.Measurement, clearBirth bool, saved []*models.Alert, removed []*models.Alert) error {
+	if clearBirth {
+		for _, record := range m.records {
+			if record.TargetUuid == measurement.TargetUuid && record.Type == measurement.Type && record.Uuid != measurement.Uuid {
+				record.IsBirth = false
+			}
+		}
+	}
+	if err := m.SaveMeasurement(measurement); err != nil {
+		return err
+	}
+	if m.alerts != nil {
+		for _, alert := range saved {
+			m.alerts.SaveAlert(alert)
+		}
+		for _, alert := range removed {
+			m.alerts.DeleteAlert(alert)
+		}
+	}
+	return nil
+}
+
+func (m *mockMeasurementDAO) GetLatestMeasurements(targetUuids []models.TargetUUID) ([]*models.LatestMeasurement, error) {
+	var res []*models.LatestMeasurement
+	for _, targetUuid := range targetUuids {
+		for _, t := range models.MeasurementTypes {
+			filtered, _ := m.GetMeasurementsByFilter(models.MeasurementFilter{TargetUuid: targetUuid, Types: []models.MeasurementType{t}})
+			if len(filtered) == 0 {
+				continue
+			}
+			last := filtered[len(filtered)-1]
+			latest := &models.LatestMeasurement{TargetUuid: targetUuid, Type: t, Uuid: last.Uuid, Timestamp: last.Timestamp, Value: last.Value}
+			if len(filtered) > 1 {
+				latest.PreviousValue = &filtered[len(filtered)-2].Value
+				latest.PreviousTimestamp = &filtered[len(filtered)-2].Timestamp
+			}
+			res = append(res, latest)
+		}
+	}
+	return res, nil
+}
+
+func (m *mockMeasurementDAO) CountMeasurements(targetUuids []models.TargetUUID) ([]*models.MeasurementCount, error) {
+	var res []*models.MeasurementCount
+	for _, targetUuid := range targetUuids {
+		for _, t := range models.MeasurementTypes {
+			count := 0
+			for _, record := range m.records {
+				if record.TargetUuid == targetUuid && record.Type == t {
+					count++
+				}
+			}
+			if count > 0 {
+				res = append(res, &models.MeasurementCount{TargetUuid: targetUuid, Type: t, Count: count})
+			}
+		}
+	}
+	return res, nil
+}
+
 func (m *mockMeasurementDAO) SaveMeasurement(measurement *models.Measurement) error {
 	measurement.ID = models.MeasurementId(100500)
 	return nil
@@ -81,9 +206,9 @@ func TestMeasurementService_Get(t *testing.T) {
 				dao: newMockMeasurementDAO(),
 				serviceLocator: &common.ServiceLocator{
 					PublicKeyGetter: &config.Config,
-					UserHasAccessToBabyChecker: func() integrations.UserHasAccessToBabyChecker {
-						mockObj := new(test_data.MockUserHasAccessToBabyChecker)
-						mockObj.On("CheckUserHasAccessToBaby", mock.Anything, mock.Anything).Return(true, nil)
+					Authorizer: func() integrations.Authorizer {
+						mockObj := new(test_data.MockAuthorizer)
+						mockObj.On("GetPermissions", mock.Anything, mock.Anything).Return(test_data.ParentPermissions, nil)
 						return mockObj
 					}(),
 				}},
@@ -96,9 +221,9 @@ func TestMeasurementService_Get(t *testing.T) {
 				dao: newMockMeasurementDAO(),
 				serviceLocator: &common.ServiceLocator{
 					PublicKeyGetter: &config.Config,
-					UserHasAccessToBabyChecker: func() integrations.UserHasAccessToBabyChecker {
-						mockObj := new(test_data.MockUserHasAccessToBabyChecker)
-						mockObj.On("CheckUserHasAccessToBaby", mock.Anything, mock.Anything).Return(true, nil)
+					Authorizer: func() integrations.Authorizer {
+						mockObj := new(test_data.MockAuthorizer)
+						mockObj.On("GetPermissions", mock.Anything, mock.Anything).Return(test_data.ParentPermissions, nil)
 						return mockObj
 					}(),
 				}},
@@ -110,9 +235,9 @@ func TestMeasurementService_Get(t *testing.T) {
 				dao: newMockMeasurementDAO(),
 				serviceLocator: &common.ServiceLocator{
 					PublicKeyGetter: &config.Config,
-					UserHasAccessToBabyChecker: func() integrations.UserHasAccessToBabyChecker {
-						mockObj := new(test_data.MockUserHasAccessToBabyChecker)
-						mockObj.On("CheckUserHasAccessToBaby", mock.Anything, mock.Anything).Return(true, nil)
+					Authorizer: func() integrations.Authorizer {
+						mockObj := new(test_data.MockAuthorizer)
+						mockObj.On("GetPermissions", mock.Anything, mock.Anything).Return(test_data.ParentPermissions, nil)
 						return mockObj
 					}(),
 				}},
@@ -125,9 +250,9 @@ func TestMeasurementService_Get(t *testing.T) {
 				dao: newMockMeasurementDAO(),
 				serviceLocator: &common.ServiceLocator{
 					PublicKeyGetter: &config.Config,
-					UserHasAccessToBabyChecker: func() integrations.UserHasAccessToBabyChecker {
-						mockObj := new(test_data.MockUserHasAccessToBabyChecker)
-						mockObj.On("CheckUserHasAccessToBaby", mock.Anything, mock.Anything).Return(false, nil)
+					Authorizer: func() integrations.Authorizer {
+						mockObj := new(test_data.MockAuthorizer)
+						mockObj.On("GetPermissions", mock.Anything, mock.Anything).Return(models.Permissions{}, nil)
 						return mockObj
 					}(),
 				}},
@@ -139,6 +264,7 @@ func TestMeasurementService_Get(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			s := &MeasurementService{
 				dao:            tt.fields.dao,
+				alertDao:       newMockAlertDAO(),
 				serviceLocator: tt.fields.serviceLocator,
 			}
 			got, err := s.GetByMeasurementUuid(tt.args.measurementUuid, tt.args.userUuid)
@@ -169,6 +295,7 @@ func TestMeasurementService_validateMeasurement(t *testing.T) {
 	}{
 		{name: "test height", args: args{request: dto.MeasurementRequest{Type: "HEIGHT"}}, wantErr: false},
 		{name: "test weight", args: args{request: dto.MeasurementRequest{Type: "WEIGHT"}}, wantErr: false},
+		{name: "test head circumference", args: args{request: dto.MeasurementRequest{Type: "HEAD_CIRCUMFERENCE"}}, wantErr: false},
 		{name: "test height case fail", args: args{request: dto.MeasurementRequest{Type: "height"}}, wantErr: true},
 		{name: "test random", args: args{request: dto.MeasurementRequest{Type: "dfa"}}, wantErr: true},
 	}
@@ -176,6 +303,7 @@ func TestMeasurementService_validateMeasurement(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			s := &MeasurementService{
 				dao:            tt.fields.dao,
+				alertDao:       newMockAlertDAO(),
 				serviceLocator: tt.fields.serviceLocator,
 			}
 			if err := s.validateMeasurement(tt.args.request); (err != nil) != tt.wantErr {
@@ -211,9 +339,9 @@ func TestMeasurementService_Save(t *testing.T) {
 				dao: newMockMeasurementDAO(),
 				serviceLocator: &common.ServiceLocator{
 					PublicKeyGetter: &config.Config,
-					UserHasAccessToBabyChecker: func() integrations.UserHasAccessToBabyChecker {
-						mockObj := new(test_data.MockUserHasAccessToBabyChecker)
-						mockObj.On("CheckUserHasAccessToBaby", mock.Anything, mock.Anything).Return(true, nil)
+					Authorizer: func() integrations.Authorizer {
+						mockObj := new(test_data.MockAuthorizer)
+						mockObj.On("GetPermissions", mock.Anything, mock.Anything).Return(test_data.ParentPermissions, nil)
 						return mockObj
 					}(),
 				}},
@@ -233,9 +361,9 @@ func TestMeasurementService_Save(t *testing.T) {
 				dao: newMockMeasurementDAO(),
 				serviceLocator: &common.ServiceLocator{
 					PublicKeyGetter: &config.Config,
-					UserHasAccessToBabyChecker: func() integrations.UserHasAccessToBabyChecker {
-						mockObj := new(test_data.MockUserHasAccessToBabyChecker)
-						mockObj.On("CheckUserHasAccessToBaby", mock.Anything, mock.Anything).Return(true, nil)
+					Authorizer: func() integrations.Authorizer {
+						mockObj := new(test_data.MockAuthorizer)
+						mockObj.On("GetPermissions", mock.Anything, mock.Anything).Return(test_data.ParentPermissions, nil)
 						return mockObj
 					}(),
 				}},
@@ -252,6 +380,7 @@ func TestMeasurementService_Save(t *testing.T) {
 				Value:      73,
 				Uuid:       models.MeasurementUUID(randomUuid),
 				TargetUuid: models.TargetUUID(targetUuid),
+				Source:     models.MeasurementSourceUser,
 			},
 		},
 		{
@@ -260,9 +389,29 @@ func TestMeasurementService_Save(t *testing.T) {
 				dao: newMockMeasurementDAO(),
 				serviceLocator: &common.ServiceLocator{
 					PublicKeyGetter: &config.Config,
-					UserHasAccessToBabyChecker: func() integrations.UserHasAccessToBabyChecker {
-						mockObj := new(test_data.MockUserHasAccessToBabyChecker)
-						mockObj.On("CheckUserHasAccessToBaby", mock.Anything, mock.Anything).Return(false, nil)
+					Authorizer: func() integrations.Authorizer {
+						mockObj := new(test_data.MockAuthorizer)
+						mockObj.On("GetPermissions", mock.Anything, mock.Anything).Return(models.Permissions{}, nil)
+						return mockObj
+					}(),
+				}},
+			args: args{uuid: randomUuid, request: dto.MeasurementRequest{
+				Type:       "HEIGHT",
+				Timestamp:  twoHoursBefore,
+				Value:      73,
+				TargetUuid: targetUuid,
+			}},
+			wantErr: true,
+		},
+		{
+			name: "test read only access",
+			fields: fields{
+				dao: newMockMeasurementDAO(),
+				serviceLocator: &common.ServiceLocator{
+					PublicKeyGetter: &config.Config,
+					Authorizer: func() integrations.Authorizer {
+						mockObj := new(test_data.MockAuthorizer)
+						mockObj.On("GetPermissions", mock.Anything, mock.Anything).Return(models.Permissions{models.PermissionRead}, nil)
 						return mockObj
 					}(),
 				}},
@@ -279,6 +428,7 @@ func TestMeasurementService_Save(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			s := &MeasurementService{
 				dao:            tt.fields.dao,
+				alertDao:       newMockAlertDAO(),
 				serviceLocator: tt.fields.serviceLocator,
 			}
 			got, err := s.Save(tt.args.uuid, tt.args.request, tt.args.userUuid)
@@ -319,9 +469,9 @@ func TestMeasurementService_GetByTargetUuid(t *testing.T) {
 				dao: newMockMeasurementDAO(),
 				serviceLocator: &common.ServiceLocator{
 					PublicKeyGetter: &config.Config,
-					UserHasAccessToBabyChecker: func() integrations.UserHasAccessToBabyChecker {
-						mockObj := new(test_data.MockUserHasAccessToBabyChecker)
-						mockObj.On("CheckUserHasAccessToBaby", mock.Anything, mock.Anything).Return(true, nil)
+					Authorizer: func() integrations.Authorizer {
+						mockObj := new(test_data.MockAuthorizer)
+						mockObj.On("GetPermissions", mock.Anything, mock.Anything).Return(test_data.ParentPermissions, nil)
 						return mockObj
 					}(),
 				}},
@@ -334,9 +484,9 @@ func TestMeasurementService_GetByTargetUuid(t *testing.T) {
 				dao: newMockMeasurementDAO(),
 				serviceLocator: &common.ServiceLocator{
 					PublicKeyGetter: &config.Config,
-					UserHasAccessToBabyChecker: func() integrations.UserHasAccessToBabyChecker {
-						mockObj := new(test_data.MockUserHasAccessToBabyChecker)
-						mockObj.On("CheckUserHasAccessToBaby", mock.Anything, mock.Anything).Return(false, nil)
+					Authorizer: func() integrations.Authorizer {
+						mockObj := new(test_data.MockAuthorizer)
+						mockObj.On("GetPermissions", mock.Anything, mock.Anything).Return(models.Permissions{}, nil)
 						return mockObj
 					}(),
 				}},
@@ -348,6 +498,7 @@ func TestMeasurementService_GetByTargetUuid(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			s := &MeasurementService{
 				dao:            tt.fields.dao,
+				alertDao:       newMockAlertDAO(),
 				serviceLocator: tt.fields.serviceLocator,
 			}
 			got, err := s.GetByTargetUuid(tt.args.targetUuid, tt.args.userUuid)
@@ -361,3 +512,71 @@ func TestMeasurementService_GetByTargetUuid(t *testing.T) {
 		})
 	}
 }
+
+func TestMeasurementService_Aggregate(t *testing.T) {
+	location, _ := time.LoadLocation("Europe/Berlin")
+	tests := []struct {
+		name        string
+		permissions models.Permissions
+		want        int
+		wantErr     bool
+	}{
+		{name: "aggregate", permissions: test_data.ParentPermissions, want: 2},
+		{name: "test access denied", permissions: models.Permissions{}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewMeasurementService(newMockMeasurementDAO(), newMockAlertDAO(), &common.ServiceLocator{
+				PublicKeyGetter: &config.Config,
+				Authorizer: func() integrations.Authorizer {
+					mockObj := new(test_data.MockAuthorizer)
+					mockObj.On("GetPermissions", mock.Anything, mock.Anything).Return(tt.permissions, nil)
+					return mockObj
+				}(),
+			})
+			got, err := s.Aggregate(models.MeasurementFilter{TargetUuid: models.TargetUUID(tUuid)}, models.AggregationBucketWeek, location, "any")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Aggregate() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err == nil && assert.Len(t, got, 1) {
+				assert.Equal(t, tt.want, got[0].Count)
+				assert.Equal(t, records[2].Value, got[0].Last)
+				assert.Equal(t, location, got[0].Bucket.Location())
+			}
+		})
+	}
+}
+
+func TestMeasurementService_GetLatest(t *testing.T) {
+	tests := []struct {
+		name        string
+		permissions models.Permissions
+		wantErr     bool
+	}{
+		{name: "latest", permissions: test_data.ParentPermissions},
+		{name: "test access denied", permissions: models.Permissions{}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewMeasurementService(newMockMeasurementDAO(), newMockAlertDAO(), &common.ServiceLocator{
+				PublicKeyGetter: &config.Config,
+				Authorizer: func() integrations.Authorizer {
+					mockObj := new(test_data.MockAuthorizer)
+					mockObj.On("GetPermissions", mock.Anything, mock.Anything).Return(tt.permissions, nil)
+					return mockObj
+				}(),
+			})
+			got, err := s.GetLatest([]string{tUuid, string(records[1].TargetUuid)}, "any")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GetLatest() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err == nil && assert.Len(t, got, 2) {
+				assert.Equal(t, records[2].Uuid, got[0].Uuid)
+				assert.Equal(t, records[0].Value, *got[0].PreviousValue)
+				assert.Nil(t, got[1].PreviousValue)
+			}
+		})
+	}
+}
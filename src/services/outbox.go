@@ -0,0 +1,77 @@
+package services
+
+import (
+	"fmt"
+	"little-diary-measurement-service/src/events"
+	"little-diary-measurement-service/src/models"
+	"time"
+)
+
+// outboxRetention is how long events stay in the outbox, handy when a consumer needs a replay.
+// Events a sink has not published yet are kept until it has
+const outboxRetention = 7 * 24 * time.Hour
+
+type outboxDAO interface {
+	ProcessPending(sink string, limit int, publish func(event *models.OutboxEvent) error) (int, error)
+	DeletePublished(before time.Time, sinks []string) error
+}
+
+// OutboxSink is a named publisher with its own position in the outbox
+type OutboxSink struct {
+	Name      string
+	Publisher events.Publisher
+}
+
+// OutboxRelay publishes measurement events stored in the outbox to its sinks. Every sink follows the outbox on its own,
+// so an outage of one does not hold the others back. Delivery is at-least-once: a sink moves past an event only after
+// its publisher has accepted it, consumers deduplicate by the message ID
+type OutboxRelay struct {
+	dao       outboxDAO
+	sinks     []OutboxSink
+	subject   string
+	batchSize int
+}
+
+func NewOutboxRelay(dao outboxDAO, sinks []OutboxSink, subject string, batchSize int) *OutboxRelay {
+	return &OutboxRelay{dao, sinks, subject, batchSize}
+}
+
+// RelayOnce publishes a batch of events pending for the sink in insertion order and returns how many were published.
+// Once an event of a target fails the later events of the target wait for the next run, so the order per target holds.
+// Events all sinks have published are removed after the retention
+func (r *OutboxRelay) RelayOnce(sink OutboxSink) (int, error) {
+	blocked := map[models.TargetUUID]bool{}
+	var failure error
+	published, err := r.dao.ProcessPending(sink.Name, r.batchSize, func(event *models.OutboxEvent) error {
+		if blocked[event.TargetUuid] {
+			return fmt.Errorf("earlier event of target %s is not published", event.TargetUuid)
+		}
+		if err := sink.Publisher.Publish(r.message(event)); err != nil {
+			blocked[event.TargetUuid] = true
+			failure = err
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return published, err
+	}
+	var names []string
+	for _, s := range r.sinks {
+		names = append(names, s.Name)
+	}
+	if err := r.dao.DeletePublished(time.Now().Add(-outboxRetention), names); err != nil {
+		return published, err
+	}
+	return published, failure
+}
+
+func (r *OutboxRelay) message(event *models.OutboxEvent) events.Message {
+	return events.Message{
+		ID:       event.EventUuid,
+		Sequence: int64(event.ID),
+		Subject:  fmt.Sprintf("%s.%s", r.subject, event.Type),
+		Key:      string(event.TargetUuid),
+		Data:     []byte(event.Payload),
+	}
+}
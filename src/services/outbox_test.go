@@ -0,0 +1,102 @@
+package services
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"little-diary-measurement-service/src/events"
+	"little-diary-measurement-service/src/models"
+	"testing"
+	"time"
+)
+
+type mockOutboxDAO struct {
+	events []*models.OutboxEvent
+	// event uuids published by sink
+	published map[string]map[string]bool
+}
+
+func (m *mockOutboxDAO) ProcessPending(sink string, limit int, publish func(event *models.OutboxEvent) error) (int, error) {
+	if m.published == nil {
+		m.published = map[string]map[string]bool{}
+	}
+	if m.published[sink] == nil {
+		m.published[sink] = map[string]bool{}
+	}
+	published := 0
+	for _, event := range m.events {
+		if published == limit {
+			break
+		}
+		if m.published[sink][event.EventUuid] || publish(event) != nil {
+			continue
+		}
+		m.published[sink][event.EventUuid] = true
+		published++
+	}
+	return published, nil
+}
+
+func (m *mockOutboxDAO) DeletePublished(before time.Time, sinks []string) error {
+	return nil
+}
+
+// flakyPublisher rejects messages of the failing key
+type flakyPublisher struct {
+	events.MemoryPublisher
+	failingKey string
+}
+
+func (p *flakyPublisher) Publish(message events.Message) error {
+	if message.Key == p.failingKey {
+		return fmt.Errorf("broker is unavailable")
+	}
+	return p.MemoryPublisher.Publish(message)
+}
+
+func TestOutboxRelay_RelayOnce(t *testing.T) {
+	event := func(id string, target string) *models.OutboxEvent {
+		return &models.OutboxEvent{
+			EventUuid:  id,
+			Type:       models.OutboxEventMeasurementCreated,
+			TargetUuid: models.TargetUUID(target),
+			Payload:    `{"event_uuid":"` + id + `"}`,
+		}
+	}
+	dao := &mockOutboxDAO{events: []*models.OutboxEvent{
+		event("1", "a"), event("2", "b"), event("3", "a"), event("4", "b"),
+	}}
+	publisher := &flakyPublisher{failingKey: "a"}
+	webhooks := events.NewMemoryPublisher()
+	sinks := []OutboxSink{{Name: models.OutboxSinkBroker, Publisher: publisher}, {Name: models.OutboxSinkWebhooks, Publisher: webhooks}}
+	relay := NewOutboxRelay(dao, sinks, "measurements", 10)
+
+	published, err := relay.RelayOnce(sinks[0])
+	assert.Error(t, err)
+	assert.Equal(t, 2, published)
+	messages := publisher.Messages()
+	assert.Len(t, messages, 2)
+	assert.Equal(t, "2", messages[0].ID)
+	assert.Equal(t, "4", messages[1].ID)
+	assert.Equal(t, "measurements.MeasurementCreated", messages[0].Subject)
+	assert.Equal(t, `{"event_uuid":"2"}`, string(messages[0].Data))
+
+	// the broker outage does not hold webhooks back
+	published, err = relay.RelayOnce(sinks[1])
+	assert.NoError(t, err)
+	assert.Equal(t, 4, published)
+	assert.Len(t, webhooks.Messages(), 4)
+
+	publisher.failingKey = ""
+	published, err = relay.RelayOnce(sinks[0])
+	assert.NoError(t, err)
+	assert.Equal(t, 2, published)
+	messages = publisher.Messages()
+	assert.Len(t, messages, 4)
+	assert.Equal(t, "1", messages[2].ID)
+	assert.Equal(t, "3", messages[3].ID)
+
+	published, err = relay.RelayOnce(sinks[1])
+	assert.NoError(t, err)
+	assert.Equal(t, 0, published)
+	assert.Len(t, webhooks.Messages(), 4)
+}
@@ -0,0 +1,151 @@
+package services
+
+import (
+	"fmt"
+	"little-diary-measurement-service/src/models"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	outlierHistoryWindow = 120 * 24 * time.Hour
+	outlierHistorySize   = 10
+	// trend is fitted only with enough points, otherwise the value is compared with the previous one
+	outlierMinTrendPoints = 4
+	// deviation from trend in robust standard deviations
+	outlierMaxDeviation = 4
+	// scale of deviation is never below that share of the predicted value
+	outlierMinRelativeScale = 0.015
+	// allowed relative change from the previous value grows with days between measurements
+	outlierJumpBase   = 0.25
+	outlierJumpPerDay = 0.02
+)
+
+// lengths may look a bit shorter than before because of measurement error
+var decreaseTolerance = map[models.MeasurementType]float32{
+	models.MeasurementTypeHeight:            1,
+	models.MeasurementTypeHeadCircumference: 0.5,
+}
+
+// flagOutlier sets suspect flag of the measurement from its recent history, confirmed values are never suspect
+func (s *MeasurementService) flagOutlier(measurement *models.Measurement) error {
+	measurement.Suspect, measurement.SuspectReason = false, ""
+	if measurement.Confirmed {
+		return nil
+	}
+	from := measurement.Timestamp.Add(-outlierHistoryWindow)
+	history, err := s.dao.GetMeasurementsByFilter(models.MeasurementFilter{
+		TargetUuid: measurement.TargetUuid,
+		Types:      []models.MeasurementType{measurement.Type},
+		From:       &from,
+		To:         &measurement.Timestamp,
+	})
+	if err != nil {
+		return err
+	}
+	reasons := outlierReasons(recentHistory(history, measurement), measurement)
+	if len(reasons) > 0 {
+		measurement.Suspect = true
+		measurement.SuspectReason = strings.Join(reasons, "; ")
+	}
+	return nil
+}
+
+// recentHistory keeps trusted measurements taken before the given one, the oldest first
+func recentHistory(history []*models.Measurement, measurement *models.Measurement) []*models.Measurement {
+	var result []*models.Measurement
+	for _, m := range history {
+		if m.Uuid == measurement.Uuid || m.Suspect || m.Timestamp.After(measurement.Timestamp) {
+			continue
+		}
+		result = append(result, m)
+	}
+	if len(result) > outlierHistorySize {
+		result = result[len(result)-outlierHistorySize:]
+	}
+	return result
+}
+
+// outlierReasons explains why the value does not fit the history, empty result means it is plausible
+func outlierReasons(history []*models.Measurement, measurement *models.Measurement) []string {
+	if len(history) == 0 {
+		return nil
+	}
+	var reasons []string
+	previous := history[len(history)-1]
+	if tolerance, ok := decreaseTolerance[measurement.Type]; ok {
+		var highest *models.Measurement
+		for _, m := range history {
+			if highest == nil || m.Value > highest.Value {
+				highest = m
+			}
+		}
+		if measurement.Value < highest.Value-tolerance {
+			reasons = append(reasons, fmt.Sprintf("value %g is lower than %g measured on %s",
+				measurement.Value, highest.Value, highest.Timestamp.Format("2006-01-02")))
+		}
+	}
+
+	if len(history) < outlierMinTrendPoints {
+		days := measurement.Timestamp.Sub(previous.Timestamp).Hours() / 24
+		change := float64(measurement.Value)/float64(previous.Value) - 1
+		if previous.Value > 0 && math.Abs(change) > outlierJumpBase+outlierJumpPerDay*days {
+			reasons = append(reasons, fmt.Sprintf("value %g differs by %.0f%% from previous %g in %.0f days",
+				measurement.Value, change*100, previous.Value, days))
+		}
+		return reasons
+	}
+
+	predicted, scale := robustTrend(history, measurement.Timestamp)
+	scale = math.Max(scale, outlierMinRelativeScale*math.Abs(predicted))
+	if deviation := math.Abs(float64(measurement.Value)-predicted) / scale; deviation > outlierMaxDeviation {
+		reasons = append(reasons, fmt.Sprintf("value %g is far from expected %.4g by the recent trend",
+			measurement.Value, predicted))
+	}
+	return reasons
+}
+
+// robustTrend fits Theil-Sen line to the history and returns its value at the given time
+// with the scale of residuals estimated by median absolute deviation
+func robustTrend(history []*models.Measurement, at time.Time) (float64, float64) {
+	origin := history[0].Timestamp
+	days := func(t time.Time) float64 {
+		return t.Sub(origin).Hours() / 24
+	}
+	var slopes []float64
+	for i := 0; i < len(history); i++ {
+		for j := i + 1; j < len(history); j++ {
+			dx := days(history[j].Timestamp) - days(history[i].Timestamp)
+			if dx > 0 {
+				slopes = append(slopes, float64(history[j].Value-history[i].Value)/dx)
+			}
+		}
+	}
+	slope := 0.0
+	if len(slopes) > 0 {
+		slope = median(slopes)
+	}
+	intercepts := make([]float64, len(history))
+	for i, m := range history {
+		intercepts[i] = float64(m.Value) - slope*days(m.Timestamp)
+	}
+	intercept := median(intercepts)
+	residuals := make([]float64, len(history))
+	for i, m := range history {
+		residuals[i] = math.Abs(float64(m.Value) - (intercept + slope*days(m.Timestamp)))
+	}
+	// 1.4826 makes median absolute deviation consistent with standard deviation of normal distribution
+	return intercept + slope*days(at), 1.4826 * median(residuals)
+}
+
+func median(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
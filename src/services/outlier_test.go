@@ -0,0 +1,99 @@
+package services
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"little-diary-measurement-service/src/common"
+	"little-diary-measurement-service/src/config"
+	"little-diary-measurement-service/src/dto"
+	"little-diary-measurement-service/src/integrations"
+	"little-diary-measurement-service/src/models"
+	"little-diary-measurement-service/src/test_data"
+	"testing"
+	"time"
+)
+
+func TestOutlierReasons(t *testing.T) {
+	start := time.Date(2020, 3, 1, 9, 0, 0, 0, time.UTC)
+	series := func(t models.MeasurementType, values ...float32) []*models.Measurement {
+		var result []*models.Measurement
+		for i, v := range values {
+			result = append(result, &models.Measurement{Type: t, Timestamp: start.AddDate(0, 0, 7*i), Value: v})
+		}
+		return result
+	}
+	next := func(t models.MeasurementType, weeks int, value float32) *models.Measurement {
+		return &models.Measurement{Type: t, Timestamp: start.AddDate(0, 0, 7*weeks), Value: value}
+	}
+	weights := series(models.MeasurementTypeWeight, 3500, 3700, 3920, 4100, 4330)
+	heights := series(models.MeasurementTypeHeight, 50, 51.2, 52.1, 53)
+
+	tests := []struct {
+		name        string
+		history     []*models.Measurement
+		measurement *models.Measurement
+		want        int
+	}{
+		{name: "no history", measurement: next(models.MeasurementTypeWeight, 0, 45000)},
+		{name: "on trend", history: weights, measurement: next(models.MeasurementTypeWeight, 5, 4520)},
+		{name: "typo against trend", history: weights, measurement: next(models.MeasurementTypeWeight, 5, 45200), want: 1},
+		{name: "drop against trend", history: weights, measurement: next(models.MeasurementTypeWeight, 5, 3600), want: 1},
+		{name: "typo against previous", history: weights[:2], measurement: next(models.MeasurementTypeWeight, 2, 390), want: 1},
+		{name: "fast but plausible gain", history: weights[:2], measurement: next(models.MeasurementTypeWeight, 2, 4100)},
+		{name: "height within error", history: heights, measurement: next(models.MeasurementTypeHeight, 4, 52.5)},
+		{name: "decreasing height", history: heights, measurement: next(models.MeasurementTypeHeight, 4, 51), want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := outlierReasons(tt.history, tt.measurement)
+			assert.Len(t, got, tt.want, "%v", got)
+		})
+	}
+}
+
+func TestMeasurementService_SaveFlagsSuspectValue(t *testing.T) {
+	targetUuid := fmt.Sprintf("%s", uuid.New())
+	start := time.Now().AddDate(0, 0, -30)
+	dao := &mockMeasurementDAO{}
+	for i, v := range []float32{3500, 3700, 3900} {
+		dao.records = append(dao.records, &models.Measurement{Uuid: models.MeasurementUUID(fmt.Sprintf("%s", uuid.New())),
+			TargetUuid: models.TargetUUID(targetUuid), Type: models.MeasurementTypeWeight, Timestamp: start.AddDate(0, 0, 7*i), Value: v})
+	}
+	s := NewMeasurementService(dao, newMockAlertDAO(), &common.ServiceLocator{
+		PublicKeyGetter: &config.Config,
+		Authorizer: func() integrations.Authorizer {
+			mockObj := new(test_data.MockAuthorizer)
+			mockObj.On("GetPermissions", mock.Anything, mock.Anything).Return(test_data.ParentPermissions, nil)
+			return mockObj
+		}(),
+	})
+	request := dto.MeasurementRequest{Type: "WEIGHT", Timestamp: start.AddDate(0, 0, 21), Value: 41000, TargetUuid: targetUuid}
+	measurementUuid := fmt.Sprintf("%s", uuid.New())
+
+	saved, err := s.Save(measurementUuid, request, "any")
+	if assert.Nil(t, err) {
+		assert.True(t, saved.Suspect)
+		assert.NotEmpty(t, saved.SuspectReason)
+	}
+	dao.records = append(dao.records, saved)
+
+	confirmed, err := s.Confirm(measurementUuid, "any")
+	if assert.Nil(t, err) {
+		assert.False(t, confirmed.Suspect)
+		assert.True(t, confirmed.Confirmed)
+	}
+
+	saved, err = s.Save(measurementUuid, request, "any")
+	if assert.Nil(t, err) {
+		assert.False(t, saved.Suspect, "confirmed value is not flagged again")
+	}
+
+	request.Value = 42000
+	saved, err = s.Save(measurementUuid, request, "any")
+	if assert.Nil(t, err) {
+		assert.True(t, saved.Suspect, "changed value is checked again")
+		assert.False(t, saved.Confirmed)
+	}
+}
@@ -0,0 +1,75 @@
+package services
+
+import (
+	"fmt"
+	"little-diary-measurement-service/src/common"
+	"little-diary-measurement-service/src/models"
+)
+
+type OverviewService struct {
+	dao            measurementDAO
+	alertDao       alertDAO
+	serviceLocator *common.ServiceLocator
+}
+
+func NewOverviewService(dao measurementDAO, alertDao alertDAO, locator *common.ServiceLocator) *OverviewService {
+	return &OverviewService{dao, alertDao, locator}
+}
+
+// GetOverview summarizes every target the family service lists for the user with read permission,
+// targets keep the order of the listing
+func (s *OverviewService) GetOverview(userUuid string) ([]*models.TargetOverview, error) {
+	if s.serviceLocator.TargetLister == nil {
+		return nil, fmt.Errorf("target listing is not configured")
+	}
+	accessible, err := s.serviceLocator.TargetLister.ListTargets(userUuid)
+	if err != nil {
+		return nil, err
+	}
+	overviews := []*models.TargetOverview{}
+	byTarget := map[models.TargetUUID]*models.TargetOverview{}
+	var targets []models.TargetUUID
+	for _, target := range accessible {
+		if _, ok := byTarget[target.TargetUuid]; ok || !target.Permissions.Allows(models.PermissionRead) {
+			continue
+		}
+		overview := &models.TargetOverview{
+			TargetUuid:  target.TargetUuid,
+			Permissions: target.Permissions,
+			Latest:      []*models.LatestMeasurement{},
+			Counts:      map[models.MeasurementType]int{},
+			OpenAlerts:  []*models.Alert{},
+		}
+		byTarget[target.TargetUuid] = overview
+		overviews = append(overviews, overview)
+		targets = append(targets, target.TargetUuid)
+	}
+	latest, err := s.dao.GetLatestMeasurements(targets)
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range latest {
+		if overview, ok := byTarget[l.TargetUuid]; ok {
+			overview.Latest = append(overview.Latest, l)
+		}
+	}
+	counts, err := s.dao.CountMeasurements(targets)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range counts {
+		if overview, ok := byTarget[c.TargetUuid]; ok {
+			overview.Counts[c.Type] = c.Count
+		}
+	}
+	alerts, err := s.alertDao.GetOpenAlertsByTargetUuids(targets)
+	if err != nil {
+		return nil, err
+	}
+	for _, alert := range alerts {
+		if overview, ok := byTarget[alert.TargetUuid]; ok {
+			overview.OpenAlerts = append(overview.OpenAlerts, alert)
+		}
+	}
+	return overviews, nil
+}
@@ -0,0 +1,56 @@
+package services
+
+import (
+	"github.com/stretchr/testify/assert"
+	"little-diary-measurement-service/src/common"
+	"little-diary-measurement-service/src/models"
+	"little-diary-measurement-service/src/test_data"
+	"testing"
+	"time"
+)
+
+func TestOverviewService_GetOverview(t *testing.T) {
+	at := time.Date(2020, 3, 1, 8, 0, 0, 0, time.UTC)
+	dao := &mockMeasurementDAO{records: []*models.Measurement{
+		{Uuid: "m1", TargetUuid: "first", Type: models.MeasurementTypeWeight, Timestamp: at, Value: 3500},
+		{Uuid: "m2", TargetUuid: "first", Type: models.MeasurementTypeWeight, Timestamp: at.AddDate(0, 0, 7), Value: 3800},
+		{Uuid: "m3", TargetUuid: "second", Type: models.MeasurementTypeHeight, Timestamp: at, Value: 52},
+		{Uuid: "m4", TargetUuid: "hidden", Type: models.MeasurementTypeHeight, Timestamp: at, Value: 52},
+	}}
+	acknowledged := at
+	alertDao := &mockAlertDAO{alerts: []*models.Alert{
+		{Uuid: "a1", TargetUuid: "first", MeasurementUuid: "m2"},
+		{Uuid: "a2", TargetUuid: "first", MeasurementUuid: "m1", AcknowledgedAt: &acknowledged},
+		{Uuid: "a3", TargetUuid: "hidden", MeasurementUuid: "m4"},
+	}}
+	lister := new(test_data.MockTargetLister)
+	lister.On("ListTargets", "user").Return([]*models.AccessibleTarget{
+		{TargetUuid: "second", Permissions: models.Permissions{models.PermissionRead}},
+		{TargetUuid: "first", Permissions: test_data.ParentPermissions},
+		{TargetUuid: "hidden", Permissions: models.Permissions{}},
+		{TargetUuid: "empty", Permissions: models.Permissions{models.PermissionAdmin}},
+	}, nil)
+	s := NewOverviewService(dao, alertDao, &common.ServiceLocator{TargetLister: lister})
+
+	overviews, err := s.GetOverview("user")
+	if assert.Nil(t, err) && assert.Len(t, overviews, 3, "targets without read permission are left out") {
+		second, first, empty := overviews[0], overviews[1], overviews[2]
+		assert.Equal(t, models.TargetUUID("second"), second.TargetUuid, "listing order is kept")
+		assert.Equal(t, map[models.MeasurementType]int{models.MeasurementTypeHeight: 1}, second.Counts)
+		assert.Empty(t, second.OpenAlerts)
+
+		assert.Equal(t, 2, first.Counts[models.MeasurementTypeWeight])
+		if assert.Len(t, first.Latest, 1) {
+			assert.Equal(t, models.MeasurementUUID("m2"), first.Latest[0].Uuid)
+		}
+		if assert.Len(t, first.OpenAlerts, 1) {
+			assert.Equal(t, models.AlertUUID("a1"), first.OpenAlerts[0].Uuid)
+		}
+
+		assert.Empty(t, empty.Latest)
+		assert.Empty(t, empty.Counts)
+	}
+
+	_, err = NewOverviewService(dao, alertDao, &common.ServiceLocator{}).GetOverview("user")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,199 @@
+package services
+
+import (
+	"fmt"
+	"github.com/jinzhu/gorm"
+	"little-diary-measurement-service/src/growth"
+	"little-diary-measurement-service/src/models"
+	"little-diary-measurement-service/src/report"
+	"math"
+	"strings"
+	"time"
+)
+
+const defaultPercentileCrossingLines = 2
+
+var defaultPercentileCrossingWindows = []time.Duration{30 * 24 * time.Hour, 90 * 24 * time.Hour, 180 * 24 * time.Hour}
+
+// without known sex of the child a crossing has to show up on both WHO standards
+var crossingSexes = []growth.Sex{growth.SexMale, growth.SexFemale}
+
+type percentileCrossing struct {
+	fromPercentile float64
+	toPercentile   float64
+	lines          int
+	standard       growth.Standard
+}
+
+// growthSubject is what the reference lookup needs to know about the child
+type growthSubject struct {
+	birthDate          time.Time
+	gestationalAgeDays int
+	sexes              []growth.Sex
+}
+
+func (s *MeasurementService) percentileCrossingSettings() (int, []time.Duration) {
+	config := s.serviceLocator.PercentileCrossingConfig
+	if config == nil {
+		return defaultPercentileCrossingLines, defaultPercentileCrossingWindows
+	}
+	return config.GetPercentileCrossingLines(), config.GetPercentileCrossingWindows()
+}
+
+// percentileCrossingCandidate evaluates the measurement against the target history of the same type,
+// age is counted from the profile birth date or from the birth record of the target
+func (s *MeasurementService) percentileCrossingCandidate(measurement *models.Measurement) (*models.Alert, error) {
+	subject := growthSubject{sexes: crossingSexes}
+	profile := babyProfile(s.serviceLocator, measurement.TargetUuid)
+	if sex, ok := profileSex(profile); ok {
+		subject.sexes = []growth.Sex{sex}
+	}
+	if profile != nil && profile.BirthDate != nil {
+		subject.birthDate = *profile.BirthDate
+	} else if measurement.IsBirth {
+		subject.birthDate = measurement.Timestamp
+	} else {
+		birth, err := s.birthMeasurement(measurement.TargetUuid)
+		if err != nil || birth == nil {
+			return nil, err
+		}
+		subject.birthDate = birth.Timestamp
+	}
+	if profile != nil {
+		subject.gestationalAgeDays = profile.GestationalAgeDays
+	}
+	lines, windows := s.percentileCrossingSettings()
+	var longest time.Duration
+	for _, window := range windows {
+		if window > longest {
+			longest = window
+		}
+	}
+	from := measurement.Timestamp.Add(-longest)
+	history, err := s.dao.GetMeasurementsByFilter(models.MeasurementFilter{
+		TargetUuid: measurement.TargetUuid,
+		Types:      []models.MeasurementType{measurement.Type},
+		From:       &from,
+		To:         &measurement.Timestamp,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return percentileCrossingAlert(history, measurement, subject, windows, lines), nil
+}
+
+// birthMeasurement returns birth record of any type, nil when the target has none
+func (s *MeasurementService) birthMeasurement(targetUuid models.TargetUUID) (*models.Measurement, error) {
+	for _, t := range models.MeasurementTypes {
+		birth, err := s.dao.GetBirthMeasurement(targetUuid, t)
+		if err == nil {
+			return birth, nil
+		}
+		if !gorm.IsRecordNotFoundError(err) {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+// percentileCrossingAlert looks for the largest downward crossing of major percentile lines
+// from any earlier measurement within the windows to the given one
+func percentileCrossingAlert(history []*models.Measurement, measurement *models.Measurement, subject growthSubject, windows []time.Duration, lines int) *models.Alert {
+	if lines <= 0 {
+		return nil
+	}
+	var worst *percentileCrossing
+	var worstWindow time.Duration
+	for _, earlier := range history {
+		elapsed := measurement.Timestamp.Sub(earlier.Timestamp)
+		if earlier.Uuid == measurement.Uuid || earlier.Suspect || elapsed <= 0 {
+			continue
+		}
+		window, ok := shortestWindow(windows, elapsed)
+		if !ok {
+			continue
+		}
+		crossing, ok := crossingBetween(earlier, measurement, subject)
+		if !ok || crossing.lines < lines {
+			continue
+		}
+		if worst == nil || crossing.lines > worst.lines || (crossing.lines == worst.lines && window < worstWindow) {
+			worst, worstWindow = crossing, window
+		}
+	}
+	if worst == nil {
+		return nil
+	}
+	severity := models.AlertSeverityWarning
+	if worst.lines > lines {
+		severity = models.AlertSeverityCritical
+	}
+	return &models.Alert{
+		Kind:     models.AlertKindPercentileCrossing,
+		Severity: severity,
+		Reason: fmt.Sprintf("%s dropped from %s to %s percentile within %d days, crossing %d major percentile lines%s",
+			strings.ToLower(report.TypeTitle(measurement.Type)), ordinal(worst.fromPercentile), ordinal(worst.toPercentile), int(worstWindow.Hours()/24), worst.lines, standardNote(worst.standard)),
+	}
+}
+
+func shortestWindow(windows []time.Duration, elapsed time.Duration) (time.Duration, bool) {
+	var shortest time.Duration
+	for _, window := range windows {
+		if elapsed <= window && (shortest == 0 || window < shortest) {
+			shortest = window
+		}
+	}
+	return shortest, shortest > 0
+}
+
+// crossingBetween computes percentiles of both measurements for each sex and keeps the smallest crossing,
+// a crossing partly on the preterm reference is reported as such
+func crossingBetween(from *models.Measurement, to *models.Measurement, subject growthSubject) (*percentileCrossing, bool) {
+	var result *percentileCrossing
+	for _, sex := range subject.sexes {
+		fromLms, fromStandard, ok := growth.LookupForAge(from.Type, sex, from.Timestamp.Sub(subject.birthDate).Hours()/24, subject.gestationalAgeDays)
+		if !ok {
+			return nil, false
+		}
+		toLms, toStandard, ok := growth.LookupForAge(to.Type, sex, to.Timestamp.Sub(subject.birthDate).Hours()/24, subject.gestationalAgeDays)
+		if !ok {
+			return nil, false
+		}
+		crossing := &percentileCrossing{
+			fromPercentile: fromLms.Percentile(float64(from.Value)),
+			toPercentile:   toLms.Percentile(float64(to.Value)),
+			standard:       toStandard,
+		}
+		if fromStandard == growth.StandardFenton {
+			crossing.standard = fromStandard
+		}
+		crossing.lines = growth.MajorLinesCrossedDown(crossing.fromPercentile, crossing.toPercentile)
+		if result == nil || crossing.lines < result.lines {
+			result = crossing
+		}
+	}
+	return result, result != nil
+}
+
+func standardNote(standard growth.Standard) string {
+	if standard == growth.StandardFenton {
+		return " on preterm growth chart"
+	}
+	return ""
+}
+
+func ordinal(percentile float64) string {
+	n := int(math.Min(math.Max(math.Round(percentile), 1), 99))
+	suffix := "th"
+	if n%100 < 11 || n%100 > 13 {
+		switch n % 10 {
+		case 1:
+			suffix = "st"
+		case 2:
+			suffix = "nd"
+		case 3:
+			suffix = "rd"
+		}
+	}
+	return fmt.Sprintf("%d%s", n, suffix)
+}
@@ -0,0 +1,135 @@
+package services
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"little-diary-measurement-service/src/common"
+	"little-diary-measurement-service/src/config"
+	"little-diary-measurement-service/src/dto"
+	"little-diary-measurement-service/src/growth"
+	"little-diary-measurement-service/src/integrations"
+	"little-diary-measurement-service/src/models"
+	"little-diary-measurement-service/src/test_data"
+	"testing"
+	"time"
+)
+
+func weightAtPercentile(sex growth.Sex, birthDate time.Time, days int, percentile float64) *models.Measurement {
+	lms, _ := growth.Lookup(models.MeasurementTypeWeight, sex, float64(days))
+	return &models.Measurement{
+		Uuid:      models.MeasurementUUID(fmt.Sprintf("%s", uuid.New())),
+		Type:      models.MeasurementTypeWeight,
+		Timestamp: birthDate.AddDate(0, 0, days),
+		Value:     float32(lms.ValueAtPercentile(percentile)),
+	}
+}
+
+func TestPercentileCrossingAlert(t *testing.T) {
+	birthDate := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	windows := []time.Duration{30 * 24 * time.Hour, 90 * 24 * time.Hour}
+	// boys are heavier, so the value is higher on girls chart and lower values cross lines on both charts
+	high := weightAtPercentile(growth.SexMale, birthDate, 60, 70)
+	low := weightAtPercentile(growth.SexFemale, birthDate, 120, 30)
+	veryLow := weightAtPercentile(growth.SexFemale, birthDate, 120, 5)
+	tooOld := weightAtPercentile(growth.SexMale, birthDate, 10, 97)
+	steady := weightAtPercentile(growth.SexMale, birthDate, 120, 60)
+	// two lines on boys chart, only one on girls chart
+	boyHigh := weightAtPercentile(growth.SexMale, birthDate, 60, 55)
+	boyLow := weightAtPercentile(growth.SexMale, birthDate, 120, 10)
+
+	tests := []struct {
+		name        string
+		history     []*models.Measurement
+		measurement *models.Measurement
+		sexes       []growth.Sex
+		lines       int
+		want        models.AlertSeverity
+	}{
+		{name: "two lines", history: []*models.Measurement{high, low}, measurement: low, lines: 2, want: models.AlertSeverityWarning},
+		{name: "three lines", history: []*models.Measurement{high, veryLow}, measurement: veryLow, lines: 2, want: models.AlertSeverityCritical},
+		{name: "more lines configured", history: []*models.Measurement{high, low}, measurement: low, lines: 3},
+		{name: "outside of windows", history: []*models.Measurement{tooOld, low}, measurement: low, lines: 2},
+		{name: "steady", history: []*models.Measurement{high, steady}, measurement: steady, lines: 2},
+		{name: "no history", history: []*models.Measurement{low}, measurement: low, lines: 2},
+		{name: "unknown sex", history: []*models.Measurement{boyHigh, boyLow}, measurement: boyLow, lines: 2},
+		{name: "known sex", history: []*models.Measurement{boyHigh, boyLow}, measurement: boyLow, sexes: []growth.Sex{growth.SexMale}, lines: 2, want: models.AlertSeverityWarning},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			subject := growthSubject{birthDate: birthDate, sexes: tt.sexes}
+			if subject.sexes == nil {
+				subject.sexes = crossingSexes
+			}
+			got := percentileCrossingAlert(tt.history, tt.measurement, subject, windows, tt.lines)
+			if tt.want == "" {
+				assert.Nil(t, got)
+				return
+			}
+			if assert.NotNil(t, got) {
+				assert.Equal(t, tt.want, got.Severity)
+				assert.Equal(t, models.AlertKindPercentileCrossing, got.Kind)
+				assert.Contains(t, got.Reason, "within 90 days")
+			}
+		})
+	}
+}
+
+func TestOrdinal(t *testing.T) {
+	assert.Equal(t, "1st", ordinal(0.2))
+	assert.Equal(t, "2nd", ordinal(2.4))
+	assert.Equal(t, "13th", ordinal(13))
+	assert.Equal(t, "23rd", ordinal(23))
+	assert.Equal(t, "99th", ordinal(99.9))
+}
+
+func TestMeasurementService_SaveDetectsPercentileCrossing(t *testing.T) {
+	targetUuid := models.TargetUUID(fmt.Sprintf("%s", uuid.New()))
+	birthDate := time.Now().AddDate(0, -4, 0)
+	birth := weightAtPercentile(growth.SexMale, birthDate, 0, 50)
+	birth.IsBirth = true
+	high := weightAtPercentile(growth.SexMale, birthDate, 60, 70)
+	for _, m := range []*models.Measurement{birth, high} {
+		m.TargetUuid = targetUuid
+	}
+	low := weightAtPercentile(growth.SexFemale, birthDate, 110, 5)
+	alertDao := newMockAlertDAO()
+	s := NewMeasurementService(&mockMeasurementDAO{records: []*models.Measurement{birth, high}, alerts: alertDao}, alertDao, &common.ServiceLocator{
+		PublicKeyGetter: &config.Config,
+		Authorizer: func() integrations.Authorizer {
+			mockObj := new(test_data.MockAuthorizer)
+			mockObj.On("GetPermissions", mock.Anything, mock.Anything).Return(test_data.ParentPermissions, nil)
+			return mockObj
+		}(),
+	})
+
+	got, err := s.Save(string(low.Uuid), dto.MeasurementRequest{Type: "WEIGHT", Timestamp: low.Timestamp, Value: low.Value, TargetUuid: string(targetUuid)}, "any")
+	assert.Nil(t, err)
+	if assert.Len(t, got.Alerts, 1) {
+		assert.Equal(t, models.AlertKindPercentileCrossing, got.Alerts[0].Kind)
+	}
+}
+
+func TestPercentileCrossingAlertPreterm(t *testing.T) {
+	birthDate := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	subject := growthSubject{birthDate: birthDate, gestationalAgeDays: 30 * 7, sexes: []growth.Sex{growth.SexFemale}}
+	pretermWeight := func(days int, percentile float64) *models.Measurement {
+		lms, _, _ := growth.LookupForAge(models.MeasurementTypeWeight, growth.SexFemale, float64(days), subject.gestationalAgeDays)
+		return &models.Measurement{
+			Uuid:      models.MeasurementUUID(fmt.Sprintf("%s", uuid.New())),
+			Type:      models.MeasurementTypeWeight,
+			Timestamp: birthDate.AddDate(0, 0, days),
+			Value:     float32(lms.ValueAtPercentile(percentile)),
+		}
+	}
+	earlier := pretermWeight(14, 70)
+	later := pretermWeight(56, 10)
+
+	got := percentileCrossingAlert([]*models.Measurement{earlier, later}, later, subject, []time.Duration{90 * 24 * time.Hour}, 2)
+	if assert.NotNil(t, got) {
+		assert.Equal(t, models.AlertSeverityWarning, got.Severity)
+		assert.Contains(t, got.Reason, "from 70th to 10th percentile")
+		assert.Contains(t, got.Reason, "preterm growth chart")
+	}
+}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"little-diary-measurement-service/src/common"
+	"little-diary-measurement-service/src/errors"
+	"little-diary-measurement-service/src/growth"
+	"little-diary-measurement-service/src/models"
+	"log"
+	"math"
+	"time"
+)
+
+// babyProfile returns profile of the target, nil when it is unknown or the family service is not reachable,
+// age aware outputs degrade instead of failing the request
+func babyProfile(locator *common.ServiceLocator, targetUuid models.TargetUUID) *models.BabyProfile {
+	if locator.BabyProfileGetter == nil {
+		return nil
+	}
+	profile, err := locator.BabyProfileGetter.GetBabyProfile(string(targetUuid))
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+	return profile
+}
+
+// profileSex returns sex of the profile when it is one of the reference standards
+func profileSex(profile *models.BabyProfile) (growth.Sex, bool) {
+	if profile == nil {
+		return "", false
+	}
+	sex, err := growth.ParseSex(profile.Sex)
+	return sex, err == nil
+}
+
+// attachAges sets age at measurement for targets with known birth date, preterm babies get corrected age as well
+func attachAges(locator *common.ServiceLocator, measurements []*models.Measurement) {
+	profiles := map[models.TargetUUID]*models.BabyProfile{}
+	for _, m := range measurements {
+		profile, ok := profiles[m.TargetUuid]
+		if !ok {
+			profile = babyProfile(locator, m.TargetUuid)
+			profiles[m.TargetUuid] = profile
+		}
+		m.Age = nil
+		if profile != nil && profile.BirthDate != nil {
+			m.Age = models.AgeAt(*profile.BirthDate, m.Timestamp)
+		}
+		if m.Age != nil && growth.CorrectedAgeDays(float64(m.Age.Days), profile.GestationalAgeDays) != float64(m.Age.Days) {
+			m.Age.Corrected = correctedAge(*profile.BirthDate, profile.GestationalAgeDays, m.Timestamp)
+		}
+	}
+}
+
+func correctedAge(birthDate time.Time, gestationalAgeDays int, at time.Time) *models.MeasurementAge {
+	dueDate := birthDate.AddDate(0, 0, growth.TermGestationDays-gestationalAgeDays)
+	if age := models.AgeAt(dueDate, at); age != nil {
+		return age
+	}
+	days := int(math.Floor(at.Sub(dueDate).Hours() / 24))
+	// weeks are floored like days, 36 days before the due date are 6 weeks before it
+	return &models.MeasurementAge{Days: days, Weeks: int(math.Floor(float64(days) / 7))}
+}
+
+// resolveAgeFilter turns age bounds of the filter into period bounds by birth date from the baby profile
+func resolveAgeFilter(locator *common.ServiceLocator, filter models.MeasurementFilter) (models.MeasurementFilter, error) {
+	if filter.AgeFrom == nil && filter.AgeTo == nil {
+		return filter, nil
+	}
+	profile := babyProfile(locator, filter.TargetUuid)
+	if profile == nil || profile.BirthDate == nil {
+		return filter, &errors.ValidationError{S: "age bounds need birth date, the baby profile does not have it"}
+	}
+	if filter.AgeFrom != nil {
+		from := filter.AgeFrom.Reached(*profile.BirthDate)
+		filter.From = &from
+	}
+	if filter.AgeTo != nil {
+		to := filter.AgeTo.Completed(*profile.BirthDate)
+		filter.To = &to
+	}
+	filter.AgeFrom, filter.AgeTo = nil, nil
+	return filter, nil
+}
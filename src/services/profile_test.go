@@ -0,0 +1,79 @@
+package services
+
+import (
+	"github.com/stretchr/testify/assert"
+	"little-diary-measurement-service/src/common"
+	"little-diary-measurement-service/src/models"
+	"little-diary-measurement-service/src/test_data"
+	"testing"
+	"time"
+)
+
+func TestAttachAges(t *testing.T) {
+	birthDate := time.Date(2020, 1, 31, 0, 0, 0, 0, time.UTC)
+	profiles := new(test_data.MockBabyProfileGetter)
+	profiles.On("GetBabyProfile", "known").Return(&models.BabyProfile{BirthDate: &birthDate, Sex: "male"}, nil)
+	profiles.On("GetBabyProfile", "unknown").Return((*models.BabyProfile)(nil), nil)
+	locator := &common.ServiceLocator{BabyProfileGetter: profiles}
+
+	measurements := []*models.Measurement{
+		{TargetUuid: "known", Timestamp: birthDate.Add(10 * time.Hour)},
+		{TargetUuid: "known", Timestamp: time.Date(2020, 3, 30, 12, 0, 0, 0, time.UTC)},
+		{TargetUuid: "known", Timestamp: time.Date(2020, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{TargetUuid: "known", Timestamp: birthDate.AddDate(0, 0, -1)},
+		{TargetUuid: "unknown", Timestamp: birthDate},
+	}
+	attachAges(locator, measurements)
+
+	assert.Equal(t, &models.MeasurementAge{Days: 0, Weeks: 0, Months: 0}, measurements[0].Age)
+	assert.Equal(t, &models.MeasurementAge{Days: 59, Weeks: 8, Months: 1}, measurements[1].Age)
+	assert.Equal(t, &models.MeasurementAge{Days: 61, Weeks: 8, Months: 2}, measurements[2].Age)
+	assert.Nil(t, measurements[3].Age, "measurement before birth has no age")
+	assert.Nil(t, measurements[4].Age)
+	profiles.AssertNumberOfCalls(t, "GetBabyProfile", 2)
+}
+
+func TestAttachAgesPreterm(t *testing.T) {
+	// born at 32 weeks, due date is 8 weeks after birth
+	birthDate := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	profiles := new(test_data.MockBabyProfileGetter)
+	profiles.On("GetBabyProfile", "preterm").Return(&models.BabyProfile{BirthDate: &birthDate, GestationalAgeDays: 32 * 7}, nil)
+	measurements := []*models.Measurement{
+		{TargetUuid: "preterm", Timestamp: birthDate.AddDate(0, 0, 21)},
+		{TargetUuid: "preterm", Timestamp: birthDate.AddDate(0, 0, 20)},
+		{TargetUuid: "preterm", Timestamp: birthDate.AddDate(0, 0, 56+30)},
+		{TargetUuid: "preterm", Timestamp: birthDate.AddDate(3, 0, 0)},
+	}
+	attachAges(&common.ServiceLocator{BabyProfileGetter: profiles}, measurements)
+
+	assert.Equal(t, &models.MeasurementAge{Days: -35, Weeks: -5}, measurements[0].Age.Corrected)
+	assert.Equal(t, &models.MeasurementAge{Days: -36, Weeks: -6}, measurements[1].Age.Corrected)
+	assert.Equal(t, 30, measurements[2].Age.Corrected.Days)
+	assert.Nil(t, measurements[3].Age.Corrected, "age is not corrected after the second birthday")
+}
+
+func TestResolveAgeFilter(t *testing.T) {
+	birthDate := time.Date(2020, 1, 31, 0, 0, 0, 0, time.UTC)
+	profiles := new(test_data.MockBabyProfileGetter)
+	profiles.On("GetBabyProfile", "known").Return(&models.BabyProfile{BirthDate: &birthDate}, nil)
+	profiles.On("GetBabyProfile", "unknown").Return((*models.BabyProfile)(nil), nil)
+	locator := &common.ServiceLocator{BabyProfileGetter: profiles}
+
+	filter, err := resolveAgeFilter(locator, models.MeasurementFilter{
+		TargetUuid: "known",
+		AgeFrom:    &models.Age{Value: 30, Unit: models.AgeUnitDays},
+		AgeTo:      &models.Age{Value: 6, Unit: models.AgeUnitMonths},
+	})
+	if assert.Nil(t, err) {
+		assert.Equal(t, time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC), *filter.From)
+		assert.Equal(t, time.Date(2020, 8, 31, 0, 0, 0, 0, time.UTC).Add(-time.Nanosecond), *filter.To, "the whole sixth month is included")
+		assert.Nil(t, filter.AgeFrom)
+	}
+
+	_, err = resolveAgeFilter(locator, models.MeasurementFilter{TargetUuid: "unknown", AgeTo: &models.Age{Value: 1, Unit: models.AgeUnitMonths}})
+	assert.NotNil(t, err, "age needs birth date")
+
+	filter, err = resolveAgeFilter(locator, models.MeasurementFilter{TargetUuid: "unknown"})
+	assert.Nil(t, err)
+	assert.Nil(t, filter.From)
+}
@@ -0,0 +1,134 @@
+package services
+
+import (
+	"fmt"
+	"little-diary-measurement-service/src/common"
+	"little-diary-measurement-service/src/errors"
+	"little-diary-measurement-service/src/growth"
+	"little-diary-measurement-service/src/models"
+	"math"
+	"time"
+)
+
+// measurements within the window before the latest one define the current percentile channel
+const projectionBasisWindow = 30 * 24 * time.Hour
+
+// half width of the uncertainty band in z-scores right after the latest measurement and its growth per month ahead,
+// children drift between channels the more the further the projection goes
+const (
+	projectionBandZ         = 0.2
+	projectionBandZPerMonth = 0.15
+)
+
+// ProjectionOptions override sex and birth date of the baby profile, both are needed to read the reference
+type ProjectionOptions struct {
+	Type      models.MeasurementType
+	Sex       growth.Sex
+	BirthDate time.Time
+	Dates     []time.Time
+	// preterm babies are projected on preterm reference and corrected age
+	GestationalAgeDays int
+}
+
+type ProjectionService struct {
+	dao            measurementDAO
+	serviceLocator *common.ServiceLocator
+}
+
+func NewProjectionService(dao measurementDAO, locator *common.ServiceLocator) *ProjectionService {
+	return &ProjectionService{dao, locator}
+}
+
+// Project extrapolates measurements of the type to the requested dates along the current percentile channel
+func (s *ProjectionService) Project(targetUuid string, userUuid string, options ProjectionOptions) (*models.Projection, error) {
+	if err := authorize(s.serviceLocator, userUuid, targetUuid, models.PermissionRead); err != nil {
+		return nil, err
+	}
+	profile := babyProfile(s.serviceLocator, models.TargetUUID(targetUuid))
+	if sex, ok := profileSex(profile); ok && options.Sex == "" {
+		options.Sex = sex
+	}
+	if profile != nil && profile.BirthDate != nil && options.BirthDate.IsZero() {
+		options.BirthDate = *profile.BirthDate
+	}
+	if profile != nil {
+		options.GestationalAgeDays = profile.GestationalAgeDays
+	}
+	if options.Sex == "" || options.BirthDate.IsZero() {
+		return nil, &errors.ValidationError{S: "sex and birth-date are required, the baby profile does not have them"}
+	}
+	measurements, err := s.dao.GetMeasurementsByFilter(models.MeasurementFilter{
+		TargetUuid: models.TargetUUID(targetUuid),
+		Types:      []models.MeasurementType{options.Type},
+	})
+	if err != nil {
+		return nil, err
+	}
+	projection, err := project(measurements, options)
+	if err != nil {
+		return nil, err
+	}
+	attachAges(s.serviceLocator, projection.Basis)
+	return projection, nil
+}
+
+// project estimates the channel as the mean z-score of non suspect measurements within the basis window
+// before the latest one and reads projected values and the band off the reference at the dates
+func project(measurements []*models.Measurement, options ProjectionOptions) (*models.Projection, error) {
+	lookup := func(at time.Time) (growth.LMS, bool) {
+		lms, _, ok := growth.LookupForAge(options.Type, options.Sex, at.Sub(options.BirthDate).Hours()/24, options.GestationalAgeDays)
+		return lms, ok
+	}
+	var latest *models.Measurement
+	for _, m := range measurements {
+		if _, ok := lookup(m.Timestamp); ok && !m.Suspect && (latest == nil || m.Timestamp.After(latest.Timestamp)) {
+			latest = m
+		}
+	}
+	if latest == nil {
+		return nil, &errors.ValidationError{S: fmt.Sprintf("no %s measurements within the reference age range", options.Type)}
+	}
+	projection := &models.Projection{Type: options.Type, Points: []*models.ProjectionPoint{}}
+	var zs []float64
+	for _, m := range measurements {
+		lms, ok := lookup(m.Timestamp)
+		if !ok || m.Suspect || m.Timestamp.After(latest.Timestamp) || latest.Timestamp.Sub(m.Timestamp) > projectionBasisWindow {
+			continue
+		}
+		projection.Basis = append(projection.Basis, m)
+		zs = append(zs, lms.ZScore(float64(m.Value)))
+	}
+	channel, spread := meanAndDeviation(zs)
+	projection.Percentile = growth.ZToPercentile(channel)
+	for _, date := range options.Dates {
+		if !date.After(latest.Timestamp) {
+			return nil, &errors.ValidationError{S: fmt.Sprintf("date %s is not after the latest measurement", date.Format(time.RFC3339))}
+		}
+		lms, ok := lookup(date)
+		if !ok {
+			return nil, &errors.ValidationError{S: fmt.Sprintf("date %s is out of the reference age range", date.Format(time.RFC3339))}
+		}
+		months := date.Sub(latest.Timestamp).Hours() / 24 / growth.DaysInMonth
+		band := math.Sqrt(projectionBandZ*projectionBandZ+spread*spread) + projectionBandZPerMonth*months
+		projection.Points = append(projection.Points, &models.ProjectionPoint{
+			Timestamp: date,
+			Value:     float32(lms.ValueAt(channel)),
+			Low:       float32(lms.ValueAt(channel - band)),
+			High:      float32(lms.ValueAt(channel + band)),
+			Age:       models.AgeAt(options.BirthDate, date),
+		})
+	}
+	return projection, nil
+}
+
+func meanAndDeviation(values []float64) (float64, float64) {
+	var sum, squares float64
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+	for _, v := range values {
+		squares += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(squares / float64(len(values)))
+}
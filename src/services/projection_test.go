@@ -0,0 +1,54 @@
+package services
+
+import (
+	"github.com/stretchr/testify/assert"
+	"little-diary-measurement-service/src/growth"
+	"little-diary-measurement-service/src/models"
+	"testing"
+	"time"
+)
+
+func TestProject(t *testing.T) {
+	birth := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	onPercentile := func(days int, percentile float64) *models.Measurement {
+		lms, _ := growth.Lookup(models.MeasurementTypeWeight, growth.SexFemale, float64(days))
+		return &models.Measurement{Timestamp: birth.AddDate(0, 0, days), Value: float32(lms.ValueAtPercentile(percentile))}
+	}
+	measurements := []*models.Measurement{
+		onPercentile(30, 90),
+		onPercentile(100, 25),
+		onPercentile(120, 25),
+		{Timestamp: birth.AddDate(0, 0, 125), Value: 20000, Suspect: true},
+	}
+	options := ProjectionOptions{
+		Type:      models.MeasurementTypeWeight,
+		Sex:       growth.SexFemale,
+		BirthDate: birth,
+		Dates:     []time.Time{birth.AddDate(0, 0, 150), birth.AddDate(0, 0, 270)},
+	}
+
+	projection, err := project(measurements, options)
+	if assert.Nil(t, err) {
+		assert.InDelta(t, 25, projection.Percentile, 1e-3, "older measurements and suspect values do not define the channel")
+		assert.Len(t, projection.Basis, 2)
+		if assert.Len(t, projection.Points, 2) {
+			near, far := projection.Points[0], projection.Points[1]
+			reference, _ := growth.Lookup(models.MeasurementTypeWeight, growth.SexFemale, 270)
+			assert.InDelta(t, reference.ValueAtPercentile(25), far.Value, 1e-2)
+			assert.True(t, near.Low < near.Value && near.Value < near.High)
+			assert.True(t, far.High-far.Low > near.High-near.Low, "the band widens with the horizon")
+			assert.Equal(t, 8, far.Age.Months)
+		}
+	}
+
+	options.Dates = []time.Time{birth.AddDate(0, 0, 110)}
+	_, err = project(measurements, options)
+	assert.NotNil(t, err, "dates before the latest measurement are not projected")
+
+	options.Dates = []time.Time{birth.AddDate(3, 0, 0)}
+	_, err = project(measurements, options)
+	assert.NotNil(t, err, "dates out of the reference are not projected")
+
+	_, err = project(nil, options)
+	assert.NotNil(t, err)
+}
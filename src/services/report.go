@@ -0,0 +1,44 @@
+package services
+
+import (
+	"io"
+	"little-diary-measurement-service/src/common"
+	"little-diary-measurement-service/src/models"
+	"little-diary-measurement-service/src/report"
+	"time"
+)
+
+type ReportService struct {
+	dao            measurementDAO
+	serviceLocator *common.ServiceLocator
+}
+
+func NewReportService(dao measurementDAO, locator *common.ServiceLocator) *ReportService {
+	return &ReportService{dao, locator}
+}
+
+func (s *ReportService) WriteGrowthReport(w io.Writer, filter models.MeasurementFilter, userUuid string) error {
+	if err := authorize(s.serviceLocator, userUuid, string(filter.TargetUuid), models.PermissionRead); err != nil {
+		return err
+	}
+	filter, err := resolveAgeFilter(s.serviceLocator, filter)
+	if err != nil {
+		return err
+	}
+	if len(filter.Types) == 0 {
+		filter.Types = models.MeasurementTypes
+	}
+	measurements, err := s.dao.GetMeasurementsByFilter(filter)
+	if err != nil {
+		return err
+	}
+	growthReport := report.GrowthReport{
+		TargetUuid:   filter.TargetUuid,
+		From:         filter.From,
+		To:           filter.To,
+		Types:        filter.Types,
+		Measurements: measurements,
+		GeneratedAt:  time.Now(),
+	}
+	return growthReport.Render(w)
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"fmt"
+	"little-diary-measurement-service/src/common"
+	"little-diary-measurement-service/src/errors"
+	"little-diary-measurement-service/src/events"
+	"little-diary-measurement-service/src/models"
+)
+
+const (
+	// a client further behind than that many events is told to reload instead of replaying them
+	streamBacklogLimit = 500
+	streamBuffer       = 64
+)
+
+type streamDAO interface {
+	GetEventsAfter(targetUuid models.TargetUUID, afterId int64, limit int) ([]*models.OutboxEvent, error)
+	GetFirstEventId() (int64, error)
+	GetLastEventId(targetUuid models.TargetUUID) (int64, error)
+}
+
+// MeasurementStream is the replay of events missed since the last event id followed by live events.
+// Outbox ids are taken before commit, so live events may come out of id order, and live events already replayed
+// are to be skipped by their sequence
+type MeasurementStream struct {
+	Backlog []events.Message
+	// Reset tells that the client missed too much or events it missed are not kept anymore and should reload
+	// measurements, ResetId is the event id to resume after
+	Reset   bool
+	ResetId int64
+	Live    *events.Subscription
+}
+
+type StreamService struct {
+	dao            streamDAO
+	serviceLocator *common.ServiceLocator
+}
+
+func NewStreamService(dao streamDAO, locator *common.ServiceLocator) *StreamService {
+	return &StreamService{dao, locator}
+}
+
+// Open subscribes the user to measurement events of the target, lastEventId is the outbox id the client has seen last
+// or zero for live events only. Every user has a limited number of open streams, the stream is closed by Live.Close
+func (s *StreamService) Open(targetUuid string, userUuid string, lastEventId int64) (*MeasurementStream, error) {
+	if s.serviceLocator.StreamHub == nil {
+		return nil, fmt.Errorf("live streams are not configured")
+	}
+	if err := authorize(s.serviceLocator, userUuid, targetUuid, models.PermissionRead); err != nil {
+		return nil, err
+	}
+	limit := 0
+	if s.serviceLocator.StreamConfig != nil {
+		limit = s.serviceLocator.StreamConfig.GetStreamConnectionsPerUser()
+	}
+	// subscribing before reading the backlog leaves no gap between them
+	live, ok := s.serviceLocator.StreamHub.Subscribe(targetUuid, userUuid, limit, streamBuffer)
+	if !ok {
+		return nil, &errors.LimitError{S: fmt.Sprintf("at most %d streams may be open at once", limit)}
+	}
+	stream := &MeasurementStream{Live: live}
+	if lastEventId <= 0 {
+		return stream, nil
+	}
+	first, err := s.dao.GetFirstEventId()
+	if err != nil {
+		live.Close()
+		return nil, err
+	}
+	backlog, err := s.dao.GetEventsAfter(models.TargetUUID(targetUuid), lastEventId, streamBacklogLimit+1)
+	if err != nil {
+		live.Close()
+		return nil, err
+	}
+	// events between the last one seen and the oldest one kept may have been removed by the outbox retention
+	if len(backlog) > streamBacklogLimit || lastEventId < first-1 || first == 0 {
+		if stream.ResetId, err = s.dao.GetLastEventId(models.TargetUUID(targetUuid)); err != nil {
+			live.Close()
+			return nil, err
+		}
+		// resuming after the oldest kept event does not reset again while the target has no newer events
+		if stream.ResetId < first-1 {
+			stream.ResetId = first - 1
+		}
+		stream.Reset = true
+		return stream, nil
+	}
+	for _, event := range backlog {
+		stream.Backlog = append(stream.Backlog, events.Message{
+			ID:       event.EventUuid,
+			Sequence: int64(event.ID),
+			Subject:  string(event.Type),
+			Key:      string(event.TargetUuid),
+			Data:     []byte(event.Payload),
+		})
+	}
+	return stream, nil
+}
+
+// CheckAccess tells whether the user may still read events of the target, open streams are closed once not
+func (s *StreamService) CheckAccess(targetUuid string, userUuid string) error {
+	return authorize(s.serviceLocator, userUuid, targetUuid, models.PermissionRead)
+}
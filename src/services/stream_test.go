@@ -0,0 +1,125 @@
+package services
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"little-diary-measurement-service/src/common"
+	"little-diary-measurement-service/src/errors"
+	"little-diary-measurement-service/src/events"
+	"little-diary-measurement-service/src/models"
+	"little-diary-measurement-service/src/test_data"
+	"testing"
+	"time"
+)
+
+type mockStreamDAO struct {
+	events []*models.OutboxEvent
+}
+
+func (m *mockStreamDAO) GetEventsAfter(targetUuid models.TargetUUID, afterId int64, limit int) ([]*models.OutboxEvent, error) {
+	var res []*models.OutboxEvent
+	for _, event := range m.events {
+		if event.TargetUuid == targetUuid && int64(event.ID) > afterId && len(res) < limit {
+			res = append(res, event)
+		}
+	}
+	return res, nil
+}
+
+func (m *mockStreamDAO) GetFirstEventId() (int64, error) {
+	if len(m.events) == 0 {
+		return 0, nil
+	}
+	return int64(m.events[0].ID), nil
+}
+
+func (m *mockStreamDAO) GetLastEventId(targetUuid models.TargetUUID) (int64, error) {
+	var last int64
+	for _, event := range m.events {
+		if event.TargetUuid == targetUuid {
+			last = int64(event.ID)
+		}
+	}
+	return last, nil
+}
+
+type streamConfig struct{}
+
+func (streamConfig) GetStreamHeartbeat() time.Duration   { return time.Second }
+func (streamConfig) GetStreamMaxDuration() time.Duration { return time.Minute }
+func (streamConfig) GetStreamConnectionsPerUser() int    { return 2 }
+
+func TestStreamService_Open(t *testing.T) {
+	authorizer := new(test_data.MockAuthorizer)
+	authorizer.On("GetPermissions", "parent", mock.Anything).Return(test_data.ParentPermissions, nil)
+	authorizer.On("GetPermissions", "stranger", mock.Anything).Return(models.Permissions{}, nil)
+	dao := &mockStreamDAO{}
+	for i := 1; i <= streamBacklogLimit+2; i++ {
+		dao.events = append(dao.events, &models.OutboxEvent{ID: uint(i), TargetUuid: "t1", Type: models.OutboxEventMeasurementCreated, Payload: "{}"})
+	}
+	hub := events.NewHub()
+	s := NewStreamService(dao, &common.ServiceLocator{Authorizer: authorizer, StreamHub: hub, StreamConfig: streamConfig{}})
+
+	stream, err := s.Open("t1", "parent", int64(streamBacklogLimit-1))
+	if assert.Nil(t, err) {
+		assert.False(t, stream.Reset)
+		if assert.Len(t, stream.Backlog, 3) {
+			assert.Equal(t, int64(streamBacklogLimit), stream.Backlog[0].Sequence)
+			assert.Equal(t, string(models.OutboxEventMeasurementCreated), stream.Backlog[0].Subject)
+		}
+		hub.Publish(events.Message{Sequence: 1000, Key: "t1"})
+		assert.Equal(t, int64(1000), (<-stream.Live.C).Sequence)
+	}
+
+	reset, err := s.Open("t1", "parent", 1)
+	if assert.Nil(t, err) {
+		assert.True(t, reset.Reset)
+		assert.Equal(t, int64(streamBacklogLimit+2), reset.ResetId)
+		assert.Empty(t, reset.Backlog)
+	}
+
+	_, err = s.Open("t1", "parent", 0)
+	assert.IsType(t, &errors.LimitError{}, err)
+	stream.Live.Close()
+	live, err := s.Open("t1", "parent", 0)
+	if assert.Nil(t, err, "closed stream frees the limit") {
+		assert.Empty(t, live.Backlog)
+	}
+
+	_, err = s.Open("t1", "stranger", 0)
+	assert.IsType(t, &errors.ForbiddenError{}, err)
+	assert.IsType(t, &errors.ForbiddenError{}, s.CheckAccess("t1", "stranger"))
+	assert.Nil(t, s.CheckAccess("t1", "parent"))
+}
+
+func TestStreamService_OpenAfterRetention(t *testing.T) {
+	authorizer := new(test_data.MockAuthorizer)
+	authorizer.On("GetPermissions", "parent", mock.Anything).Return(test_data.ParentPermissions, nil)
+	// events up to 9 were removed by the retention
+	dao := &mockStreamDAO{events: []*models.OutboxEvent{
+		{ID: 10, TargetUuid: "other", Type: models.OutboxEventMeasurementCreated, Payload: "{}"},
+		{ID: 12, TargetUuid: "t1", Type: models.OutboxEventMeasurementCreated, Payload: "{}"},
+	}}
+	s := NewStreamService(dao, &common.ServiceLocator{Authorizer: authorizer, StreamHub: events.NewHub()})
+
+	stream, err := s.Open("t1", "parent", 5)
+	if assert.Nil(t, err) {
+		assert.True(t, stream.Reset, "removed events cannot be replayed")
+		assert.Equal(t, int64(12), stream.ResetId)
+		assert.Empty(t, stream.Backlog)
+		stream.Live.Close()
+	}
+
+	stream, err = s.Open("t1", "parent", 9)
+	if assert.Nil(t, err) {
+		assert.False(t, stream.Reset)
+		assert.Len(t, stream.Backlog, 1)
+		stream.Live.Close()
+	}
+
+	stream, err = s.Open("quiet", "parent", 5)
+	if assert.Nil(t, err) {
+		assert.True(t, stream.Reset)
+		assert.Equal(t, int64(9), stream.ResetId, "resuming after it does not reset again")
+	}
+}
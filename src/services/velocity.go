@@ -0,0 +1,98 @@
+package services
+
+import (
+	"little-diary-measurement-service/src/common"
+	"little-diary-measurement-service/src/growth"
+	"little-diary-measurement-service/src/models"
+	"time"
+)
+
+// intervals shorter than that are mostly measurement noise and are not reported
+const minVelocityInterval = 12 * time.Hour
+
+// VelocityOptions enable comparison with the reference when both fields are set,
+// empty options are filled from the baby profile
+type VelocityOptions struct {
+	Sex       growth.Sex
+	BirthDate *time.Time
+	// preterm babies are compared at corrected age
+	GestationalAgeDays int
+}
+
+type VelocityService struct {
+	dao            measurementDAO
+	serviceLocator *common.ServiceLocator
+}
+
+func NewVelocityService(dao measurementDAO, locator *common.ServiceLocator) *VelocityService {
+	return &VelocityService{dao, locator}
+}
+
+// GetVelocity computes growth velocity of the single filter type
+func (s *VelocityService) GetVelocity(filter models.MeasurementFilter, options VelocityOptions, userUuid string) (*models.Velocity, error) {
+	if err := authorize(s.serviceLocator, userUuid, string(filter.TargetUuid), models.PermissionRead); err != nil {
+		return nil, err
+	}
+	profile := babyProfile(s.serviceLocator, filter.TargetUuid)
+	if sex, ok := profileSex(profile); ok && profile.BirthDate != nil && options.Sex == "" && options.BirthDate == nil {
+		options.Sex, options.BirthDate = sex, profile.BirthDate
+	}
+	if profile != nil {
+		options.GestationalAgeDays = profile.GestationalAgeDays
+	}
+	filter, err := resolveAgeFilter(s.serviceLocator, filter)
+	if err != nil {
+		return nil, err
+	}
+	measurements, err := s.dao.GetMeasurementsByFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	return computeVelocity(filter.Types[0], measurements, options), nil
+}
+
+func computeVelocity(t models.MeasurementType, measurements []*models.Measurement, options VelocityOptions) *models.Velocity {
+	unit, period := growth.VelocityUnit(t)
+	velocity := &models.Velocity{Type: t, Unit: unit, Intervals: []*models.VelocityInterval{}}
+	var previous *models.Measurement
+	for _, m := range measurements {
+		if previous != nil {
+			if m.Timestamp.Sub(previous.Timestamp) < minVelocityInterval {
+				continue
+			}
+			velocity.Intervals = append(velocity.Intervals, velocityInterval(t, previous, m, period, options))
+		}
+		previous = m
+	}
+	if len(measurements) > 1 {
+		first, last := measurements[0], measurements[len(measurements)-1]
+		if last.Timestamp.Sub(first.Timestamp) >= minVelocityInterval {
+			velocity.Window = velocityInterval(t, first, last, period, options)
+		}
+	}
+	return velocity
+}
+
+func velocityInterval(t models.MeasurementType, from *models.Measurement, to *models.Measurement, period float64, options VelocityOptions) *models.VelocityInterval {
+	days := to.Timestamp.Sub(from.Timestamp).Hours() / 24
+	interval := &models.VelocityInterval{
+		From: from,
+		To:   to,
+		Days: days,
+		Gain: float64(to.Value - from.Value),
+	}
+	interval.Rate = interval.Gain / days * period
+	if options.Sex == "" || options.BirthDate == nil {
+		return interval
+	}
+	fromAge := from.Timestamp.Sub(*options.BirthDate).Hours() / 24
+	toAge := to.Timestamp.Sub(*options.BirthDate).Hours() / 24
+	if comparison, ok := growth.CompareVelocity(t, options.Sex, options.GestationalAgeDays, fromAge, toAge, interval.Gain); ok {
+		interval.Reference = &models.VelocityReference{
+			IntervalMonths: comparison.Months,
+			MedianRate:     comparison.MedianGain / days * period,
+			Percentile:     comparison.Percentile,
+		}
+	}
+	return interval
+}
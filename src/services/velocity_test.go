@@ -0,0 +1,99 @@
+package services
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"little-diary-measurement-service/src/common"
+	"little-diary-measurement-service/src/config"
+	"little-diary-measurement-service/src/growth"
+	"little-diary-measurement-service/src/integrations"
+	"little-diary-measurement-service/src/models"
+	"little-diary-measurement-service/src/test_data"
+	"testing"
+	"time"
+)
+
+func TestComputeVelocity(t *testing.T) {
+	birth := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	weight := func(days float64, value float32) *models.Measurement {
+		return &models.Measurement{Type: models.MeasurementTypeWeight, Timestamp: birth.Add(time.Duration(days * 24 * float64(time.Hour))), Value: value}
+	}
+	measurements := []*models.Measurement{
+		weight(0, 3300),
+		weight(4, 3100),
+		weight(4.1, 3120),
+		weight(14, 3500),
+	}
+
+	velocity := computeVelocity(models.MeasurementTypeWeight, measurements, VelocityOptions{})
+	assert.Equal(t, "g/day", velocity.Unit)
+	if assert.Len(t, velocity.Intervals, 2, "measurements closer than 12 hours are not paired") {
+		assert.InDelta(t, -50, velocity.Intervals[0].Rate, 1e-6)
+		assert.InDelta(t, 40, velocity.Intervals[1].Rate, 1e-6)
+		assert.Nil(t, velocity.Intervals[0].Reference)
+	}
+	if assert.NotNil(t, velocity.Window) {
+		assert.InDelta(t, 14, velocity.Window.Days, 1e-6)
+		assert.InDelta(t, 200, velocity.Window.Gain, 1e-6)
+	}
+
+	velocity = computeVelocity(models.MeasurementTypeWeight, measurements, VelocityOptions{Sex: growth.SexFemale, BirthDate: &birth})
+	assert.Nil(t, velocity.Window.Reference, "two weeks fit no WHO increment interval")
+
+	// test_data/who_velocity.csv: boys gain 1000 g in the first month, 925 g in the second and 1900 g in both
+	monthly := []*models.Measurement{weight(0, 3300), weight(growth.DaysInMonth, 4300), weight(2*growth.DaysInMonth, 5100)}
+	velocity = computeVelocity(models.MeasurementTypeWeight, monthly, VelocityOptions{Sex: growth.SexMale, BirthDate: &birth})
+	if assert.Len(t, velocity.Intervals, 2) && assert.NotNil(t, velocity.Intervals[0].Reference) && assert.NotNil(t, velocity.Intervals[1].Reference) {
+		assert.Equal(t, 1, velocity.Intervals[0].Reference.IntervalMonths)
+		assert.InDelta(t, 1000/growth.DaysInMonth, velocity.Intervals[0].Reference.MedianRate, 1e-6)
+		assert.InDelta(t, 50, velocity.Intervals[0].Reference.Percentile, 1e-6)
+		assert.Less(t, velocity.Intervals[1].Reference.Percentile, 50.0)
+	}
+	if assert.NotNil(t, velocity.Window.Reference) {
+		assert.Equal(t, 2, velocity.Window.Reference.IntervalMonths)
+		assert.InDelta(t, 1900/(2*growth.DaysInMonth), velocity.Window.Reference.MedianRate, 1e-6)
+		assert.Less(t, velocity.Window.Reference.Percentile, 50.0)
+	}
+
+	length := &models.Measurement{Type: models.MeasurementTypeHeight, Timestamp: birth, Value: 50}
+	grown := &models.Measurement{Type: models.MeasurementTypeHeight, Timestamp: birth.AddDate(0, 0, 61), Value: 58}
+	velocity = computeVelocity(models.MeasurementTypeHeight, []*models.Measurement{length, grown}, VelocityOptions{})
+	assert.Equal(t, "cm/month", velocity.Unit)
+	assert.InDelta(t, 8/61.0*growth.DaysInMonth, velocity.Window.Rate, 1e-6)
+
+	velocity = computeVelocity(models.MeasurementTypeHeight, []*models.Measurement{length}, VelocityOptions{})
+	assert.Empty(t, velocity.Intervals)
+	assert.Nil(t, velocity.Window)
+}
+
+func TestVelocityService_GetVelocity(t *testing.T) {
+	tests := []struct {
+		name        string
+		permissions models.Permissions
+		wantErr     bool
+	}{
+		{name: "velocity", permissions: test_data.ParentPermissions},
+		{name: "test access denied", permissions: models.Permissions{}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewVelocityService(newMockMeasurementDAO(), &common.ServiceLocator{
+				PublicKeyGetter: &config.Config,
+				Authorizer: func() integrations.Authorizer {
+					mockObj := new(test_data.MockAuthorizer)
+					mockObj.On("GetPermissions", mock.Anything, mock.Anything).Return(tt.permissions, nil)
+					return mockObj
+				}(),
+			})
+			filter := models.MeasurementFilter{TargetUuid: models.TargetUUID(tUuid), Types: []models.MeasurementType{models.MeasurementTypeHeight}}
+			got, err := s.GetVelocity(filter, VelocityOptions{}, "any")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GetVelocity() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err == nil {
+				assert.Equal(t, models.MeasurementTypeHeight, got.Type)
+			}
+		})
+	}
+}
@@ -0,0 +1,370 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"little-diary-measurement-service/src/common"
+	"little-diary-measurement-service/src/dto"
+	"little-diary-measurement-service/src/errors"
+	"little-diary-measurement-service/src/events"
+	"little-diary-measurement-service/src/integrations"
+	"little-diary-measurement-service/src/models"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	// a delivery is tried that many times, waiting twice as long after every failure starting from the base delay
+	webhookMaxAttempts     = 8
+	webhookRetryBaseDelay  = 30 * time.Second
+	webhookRetryMaxDelay   = time.Hour
+	webhookDisableAfter    = 5
+	webhookDeliveryLogSize = 100
+	webhookSecretBytes     = 32
+	webhookLastErrorLength = 500
+)
+
+type webhookDAO interface {
+	GetByWebhookUuid(webhookUuid models.WebhookUUID) (*models.Webhook, error)
+	GetWebhooksByTargetUuid(targetUuid models.TargetUUID) ([]*models.Webhook, error)
+	SaveWebhook(webhook *models.Webhook) error
+	DeleteWebhook(webhook *models.Webhook) error
+	GetDelivery(deliveryUuid models.WebhookDeliveryUUID) (*models.WebhookDelivery, error)
+	GetDeliveries(webhookUuid models.WebhookUUID, limit int) ([]*models.WebhookDelivery, error)
+	CreateDelivery(delivery *models.WebhookDelivery) error
+	SaveDelivery(delivery *models.WebhookDelivery) error
+	ClaimDueDeliveries(limit int, now time.Time) ([]*models.WebhookDelivery, error)
+}
+
+// WebhookService manages webhook subscriptions of targets, reading needs read permission and changes need write
+type WebhookService struct {
+	dao            webhookDAO
+	serviceLocator *common.ServiceLocator
+}
+
+func NewWebhookService(dao webhookDAO, locator *common.ServiceLocator) *WebhookService {
+	return &WebhookService{dao, locator}
+}
+
+// Create subscribes the url to events of the target, the returned webhook holds the generated signing secret
+func (s *WebhookService) Create(request dto.WebhookRequest, userUuid string) (*models.Webhook, error) {
+	eventTypes, err := validateWebhook(request)
+	if err != nil {
+		return nil, err
+	}
+	if err := authorize(s.serviceLocator, userUuid, request.TargetUuid, models.PermissionWrite); err != nil {
+		return nil, err
+	}
+	secret := make([]byte, webhookSecretBytes)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	webhook := &models.Webhook{
+		Uuid:       models.WebhookUUID(uuid.New().String()),
+		TargetUuid: models.TargetUUID(request.TargetUuid),
+		CreatedBy:  userUuid,
+		Url:        request.Url,
+		Secret:     hex.EncodeToString(secret),
+		Enabled:    request.Enabled == nil || *request.Enabled,
+	}
+	webhook.SetEventTypes(eventTypes)
+	return webhook, s.dao.SaveWebhook(webhook)
+}
+
+func (s *WebhookService) GetByTargetUuid(targetUuid string, userUuid string) ([]*models.Webhook, error) {
+	if err := authorize(s.serviceLocator, userUuid, targetUuid, models.PermissionRead); err != nil {
+		return nil, err
+	}
+	return s.dao.GetWebhooksByTargetUuid(models.TargetUUID(targetUuid))
+}
+
+func (s *WebhookService) Get(webhookUuid string, userUuid string) (*models.Webhook, error) {
+	return s.authorizedWebhook(webhookUuid, userUuid, models.PermissionRead)
+}
+
+// Update replaces url and event types of the webhook, enabling it again clears the failures it was disabled for.
+// The target of a webhook does not change
+func (s *WebhookService) Update(webhookUuid string, request dto.WebhookRequest, userUuid string) (*models.Webhook, error) {
+	webhook, err := s.authorizedWebhook(webhookUuid, userUuid, models.PermissionWrite)
+	if err != nil {
+		return nil, err
+	}
+	request.TargetUuid = string(webhook.TargetUuid)
+	eventTypes, err := validateWebhook(request)
+	if err != nil {
+		return nil, err
+	}
+	webhook.Url = request.Url
+	webhook.SetEventTypes(eventTypes)
+	if request.Enabled != nil {
+		if *request.Enabled && !webhook.Enabled {
+			webhook.ConsecutiveFailures = 0
+			webhook.DisabledAt = nil
+		}
+		webhook.Enabled = *request.Enabled
+	}
+	return webhook, s.dao.SaveWebhook(webhook)
+}
+
+func (s *WebhookService) Delete(webhookUuid string, userUuid string) error {
+	webhook, err := s.authorizedWebhook(webhookUuid, userUuid, models.PermissionWrite)
+	if err != nil {
+		return err
+	}
+	return s.dao.DeleteWebhook(webhook)
+}
+
+// GetDeliveries returns the delivery log of the webhook, the newest first
+func (s *WebhookService) GetDeliveries(webhookUuid string, userUuid string) ([]*models.WebhookDelivery, error) {
+	webhook, err := s.authorizedWebhook(webhookUuid, userUuid, models.PermissionRead)
+	if err != nil {
+		return nil, err
+	}
+	return s.dao.GetDeliveries(webhook.Uuid, webhookDeliveryLogSize)
+}
+
+// Redeliver queues the delivery again with a fresh set of attempts, the payload and event uuid stay the same
+func (s *WebhookService) Redeliver(webhookUuid string, deliveryUuid string, userUuid string) (*models.WebhookDelivery, error) {
+	webhook, err := s.authorizedWebhook(webhookUuid, userUuid, models.PermissionWrite)
+	if err != nil {
+		return nil, err
+	}
+	delivery, err := s.dao.GetDelivery(models.WebhookDeliveryUUID(deliveryUuid))
+	if err != nil {
+		return nil, err
+	}
+	if delivery.WebhookUuid != webhook.Uuid {
+		return nil, &errors.ValidationError{S: "delivery does not belong to the webhook"}
+	}
+	now := time.Now()
+	delivery.Status = models.WebhookDeliveryPending
+	delivery.Attempts = 0
+	delivery.NextAttemptAt = &now
+	return delivery, s.dao.SaveDelivery(delivery)
+}
+
+func (s *WebhookService) authorizedWebhook(webhookUuid string, userUuid string, action models.Permission) (*models.Webhook, error) {
+	webhook, err := s.dao.GetByWebhookUuid(models.WebhookUUID(webhookUuid))
+	if err != nil {
+		return nil, err
+	}
+	if err := authorize(s.serviceLocator, userUuid, string(webhook.TargetUuid), action); err != nil {
+		return nil, err
+	}
+	return webhook, nil
+}
+
+// validateWebhook checks url and event types of the request, no event types subscribe to all of them
+func validateWebhook(request dto.WebhookRequest) ([]models.OutboxEventType, error) {
+	if _, err := uuid.Parse(request.TargetUuid); err != nil {
+		return nil, &errors.ValidationError{S: "target_uuid must be a uuid"}
+	}
+	u, err := url.Parse(request.Url)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return nil, &errors.ValidationError{S: "url must be an absolute http or https url"}
+	}
+	var eventTypes []models.OutboxEventType
+	for _, name := range request.EventTypes {
+		known := false
+		for _, t := range models.OutboxEventTypes {
+			known = known || string(t) == name
+		}
+		if !known {
+			return nil, &errors.ValidationError{S: fmt.Sprintf("event type %s does not exist", name)}
+		}
+		eventTypes = append(eventTypes, models.OutboxEventType(name))
+	}
+	return eventTypes, nil
+}
+
+// WebhookPublisher queues measurement events for the enabled webhooks of their target subscribed to the event type.
+// Webhooks whose creator has lost read access to the target get nothing. Queueing an event again is a no-op
+type WebhookPublisher struct {
+	dao            webhookDAO
+	serviceLocator *common.ServiceLocator
+}
+
+func NewWebhookPublisher(dao webhookDAO, locator *common.ServiceLocator) *WebhookPublisher {
+	return &WebhookPublisher{dao, locator}
+}
+
+func (p *WebhookPublisher) Publish(message events.Message) error {
+	var event models.MeasurementEvent
+	if err := json.Unmarshal(message.Data, &event); err != nil {
+		return err
+	}
+	webhooks, err := p.dao.GetWebhooksByTargetUuid(models.TargetUUID(message.Key))
+	if err != nil {
+		return err
+	}
+	for _, webhook := range webhooks {
+		if !webhook.Enabled || !webhook.Subscribes(event.Type) {
+			continue
+		}
+		if err := authorize(p.serviceLocator, webhook.CreatedBy, message.Key, models.PermissionRead); err != nil {
+			if _, ok := err.(*errors.ForbiddenError); ok {
+				continue
+			}
+			return err
+		}
+		now := time.Now()
+		delivery := &models.WebhookDelivery{
+			Uuid:          models.WebhookDeliveryUUID(uuid.New().String()),
+			WebhookUuid:   webhook.Uuid,
+			EventUuid:     message.ID,
+			EventType:     event.Type,
+			Payload:       string(message.Data),
+			Status:        models.WebhookDeliveryPending,
+			NextAttemptAt: &now,
+		}
+		if err := p.dao.CreateDelivery(delivery); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *WebhookPublisher) Close() error {
+	return nil
+}
+
+// WebhookDispatcher sends queued deliveries, failed attempts are retried with exponential backoff
+// and a webhook failing webhookDisableAfter deliveries in a row is disabled
+type WebhookDispatcher struct {
+	dao    webhookDAO
+	poster integrations.WebhookPoster
+}
+
+func NewWebhookDispatcher(dao webhookDAO, poster integrations.WebhookPoster) *WebhookDispatcher {
+	return &WebhookDispatcher{dao, poster}
+}
+
+// DeliverDue attempts deliveries due by now and returns how many succeeded
+func (d *WebhookDispatcher) DeliverDue(limit int) (int, error) {
+	now := time.Now()
+	deliveries, err := d.dao.ClaimDueDeliveries(limit, now)
+	if err != nil {
+		return 0, err
+	}
+	sort.Slice(deliveries, func(i, j int) bool {
+		return deliveries[i].ID < deliveries[j].ID
+	})
+	webhooks := map[models.WebhookUUID]*models.Webhook{}
+	succeeded := 0
+	for _, delivery := range deliveries {
+		webhook, ok := webhooks[delivery.WebhookUuid]
+		if !ok {
+			if webhook, err = d.dao.GetByWebhookUuid(delivery.WebhookUuid); err != nil {
+				return succeeded, err
+			}
+			webhooks[delivery.WebhookUuid] = webhook
+		}
+		if !webhook.Enabled {
+			continue
+		}
+		ok, err := d.attempt(webhook, delivery, time.Now())
+		if err != nil {
+			return succeeded, err
+		}
+		if ok {
+			succeeded++
+		}
+	}
+	return succeeded, nil
+}
+
+// attempt posts the delivery once and records the outcome on the delivery and the webhook
+func (d *WebhookDispatcher) attempt(webhook *models.Webhook, delivery *models.WebhookDelivery, now time.Time) (bool, error) {
+	body := []byte(delivery.Payload)
+	status, err := d.poster.PostWebhook(webhook.Url, signatureHeaders(webhook, delivery, body, now), body)
+	delivery.Attempts++
+	delivery.LastAttemptAt = &now
+	delivery.LastStatusCode = status
+	delivery.LastError = ""
+	if err != nil {
+		delivery.LastError = truncate(err.Error(), webhookLastErrorLength)
+	} else if status < 200 || status >= 300 {
+		delivery.LastError = fmt.Sprintf("subscriber responded with status %d", status)
+	}
+	succeeded := delivery.LastError == ""
+
+	webhookChanged := false
+	switch {
+	case succeeded:
+		delivery.Status = models.WebhookDeliverySucceeded
+		delivery.NextAttemptAt = nil
+		webhookChanged = webhook.ConsecutiveFailures != 0
+		webhook.ConsecutiveFailures = 0
+	case delivery.Attempts >= webhookMaxAttempts:
+		delivery.Status = models.WebhookDeliveryFailed
+		delivery.NextAttemptAt = nil
+		webhookChanged = true
+		webhook.ConsecutiveFailures++
+		if webhook.ConsecutiveFailures >= webhookDisableAfter {
+			webhook.Enabled = false
+			webhook.DisabledAt = &now
+			log.Printf("webhook %s disabled after %d failed deliveries", webhook.Uuid, webhook.ConsecutiveFailures)
+		}
+	default:
+		next := now.Add(webhookRetryDelay(delivery.Attempts))
+		delivery.NextAttemptAt = &next
+	}
+	if err := d.dao.SaveDelivery(delivery); err != nil {
+		return false, err
+	}
+	if webhookChanged {
+		if err := d.dao.SaveWebhook(webhook); err != nil {
+			return false, err
+		}
+	}
+	return succeeded, nil
+}
+
+// webhookRetryDelay is the wait after the given number of failed attempts
+func webhookRetryDelay(attempts int) time.Duration {
+	delay := webhookRetryBaseDelay
+	for i := 1; i < attempts && delay < webhookRetryMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > webhookRetryMaxDelay {
+		delay = webhookRetryMaxDelay
+	}
+	return delay
+}
+
+// signatureHeaders identify the delivery and sign it: X-Webhook-Signature is sha256= followed by hex encoded
+// HMAC-SHA256 of the X-Webhook-Timestamp value, a dot and the body, keyed by the webhook secret
+func signatureHeaders(webhook *models.Webhook, delivery *models.WebhookDelivery, body []byte, now time.Time) http.Header {
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	headers := http.Header{}
+	headers.Set("X-Webhook-Id", string(webhook.Uuid))
+	headers.Set("X-Webhook-Delivery", string(delivery.Uuid))
+	headers.Set("X-Webhook-Event", string(delivery.EventType))
+	headers.Set("X-Webhook-Timestamp", timestamp)
+	headers.Set("X-Webhook-Signature", "sha256="+webhookSignature(webhook.Secret, timestamp, body))
+	return headers
+}
+
+func webhookSignature(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func truncate(s string, length int) string {
+	if len(s) <= length {
+		return s
+	}
+	return s[:length]
+}
@@ -0,0 +1,290 @@
+package services
+
+import (
+	"fmt"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"little-diary-measurement-service/src/common"
+	"little-diary-measurement-service/src/dto"
+	"little-diary-measurement-service/src/errors"
+	"little-diary-measurement-service/src/events"
+	"little-diary-measurement-service/src/models"
+	"little-diary-measurement-service/src/test_data"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+type mockWebhookDAO struct {
+	webhooks   []*models.Webhook
+	deliveries []*models.WebhookDelivery
+}
+
+func (m *mockWebhookDAO) GetByWebhookUuid(webhookUuid models.WebhookUUID) (*models.Webhook, error) {
+	for _, webhook := range m.webhooks {
+		if webhook.Uuid == webhookUuid {
+			return webhook, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (m *mockWebhookDAO) GetWebhooksByTargetUuid(targetUuid models.TargetUUID) ([]*models.Webhook, error) {
+	var res []*models.Webhook
+	for _, webhook := range m.webhooks {
+		if webhook.TargetUuid == targetUuid {
+			res = append(res, webhook)
+		}
+	}
+	return res, nil
+}
+
+func (m *mockWebhookDAO) SaveWebhook(webhook *models.Webhook) error {
+	if _, err := m.GetByWebhookUuid(webhook.Uuid); err != nil {
+		m.webhooks = append(m.webhooks, webhook)
+	}
+	return nil
+}
+
+func (m *mockWebhookDAO) DeleteWebhook(webhook *models.Webhook) error {
+	return nil
+}
+
+func (m *mockWebhookDAO) GetDelivery(deliveryUuid models.WebhookDeliveryUUID) (*models.WebhookDelivery, error) {
+	for _, delivery := range m.deliveries {
+		if delivery.Uuid == deliveryUuid {
+			return delivery, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (m *mockWebhookDAO) GetDeliveries(webhookUuid models.WebhookUUID, limit int) ([]*models.WebhookDelivery, error) {
+	return m.deliveries, nil
+}
+
+func (m *mockWebhookDAO) CreateDelivery(delivery *models.WebhookDelivery) error {
+	for _, d := range m.deliveries {
+		if d.WebhookUuid == delivery.WebhookUuid && d.EventUuid == delivery.EventUuid {
+			return nil
+		}
+	}
+	delivery.ID = uint(len(m.deliveries) + 1)
+	m.deliveries = append(m.deliveries, delivery)
+	return nil
+}
+
+func (m *mockWebhookDAO) SaveDelivery(delivery *models.WebhookDelivery) error {
+	return nil
+}
+
+func (m *mockWebhookDAO) ClaimDueDeliveries(limit int, now time.Time) ([]*models.WebhookDelivery, error) {
+	var res []*models.WebhookDelivery
+	for _, delivery := range m.deliveries {
+		if delivery.Status == models.WebhookDeliveryPending && !delivery.NextAttemptAt.After(now) {
+			res = append(res, delivery)
+		}
+	}
+	return res, nil
+}
+
+type webhookRequest struct {
+	url     string
+	headers http.Header
+	body    string
+}
+
+type mockWebhookPoster struct {
+	status   int
+	err      error
+	requests []webhookRequest
+}
+
+func (p *mockWebhookPoster) PostWebhook(url string, headers http.Header, body []byte) (int, error) {
+	p.requests = append(p.requests, webhookRequest{url, headers, string(body)})
+	return p.status, p.err
+}
+
+func TestWebhookService_Create(t *testing.T) {
+	authorizer := new(test_data.MockAuthorizer)
+	authorizer.On("GetPermissions", "parent", mock.Anything).Return(test_data.ParentPermissions, nil)
+	authorizer.On("GetPermissions", "viewer", mock.Anything).Return(models.Permissions{models.PermissionRead}, nil)
+	dao := &mockWebhookDAO{}
+	s := NewWebhookService(dao, &common.ServiceLocator{Authorizer: authorizer})
+	target := "7c0d4a5e-1b2c-4d3e-8f90-a1b2c3d4e5f6"
+
+	webhook, err := s.Create(dto.WebhookRequest{TargetUuid: target, Url: "https://example.com/hook", EventTypes: []string{"MeasurementCreated"}}, "parent")
+	if assert.Nil(t, err) {
+		assert.True(t, webhook.Enabled)
+		assert.Len(t, webhook.Secret, 64)
+		assert.Equal(t, []models.OutboxEventType{models.OutboxEventMeasurementCreated}, webhook.GetEventTypes())
+		assert.Len(t, dao.webhooks, 1)
+	}
+
+	_, err = s.Create(dto.WebhookRequest{TargetUuid: target, Url: "https://example.com/hook"}, "viewer")
+	assert.IsType(t, &errors.ForbiddenError{}, err)
+
+	invalid := []dto.WebhookRequest{
+		{TargetUuid: target, Url: "ftp://example.com/hook"},
+		{TargetUuid: target, Url: "/hook"},
+		{TargetUuid: target, Url: "https://example.com/hook", EventTypes: []string{"AlertRaised"}},
+		{TargetUuid: "target", Url: "https://example.com/hook"},
+	}
+	for _, request := range invalid {
+		_, err = s.Create(request, "parent")
+		assert.IsType(t, &errors.ValidationError{}, err, request)
+	}
+}
+
+func TestWebhookService_Update(t *testing.T) {
+	authorizer := new(test_data.MockAuthorizer)
+	authorizer.On("GetPermissions", "parent", mock.Anything).Return(test_data.ParentPermissions, nil)
+	disabledAt := time.Now()
+	dao := &mockWebhookDAO{webhooks: []*models.Webhook{{
+		Uuid:                "w1",
+		TargetUuid:          "7c0d4a5e-1b2c-4d3e-8f90-a1b2c3d4e5f6",
+		Url:                 "https://example.com/hook",
+		ConsecutiveFailures: webhookDisableAfter,
+		DisabledAt:          &disabledAt,
+	}}}
+	s := NewWebhookService(dao, &common.ServiceLocator{Authorizer: authorizer})
+
+	enabled := true
+	webhook, err := s.Update("w1", dto.WebhookRequest{TargetUuid: "ignored", Url: "https://example.com/other", Enabled: &enabled}, "parent")
+	if assert.Nil(t, err) {
+		assert.Equal(t, models.TargetUUID("7c0d4a5e-1b2c-4d3e-8f90-a1b2c3d4e5f6"), webhook.TargetUuid)
+		assert.Equal(t, "https://example.com/other", webhook.Url)
+		assert.True(t, webhook.Enabled)
+		assert.Equal(t, 0, webhook.ConsecutiveFailures)
+		assert.Nil(t, webhook.DisabledAt)
+	}
+}
+
+func TestWebhookPublisher_Publish(t *testing.T) {
+	authorizer := new(test_data.MockAuthorizer)
+	authorizer.On("GetPermissions", "parent", "t1").Return(test_data.ParentPermissions, nil)
+	authorizer.On("GetPermissions", "revoked", "t1").Return(models.Permissions{}, nil)
+	dao := &mockWebhookDAO{webhooks: []*models.Webhook{
+		{Uuid: "all", TargetUuid: "t1", CreatedBy: "parent", Enabled: true},
+		{Uuid: "deleted-only", TargetUuid: "t1", CreatedBy: "parent", Enabled: true, EventTypes: "MeasurementDeleted"},
+		{Uuid: "disabled", TargetUuid: "t1", CreatedBy: "parent"},
+		{Uuid: "revoked", TargetUuid: "t1", CreatedBy: "revoked", Enabled: true},
+		{Uuid: "other", TargetUuid: "t2", CreatedBy: "parent", Enabled: true},
+	}}
+	publisher := NewWebhookPublisher(dao, &common.ServiceLocator{Authorizer: authorizer})
+	message := events.Message{ID: "e1", Key: "t1", Data: []byte(`{"event_uuid":"e1","type":"MeasurementCreated"}`)}
+
+	assert.Nil(t, publisher.Publish(message))
+	assert.Nil(t, publisher.Publish(message), "publishing again queues nothing new")
+	if assert.Len(t, dao.deliveries, 1) {
+		delivery := dao.deliveries[0]
+		assert.Equal(t, models.WebhookUUID("all"), delivery.WebhookUuid)
+		assert.Equal(t, "e1", delivery.EventUuid)
+		assert.Equal(t, models.OutboxEventMeasurementCreated, delivery.EventType)
+		assert.Equal(t, models.WebhookDeliveryPending, delivery.Status)
+		assert.Equal(t, string(message.Data), delivery.Payload)
+	}
+}
+
+func TestWebhookDispatcher_DeliverDue(t *testing.T) {
+	webhook := &models.Webhook{Uuid: "w1", Url: "https://example.com/hook", Secret: "secret", Enabled: true}
+	past := time.Now().Add(-time.Minute)
+	delivery := &models.WebhookDelivery{
+		Uuid:          "d1",
+		WebhookUuid:   "w1",
+		EventType:     models.OutboxEventMeasurementCreated,
+		Payload:       `{"event_uuid":"e1"}`,
+		Status:        models.WebhookDeliveryPending,
+		NextAttemptAt: &past,
+	}
+	dao := &mockWebhookDAO{webhooks: []*models.Webhook{webhook}, deliveries: []*models.WebhookDelivery{delivery}}
+	poster := &mockWebhookPoster{status: http.StatusServiceUnavailable}
+	dispatcher := NewWebhookDispatcher(dao, poster)
+
+	succeeded, err := dispatcher.DeliverDue(10)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, succeeded)
+	assert.Equal(t, 1, delivery.Attempts)
+	assert.Equal(t, http.StatusServiceUnavailable, delivery.LastStatusCode)
+	assert.Equal(t, models.WebhookDeliveryPending, delivery.Status)
+	assert.WithinDuration(t, time.Now().Add(webhookRetryBaseDelay), *delivery.NextAttemptAt, time.Second)
+
+	succeeded, err = dispatcher.DeliverDue(10)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, succeeded, "the retry is not due yet")
+	assert.Len(t, poster.requests, 1)
+
+	delivery.NextAttemptAt = &past
+	poster.status = http.StatusNoContent
+	succeeded, err = dispatcher.DeliverDue(10)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, succeeded)
+	assert.Equal(t, models.WebhookDeliverySucceeded, delivery.Status)
+	assert.Equal(t, 2, delivery.Attempts)
+	assert.Empty(t, delivery.LastError)
+
+	request := poster.requests[1]
+	assert.Equal(t, "https://example.com/hook", request.url)
+	assert.Equal(t, `{"event_uuid":"e1"}`, request.body)
+	assert.Equal(t, "d1", request.headers.Get("X-Webhook-Delivery"))
+	assert.Equal(t, "MeasurementCreated", request.headers.Get("X-Webhook-Event"))
+	timestamp := request.headers.Get("X-Webhook-Timestamp")
+	_, err = strconv.ParseInt(timestamp, 10, 64)
+	assert.Nil(t, err)
+	assert.Equal(t, "sha256="+webhookSignature("secret", timestamp, []byte(request.body)), request.headers.Get("X-Webhook-Signature"))
+}
+
+func TestWebhookDispatcher_DisablesFailingWebhook(t *testing.T) {
+	webhook := &models.Webhook{Uuid: "w1", Url: "https://example.com/hook", Enabled: true}
+	dao := &mockWebhookDAO{webhooks: []*models.Webhook{webhook}}
+	poster := &mockWebhookPoster{err: fmt.Errorf("connection refused")}
+	dispatcher := NewWebhookDispatcher(dao, poster)
+
+	for i := 0; i < webhookDisableAfter; i++ {
+		delivery := &models.WebhookDelivery{Uuid: models.WebhookDeliveryUUID(strconv.Itoa(i)), WebhookUuid: "w1", Status: models.WebhookDeliveryPending}
+		dao.deliveries = append(dao.deliveries, delivery)
+		for attempt := 0; attempt < webhookMaxAttempts; attempt++ {
+			due := time.Now().Add(-time.Minute)
+			delivery.NextAttemptAt = &due
+			_, err := dispatcher.DeliverDue(10)
+			assert.Nil(t, err)
+		}
+		assert.Equal(t, models.WebhookDeliveryFailed, delivery.Status)
+		assert.Equal(t, "connection refused", delivery.LastError)
+		assert.Equal(t, i+1, webhook.ConsecutiveFailures)
+	}
+	assert.False(t, webhook.Enabled)
+	assert.NotNil(t, webhook.DisabledAt)
+	assert.Len(t, poster.requests, webhookDisableAfter*webhookMaxAttempts)
+}
+
+func TestWebhookService_Redeliver(t *testing.T) {
+	authorizer := new(test_data.MockAuthorizer)
+	authorizer.On("GetPermissions", "parent", mock.Anything).Return(test_data.ParentPermissions, nil)
+	dao := &mockWebhookDAO{
+		webhooks: []*models.Webhook{{Uuid: "w1", TargetUuid: "t1"}, {Uuid: "w2", TargetUuid: "t1"}},
+		deliveries: []*models.WebhookDelivery{
+			{Uuid: "d1", WebhookUuid: "w1", Status: models.WebhookDeliveryFailed, Attempts: webhookMaxAttempts},
+		},
+	}
+	s := NewWebhookService(dao, &common.ServiceLocator{Authorizer: authorizer})
+
+	delivery, err := s.Redeliver("w1", "d1", "parent")
+	if assert.Nil(t, err) {
+		assert.Equal(t, models.WebhookDeliveryPending, delivery.Status)
+		assert.Equal(t, 0, delivery.Attempts)
+		assert.WithinDuration(t, time.Now(), *delivery.NextAttemptAt, time.Second)
+	}
+
+	_, err = s.Redeliver("w2", "d1", "parent")
+	assert.IsType(t, &errors.ValidationError{}, err)
+}
+
+func TestWebhookRetryDelay(t *testing.T) {
+	assert.Equal(t, 30*time.Second, webhookRetryDelay(1))
+	assert.Equal(t, time.Minute, webhookRetryDelay(2))
+	assert.Equal(t, 16*time.Minute, webhookRetryDelay(6))
+	assert.Equal(t, time.Hour, webhookRetryDelay(20))
+}
@@ -46,3 +46,26 @@ var MeasurementStoredFactory = factory.NewFactory(&models.Measurement{}).
 	OnCreate(func(args factory.Args) error {
 		return config.Config.DB.Create(args.Instance()).Error
 	})
+
+var AlertStoredFactory = factory.NewFactory(&models.Alert{}).
+	Attr("Uuid", func(args factory.Args) (interface{}, error) {
+		return models.AlertUUID(fmt.Sprintf("%s", uuid.New())), nil
+	}).
+	Attr("TargetUuid", func(args factory.Args) (interface{}, error) {
+		return models.TargetUUID(fmt.Sprintf("%s", uuid.New())), nil
+	}).
+	Attr("MeasurementUuid", func(args factory.Args) (interface{}, error) {
+		return models.MeasurementUUID(fmt.Sprintf("%s", uuid.New())), nil
+	}).
+	Attr("Kind", func(args factory.Args) (interface{}, error) {
+		return models.AlertKindBirthWeightLoss, nil
+	}).
+	Attr("Severity", func(args factory.Args) (interface{}, error) {
+		return models.AlertSeverityWarning, nil
+	}).
+	Attr("Reason", func(args factory.Args) (interface{}, error) {
+		return "weight is below birth weight", nil
+	}).
+	OnCreate(func(args factory.Args) error {
+		return config.Config.DB.Create(args.Instance()).Error
+	})
@@ -5,8 +5,11 @@ import (
 	"github.com/jinzhu/gorm"
 	_ "github.com/jinzhu/gorm/dialects/postgres"
 	"gopkg.in/gormigrate.v1"
+	"io"
 	"little-diary-measurement-service/src/config"
+	"little-diary-measurement-service/src/growth"
 	"little-diary-measurement-service/src/migrations"
+	"os"
 )
 
 func init() {
@@ -22,6 +25,28 @@ func init() {
 	config.Config.DB.LogMode(true)
 
 	migrate()
+	loadReferences()
+}
+
+// loadReferences reads synthetic growth reference tables, they are shaped like the published tables but are not them
+func loadReferences() {
+	references := map[string]func(io.Reader) error{
+		"fenton_lms.csv":            growth.LoadFenton,
+		"who_weight_for_length.csv": growth.LoadWeightForLength,
+		"who_bmi_for_age.csv":       growth.LoadBMIForAge,
+		"who_velocity.csv":          growth.LoadVelocity,
+	}
+	for name, load := range references {
+		file, err := os.Open("../test_data/" + name)
+		if err != nil {
+			panic(err)
+		}
+		err = load(file)
+		file.Close()
+		if err != nil {
+			panic(fmt.Errorf("invalid test growth reference %s: %s", name, err))
+		}
+	}
 }
 
 func migrate() {
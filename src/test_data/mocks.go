@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"github.com/stretchr/testify/mock"
 	"io/ioutil"
+	"little-diary-measurement-service/src/models"
 	"net/http"
 )
 
@@ -67,11 +68,45 @@ func (m *MockFamilyServerConfig) GetFamilyServerUrl() string {
 	return m.Called().String(0)
 }
 
-type MockUserHasAccessToBabyChecker struct {
+// ParentPermissions are granted to parents with full access to the target
+var ParentPermissions = models.Permissions{models.PermissionRead, models.PermissionWrite, models.PermissionDelete}
+
+type MockAuthorizer struct {
 	mock.Mock
 }
 
-func (m MockUserHasAccessToBabyChecker) CheckUserHasAccessToBaby(userUuid string, targetUuid string) (bool, error) {
+func (m *MockAuthorizer) GetPermissions(userUuid string, targetUuid string) (models.Permissions, error) {
 	args := m.Called(userUuid, targetUuid)
-	return args.Bool(0), args.Error(1)
+	return args.Get(0).(models.Permissions), args.Error(1)
+}
+
+// GetPermissionsForBabies answers by GetPermissions expectations of every target
+func (m *MockAuthorizer) GetPermissionsForBabies(userUuid string, targetUuids []string) (map[string]models.Permissions, error) {
+	permissions := map[string]models.Permissions{}
+	for _, targetUuid := range targetUuids {
+		granted, err := m.GetPermissions(userUuid, targetUuid)
+		if err != nil {
+			return nil, err
+		}
+		permissions[targetUuid] = granted
+	}
+	return permissions, nil
+}
+
+type MockBabyProfileGetter struct {
+	mock.Mock
+}
+
+func (m *MockBabyProfileGetter) GetBabyProfile(targetUuid string) (*models.BabyProfile, error) {
+	args := m.Called(targetUuid)
+	return args.Get(0).(*models.BabyProfile), args.Error(1)
+}
+
+type MockTargetLister struct {
+	mock.Mock
+}
+
+func (m *MockTargetLister) ListTargets(userUuid string) ([]*models.AccessibleTarget, error) {
+	args := m.Called(userUuid)
+	return args.Get(0).([]*models.AccessibleTarget), args.Error(1)
 }